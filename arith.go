@@ -0,0 +1,544 @@
+package r
+
+import "errors"
+import "fmt"
+import "math"
+import "math/cmplx"
+
+// The vectorised arithmetic, comparison and logic operators of R and the coercions of the atomic vectors, with the
+// semantics of R 4.3: recycling of the shorter operand, NA propagation, integer overflow to NA, and the warnings
+// and errors of R. The warnings are returned once each, in the order R signals them; an error discards the result
+// and the warnings. The package github.com/romain-jacotin/r/arith exposes them.
+
+// The warnings of the operators and of the coercions.
+const (
+	WarnRecycle      = "longer object length is not a multiple of shorter object length"
+	WarnOverflow     = "NAs produced by integer overflow"
+	WarnModulus      = "probable complete loss of accuracy in modulus"
+	WarnCoercion     = "NAs introduced by coercion"
+	WarnIntegerRange = "NAs introduced by coercion to integer range"
+	WarnImaginary    = "imaginary parts discarded in coercion"
+	WarnRaw          = "out-of-range values treated as 0 in coercion to raw"
+)
+
+// warnings is the list of the warnings of an operation, each warning appears once.
+type warnings []string
+
+func (this *warnings) add(w string) {
+	for _, s := range *this {
+		if s == w {
+			return
+		}
+	}
+	*this = append(*this, w)
+}
+
+// Binary applies the binary operator op to x and y: op is the text of the operator token, ** included, or the name
+// of the function (%% and %/% are the only arithmetic INFIX operators).
+func Binary(op string, x, y Value) (Value, []string, error) {
+	switch op {
+	case "+", "-", "*", "/", "^", "**", "%%", "%/%" :
+		return Arith(op, x, y)
+	case "==", "!=", "<", ">", "<=", ">=" :
+		return Compare(op, x, y)
+	case "&", "|" :
+		return Logic(op, x, y)
+	}
+	return nil, nil, fmt.Errorf("%s is not a vectorised operator", op)
+}
+
+// Arith applies the arithmetic operator op (+, -, *, /, ^, ** , %% or %/%) to x and y.
+//
+// The logical operands are integers, / and ^ return doubles, the integer overflows are NA with a warning, and
+// the result has the attributes of the operands of its length, x first.
+func Arith(op string, x, y Value) (Value, []string, error) {
+	var w warnings
+	if op == "**" {
+		op = "^"
+	}
+	switch op {
+	case "+", "-", "*", "/", "^", "%%", "%/%" :
+	default:
+		return nil, nil, fmt.Errorf("%s is not an arithmetic operator", op)
+	}
+	if Inherits(x, "factor") || Inherits(y, "factor") {
+		return factorNA(Length(x), Length(y)), []string{ fmt.Sprintf("‘%s’ not meaningful for factors", op) }, nil
+	}
+	if !isNumeric(x) || !isNumeric(y) {
+		return nil, nil, errors.New("non-numeric argument to binary operator")
+	}
+	dim, dimnames, err := binaryDims(x, y, &w)
+	if err != nil {
+		return nil, nil, err
+	}
+	nx, ny := Length(x), Length(y)
+	n := recycle(nx, ny, &w)
+
+	var ans Value
+	switch typ := arithType(x, y); {
+	case typ == CPLXSXP :
+		if op == "%%" || op == "%/%" {
+			return nil, nil, errors.New("invalid operation on complex numbers")
+		}
+		a, b := asComplexes(x), asComplexes(y)
+		v := &Complex{ Data: make([]complex128, n) }
+		for i := range v.Data {
+			v.Data[i] = complexArith(op, a[i % nx], b[i % ny])
+		}
+		ans = v
+	case typ == INTSXP && op != "/" && op != "^" :
+		a, b := asInts(x), asInts(y)
+		v := &Integer{ Data: make([]int32, n) }
+		for i := range v.Data {
+			v.Data[i] = integerArith(op, a[i % nx], b[i % ny], &w)
+		}
+		ans = v
+	default:
+		a, b := asReals(x), asReals(y)
+		v := &Real{ Data: make([]float64, n) }
+		for i := range v.Data {
+			v.Data[i] = realArith(op, a[i % nx], b[i % ny], &w)
+		}
+		ans = v
+	}
+	setAttributes(ans, x, y, dim, dimnames, true)
+	return ans, w, nil
+}
+
+// Unary applies the unary operator op (+, - or !) to x: + and - keep the attributes and return an integer vector
+// for a logical one.
+func Unary(op string, x Value) (Value, []string, error) {
+	switch op {
+	case "!" :
+		v, err := Not(x)
+		return v, nil, err
+	case "+", "-" :
+	default:
+		return nil, nil, fmt.Errorf("%s is not an unary operator", op)
+	}
+	if Inherits(x, "factor") {
+		return factorNA(Length(x), 0), []string{ fmt.Sprintf("‘%s’ not meaningful for factors", op) }, nil
+	}
+	var ans Value
+	switch v := x.(type) {
+	case *Logical, *Integer :
+		a := asInts(v)
+		n := &Integer{ Data: make([]int32, len(a)) }
+		for i, k := range a {
+			if op == "-" && k != NaInteger {
+				k = -k
+			}
+			n.Data[i] = k
+		}
+		ans = n
+	case *Real :
+		f := &Real{ Data: make([]float64, len(v.Data)) }
+		for i, k := range v.Data {
+			if op == "-" {
+				k = -k
+			}
+			f.Data[i] = k
+		}
+		ans = f
+	case *Complex :
+		c := &Complex{ Data: make([]complex128, len(v.Data)) }
+		for i, k := range v.Data {
+			if op == "-" {
+				k = -k
+			}
+			c.Data[i] = k
+		}
+		ans = c
+	default:
+		return nil, nil, errors.New("invalid argument to unary operator")
+	}
+	copyAttributes(ans, x)
+	return ans, nil, nil
+}
+
+// factorNA returns the NA result of an arithmetic operator on factors.
+func factorNA(nx, ny int) Value {
+	n := nx
+	if ny > n {
+		n = ny
+	}
+	v := &Logical{ Data: make([]int32, n) }
+	for i := range v.Data {
+		v.Data[i] = NaLogical
+	}
+	return v
+}
+
+// isNumeric reports whether v is an operand of the arithmetic operators: NULL, a logical, integer, double or
+// complex vector.
+func isNumeric(v Value) bool {
+	switch v.Type() {
+	case NILSXP, LGLSXP, INTSXP, REALSXP, CPLXSXP :
+		return true
+	}
+	return false
+}
+
+// arithType returns the type of the arithmetic on x and y: INTSXP, REALSXP or CPLXSXP.
+func arithType(x, y Value) SexpType {
+	switch {
+	case x.Type() == CPLXSXP || y.Type() == CPLXSXP :
+		return CPLXSXP
+	case x.Type() == REALSXP || y.Type() == REALSXP :
+		return REALSXP
+	}
+	return INTSXP
+}
+
+// recycle returns the length of the result of a binary operator on vectors of lengths nx and ny: 0 if one of them
+// is empty, else the longer one which should be a multiple of the shorter.
+func recycle(nx, ny int, w *warnings) int {
+	if nx == 0 || ny == 0 {
+		return 0
+	}
+	n := nx
+	if ny > n {
+		n = ny
+	}
+	if n % nx != 0 || n % ny != 0 {
+		w.add(WarnRecycle)
+	}
+	return n
+}
+
+// integerArith applies op to integers, the overflows and the divisions by zero are NA.
+func integerArith(op string, p, q int32, w *warnings) int32 {
+	if p == NaInteger || q == NaInteger {
+		return NaInteger
+	}
+	var k int64
+	switch op {
+	case "+" :
+		k = int64(p) + int64(q)
+	case "-" :
+		k = int64(p) - int64(q)
+	case "*" :
+		k = int64(p) * int64(q)
+	case "%%" :
+		switch {
+		case q == 0 :
+			return NaInteger
+		case p >= 0 && q > 0 :
+			return p % q
+		}
+		return int32(fmod(float64(p), float64(q), w))
+	case "%/%" :
+		if q == 0 {
+			return NaInteger
+		}
+		return int32(math.Floor(float64(p) / float64(q)))
+	}
+	// R's integers are symmetric: the most negative int32 is NA
+	if k > math.MaxInt32 || k <= math.MinInt32 {
+		w.add(WarnOverflow)
+		return NaInteger
+	}
+	return int32(k)
+}
+
+// realArith applies op to doubles.
+func realArith(op string, p, q float64, w *warnings) float64 {
+	var f float64
+	switch op {
+	case "+" :
+		f = p + q
+	case "-" :
+		f = p - q
+	case "*" :
+		f = p * q
+	case "/" :
+		f = p / q
+	case "^" :
+		return pow(p, q)
+	case "%%" :
+		return fmod(p, q, w)
+	case "%/%" :
+		return fdiv(p, q)
+	}
+	if math.IsNaN(f) {
+		return nan(p, q)
+	}
+	return f
+}
+
+// nan returns the NaN result of an operation on p and q: the first operand that is NaN, so that NA + NaN is NA
+// and NaN + NA is NaN as on the x86 and ARM processors, or the default NaN if none is (Inf - Inf).
+func nan(p, q float64) float64 {
+	switch {
+	case math.IsNaN(p) :
+		return p
+	case math.IsNaN(q) :
+		return q
+	}
+	return math.NaN()
+}
+
+// fmod is the %% of R: the remainder of the floored division, with the sign of q.
+func fmod(p, q float64, w *warnings) float64 {
+	if q == 0 {
+		return math.NaN()
+	}
+	if math.IsNaN(p) || math.IsNaN(q) {
+		return nan(p, q)
+	}
+	if math.Abs(q) * epsilon > 1 && !math.IsInf(p, 0) && math.Abs(p) <= math.Abs(q) {
+		switch {
+		case math.Abs(p) == math.Abs(q) :
+			return 0
+		case p < 0 && q > 0 || q < 0 && p > 0 :
+			return p + q
+		}
+		return p
+	}
+	d := p / q
+	if !math.IsInf(d, 0) && math.Abs(d) * epsilon > 1 {
+		w.add(WarnModulus)
+	}
+	t := p - math.Floor(d) * q
+	return t - math.Floor(t / q) * q
+}
+
+// fdiv is the %/% of R: the floored division.
+func fdiv(p, q float64) float64 {
+	d := p / q
+	if math.IsNaN(d) {
+		return nan(p, q)
+	}
+	if q == 0 || math.Abs(d) * epsilon > 1 || math.IsInf(d, 0) {
+		return d
+	}
+	if math.Abs(d) < 1 {
+		if d < 0 || p < 0 && q > 0 || p > 0 && q < 0 {
+			return -1
+		}
+		return 0
+	}
+	t := p - math.Floor(d) * q
+	return math.Floor(d) + math.Floor(t / q)
+}
+
+// epsilon is DBL_EPSILON.
+const epsilon = 2.220446049250313e-16
+
+// pow is the R_pow of R: 1 ^ y and x ^ 0 are 1 even for NA and NaN.
+func pow(x, y float64) float64 {
+	switch {
+	case x == 1 || y == 0 :
+		return 1
+	case x == 0 :
+		switch {
+		case y > 0 :
+			return 0
+		case y < 0 :
+			return math.Inf(1)
+		}
+		return y
+	case !math.IsInf(x, 0) && !math.IsNaN(x) && !math.IsInf(y, 0) && !math.IsNaN(y) :
+		if y == 2 {
+			return x * x
+		}
+		return math.Pow(x, y)
+	case math.IsNaN(x) || math.IsNaN(y) :
+		return nan(x, y)
+	case math.IsInf(x, 0) :
+		if x > 0 {
+			if y < 0 {
+				return 0
+			}
+			return math.Inf(1)
+		}
+		if !math.IsInf(y, 0) && y == math.Floor(y) {
+			switch {
+			case y < 0 :
+				return 0
+			case math.Mod(y, 2) != 0 :
+				return x
+			}
+			return -x
+		}
+	}
+	if math.IsInf(y, 0) && x >= 0 {
+		if y > 0 {
+			if x >= 1 {
+				return math.Inf(1)
+			}
+			return 0
+		}
+		if x < 1 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return math.NaN()
+}
+
+// complexArith applies op to complex numbers.
+func complexArith(op string, p, q complex128) complex128 {
+	switch op {
+	case "+" :
+		return p + q
+	case "-" :
+		return p - q
+	case "*" :
+		return p * q
+	case "/" :
+		return cdiv(p, q)
+	}
+	return cpow(p, q)
+}
+
+// cdiv is the complex division of R, Smith's algorithm.
+func cdiv(a, b complex128) complex128 {
+	if math.Abs(real(b)) <= math.Abs(imag(b)) {
+		ratio := real(b) / imag(b)
+		den := imag(b) * (1 + ratio * ratio)
+		return complex((real(a) * ratio + imag(a)) / den, (imag(a) * ratio - real(a)) / den)
+	}
+	ratio := imag(b) / real(b)
+	den := real(b) * (1 + ratio * ratio)
+	return complex((real(a) + imag(a) * ratio) / den, (imag(a) - real(a) * ratio) / den)
+}
+
+// cpow is the complex power of R: the small integer powers are exact products.
+func cpow(x, y complex128) complex128 {
+	if x == 0 {
+		if imag(y) == 0 {
+			return complex(pow(0, real(y)), 0)
+		}
+		return complex(math.NaN(), math.NaN())
+	}
+	if k := real(y); imag(y) == 0 && k == math.Trunc(k) && math.Abs(k) <= 65536 {
+		return cpowInt(x, int(k))
+	}
+	return cmplx.Pow(x, y)
+}
+
+// cpowInt returns x ^ k by repeated squaring.
+func cpowInt(x complex128, k int) complex128 {
+	if k < 0 {
+		return 1 / cpowInt(x, -k)
+	}
+	z := complex128(1)
+	for ; k > 0; k >>= 1 {
+		if k & 1 != 0 {
+			z *= x
+		}
+		if k > 1 {
+			x *= x
+		}
+	}
+	return z
+}
+
+// attr returns the attribute name of v, nil for NULL.
+func attr(v Value, name string) Value {
+	if a := v.Attributes(); a != nil {
+		return a.Get(name)
+	}
+	return nil
+}
+
+// copyAttributes copies all the attributes of v to ans.
+func copyAttributes(ans, v Value) {
+	if a := v.Attributes(); a != nil {
+		ans.Attributes().List = append([]Attribute(nil), a.List...)
+	}
+}
+
+// binaryDims returns the dim and dimnames of the result of a binary operator on x and y, the error of the arrays
+// that do not conform.
+func binaryDims(x, y Value, w *warnings) (dim, dimnames Value, err error) {
+	nx, ny := Length(x), Length(y)
+	xdim, ydim := Dim(x), Dim(y)
+	// an array of length 1 recycled against a vector is a vector
+	if xdim != nil && ydim == nil && nx == 1 && ny != 1 {
+		if xdim = nil; ny != 0 {
+			w.add("Recycling array of length 1 in array-vector arithmetic is deprecated.\n  Use c() or as.vector() instead.")
+		}
+	}
+	if ydim != nil && xdim == nil && ny == 1 && nx != 1 {
+		if ydim = nil; nx != 0 {
+			w.add("Recycling array of length 1 in vector-array arithmetic is deprecated.\n  Use c() or as.vector() instead.")
+		}
+	}
+	switch {
+	case xdim != nil && ydim != nil :
+		if !sameDim(xdim, ydim) {
+			return nil, nil, errors.New("non-conformable arrays")
+		}
+		if dim, dimnames = attr(x, "dim"), attr(x, "dimnames"); dimnames == nil {
+			dimnames = attr(y, "dimnames")
+		}
+	case xdim != nil && (ny != 0 || nx == 0) :
+		if ny > nx {
+			return nil, nil, fmt.Errorf("dims [product %d] do not match the length of object [%d]", product(xdim), ny)
+		}
+		dim, dimnames = attr(x, "dim"), attr(x, "dimnames")
+	case ydim != nil && (nx != 0 || ny == 0) :
+		if nx > ny {
+			return nil, nil, fmt.Errorf("dims [product %d] do not match the length of object [%d]", product(ydim), nx)
+		}
+		dim, dimnames = attr(y, "dim"), attr(y, "dimnames")
+	}
+	return
+}
+
+// setAttributes sets the attributes of the result of a binary operator on x and y: the dim and dimnames of the
+// arrays, otherwise the names of the first operand of the length of the result, and, if most, the other
+// attributes of the operands of the length of the result, those of x last.
+func setAttributes(ans, x, y, dim, dimnames Value, most bool) {
+	n := Length(ans)
+	if most {
+		for _, v := range []Value{ y, x } {
+			if a := v.Attributes(); a != nil && Length(v) == n {
+				for _, at := range a.List {
+					switch at.Name {
+					case "names", "dim", "dimnames" :
+					default:
+						ans.Attributes().Set(at.Name, at.Value)
+					}
+				}
+			}
+		}
+	}
+	if dim != nil {
+		ans.Attributes().Set("dim", dim)
+		if dimnames != nil {
+			ans.Attributes().Set("dimnames", dimnames)
+		}
+		return
+	}
+	for _, v := range []Value{ x, y } {
+		if names := attr(v, "names"); names != nil && Length(names) == n {
+			ans.Attributes().Set("names", names)
+			return
+		}
+	}
+}
+
+// sameDim reports whether the dimensions a and b are equal.
+func sameDim(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// product returns the product of the dimensions.
+func product(dim []int) int {
+	n := 1
+	for _, d := range dim {
+		n *= d
+	}
+	return n
+}
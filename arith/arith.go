@@ -0,0 +1,41 @@
+// Package arith implements the vectorised arithmetic, comparison and logic operators of R and the coercions of the
+// atomic vectors, with the semantics of R 4.3: recycling of the shorter operand, NA propagation, integer overflow
+// to NA, and the warnings and errors of R.
+//
+// The operators take and return the values of package r, which implements them for its evaluation of the
+// constants. The warnings are returned once each, in the order R signals them; an error discards the result and
+// the warnings.
+package arith
+
+import "github.com/romain-jacotin/r"
+
+// The warnings of the operators and of the coercions.
+const (
+	WarnRecycle      = r.WarnRecycle
+	WarnOverflow     = r.WarnOverflow
+	WarnModulus      = r.WarnModulus
+	WarnCoercion     = r.WarnCoercion
+	WarnIntegerRange = r.WarnIntegerRange
+	WarnImaginary    = r.WarnImaginary
+	WarnRaw          = r.WarnRaw
+)
+
+// Binary applies the binary operator op to x and y: op is the text of the operator token, ** included, or the name
+// of the function (%% and %/% are the only arithmetic INFIX operators).
+func Binary(op string, x, y r.Value) (r.Value, []string, error) {
+	return r.Binary(op, x, y)
+}
+
+// Arith applies the arithmetic operator op (+, -, *, /, ^, ** , %% or %/%) to x and y.
+//
+// The logical operands are integers, / and ^ return doubles, the integer overflows are NA with a warning, and
+// the result has the attributes of the operands of its length, x first.
+func Arith(op string, x, y r.Value) (r.Value, []string, error) {
+	return r.Arith(op, x, y)
+}
+
+// Unary applies the unary operator op (+, - or !) to x: + and - keep the attributes and return an integer vector
+// for a logical one.
+func Unary(op string, x r.Value) (r.Value, []string, error) {
+	return r.Unary(op, x)
+}
@@ -0,0 +1,333 @@
+package arith
+
+import "math"
+import "strconv"
+import "strings"
+import "testing"
+
+import "github.com/romain-jacotin/r"
+
+// value returns the value of the constant R expression src.
+func value(e *testing.T, src string) r.Value {
+	x, err := r.ParseExpr(src)
+	if err != nil {
+		e.Fatal(err)
+	}
+	v, err := r.EvalConstant(x)
+	if err != nil {
+		e.Fatal(src, err)
+	}
+	return v
+}
+
+// show formats a vector as its type followed by its elements, the doubles with 15 significant digits.
+func show(v r.Value) string {
+	s := []string{ v.Type().String() }
+	format := func(f float64) string {
+		switch {
+		case r.IsNaReal(f) :
+			return "NA"
+		case math.IsNaN(f) :
+			return "NaN"
+		case math.IsInf(f, 1) :
+			return "Inf"
+		case math.IsInf(f, -1) :
+			return "-Inf"
+		}
+		return strconv.FormatFloat(f, 'g', 15, 64)
+	}
+	for i := 0; i < r.Length(v); i++ {
+		switch x := v.(type) {
+		case *r.Logical :
+			s = append(s, map[int32]string{ 0: "FALSE", 1: "TRUE", r.NaLogical: "NA" }[x.Data[i]])
+		case *r.Integer :
+			if x.Data[i] == r.NaInteger {
+				s = append(s, "NA")
+			} else {
+				s = append(s, strconv.Itoa(int(x.Data[i])))
+			}
+		case *r.Real :
+			s = append(s, format(x.Data[i]))
+		case *r.Complex :
+			re, im := real(x.Data[i]), imag(x.Data[i])
+			switch {
+			case r.IsNaReal(re) || r.IsNaReal(im) :
+				s = append(s, "NA")
+			case im < 0 :
+				s = append(s, format(re) + format(im) + "i")
+			default:
+				s = append(s, format(re) + "+" + format(im) + "i")
+			}
+		case *r.Character :
+			if x.IsNA(i) {
+				s = append(s, "NA")
+			} else {
+				s = append(s, strconv.Quote(x.Data[i]))
+			}
+		case *r.Raw :
+			s = append(s, strconv.FormatUint(uint64(x.Data[i]) | 0x100, 16)[1:])
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestArith(e *testing.T) {
+	tests := []struct {
+		op       string
+		x, y     string
+		result   string
+		warnings string
+	}{
+		{ "+", "1L", "2L", "integer 3", "" },
+		{ "+", "TRUE", "TRUE", "integer 2", "" },
+		{ "-", "FALSE", "1L", "integer -1", "" },
+		{ "+", "1L", "0.5", "double 1.5", "" },
+		{ "*", "2L", "1.5", "double 3", "" },
+		{ "/", "1L", "2L", "double 0.5", "" },
+		{ "/", "1L", "0L", "double Inf", "" },
+		{ "/", "0L", "0L", "double NaN", "" },
+		{ "/", "-1", "0", "double -Inf", "" },
+		{ "/", "NA_integer_", "0L", "double NA", "" },
+		{ "^", "2L", "3L", "double 8", "" },
+		{ "**", "2", "10", "double 1024", "" },
+		{ "^", "NA", "0L", "double 1", "" },
+		{ "^", "1", "NA_real_", "double 1", "" },
+		{ "^", "1L", "NaN", "double 1", "" },
+		{ "^", "NA_integer_", "2L", "double NA", "" },
+		{ "^", "NaN", "2", "double NaN", "" },
+		{ "^", "-8", "0.5", "double NaN", "" },
+		{ "^", "0", "-1", "double Inf", "" },
+		{ "^", "0", "NaN", "double NaN", "" },
+		{ "^", "-Inf", "3", "double -Inf", "" },
+		{ "^", "-Inf", "2", "double Inf", "" },
+		{ "^", "-Inf", "-1", "double 0", "" },
+		{ "^", "-Inf", "0.5", "double NaN", "" },
+		{ "^", "Inf", "-1", "double 0", "" },
+		{ "^", "2", "Inf", "double Inf", "" },
+		{ "^", "0.5", "Inf", "double 0", "" },
+		{ "^", "0.5", "-Inf", "double Inf", "" },
+		{ "^", "2", "-Inf", "double 0", "" },
+		{ "^", "-1", "Inf", "double NaN", "" },
+		{ "+", "2147483647L", "1L", "integer NA", WarnOverflow },
+		{ "-", "-2147483647L", "1L", "integer NA", WarnOverflow },
+		{ "*", "65536L", "32768L", "integer NA", WarnOverflow },
+		{ "*", "c(46341L, 2L)", "46341L", "integer NA 92682", WarnOverflow },
+		{ "+", "2147483646L", "1L", "integer 2147483647", "" },
+		{ "+", "NA_integer_", "1L", "integer NA", "" },
+		{ "+", "NA", "TRUE", "integer NA", "" },
+		{ "%%", "5L", "3L", "integer 2", "" },
+		{ "%%", "-5L", "3L", "integer 1", "" },
+		{ "%%", "5L", "-3L", "integer -1", "" },
+		{ "%%", "-5L", "-3L", "integer -2", "" },
+		{ "%%", "5L", "0L", "integer NA", "" },
+		{ "%%", "NA_integer_", "2L", "integer NA", "" },
+		{ "%/%", "5L", "2L", "integer 2", "" },
+		{ "%/%", "-5L", "2L", "integer -3", "" },
+		{ "%/%", "5L", "-2L", "integer -3", "" },
+		{ "%/%", "5L", "0L", "integer NA", "" },
+		{ "%%", "5.5", "2", "double 1.5", "" },
+		{ "%%", "-5.5", "2", "double 0.5", "" },
+		{ "%%", "5.5", "-2", "double -0.5", "" },
+		{ "%%", "5L", "2", "double 1", "" },
+		{ "%%", "5", "0", "double NaN", "" },
+		{ "%%", "NA_real_", "0", "double NaN", "" },
+		{ "%%", "NA_real_", "2", "double NA", "" },
+		{ "%%", "5", "Inf", "double 5", "" },
+		{ "%%", "-5", "Inf", "double Inf", "" },
+		{ "%%", "5", "-Inf", "double -Inf", "" },
+		{ "%%", "Inf", "2", "double NaN", "" },
+		{ "%/%", "5.5", "2", "double 2", "" },
+		{ "%/%", "-5.5", "2", "double -3", "" },
+		{ "%/%", "5", "0", "double Inf", "" },
+		{ "%/%", "-5", "0", "double -Inf", "" },
+		{ "%/%", "0", "0", "double NaN", "" },
+		{ "%/%", "5", "Inf", "double 0", "" },
+		{ "%/%", "-5", "Inf", "double -1", "" },
+		{ "%/%", "NA_real_", "0", "double NA", "" },
+		{ "+", "NA_real_", "NaN", "double NA", "" },
+		{ "+", "NaN", "NA_real_", "double NaN", "" },
+		{ "*", "NA_integer_", "NaN", "double NA", "" },
+		{ "-", "NaN", "1L", "double NaN", "" },
+		{ "+", "NA", "1", "double NA", "" },
+		{ "-", "Inf", "Inf", "double NaN", "" },
+		{ "*", "0", "-Inf", "double NaN", "" },
+		{ "+", "0.1", "0.2", "double 0.3", "" },
+		{ "+", "1:6", "1:2", "integer 2 4 4 6 6 8", "" },
+		{ "+", "1:3", "1:2", "integer 2 4 4", WarnRecycle },
+		{ "-", "1:2", "c(1, 2, 3)", "double 0 0 -2", WarnRecycle },
+		{ "*", "c(2147483647L, 1L, 2L)", "1:2", "integer 2147483647 2 2", WarnRecycle },
+		{ "+", "c(2147483647L, 1L, 2L)", "1:2", "integer NA 3 3", WarnRecycle + ";" + WarnOverflow },
+		{ "+", "1:3", "NULL", "integer", "" },
+		{ "+", "NULL", "1", "double", "" },
+		{ "+", "NULL", "NULL", "integer", "" },
+		{ "/", "NULL", "TRUE", "double", "" },
+		{ "+", "1+2i", "1", "complex 2+2i", "" },
+		{ "-", "1L", "2i", "complex 1-2i", "" },
+		{ "*", "1+2i", "3-1i", "complex 5+5i", "" },
+		{ "/", "5+5i", "3-1i", "complex 1+2i", "" },
+		{ "^", "1+2i", "2L", "complex -3+4i", "" },
+		{ "^", "1i", "2", "complex -1+0i", "" },
+		{ "^", "2+0i", "-1", "complex 0.5+0i", "" },
+		{ "^", "0+0i", "0", "complex 1+0i", "" },
+		{ "^", "0+0i", "2", "complex 0+0i", "" },
+		{ "+", "NA_complex_", "1", "complex NA", "" },
+		{ "*", "c(1i, 2i)", "c(1L, NA)", "complex 0+1i NA", "" },
+	}
+	for i, test := range tests {
+		v, w, err := Arith(test.op, value(e, test.x), value(e, test.y))
+		if err != nil {
+			e.Error("Test Arith[", i, "] Failed:", err)
+			continue
+		}
+		if s := show(v); s != test.result || strings.Join(w, ";") != test.warnings {
+			e.Error("Test Arith[", i, "] Failed:", s, w)
+		}
+	}
+
+	if _, w, _ := Arith("%%", value(e, "1e20"), value(e, "3")); strings.Join(w, ";") != WarnModulus {
+		e.Error("Test Arith Failed: modulus", w)
+	}
+	v, w, _ := Arith("+", value(e, "structure(1, dim = 1L)"), value(e, "1:3"))
+	if show(v) != "double 2 3 4" || len(w) != 1 || !strings.HasPrefix(w[0], "Recycling array of length 1 in array-vector arithmetic is deprecated.") {
+		e.Error("Test Arith Failed: array of length 1", show(v), w)
+	}
+}
+
+func TestArithErrors(e *testing.T) {
+	tests := []struct {
+		op    string
+		x, y  string
+		error string
+	}{
+		{ "+", "'a'", "1", "non-numeric argument to binary operator" },
+		{ "*", "1L", "c('a', 'b')", "non-numeric argument to binary operator" },
+		{ "+", "list(1)", "1", "non-numeric argument to binary operator" },
+		{ "%%", "1i", "2", "invalid operation on complex numbers" },
+		{ "%/%", "1", "2i", "invalid operation on complex numbers" },
+		{ "&&", "1", "2", "&& is not an arithmetic operator" },
+		{ "+", "structure(1:4, dim = c(2L, 2L))", "structure(1:4, dim = c(4L, 1L))", "non-conformable arrays" },
+		{ "+", "structure(1:4, dim = c(2L, 2L))", "1:8", "dims [product 4] do not match the length of object [8]" },
+	}
+	for i, test := range tests {
+		if _, _, err := Arith(test.op, value(e, test.x), value(e, test.y)); err == nil || err.Error() != test.error {
+			e.Error("Test ArithErrors[", i, "] Failed:", err)
+		}
+	}
+}
+
+func TestArithAttributes(e *testing.T) {
+	tests := []struct {
+		x, y  string
+		attrs string
+	}{
+		{ "c(a = 1, b = 2)", "1", "names=a,b" },
+		{ "1", "c(a = 1, b = 2)", "names=a,b" },
+		{ "c(a = 1, b = 2)", "c(x = 1, y = 2)", "names=a,b" },
+		{ "c(a = 1)", "c(x = 1, y = 2)", "names=x,y" },
+		{ "c(a = 1, b = 2)", "1:4", "" },
+		{ "structure(1:4, dim = c(2L, 2L))", "1:4", "dim=2,2" },
+		{ "1", "structure(1:4, dim = c(2L, 2L))", "dim=2,2" },
+		{ "structure(1:4, dim = c(2L, 2L))", "structure(1:4, dim = c(2L, 2L), dimnames = list(c('a', 'b'), NULL))", "dim=2,2 dimnames" },
+		{ "structure(1, dim = 1L)", "1:2", "" },
+		{ "structure(1:2, dim = 2L)", "NULL", "" },
+		{ "structure(1, class = 'x', u = 'x')", "structure(2, class = 'y', v = 'y')", "class=x u=x v=y" },
+		{ "structure(1, class = 'x')", "1:2", "" },
+	}
+	for i, test := range tests {
+		v, _, err := Arith("+", value(e, test.x), value(e, test.y))
+		if err != nil {
+			e.Fatal(err)
+		}
+		if s := attributes(v); s != test.attrs {
+			e.Error("Test ArithAttributes[", i, "] Failed:", s)
+		}
+	}
+}
+
+// attributes formats the attributes of v sorted by name, with the values of the character and integer ones.
+func attributes(v r.Value) string {
+	var s []string
+	for _, a := range v.Attributes().List {
+		switch x := a.Value.(type) {
+		case *r.Character :
+			s = append(s, a.Name + "=" + strings.Join(x.Data, ","))
+		case *r.Integer :
+			s = append(s, a.Name + "=" + strings.Join(strings.Fields(show(x))[1:], ","))
+		default:
+			s = append(s, a.Name)
+		}
+	}
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && s[j] < s[j-1]; j-- {
+			s[j], s[j-1] = s[j-1], s[j]
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func TestUnary(e *testing.T) {
+	tests := []struct {
+		op     string
+		x      string
+		result string
+	}{
+		{ "-", "TRUE", "integer -1" },
+		{ "+", "c(TRUE, NA)", "integer 1 NA" },
+		{ "-", "c(1L, NA)", "integer -1 NA" },
+		{ "-", "2147483647L", "integer -2147483647" },
+		{ "-", "c(1.5, NA, NaN, Inf)", "double -1.5 NA NaN -Inf" },
+		{ "-", "1+2i", "complex -1-2i" },
+		{ "!", "c(0, 1, NA)", "logical TRUE FALSE NA" },
+	}
+	for i, test := range tests {
+		v, _, err := Unary(test.op, value(e, test.x))
+		if err != nil {
+			e.Error("Test Unary[", i, "] Failed:", err)
+		} else if s := show(v); s != test.result {
+			e.Error("Test Unary[", i, "] Failed:", s)
+		}
+	}
+
+	if v, _, _ := Unary("-", value(e, "structure(c(a = 1L), class = 'x')")); attributes(v) != "class=x names=a" {
+		e.Error("Test Unary Failed: attributes", attributes(v))
+	}
+	if _, _, err := Unary("-", value(e, "'a'")); err == nil || err.Error() != "invalid argument to unary operator" {
+		e.Error("Test Unary Failed: character", err)
+	}
+	v, w, _ := Unary("-", r.NewFactor([]string{ "a", "b" }, []string{ "a", "b" }))
+	if show(v) != "logical NA NA" || len(w) != 1 || w[0] != "‘-’ not meaningful for factors" {
+		e.Error("Test Unary Failed: factor", show(v), w)
+	}
+}
+
+func TestBinary(e *testing.T) {
+	tests := []struct {
+		op     string
+		result string
+	}{
+		{ "+", "double 5" },
+		{ "**", "double 8" },
+		{ "%/%", "double 0" },
+		{ "<", "logical TRUE" },
+		{ "!=", "logical TRUE" },
+		{ "&", "logical TRUE" },
+		{ "|", "logical TRUE" },
+	}
+	for i, test := range tests {
+		v, _, err := Binary(test.op, value(e, "2"), value(e, "3"))
+		if err != nil {
+			e.Error("Test Binary[", i, "] Failed:", err)
+		} else if s := show(v); s != test.result {
+			e.Error("Test Binary[", i, "] Failed:", s)
+		}
+	}
+	if _, _, err := Binary("%in%", value(e, "2"), value(e, "3")); err == nil {
+		e.Error("Test Binary Failed: %in%")
+	}
+
+	f := r.NewFactor([]string{ "a", "b", "a" }, []string{ "a", "b" })
+	v, w, _ := Binary("+", f, value(e, "1"))
+	if show(v) != "logical NA NA NA" || len(w) != 1 || w[0] != "‘+’ not meaningful for factors" {
+		e.Error("Test Binary Failed: factor", show(v), w)
+	}
+}
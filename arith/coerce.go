@@ -0,0 +1,10 @@
+package arith
+
+import "github.com/romain-jacotin/r"
+
+// Coerce converts the atomic vector v to the type typ (LGLSXP, INTSXP, REALSXP, CPLXSXP, STRSXP or RAWSXP) as
+// as.vector() does: the result has no attributes, and the elements that can not be converted are NA with a
+// warning. NULL converts to an empty vector.
+func Coerce(v r.Value, typ r.SexpType) (r.Value, []string, error) {
+	return r.Coerce(v, typ)
+}
@@ -0,0 +1,65 @@
+package arith
+
+import "strings"
+import "testing"
+
+import "github.com/romain-jacotin/r"
+
+func TestCoerce(e *testing.T) {
+	tests := []struct {
+		x        string
+		typ      r.SexpType
+		result   string
+		warnings string
+	}{
+		{ "c(TRUE, FALSE, NA)", r.INTSXP, "integer 1 0 NA", "" },
+		{ "c(TRUE, NA)", r.REALSXP, "double 1 NA", "" },
+		{ "c(TRUE, NA)", r.STRSXP, `character "TRUE" NA`, "" },
+		{ "c(2L, 0L, NA)", r.LGLSXP, "logical TRUE FALSE NA", "" },
+		{ "c(-2L, NA)", r.STRSXP, `character "-2" NA`, "" },
+		{ "c(-2L, NA)", r.CPLXSXP, "complex -2+0i NA", "" },
+		{ "c(1.5, 0, NaN, NA)", r.LGLSXP, "logical TRUE FALSE NA NA", "" },
+		{ "c(1.9, -1.9, NaN, NA)", r.INTSXP, "integer 1 -1 NA NA", "" },
+		{ "c(2147483647, 2147483648, -2147483648)", r.INTSXP, "integer 2147483647 NA NA", WarnIntegerRange },
+		{ "c(1e5, 123456, 0.1 + 0.2, 1/3, 1e-4, 0.001, 1e15, 1e16, -2.5, 100)", r.STRSXP,
+			`character "1e+05" "123456" "0.3" "0.333333333333333" "1e-04" "0.001" "1e+15" "1e+16" "-2.5" "100"`, "" },
+		{ "c(NaN, Inf, -Inf, NA)", r.STRSXP, `character "NaN" "Inf" "-Inf" NA`, "" },
+		{ "c(1+2i, 1-2i, -1.5i, NA_complex_)", r.STRSXP, `character "1+2i" "1-2i" "0-1.5i" NA`, "" },
+		{ "c(1+2i, 3+0i, NA_complex_)", r.REALSXP, "double 1 3 NA", WarnImaginary },
+		{ "c(1+0i, 0i, NA_complex_)", r.LGLSXP, "logical TRUE FALSE NA", "" },
+		{ "c('1', ' 2 ', 'a', NA, '', 'NA')", r.INTSXP, "integer 1 2 NA NA NA NA", WarnCoercion },
+		{ "c('1.9', '-1.9', '1e10')", r.INTSXP, "integer 1 -1 NA", WarnIntegerRange },
+		{ "c('0x1A', '1e-2', '.5', 'Inf', '-inf', 'NaN', 'NA')", r.REALSXP, "double 26 0.01 0.5 Inf -Inf NaN NA", "" },
+		{ "c('1L', '1_0', '1 2', '--1', 'TRUE')", r.REALSXP, "double NA NA NA NA NA", WarnCoercion },
+		{ "c('T', 'true', 'True', 'yes', 'FALSE', 'f', NA)", r.LGLSXP, "logical TRUE TRUE TRUE NA FALSE NA NA", "" },
+		{ "c('1+2i', '3', ' -1.5-2i ', '1e-2-1i')", r.CPLXSXP, "complex 1+2i 3+0i -1.5-2i 0.01-1i", "" },
+		{ "c('2i', '1+i', 'a')", r.CPLXSXP, "complex NA NA NA", WarnCoercion },
+		{ "c(1L, 255L, 300L, -1L, NA)", r.RAWSXP, "raw 01 ff 00 00 00", WarnRaw },
+		{ "NULL", r.REALSXP, "double", "" },
+		{ "NULL", r.STRSXP, "character", "" },
+		{ "c(a = 1)", r.INTSXP, "integer 1", "" },
+	}
+	for i, test := range tests {
+		v, w, err := Coerce(value(e, test.x), test.typ)
+		if err != nil {
+			e.Error("Test Coerce[", i, "] Failed:", err)
+			continue
+		}
+		if s := show(v); s != test.result || strings.Join(w, ";") != test.warnings || len(v.Attributes().List) != 0 {
+			e.Error("Test Coerce[", i, "] Failed:", s, w)
+		}
+	}
+
+	b := &r.Raw{ Data: []byte{ 0, 0x1f } }
+	for i, result := range []string{ "logical FALSE TRUE", "integer 0 31", `character "00" "1f"` } {
+		if v, _, _ := Coerce(b, []r.SexpType{ r.LGLSXP, r.INTSXP, r.STRSXP }[i]); show(v) != result {
+			e.Error("Test Coerce Failed: raw", show(v))
+		}
+	}
+	if _, _, err := Coerce(&r.Environment{}, r.REALSXP); err == nil || err.Error() != "cannot coerce type 'environment' to vector of type 'double'" {
+		e.Error("Test Coerce Failed: environment", err)
+	}
+	if _, _, err := Coerce(value(e, "1"), r.VECSXP); err == nil || err.Error() != "cannot coerce type 'double' to vector of type 'list'" {
+		e.Error("Test Coerce Failed: list", err)
+	}
+}
@@ -0,0 +1,12 @@
+package arith
+
+import "github.com/romain-jacotin/r"
+
+// Compare applies the comparison operator op (==, !=, <, >, <= or >=) to x and y.
+//
+// The operands are compared as strings if one of them is a character vector, the numbers converted as by
+// as.character(), else as complex numbers, doubles or integers. A comparison with NA or NaN is NA. The result keeps
+// the names, dim and dimnames of the operands. The strings are ordered by r.Collate.
+func Compare(op string, x, y r.Value) (r.Value, []string, error) {
+	return r.Compare(op, x, y)
+}
@@ -0,0 +1,138 @@
+package arith
+
+import "strings"
+import "testing"
+
+import "github.com/romain-jacotin/r"
+
+func TestCompare(e *testing.T) {
+	tests := []struct {
+		op       string
+		x, y     string
+		result   string
+		warnings string
+	}{
+		{ "==", "1L", "1", "logical TRUE", "" },
+		{ "!=", "TRUE", "1L", "logical FALSE", "" },
+		{ "<", "1:3", "2L", "logical TRUE FALSE FALSE", "" },
+		{ "<=", "1:3", "2", "logical TRUE TRUE FALSE", "" },
+		{ ">", "c(1.5, -Inf, Inf)", "0L", "logical TRUE FALSE TRUE", "" },
+		{ ">=", "-Inf", "-Inf", "logical TRUE", "" },
+		{ "==", "NA", "NA", "logical NA", "" },
+		{ "==", "NaN", "NaN", "logical NA", "" },
+		{ "!=", "NA_integer_", "1L", "logical NA", "" },
+		{ "<", "c(1, NA, NaN)", "2", "logical TRUE NA NA", "" },
+		{ "==", "TRUE", "'TRUE'", "logical TRUE", "" },
+		{ "==", "1", "'1'", "logical TRUE", "" },
+		{ "==", "0.1 + 0.2", "'0.3'", "logical TRUE", "" },
+		{ "==", "0.1 + 0.2", "0.3", "logical FALSE", "" },
+		{ "==", "1e5", "'1e+05'", "logical TRUE", "" },
+		{ "==", "1L", "'1.0'", "logical FALSE", "" },
+		{ "<", "10", "'9'", "logical TRUE", "" },
+		{ "<", "'B'", "'a'", "logical TRUE", "" },
+		{ "<", "'a'", "'b'", "logical TRUE", "" },
+		{ ">=", "'abc'", "'ab'", "logical TRUE", "" },
+		{ "==", "'a'", "NA_character_", "logical NA", "" },
+		{ "==", "NA", "'NA'", "logical NA", "" },
+		{ "==", "1+2i", "1+2i", "logical TRUE", "" },
+		{ "!=", "1+2i", "1", "logical TRUE", "" },
+		{ "==", "1+0i", "1L", "logical TRUE", "" },
+		{ "==", "1i", "NA", "logical NA", "" },
+		{ "==", "1+2i", "'1+2i'", "logical TRUE", "" },
+		{ "==", "1:3", "1:2", "logical TRUE TRUE FALSE", WarnRecycle },
+		{ "==", "NULL", "1", "logical", "" },
+		{ "<", "'a'", "NULL", "logical", "" },
+	}
+	for i, test := range tests {
+		v, w, err := Compare(test.op, value(e, test.x), value(e, test.y))
+		if err != nil {
+			e.Error("Test Compare[", i, "] Failed:", err)
+			continue
+		}
+		if s := show(v); s != test.result || strings.Join(w, ";") != test.warnings {
+			e.Error("Test Compare[", i, "] Failed:", s, w)
+		}
+	}
+}
+
+func TestCompareErrors(e *testing.T) {
+	tests := []struct {
+		op    string
+		x, y  r.Value
+		error string
+	}{
+		{ "<", value(e, "1i"), value(e, "1"), "invalid comparison with complex values" },
+		{ ">=", value(e, "'a'"), value(e, "2i"), "" },
+		{ "==", &r.Environment{}, value(e, "1"), "comparison (==) is possible only for atomic and list types" },
+		{ "<", value(e, "1"), &r.Closure{}, "comparison (<) is possible only for atomic and list types" },
+		{ "==", value(e, "list(1)"), value(e, "1"), "comparison of these types is not implemented" },
+		{ "==", value(e, "structure(1:4, dim = c(2L, 2L))"), value(e, "structure(1:4, dim = c(1L, 4L))"), "non-conformable arrays" },
+		{ "=", value(e, "1"), value(e, "1"), "= is not a comparison operator" },
+	}
+	for i, test := range tests {
+		_, _, err := Compare(test.op, test.x, test.y)
+		if test.error == "" && err != nil || test.error != "" && (err == nil || err.Error() != test.error) {
+			e.Error("Test CompareErrors[", i, "] Failed:", err)
+		}
+	}
+}
+
+func TestCompareAttributes(e *testing.T) {
+	tests := []struct {
+		x, y  string
+		attrs string
+	}{
+		{ "c(a = 1, b = 2)", "1", "names=a,b" },
+		{ "1", "c(a = 1, b = 2)", "names=a,b" },
+		{ "structure(c(a = 1), class = 'x')", "1", "names=a" },
+		{ "structure(1:4, dim = c(2L, 2L), u = 'x')", "2L", "dim=2,2" },
+	}
+	for i, test := range tests {
+		v, _, err := Compare("==", value(e, test.x), value(e, test.y))
+		if err != nil {
+			e.Fatal(err)
+		}
+		if s := attributes(v); s != test.attrs {
+			e.Error("Test CompareAttributes[", i, "] Failed:", s)
+		}
+	}
+}
+
+func TestCompareFactors(e *testing.T) {
+	f := r.NewFactor([]string{ "a", "b", "a" }, []string{ "a", "b" })
+	if v, _, _ := Compare("==", f, value(e, "'a'")); show(v) != "logical TRUE FALSE TRUE" {
+		e.Error("Test CompareFactors Failed: ==", show(v))
+	}
+	if v, _, _ := Compare("!=", value(e, "'b'"), f); show(v) != "logical TRUE FALSE TRUE" {
+		e.Error("Test CompareFactors Failed: !=", show(v))
+	}
+	v, w, _ := Compare("<", f, value(e, "'b'"))
+	if show(v) != "logical NA NA NA" || len(w) != 1 || w[0] != "‘<’ not meaningful for factors" {
+		e.Error("Test CompareFactors Failed: <", show(v), w)
+	}
+}
+
+func TestCollate(e *testing.T) {
+	defer func(c func(a, b string) int) { r.Collate = c }(r.Collate)
+	r.Collate = func(a, b string) int {
+		if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+			return c
+		}
+		return -strings.Compare(a, b)
+	}
+	tests := []struct {
+		op     string
+		x, y   string
+		result string
+	}{
+		{ "<", "'B'", "'a'", "logical FALSE" },
+		{ "<", "'a'", "'A'", "logical TRUE" },
+		{ "==", "'a'", "'A'", "logical FALSE" },
+		{ "<=", "c('b', 'C')", "'B'", "logical TRUE FALSE" },
+	}
+	for i, test := range tests {
+		if v, _, _ := Compare(test.op, value(e, test.x), value(e, test.y)); show(v) != test.result {
+			e.Error("Test Collate[", i, "] Failed:", show(v))
+		}
+	}
+}
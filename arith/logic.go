@@ -0,0 +1,15 @@
+package arith
+
+import "github.com/romain-jacotin/r"
+
+// Logic applies the element-wise logical operator op (& or |) to x and y, in three-valued logic: NA & FALSE is
+// FALSE and NA | TRUE is TRUE. The numbers are TRUE if they are not zero, two raw vectors combine bitwise.
+func Logic(op string, x, y r.Value) (r.Value, []string, error) {
+	return r.Logic(op, x, y)
+}
+
+// Not applies ! to x: the logical negation of a logical or numeric vector, the bitwise negation of a raw vector.
+// A logical or raw vector keeps its attributes, a numeric one its names, dim and dimnames.
+func Not(x r.Value) (r.Value, error) {
+	return r.Not(x)
+}
@@ -0,0 +1,117 @@
+package arith
+
+import "strings"
+import "testing"
+
+import "github.com/romain-jacotin/r"
+
+func TestLogic(e *testing.T) {
+	tests := []struct {
+		op       string
+		x, y     string
+		result   string
+		warnings string
+	}{
+		{ "&", "TRUE", "TRUE", "logical TRUE", "" },
+		{ "&", "TRUE", "FALSE", "logical FALSE", "" },
+		{ "|", "FALSE", "FALSE", "logical FALSE", "" },
+		{ "&", "NA", "FALSE", "logical FALSE", "" },
+		{ "&", "FALSE", "NA", "logical FALSE", "" },
+		{ "&", "NA", "TRUE", "logical NA", "" },
+		{ "|", "NA", "TRUE", "logical TRUE", "" },
+		{ "|", "TRUE", "NA", "logical TRUE", "" },
+		{ "|", "NA", "FALSE", "logical NA", "" },
+		{ "&", "NA", "NA", "logical NA", "" },
+		{ "&", "c(TRUE, FALSE, NA)", "NA", "logical NA FALSE NA", "" },
+		{ "|", "c(TRUE, FALSE, NA)", "NA", "logical TRUE NA NA", "" },
+		{ "&", "2", "0.5", "logical TRUE", "" },
+		{ "&", "-1L", "0L", "logical FALSE", "" },
+		{ "&", "NaN", "TRUE", "logical NA", "" },
+		{ "&", "NaN", "FALSE", "logical FALSE", "" },
+		{ "|", "NA_integer_", "1L", "logical TRUE", "" },
+		{ "|", "0L", "0i", "logical FALSE", "" },
+		{ "|", "1i", "FALSE", "logical TRUE", "" },
+		{ "&", "1:3", "c(TRUE, FALSE)", "logical TRUE FALSE TRUE", WarnRecycle },
+		{ "|", "NULL", "TRUE", "logical", "" },
+	}
+	for i, test := range tests {
+		v, w, err := Logic(test.op, value(e, test.x), value(e, test.y))
+		if err != nil {
+			e.Error("Test Logic[", i, "] Failed:", err)
+			continue
+		}
+		if s := show(v); s != test.result || strings.Join(w, ";") != test.warnings {
+			e.Error("Test Logic[", i, "] Failed:", s, w)
+		}
+	}
+
+	if v, _, _ := Logic("&", value(e, "c(a = TRUE, b = NA)"), value(e, "TRUE")); attributes(v) != "names=a,b" {
+		e.Error("Test Logic Failed: names", attributes(v))
+	}
+	a, b := &r.Raw{ Data: []byte{ 0x0f, 0xf0 } }, &r.Raw{ Data: []byte{ 0x3c } }
+	if v, _, _ := Logic("&", a, b); show(v) != "raw 0c 30" {
+		e.Error("Test Logic Failed: raw &", show(v))
+	}
+	if v, _, _ := Logic("|", a, b); show(v) != "raw 3f fc" {
+		e.Error("Test Logic Failed: raw |", show(v))
+	}
+}
+
+func TestLogicErrors(e *testing.T) {
+	tests := []struct {
+		op    string
+		x, y  r.Value
+		error string
+	}{
+		{ "&", value(e, "'a'"), value(e, "TRUE"), "operations are possible only for numeric, logical or complex types" },
+		{ "|", value(e, "TRUE"), value(e, "list(TRUE)"), "operations are possible only for numeric, logical or complex types" },
+		{ "&", &r.Raw{ Data: []byte{ 1 } }, value(e, "TRUE"), "operations are possible only for numeric, logical or complex types" },
+		{ "&", r.NewFactor([]string{ "a" }, []string{ "a" }), value(e, "TRUE"), "operations are possible only for numeric, logical or complex types" },
+		{ "&&", value(e, "TRUE"), value(e, "TRUE"), "&& is not a logical operator" },
+		{ "|", value(e, "structure(TRUE, dim = c(1L, 1L))"), value(e, "structure(TRUE, dim = 1L)"), "non-conformable arrays" },
+	}
+	for i, test := range tests {
+		if _, _, err := Logic(test.op, test.x, test.y); err == nil || err.Error() != test.error {
+			e.Error("Test LogicErrors[", i, "] Failed:", err)
+		}
+	}
+}
+
+func TestNot(e *testing.T) {
+	tests := []struct {
+		x      string
+		result string
+		attrs  string
+	}{
+		{ "TRUE", "logical FALSE", "" },
+		{ "c(FALSE, NA)", "logical TRUE NA", "" },
+		{ "c(0, 1, NA, NaN, -2.5)", "logical TRUE FALSE NA NA FALSE", "" },
+		{ "c(0L, 3L, NA)", "logical TRUE FALSE NA", "" },
+		{ "c(0i, 1i)", "logical TRUE FALSE", "" },
+		{ "NULL", "logical", "" },
+		{ "c(a = 1, b = 0)", "logical FALSE TRUE", "names=a,b" },
+		{ "structure(1, class = 'x')", "logical FALSE", "" },
+		{ "structure(TRUE, class = 'x')", "logical FALSE", "class=x" },
+		{ "structure(1:4, dim = c(2L, 2L))", "logical FALSE FALSE FALSE FALSE", "dim=2,2" },
+	}
+	for i, test := range tests {
+		v, err := Not(value(e, test.x))
+		if err != nil {
+			e.Error("Test Not[", i, "] Failed:", err)
+			continue
+		}
+		if s := show(v); s != test.result || attributes(v) != test.attrs {
+			e.Error("Test Not[", i, "] Failed:", s, attributes(v))
+		}
+	}
+
+	if v, err := Not(&r.Character{}); err != nil || show(v) != "logical" {
+		e.Error("Test Not Failed: character(0)", err)
+	}
+	if _, err := Not(value(e, "'a'")); err == nil || err.Error() != "invalid argument type" {
+		e.Error("Test Not Failed: character", err)
+	}
+	if v, _ := Not(&r.Raw{ Data: []byte{ 0x0f } }); show(v) != "raw f0" {
+		e.Error("Test Not Failed: raw", show(v))
+	}
+}
@@ -0,0 +1,277 @@
+package r
+
+// Node is implemented by all the nodes of the abstract syntax tree.
+type Node interface {
+	// Position of the first character of the node
+	Pos() Position
+	// File offset of the character immediately after the node
+	End() int
+}
+
+// Expr is implemented by all the expression nodes.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// File is the result of the parsing of a whole R source.
+type File struct {
+	// Top level expressions
+	Exprs []Expr
+	// COMMENT and LINE_DIRECTIVE tokens in source order
+	Comments []*Token
+}
+
+// Constant is a literal: CONST_* and NA_* tokens.
+type Constant struct {
+	Token *Token
+}
+
+// Ident is a symbol: xxx or `xxx`.
+type Ident struct {
+	Token *Token
+}
+
+// UnaryExpr is a prefix operator applied to an expression: -x +x !x ~x ?x
+type UnaryExpr struct {
+	Op *Token
+	X  Expr
+}
+
+// BinaryExpr is an infix operator applied to two expressions,
+// this includes assignments, formulas, x$name, x@name, pkg::name and pkg:::name.
+type BinaryExpr struct {
+	Op *Token
+	X  Expr
+	Y  Expr
+}
+
+// ParenExpr is a parenthesized expression: (x)
+type ParenExpr struct {
+	Lparen *Token
+	X      Expr
+	Rparen *Token
+}
+
+// BlockExpr is a braced list of expressions: { x; y }
+type BlockExpr struct {
+	Lbrace *Token
+	List   []Expr
+	Rbrace *Token
+}
+
+// Arg is an argument of a call or of an index expression: value, name = value, name = or an empty argument.
+type Arg struct {
+	// SYMBOL, CONST_CHARACTER or CONST_NULL token of a named argument, nil otherwise
+	Name *Token
+	// Value of the argument, nil for an empty argument
+	Value Expr
+}
+
+// CallExpr is a function call: f(x, y = 2)
+type CallExpr struct {
+	Fun    Expr
+	Lparen *Token
+	Args   []*Arg
+	Rparen *Token
+}
+
+// IndexExpr is an indexing: x[i, j] or x[[i]]
+type IndexExpr struct {
+	X Expr
+	// OP_LEFT_SQUARE or OP_LEFT_SQUARE2 token
+	Lbrack *Token
+	Args   []*Arg
+	// Last OP_RIGHT_SQUARE token
+	Rbrack *Token
+}
+
+// Formal is a parameter of a function definition: name or name = default
+type Formal struct {
+	Name    *Token
+	Default Expr
+}
+
+// FunctionExpr is a function definition: function(x, y = 2) body
+type FunctionExpr struct {
+	Function *Token
+	Formals  []*Formal
+	Body     Expr
+}
+
+// IfExpr is a conditional: if (cond) then else otherwise
+type IfExpr struct {
+	If   *Token
+	Cond Expr
+	Then Expr
+	// nil if there is no else branch
+	Else Expr
+}
+
+// ForExpr is a for loop: for (var in seq) body
+type ForExpr struct {
+	For  *Token
+	Var  *Token
+	Seq  Expr
+	Body Expr
+}
+
+// WhileExpr is a while loop: while (cond) body
+type WhileExpr struct {
+	While *Token
+	Cond  Expr
+	Body  Expr
+}
+
+// RepeatExpr is an infinite loop: repeat body
+type RepeatExpr struct {
+	Repeat *Token
+	Body   Expr
+}
+
+// NextExpr is the next keyword.
+type NextExpr struct {
+	Token *Token
+}
+
+// BreakExpr is the break keyword.
+type BreakExpr struct {
+	Token *Token
+}
+
+func (this *Constant) Pos() Position     { return this.Token.Pos() }
+func (this *Ident) Pos() Position        { return this.Token.Pos() }
+func (this *UnaryExpr) Pos() Position    { return this.Op.Pos() }
+func (this *BinaryExpr) Pos() Position   { return this.X.Pos() }
+func (this *ParenExpr) Pos() Position    { return this.Lparen.Pos() }
+func (this *BlockExpr) Pos() Position    { return this.Lbrace.Pos() }
+func (this *CallExpr) Pos() Position     { return this.Fun.Pos() }
+func (this *IndexExpr) Pos() Position    { return this.X.Pos() }
+func (this *FunctionExpr) Pos() Position { return this.Function.Pos() }
+func (this *IfExpr) Pos() Position       { return this.If.Pos() }
+func (this *ForExpr) Pos() Position      { return this.For.Pos() }
+func (this *WhileExpr) Pos() Position    { return this.While.Pos() }
+func (this *RepeatExpr) Pos() Position   { return this.Repeat.Pos() }
+func (this *NextExpr) Pos() Position     { return this.Token.Pos() }
+func (this *BreakExpr) Pos() Position    { return this.Token.Pos() }
+
+func (this *Constant) End() int     { return this.Token.End() }
+func (this *Ident) End() int        { return this.Token.End() }
+func (this *UnaryExpr) End() int    { return this.X.End() }
+func (this *BinaryExpr) End() int   { return this.Y.End() }
+func (this *ParenExpr) End() int    { return this.Rparen.End() }
+func (this *BlockExpr) End() int    { return this.Rbrace.End() }
+func (this *CallExpr) End() int     { return this.Rparen.End() }
+func (this *IndexExpr) End() int    { return this.Rbrack.End() }
+func (this *FunctionExpr) End() int { return this.Body.End() }
+func (this *ForExpr) End() int      { return this.Body.End() }
+func (this *WhileExpr) End() int    { return this.Body.End() }
+func (this *RepeatExpr) End() int   { return this.Body.End() }
+func (this *NextExpr) End() int     { return this.Token.End() }
+func (this *BreakExpr) End() int    { return this.Token.End() }
+
+func (this *IfExpr) End() int {
+	if this.Else != nil {
+		return this.Else.End()
+	}
+	return this.Then.End()
+}
+
+func (this *Constant) exprNode()     {}
+func (this *Ident) exprNode()        {}
+func (this *UnaryExpr) exprNode()    {}
+func (this *BinaryExpr) exprNode()   {}
+func (this *ParenExpr) exprNode()    {}
+func (this *BlockExpr) exprNode()    {}
+func (this *CallExpr) exprNode()     {}
+func (this *IndexExpr) exprNode()    {}
+func (this *FunctionExpr) exprNode() {}
+func (this *IfExpr) exprNode()       {}
+func (this *ForExpr) exprNode()      {}
+func (this *WhileExpr) exprNode()    {}
+func (this *RepeatExpr) exprNode()   {}
+func (this *NextExpr) exprNode()     {}
+func (this *BreakExpr) exprNode()    {}
+
+// Name returns the name of the symbol.
+func (this *Ident) Name() string {
+	return this.Token.stringvalue
+}
+
+// FunctionName returns the name of the called function for f(...), pkg::f(...) and pkg:::f(...), or "" otherwise.
+func (this *CallExpr) FunctionName() string {
+	switch f := this.Fun.(type) {
+	case *Ident :
+		return f.Name()
+	case *Constant :
+		if f.Token.Type == CONST_CHARACTER {
+			return f.Token.stringvalue
+		}
+	case *BinaryExpr :
+		if f.Op.Type == OP_NAMESPACE || f.Op.Type == OP_NAMESPACE_INTERNAL {
+			if y, ok := f.Y.(*Ident); ok {
+				return y.Name()
+			}
+			if y, ok := f.Y.(*Constant); ok {
+				return y.Token.stringvalue
+			}
+		}
+	}
+	return ""
+}
+
+// Inspect traverses the tree rooted at node in depth-first order: it calls f(node) and,
+// if f returns true, inspects recursively each of the non-nil children of node.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *UnaryExpr :
+		Inspect(n.X, f)
+	case *BinaryExpr :
+		Inspect(n.X, f)
+		Inspect(n.Y, f)
+	case *ParenExpr :
+		Inspect(n.X, f)
+	case *BlockExpr :
+		for _, x := range n.List {
+			Inspect(x, f)
+		}
+	case *CallExpr :
+		Inspect(n.Fun, f)
+		for _, a := range n.Args {
+			if a.Value != nil {
+				Inspect(a.Value, f)
+			}
+		}
+	case *IndexExpr :
+		Inspect(n.X, f)
+		for _, a := range n.Args {
+			if a.Value != nil {
+				Inspect(a.Value, f)
+			}
+		}
+	case *FunctionExpr :
+		for _, p := range n.Formals {
+			if p.Default != nil {
+				Inspect(p.Default, f)
+			}
+		}
+		Inspect(n.Body, f)
+	case *IfExpr :
+		Inspect(n.Cond, f)
+		Inspect(n.Then, f)
+		if n.Else != nil {
+			Inspect(n.Else, f)
+		}
+	case *ForExpr :
+		Inspect(n.Seq, f)
+		Inspect(n.Body, f)
+	case *WhileExpr :
+		Inspect(n.Cond, f)
+		Inspect(n.Body, f)
+	case *RepeatExpr :
+		Inspect(n.Body, f)
+	}
+}
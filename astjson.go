@@ -0,0 +1,555 @@
+package r
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "io"
+import "math"
+import "strconv"
+import "strings"
+import "unicode/utf8"
+
+// ASTVersion is the version of the JSON format of the syntax tree written by WriteJSON, described by the JSON
+// schema ast.schema.json. It changes when a field is removed or changes meaning, not when a field is added.
+const ASTVersion = 1
+
+// The JSON document is { "version": 1, "exprs": [node...], "comments": [token...] }.
+//
+// A token is { "type", "text", "pos": { "offset", "line", "column" }, "bytes", "runes" }: type is the name given
+// by TokenType.String and text the value of the token (the name of a symbol, the unquoted string, the literal of
+// a number or of an operator). The numeric tokens add "int" and "real", the values computed by the scanner, and
+// the non-ASCII strings add "encoding", their mark "latin1" or "UTF-8". A text that is not valid UTF-8, as the
+// string "\xff", is written with its invalid bytes replaced by U+FFFD and its exact bytes in "raw", in base64.
+//
+// A node is { "kind", "pos", "end", ... } with the fields of the Go node of the same kind: Constant, Ident,
+// Unary, Binary, Paren, Block, Call, Index, Function, If, For, While, Repeat, Next or Break. The fields holding a
+// token (op, lparen, name...) are tokens and the fields holding an expression are nodes. A Constant has the
+// exact R value of its literal: "valueType" is the typeof() of the value, "value" is a JSON number, string,
+// boolean, null, or { "re", "im" } for a complex, and "na" is true for the NA constants. The doubles that are not
+// JSON numbers are written "Inf", "-Inf" and "NaN".
+
+type jsonPos struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// jsonReal is a double written as a JSON number or as "Inf", "-Inf" or "NaN".
+type jsonReal float64
+
+func (this jsonReal) MarshalJSON() ([]byte, error) {
+	f := float64(this)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return []byte(`"` + FormatReal(f) + `"`), nil
+	}
+	return []byte(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+func (this *jsonReal) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case `"Inf"` :
+		*this = jsonReal(math.Inf(1))
+	case `"-Inf"` :
+		*this = jsonReal(math.Inf(-1))
+	case `"NaN"` :
+		*this = jsonReal(math.NaN())
+	default:
+		f, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return fmt.Errorf("invalid double %s", b)
+		}
+		*this = jsonReal(f)
+	}
+	return nil
+}
+
+type jsonComplex struct {
+	Re jsonReal `json:"re"`
+	Im jsonReal `json:"im"`
+}
+
+type jsonToken struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text"`
+	Pos      jsonPos   `json:"pos"`
+	Bytes    int       `json:"bytes"`
+	Runes    int       `json:"runes"`
+	Int      *int64    `json:"int,omitempty"`
+	Real     *jsonReal `json:"real,omitempty"`
+	Encoding string    `json:"encoding,omitempty"`
+	Raw      []byte    `json:"raw,omitempty"`
+}
+
+type jsonArg struct {
+	Name  *jsonToken `json:"name,omitempty"`
+	Value *jsonNode  `json:"value,omitempty"`
+}
+
+type jsonFormal struct {
+	Name    *jsonToken `json:"name"`
+	Default *jsonNode  `json:"default,omitempty"`
+}
+
+type jsonNode struct {
+	Kind string  `json:"kind"`
+	Pos  jsonPos `json:"pos"`
+	End  int     `json:"end"`
+	// Constant, Ident, Next, Break
+	Token     *jsonToken      `json:"token,omitempty"`
+	ValueType string          `json:"valueType,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+	NA        bool            `json:"na,omitempty"`
+	// Unary, Binary
+	Op *jsonToken `json:"op,omitempty"`
+	X  *jsonNode  `json:"x,omitempty"`
+	Y  *jsonNode  `json:"y,omitempty"`
+	// Paren, Block, Call, Index
+	Open  *jsonToken `json:"open,omitempty"`
+	Close *jsonToken `json:"close,omitempty"`
+	List  []*jsonNode `json:"list,omitempty"`
+	Fun   *jsonNode  `json:"fun,omitempty"`
+	Args  []*jsonArg `json:"args,omitempty"`
+	// Function, If, For, While, Repeat: keyword is the function, if, for, while or repeat token
+	Keyword *jsonToken    `json:"keyword,omitempty"`
+	Formals []*jsonFormal `json:"formals,omitempty"`
+	Cond    *jsonNode     `json:"cond,omitempty"`
+	Then    *jsonNode     `json:"then,omitempty"`
+	Else    *jsonNode     `json:"else,omitempty"`
+	Var     *jsonToken    `json:"var,omitempty"`
+	Seq     *jsonNode     `json:"seq,omitempty"`
+	Body    *jsonNode     `json:"body,omitempty"`
+}
+
+type jsonFile struct {
+	Version  int          `json:"version"`
+	Exprs    []*jsonNode  `json:"exprs"`
+	Comments []*jsonToken `json:"comments"`
+}
+
+// WriteJSON writes the syntax tree of the file in the JSON format of version ASTVersion.
+func WriteJSON(w io.Writer, f *File) error {
+	j := &jsonFile{ Version: ASTVersion, Exprs: []*jsonNode{}, Comments: []*jsonToken{} }
+	for _, x := range f.Exprs {
+		j.Exprs = append(j.Exprs, exprJSON(x))
+	}
+	for _, t := range f.Comments {
+		j.Comments = append(j.Comments, tokenJSON(t))
+	}
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadJSON reads a syntax tree written by WriteJSON, the tokens are rebuilt with their positions and values.
+func ReadJSON(r io.Reader) (f *File, err error) {
+	var j jsonFile
+
+	d := json.NewDecoder(r)
+	if err = d.Decode(&j); err != nil {
+		return
+	}
+	if j.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported AST version %d", j.Version)
+	}
+	// Conversion errors are reported with a panic
+	defer func() {
+		if e := recover(); e != nil {
+			if je, ok := e.(jsonError); ok {
+				f, err = nil, je
+				return
+			}
+			panic(e)
+		}
+	}()
+	f = new(File)
+	for _, x := range j.Exprs {
+		if x == nil {
+			panic(jsonError("null expression"))
+		}
+		f.Exprs = append(f.Exprs, x.expr())
+	}
+	for _, t := range j.Comments {
+		if t == nil {
+			panic(jsonError("null comment"))
+		}
+		f.Comments = append(f.Comments, t.token())
+	}
+	return
+}
+
+type jsonError string
+
+func (this jsonError) Error() string {
+	return string(this)
+}
+
+func tokenJSON(t *Token) *jsonToken {
+	if t == nil {
+		return nil
+	}
+	p := t.Pos()
+	j := &jsonToken{ Type: t.Type.String(), Text: t.stringvalue, Pos: jsonPos{ p.Offset, p.Line, p.Column }, Bytes: t.nbyte, Runes: t.nrune }
+	switch t.Type {
+	case CONST_INTEGER, CONST_REAL, CONST_COMPLEX, LINE_DIRECTIVE :
+		n, f := t.intvalue, jsonReal(t.realvalue)
+		j.Int, j.Real = &n, &f
+	case DOTDOT_N :
+		n := t.intvalue
+		j.Int = &n
+	case CONST_CHARACTER :
+		if e := t.Encoding(); e != "unknown" {
+			j.Encoding = e
+		}
+	}
+	if !utf8.ValidString(t.stringvalue) {
+		j.Text, j.Raw = strings.ToValidUTF8(t.stringvalue, "\uFFFD"), []byte(t.stringvalue)
+	}
+	return j
+}
+
+// tokenTypes gives the TokenType of the names written in the JSON tokens.
+var tokenTypes = make(map[string]TokenType)
+
+func init() {
+	for t := ERROR; t <= OP_QUESTION; t++ {
+		if s := t.String(); s != "" {
+			tokenTypes[s] = t
+		}
+	}
+}
+
+func (this *jsonToken) token() *Token {
+	if this == nil {
+		return nil
+	}
+	tt, ok := tokenTypes[this.Type]
+	if !ok {
+		panic(jsonError("unknown token type " + this.Type))
+	}
+	t := &Token{ Type: tt, stringvalue: this.Text, offset: this.Pos.Offset, nline: this.Pos.Line, ncol: this.Pos.Column, nbyte: this.Bytes, nrune: this.Runes }
+	if this.Int != nil {
+		t.intvalue = *this.Int
+	}
+	if this.Real != nil {
+		t.realvalue = float64(*this.Real)
+	}
+	if this.Raw != nil {
+		t.stringvalue = string(this.Raw)
+	}
+	switch this.Encoding {
+	case "latin1" :
+		t.intvalue = markLatin1
+	case "UTF-8" :
+		t.intvalue = markUTF8
+	}
+	return t
+}
+
+// constantJSON sets the value of a Constant node.
+func constantJSON(j *jsonNode, t *Token) {
+	var v interface{}
+	switch t.Type {
+	case CONST_INTEGER, CONST_REAL, CONST_INF, CONST_NAN :
+		switch x, _ := constantValue(t); n := x.(type) {
+		case *Integer :
+			j.ValueType, v = "integer", n.Data[0]
+		case *Real :
+			j.ValueType, v = "double", jsonReal(n.Data[0])
+		}
+	case CONST_COMPLEX :
+		j.ValueType, v = "complex", jsonComplex{ 0, jsonReal(t.realvalue) }
+	case CONST_CHARACTER :
+		j.ValueType, v = "character", t.stringvalue
+	case CONST_TRUE, CONST_FALSE :
+		j.ValueType, v = "logical", t.Type == CONST_TRUE
+	case CONST_NULL :
+		j.ValueType = "NULL"
+	case NA_LOGICAL, NA_INTEGER, NA_REAL, NA_COMPLEX, NA_CHARACTER :
+		x, _ := constantValue(t)
+		j.ValueType, j.NA = x.Type().String(), true
+	}
+	j.Value, _ = json.Marshal(v)
+}
+
+func exprJSON(x Expr) *jsonNode {
+	if x == nil {
+		return nil
+	}
+	p := x.Pos()
+	j := &jsonNode{ Pos: jsonPos{ p.Offset, p.Line, p.Column }, End: x.End() }
+	switch n := x.(type) {
+	case *Constant :
+		j.Kind, j.Token = "Constant", tokenJSON(n.Token)
+		constantJSON(j, n.Token)
+	case *Ident :
+		j.Kind, j.Token = "Ident", tokenJSON(n.Token)
+	case *UnaryExpr :
+		j.Kind, j.Op, j.X = "Unary", tokenJSON(n.Op), exprJSON(n.X)
+	case *BinaryExpr :
+		j.Kind, j.Op, j.X, j.Y = "Binary", tokenJSON(n.Op), exprJSON(n.X), exprJSON(n.Y)
+	case *ParenExpr :
+		j.Kind, j.Open, j.X, j.Close = "Paren", tokenJSON(n.Lparen), exprJSON(n.X), tokenJSON(n.Rparen)
+	case *BlockExpr :
+		j.Kind, j.Open, j.Close = "Block", tokenJSON(n.Lbrace), tokenJSON(n.Rbrace)
+		j.List = []*jsonNode{}
+		for _, e := range n.List {
+			j.List = append(j.List, exprJSON(e))
+		}
+	case *CallExpr :
+		j.Kind, j.Fun, j.Open, j.Close = "Call", exprJSON(n.Fun), tokenJSON(n.Lparen), tokenJSON(n.Rparen)
+		j.Args = argsJSON(n.Args)
+	case *IndexExpr :
+		j.Kind, j.X, j.Open, j.Close = "Index", exprJSON(n.X), tokenJSON(n.Lbrack), tokenJSON(n.Rbrack)
+		j.Args = argsJSON(n.Args)
+	case *FunctionExpr :
+		j.Kind, j.Keyword, j.Body = "Function", tokenJSON(n.Function), exprJSON(n.Body)
+		j.Formals = []*jsonFormal{}
+		for _, f := range n.Formals {
+			j.Formals = append(j.Formals, &jsonFormal{ tokenJSON(f.Name), exprJSON(f.Default) })
+		}
+	case *IfExpr :
+		j.Kind, j.Keyword, j.Cond, j.Then, j.Else = "If", tokenJSON(n.If), exprJSON(n.Cond), exprJSON(n.Then), exprJSON(n.Else)
+	case *ForExpr :
+		j.Kind, j.Keyword, j.Var, j.Seq, j.Body = "For", tokenJSON(n.For), tokenJSON(n.Var), exprJSON(n.Seq), exprJSON(n.Body)
+	case *WhileExpr :
+		j.Kind, j.Keyword, j.Cond, j.Body = "While", tokenJSON(n.While), exprJSON(n.Cond), exprJSON(n.Body)
+	case *RepeatExpr :
+		j.Kind, j.Keyword, j.Body = "Repeat", tokenJSON(n.Repeat), exprJSON(n.Body)
+	case *NextExpr :
+		j.Kind, j.Token = "Next", tokenJSON(n.Token)
+	case *BreakExpr :
+		j.Kind, j.Token = "Break", tokenJSON(n.Token)
+	}
+	return j
+}
+
+func argsJSON(args []*Arg) []*jsonArg {
+	list := []*jsonArg{}
+	for _, a := range args {
+		list = append(list, &jsonArg{ tokenJSON(a.Name), exprJSON(a.Value) })
+	}
+	return list
+}
+
+// need returns the node or panics if a required field is missing.
+func (this *jsonNode) need(field string, x interface{}) {
+	switch v := x.(type) {
+	case *jsonNode :
+		if v != nil {
+			return
+		}
+	case *jsonToken :
+		if v != nil {
+			return
+		}
+	}
+	panic(jsonError(fmt.Sprintf("%d:%d: %s node without %s", this.Pos.Line, this.Pos.Column, this.Kind, field)))
+}
+
+func (this *jsonNode) expr() Expr {
+	if this == nil {
+		return nil
+	}
+	switch this.Kind {
+	case "Constant", "Ident", "Next", "Break" :
+		this.need("token", this.Token)
+	case "Unary" :
+		this.need("op", this.Op)
+		this.need("x", this.X)
+	case "Binary" :
+		this.need("op", this.Op)
+		this.need("x", this.X)
+		this.need("y", this.Y)
+	case "Paren", "Block", "Call", "Index" :
+		this.need("open", this.Open)
+		this.need("close", this.Close)
+	case "Function", "If", "For", "While", "Repeat" :
+		this.need("keyword", this.Keyword)
+	}
+
+	switch this.Kind {
+	case "Constant" :
+		return &Constant{ this.Token.token() }
+	case "Ident" :
+		return &Ident{ this.Token.token() }
+	case "Next" :
+		return &NextExpr{ this.Token.token() }
+	case "Break" :
+		return &BreakExpr{ this.Token.token() }
+	case "Unary" :
+		return &UnaryExpr{ this.Op.token(), this.X.expr() }
+	case "Binary" :
+		return &BinaryExpr{ this.Op.token(), this.X.expr(), this.Y.expr() }
+	case "Paren" :
+		this.need("x", this.X)
+		return &ParenExpr{ this.Open.token(), this.X.expr(), this.Close.token() }
+	case "Block" :
+		b := &BlockExpr{ Lbrace: this.Open.token(), Rbrace: this.Close.token() }
+		for _, x := range this.List {
+			if x == nil {
+				panic(jsonError(fmt.Sprintf("%d:%d: null expression in Block", this.Pos.Line, this.Pos.Column)))
+			}
+			b.List = append(b.List, x.expr())
+		}
+		return b
+	case "Call" :
+		this.need("fun", this.Fun)
+		return &CallExpr{ this.Fun.expr(), this.Open.token(), this.args(), this.Close.token() }
+	case "Index" :
+		this.need("x", this.X)
+		return &IndexExpr{ this.X.expr(), this.Open.token(), this.args(), this.Close.token() }
+	case "Function" :
+		this.need("body", this.Body)
+		f := &FunctionExpr{ Function: this.Keyword.token(), Body: this.Body.expr() }
+		for _, p := range this.Formals {
+			if p == nil || p.Name == nil {
+				panic(jsonError("formal without name"))
+			}
+			f.Formals = append(f.Formals, &Formal{ p.Name.token(), p.Default.expr() })
+		}
+		return f
+	case "If" :
+		this.need("cond", this.Cond)
+		this.need("then", this.Then)
+		return &IfExpr{ this.Keyword.token(), this.Cond.expr(), this.Then.expr(), this.Else.expr() }
+	case "For" :
+		this.need("var", this.Var)
+		this.need("seq", this.Seq)
+		this.need("body", this.Body)
+		return &ForExpr{ this.Keyword.token(), this.Var.token(), this.Seq.expr(), this.Body.expr() }
+	case "While" :
+		this.need("cond", this.Cond)
+		this.need("body", this.Body)
+		return &WhileExpr{ this.Keyword.token(), this.Cond.expr(), this.Body.expr() }
+	case "Repeat" :
+		this.need("body", this.Body)
+		return &RepeatExpr{ this.Keyword.token(), this.Body.expr() }
+	}
+	panic(jsonError(fmt.Sprintf("unknown node kind %q", this.Kind)))
+}
+
+func (this *jsonNode) args() (args []*Arg) {
+	for _, a := range this.Args {
+		if a == nil {
+			panic(jsonError(fmt.Sprintf("%d:%d: null argument of %s", this.Pos.Line, this.Pos.Column, this.Kind)))
+		}
+		args = append(args, &Arg{ a.Name.token(), a.Value.expr() })
+	}
+	return
+}
+
+// WriteYAML writes the syntax tree of the file in YAML: the same document as WriteJSON, in block style.
+func WriteYAML(w io.Writer, f *File) error {
+	var buf bytes.Buffer
+
+	if err := WriteJSON(&buf, f); err != nil {
+		return err
+	}
+	d := json.NewDecoder(&buf)
+	d.UseNumber()
+	var y strings.Builder
+	y.WriteString("---\n")
+	if err := yamlValue(&y, d, 0, ""); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, y.String())
+	return err
+}
+
+// yamlValue converts the next JSON value read by d to YAML. The value follows prefix, which is "", "key:" or "-".
+func yamlValue(y *strings.Builder, d *json.Decoder, indent int, prefix string) error {
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+	pad := strings.Repeat("  ", indent)
+	scalar := func(s string) {
+		y.WriteString(pad)
+		if prefix != "" {
+			y.WriteString(prefix + " ")
+		}
+		y.WriteString(s + "\n")
+	}
+
+	switch v := t.(type) {
+	case json.Delim :
+		if !d.More() {
+			d.Token()
+			if v == '{' {
+				scalar("{}")
+			} else {
+				scalar("[]")
+			}
+			return nil
+		}
+		// The first entry of a block in a sequence is written on the line of the -
+		inner := indent
+		if prefix != "" {
+			y.WriteString(pad + prefix)
+			if prefix == "-" {
+				y.WriteString(" ")
+			} else {
+				y.WriteString("\n")
+			}
+			inner++
+		}
+		first := prefix == "-"
+		for d.More() {
+			p := "-"
+			if v == '{' {
+				k, err := d.Token()
+				if err != nil {
+					return err
+				}
+				p = yamlString(k.(string)) + ":"
+			}
+			if first {
+				first = false
+				// Written after "- " without indentation
+				var sub strings.Builder
+				if err := yamlValue(&sub, d, inner, p); err != nil {
+					return err
+				}
+				y.WriteString(strings.TrimLeft(sub.String(), " "))
+				continue
+			}
+			if err := yamlValue(y, d, inner, p); err != nil {
+				return err
+			}
+		}
+		_, err = d.Token()
+		return err
+	case string :
+		scalar(yamlString(v))
+	case json.Number :
+		scalar(v.String())
+	case bool :
+		scalar(strconv.FormatBool(v))
+	case nil :
+		scalar("null")
+	}
+	return nil
+}
+
+// yamlString quotes a string unless it is a plain YAML scalar that can not be read as another type.
+func yamlString(s string) string {
+	plain := s != ""
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_') {
+			plain = false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n" :
+		plain = false
+	}
+	if plain {
+		return s
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
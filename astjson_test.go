@@ -0,0 +1,275 @@
+package r
+
+import "testing"
+import "bytes"
+import "encoding/json"
+import "io/ioutil"
+import "reflect"
+import "strings"
+
+var jsonSources = []string{
+	"x <- c(a = 1L, 'b', 2.5e3, 0x10L, 3i, TRUE, NULL, NA, NA_integer_, NA_real_, NA_complex_, NA_character_)",
+	"f <- function(x, y = -1, ...) { if (x > y) x[[1]] else y[-1, , drop = FALSE] }",
+	"for (i in 1:10) { while (TRUE) break; repeat next }\n# comment\n`my var` %in% (Inf + NaN)",
+	"#line 10 \"a.R\"\npkg::f(x)$y@z ~ .",
+	"x <- 0x1p99999 ; y -> z ; a <<- b ; 'é' ->> \"\\u00e9\\n\"",
+	"x <- c('\\xff', 'a\\xe9b', `\\xfe`) # \xff",
+	"",
+}
+
+func TestJSONRoundTrip(e *testing.T) {
+	for i, src := range jsonSources {
+		f, err := ParseFile(strings.NewReader(src))
+		if err != nil {
+			e.Error("Test JSONRoundTrip[", i, "] Failed with parse error", err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err = WriteJSON(&buf, f); err != nil {
+			e.Error("Test JSONRoundTrip[", i, "] Failed with error", err)
+			continue
+		}
+		g, err := ReadJSON(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			e.Error("Test JSONRoundTrip[", i, "] Failed with read error", err, buf.String())
+			continue
+		}
+		if !reflect.DeepEqual(f.Exprs, g.Exprs) || len(f.Comments) != len(g.Comments) {
+			e.Error("Test JSONRoundTrip[", i, "] Failed: the trees differ", buf.String())
+		}
+		for j := range f.Comments {
+			if !reflect.DeepEqual(f.Comments[j], g.Comments[j]) {
+				e.Error("Test JSONRoundTrip[", i, "] Failed: comment", j)
+			}
+		}
+
+		// Written again, the document is the same
+		var again bytes.Buffer
+		WriteJSON(&again, g)
+		if again.String() != buf.String() {
+			e.Error("Test JSONRoundTrip[", i, "] Failed: the documents differ")
+		}
+	}
+}
+
+func TestJSONConstants(e *testing.T) {
+	f, _ := ParseFile(strings.NewReader(jsonSources[0] + "\n0x1p99999"))
+	var buf bytes.Buffer
+	WriteJSON(&buf, f)
+
+	var doc struct {
+		Version int
+		Exprs   []struct {
+			Y struct {
+				Args []struct {
+					Value struct {
+						ValueType string
+						Value     json.RawMessage
+						NA        bool
+					}
+				}
+			}
+			Value json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		e.Fatal(err)
+	}
+	if doc.Version != ASTVersion || len(doc.Exprs) != 2 {
+		e.Fatal("Test JSONConstants Failed:", doc.Version, len(doc.Exprs))
+	}
+	var tests = []struct {
+		typ   string
+		value string
+		na    bool
+	}{
+		{ "integer", "1", false },
+		{ "character", `"b"`, false },
+		{ "double", "2500", false },
+		{ "integer", "16", false },
+		{ "complex", `{"re":0,"im":3}`, false },
+		{ "logical", "true", false },
+		{ "NULL", "null", false },
+		{ "logical", "null", true },
+		{ "integer", "null", true },
+		{ "double", "null", true },
+		{ "complex", "null", true },
+		{ "character", "null", true },
+	}
+	args := doc.Exprs[0].Y.Args
+	for i, test := range tests {
+		v := args[i].Value
+		var value bytes.Buffer
+		json.Compact(&value, v.Value)
+		if v.ValueType != test.typ || value.String() != test.value || v.NA != test.na {
+			e.Error("Test JSONConstants[", i, "] Failed:", v.ValueType, value.String(), v.NA)
+		}
+	}
+	if string(doc.Exprs[1].Value) != `"Inf"` {
+		e.Error("Test JSONConstants Failed: Inf", string(doc.Exprs[1].Value))
+	}
+}
+
+func TestJSONRaw(e *testing.T) {
+	f, _ := ParseFile(strings.NewReader("'a\\xffb'"))
+	var buf bytes.Buffer
+	WriteJSON(&buf, f)
+	if !strings.Contains(buf.String(), "\"text\": \"a\ufffdb\"") || !strings.Contains(buf.String(), `"raw": "Yf9i"`) {
+		e.Error("Test JSONRaw Failed:", buf.String())
+	}
+	g, err := ReadJSON(&buf)
+	if err != nil || g.Exprs[0].(*Constant).Token.stringvalue != "a\xffb" {
+		e.Error("Test JSONRaw Failed: read", err)
+	}
+}
+
+func TestJSONErrors(e *testing.T) {
+	var errors = []string{
+		``,
+		`{"version": 2, "exprs": [], "comments": []}`,
+		`{"version": 1, "exprs": [{"kind": "Lambda", "pos": {}, "end": 0}], "comments": []}`,
+		`{"version": 1, "exprs": [{"kind": "Binary", "pos": {}, "end": 0}], "comments": []}`,
+		`{"version": 1, "exprs": [{"kind": "Ident", "pos": {}, "end": 0, "token": {"type": "WORD"}}], "comments": []}`,
+		`{"version": 1, "exprs": [{"kind": "Constant", "pos": {}, "end": 0, "token": {"type": "CONST_REAL", "real": "Big"}}]}`,
+		`{"version": 1, "exprs": [null], "comments": []}`,
+		`{"version": 1, "exprs": [], "comments": [null]}`,
+		`{"version": 1, "exprs": [{"kind": "Call", "pos": {}, "end": 0, "open": {"type": "LEFT_ROUND"}, "close": {"type": "RIGHT_ROUND"}, "fun": {"kind": "Ident", "pos": {}, "end": 0, "token": {"type": "SYMBOL"}}, "args": [null]}]}`,
+		`{"version": 1, "exprs": [{"kind": "Block", "pos": {}, "end": 0, "open": {"type": "LEFT_CURLY"}, "close": {"type": "RIGHT_CURLY"}, "list": [null]}]}`,
+		`{"version": 1, "exprs": [{"kind": "Function", "pos": {}, "end": 0, "keyword": {"type": "FUNCTION"}, "body": {"kind": "Ident", "pos": {}, "end": 0, "token": {"type": "SYMBOL"}}, "formals": [null]}]}`,
+	}
+	for i, src := range errors {
+		if _, err := ReadJSON(strings.NewReader(src)); err == nil {
+			e.Error("Test JSONErrors[", i, "] Failed: no error")
+		}
+	}
+}
+
+func TestJSONSchema(e *testing.T) {
+	b, err := ioutil.ReadFile("ast.schema.json")
+	if err != nil {
+		e.Fatal(err)
+	}
+	var schema struct {
+		Properties struct {
+			Version struct {
+				Const int
+			}
+		}
+		Definitions struct {
+			Node struct {
+				Properties struct {
+					Kind struct {
+						Enum []string
+					}
+				}
+			}
+		}
+	}
+	if err = json.Unmarshal(b, &schema); err != nil {
+		e.Fatal(err)
+	}
+	if schema.Properties.Version.Const != ASTVersion {
+		e.Error("Test JSONSchema Failed: version", schema.Properties.Version.Const)
+	}
+
+	// All the node kinds are in the schema
+	kinds := make(map[string]bool)
+	for _, k := range schema.Definitions.Node.Properties.Kind.Enum {
+		kinds[k] = true
+	}
+	for _, src := range jsonSources {
+		f, _ := ParseFile(strings.NewReader(src))
+		for _, x := range f.Exprs {
+			Inspect(x, func(n Node) bool {
+				if k := exprJSON(n.(Expr)).Kind; !kinds[k] {
+					e.Error("Test JSONSchema Failed: kind", k)
+				}
+				return true
+			})
+		}
+	}
+}
+
+func TestYAML(e *testing.T) {
+	f, _ := ParseFile(strings.NewReader("f(x = 'on')"))
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, f); err != nil {
+		e.Fatal(err)
+	}
+	expected := `---
+version: 1
+exprs:
+  - kind: Call
+    pos:
+      offset: 0
+      line: 1
+      column: 1
+    end: 11
+    open:
+      type: LEFT_ROUND
+      text: "("
+      pos:
+        offset: 1
+        line: 1
+        column: 2
+      bytes: 1
+      runes: 1
+    close:
+      type: RIGHT_ROUND
+      text: ")"
+      pos:
+        offset: 10
+        line: 1
+        column: 11
+      bytes: 1
+      runes: 1
+    fun:
+      kind: Ident
+      pos:
+        offset: 0
+        line: 1
+        column: 1
+      end: 1
+      token:
+        type: SYMBOL
+        text: f
+        pos:
+          offset: 0
+          line: 1
+          column: 1
+        bytes: 1
+        runes: 1
+    args:
+      - name:
+          type: SYMBOL
+          text: x
+          pos:
+            offset: 2
+            line: 1
+            column: 3
+          bytes: 1
+          runes: 1
+        value:
+          kind: Constant
+          pos:
+            offset: 6
+            line: 1
+            column: 7
+          end: 10
+          token:
+            type: CONST_CHARACTER
+            text: "on"
+            pos:
+              offset: 6
+              line: 1
+              column: 7
+            bytes: 4
+            runes: 4
+          valueType: character
+          value: "on"
+comments: []
+`
+	if buf.String() != expected {
+		e.Error("Test YAML Failed:\n" + buf.String())
+	}
+}
@@ -0,0 +1,339 @@
+package r
+
+import "errors"
+import "fmt"
+import "strings"
+
+// Frame is a context of the call stack: the call of a closure.
+type Frame struct {
+	Call     *Language
+	Function *Closure
+	// Evaluation environment of the body: the arguments and the local variables
+	Env *Environment
+	// Environment the call is evaluated from, the parent.frame() of the function
+	CallEnv *Environment
+}
+
+// Context is the call stack of an evaluator. Eval evaluates the bodies of the closures and the expressions of the
+// promises: it is the evaluator of the language objects built on the parser. The special environments stand for
+// those of the Session.
+type Context struct {
+	Eval    func(x Value, env *Environment) (Value, error)
+	Frames  []*Frame
+	Session *Session
+}
+
+// ReturnSignal is the error of the evaluation of return(Value) in Env: the call of the closure evaluated in Env
+// returns Value.
+type ReturnSignal struct {
+	Env   *Environment
+	Value Value
+}
+
+func (this *ReturnSignal) Error() string {
+	return "no function to return from, jumping to top level"
+}
+
+// PromiseArgs returns the arguments of a call evaluated in env as R passes them to a closure: a promise for each
+// argument, the promises bound to ... in place of the ... argument, MissingArg for the empty arguments.
+func PromiseArgs(args []Tagged, env *Environment) ([]Tagged, error) {
+	var promises []Tagged
+	for _, a := range args {
+		switch {
+		case a.Value == MissingArg :
+			promises = append(promises, a)
+		case isSymbolNamed(a.Value, "...") :
+			dots, _ := env.Lookup("...")
+			switch d := dots.(type) {
+			case *Pairlist :
+				promises = append(promises, d.Elements...)
+			case nil :
+				return nil, errors.New("'...' used in an incorrect context")
+			}
+		default:
+			promises = append(promises, Tagged{ a.Tag, &Promise{ Expr: a.Value, Env: env } })
+		}
+	}
+	return promises, nil
+}
+
+// isSymbolNamed reports whether v is the symbol name.
+func isSymbolNamed(v Value, name string) bool {
+	s, ok := v.(*Symbol)
+	return ok && s.Name == name
+}
+
+// isForced reports whether the promise has a value.
+func (this *Promise) isForced() bool {
+	return this.Value != nil && this.Value != UnboundValue
+}
+
+// Force returns the value of the promise: its expression is evaluated at the first call only.
+func (this *Context) Force(p *Promise) (Value, error) {
+	if p.isForced() {
+		return p.Value, nil
+	}
+	if p.forcing {
+		return nil, errors.New("promise already under evaluation: recursive default argument reference or earlier problems?")
+	}
+	env, _ := p.Env.(*Environment)
+	if env == nil {
+		env = GlobalEnv
+	}
+	env = this.Session.Resolve(env)
+	p.forcing = true
+	v, err := this.Eval(p.Expr, env)
+	p.forcing = false
+	if err != nil {
+		return nil, err
+	}
+	p.Value, p.Env = v, nil
+	return v, nil
+}
+
+// Get returns the value of the variable name in env or its enclosures, the promises forced, as the evaluation of
+// the symbol does.
+func (this *Context) Get(env *Environment, name string) (Value, error) {
+	if name == "..." {
+		return nil, errors.New("'...' used in an incorrect context")
+	}
+	v := this.lookup(env, name)
+	switch x := v.(type) {
+	case nil :
+		return nil, fmt.Errorf("object '%s' not found", name)
+	case *Promise :
+		return this.Force(x)
+	}
+	if v == MissingArg {
+		return nil, fmt.Errorf("argument \"%s\" is missing, with no default", name)
+	}
+	return v, nil
+}
+
+// lookup returns the value of the variable name in env or its enclosures as Lookup does, the special environments
+// resolved to those of the session.
+func (this *Context) lookup(env *Environment, name string) Value {
+	for e := this.Session.Resolve(env); e != nil; e = this.Session.Resolve(e.Parent()) {
+		if v := frame(e).Get(name); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// FindFunction returns the function name in env or its enclosures as the evaluation of a call does: the variables
+// that are not functions are skipped.
+func (this *Context) FindFunction(env *Environment, name string) (Value, error) {
+	for e := this.Session.Resolve(env); e != nil; e = this.Session.Resolve(e.Parent()) {
+		v := frame(e).Get(name)
+		if p, ok := v.(*Promise); ok {
+			var err error
+			if v, err = this.Force(p); err != nil {
+				return nil, err
+			}
+		}
+		switch v.(type) {
+		case *Closure, *Builtin :
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find function \"%s\"", name)
+}
+
+// Apply calls the closure with the arguments of the call evaluated from callEnv, promises or values. The
+// arguments are matched to the formals as R does, the evaluation environment is enclosed by the environment of
+// the closure and binds the missing arguments to MissingArg, or to a promise of their default value evaluated in
+// it. The body is evaluated in a new frame of the call stack.
+func (this *Context) Apply(fun *Closure, call *Language, args []Tagged, callEnv *Environment) (Value, error) {
+	actuals, dots, err := matchClosureArgs(fun.Formals, args)
+	if err != nil {
+		return nil, err
+	}
+	enclos, _ := fun.Env.(*Environment)
+	if enclos == nil {
+		enclos = GlobalEnv
+	}
+	env := NewEnvironment(this.Session.Resolve(enclos))
+	for i, f := range fun.Formals {
+		v := actuals[i]
+		switch {
+		case f.Tag == "..." :
+			v = MissingArg
+			if len(dots) > 0 {
+				v = &Pairlist{ Elements: dots, Dots: true }
+			}
+		case (v == nil || v == MissingArg) && f.Value != MissingArg :
+			v = &Promise{ Expr: f.Value, Env: env, Default: true }
+		case v == nil :
+			v = MissingArg
+		}
+		env.Frame = append(env.Frame, Tagged{ f.Tag, v })
+	}
+
+	this.Frames = append(this.Frames, &Frame{ call, fun, env, callEnv })
+	defer func() { this.Frames = this.Frames[:len(this.Frames)-1] }()
+	v, err := this.Eval(fun.Body, env)
+	if r, ok := err.(*ReturnSignal); ok && r.Env == env {
+		return r.Value, nil
+	}
+	return v, err
+}
+
+// matchClosureArgs matches the arguments to the formals as MatchArgs does. It returns the argument of each formal,
+// nil if it has none, and the arguments of ....
+func matchClosureArgs(formals, args []Tagged) (actuals []Value, dots []Tagged, err error) {
+	names := make([]string, len(formals))
+	for i, f := range formals {
+		names[i] = f.Tag
+	}
+	tags := make([]string, len(args))
+	for j, a := range args {
+		tags[j] = a.Tag
+	}
+	bindings, problems, _ := matchNames(names, tags, nil)
+	for _, p := range problems {
+		if !p.warning {
+			return nil, nil, errors.New(p.msg)
+		}
+	}
+
+	actuals = make([]Value, len(formals))
+	var unused []string
+	for j, b := range bindings {
+		switch b.kind {
+		case MATCH_DOTS :
+			dots = append(dots, args[j])
+		case MATCH_UNUSED :
+			unused = append(unused, deparseArg(args[j]))
+		default:
+			actuals[b.formal] = args[j].Value
+		}
+	}
+	switch len(unused) {
+	case 0 :
+		return actuals, dots, nil
+	case 1 :
+		return nil, nil, fmt.Errorf("unused argument (%s)", unused[0])
+	}
+	return nil, nil, fmt.Errorf("unused arguments (%s)", strings.Join(unused, ", "))
+}
+
+// deparseArg returns the text of an argument in the messages: its name and its expression.
+func deparseArg(a Tagged) string {
+	v := a.Value
+	if p, ok := v.(*Promise); ok {
+		v = p.Expr
+	}
+	var b strings.Builder
+	if a.Tag != "" {
+		b.WriteString(sexpName(a.Tag) + " = ")
+	}
+	writeSexp(&b, v)
+	return b.String()
+}
+
+// Missing reports whether the argument name of the function evaluated in env is missing, as missing(name) does:
+// it has no argument, the argument is missing or it has its default value.
+func Missing(env *Environment, name string) (bool, error) {
+	var v Value
+	found := false
+	for _, t := range env.Frame {
+		if t.Tag == name {
+			v, found = t.Value, true
+		}
+	}
+	if !found {
+		return false, errors.New("'missing' can only be used for arguments")
+	}
+	return isMissing(v), nil
+}
+
+// isMissing reports whether the value of an argument is missing: MissingArg, a default value, an empty ..., or an
+// unforced promise of an argument missing in the caller.
+func isMissing(v Value) bool {
+	switch x := v.(type) {
+	case *Promise :
+		if x.Default {
+			return true
+		}
+		if s, ok := x.Expr.(*Symbol); ok && !x.isForced() {
+			if env, ok := x.Env.(*Environment); ok {
+				if a := env.Get(s.Name); a != nil {
+					return isMissing(a)
+				}
+			}
+		}
+		return false
+	case *Pairlist :
+		return x.Dots && len(x.Elements) == 0
+	}
+	return v == MissingArg
+}
+
+// frameOf returns the index of the frame of the closure call evaluated in env, -1 at the top level.
+func (this *Context) frameOf(env *Environment) int {
+	for i := len(this.Frames) - 1; i >= 0; i-- {
+		if this.Frames[i].Env == env {
+			return i
+		}
+	}
+	return -1
+}
+
+// sysFrame returns the frame which of the call stack from env, as sys.call(which) and sys.function(which) select
+// it: the frame of env for 0, the frames before it for the negative numbers, the frames from the first one for the
+// positive numbers. It returns nil for the top level.
+func (this *Context) sysFrame(env *Environment, which int) (*Frame, error) {
+	n := this.frameOf(env) + 1
+	if which <= 0 {
+		which += n
+	}
+	switch {
+	case which < 0 || which > n :
+		return nil, errors.New("not that many frames on the stack")
+	case which == 0 :
+		return nil, nil
+	}
+	return this.Frames[which-1], nil
+}
+
+// SysCall returns the call of the frame which as sys.call(which) does when it is evaluated in env, nil at the top
+// level.
+func (this *Context) SysCall(env *Environment, which int) (*Language, error) {
+	f, err := this.sysFrame(env, which)
+	if f == nil {
+		return nil, err
+	}
+	return f.Call, nil
+}
+
+// SysFunction returns the function of the frame which as sys.function(which) does when it is evaluated in env.
+func (this *Context) SysFunction(env *Environment, which int) (*Closure, error) {
+	f, err := this.sysFrame(env, which)
+	if f == nil && err == nil {
+		err = errors.New("not that many frames on the stack")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f.Function, nil
+}
+
+// ParentFrame returns the environment parent.frame(n) returns when it is evaluated in env: the environment the
+// function evaluated in env is called from for n = 1, and so on up the callers, the global environment at the top
+// level.
+func (this *Context) ParentFrame(env *Environment, n int) (*Environment, error) {
+	if n < 1 {
+		return nil, errors.New("invalid 'n' value")
+	}
+	for i := len(this.Frames) - 1; i >= 0; i-- {
+		if f := this.Frames[i]; f.Env == env {
+			if n == 1 {
+				return f.CallEnv, nil
+			}
+			n--
+			env = f.CallEnv
+		}
+	}
+	return this.Session.GlobalEnv, nil
+}
@@ -0,0 +1,344 @@
+package r
+
+import "errors"
+import "fmt"
+import "strings"
+import "testing"
+
+// evaluator is a minimal evaluator of the language objects for the tests of the closure calls: {, <-, <<-,
+// function, return, missing, sys.call, sys.function, parent.frame, environment, +, -, stop, the closure calls, and
+// trace(name, x) that logs name when its argument is evaluated.
+type evaluator struct {
+	Context
+	trace []string
+}
+
+func newEvaluator() *evaluator {
+	this := &evaluator{}
+	this.Eval = this.eval
+	this.Session = NewSession()
+	return this
+}
+
+func (this *evaluator) eval(x Value, env *Environment) (Value, error) {
+	switch v := x.(type) {
+	case *Symbol :
+		return this.Get(env, v.Name)
+	case *Language :
+		return this.call(v, env)
+	}
+	return x, nil
+}
+
+// arg returns the argument i of the call evaluated, def if there is none.
+func (this *evaluator) arg(c *Language, i int, env *Environment, def float64) (float64, error) {
+	if i >= len(c.Args) {
+		return def, nil
+	}
+	v, err := this.eval(c.Args[i].Value, env)
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := v.(*Real); ok && len(f.Data) == 1 {
+		return f.Data[0], nil
+	}
+	return 0, errors.New("non-numeric argument")
+}
+
+func (this *evaluator) call(c *Language, env *Environment) (Value, error) {
+	s, ok := c.Fun.(*Symbol)
+	if !ok {
+		return nil, errors.New("attempt to apply non-function")
+	}
+	switch s.Name {
+	case "{" :
+		var v Value = NullValue
+		for _, a := range c.Args {
+			var err error
+			if v, err = this.eval(a.Value, env); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case "<-", "<<-" :
+		v, err := this.eval(c.Args[1].Value, env)
+		if err != nil {
+			return nil, err
+		}
+		name := c.Args[0].Value.(*Symbol).Name
+		if s.Name == "<-" {
+			return v, env.Assign(name, v)
+		}
+		return v, env.SuperAssign(name, v)
+	case "function" :
+		fun := &Closure{ Body: c.Args[1].Value, Env: env }
+		if p, ok := c.Args[0].Value.(*Pairlist); ok {
+			fun.Formals = p.Elements
+		}
+		return fun, nil
+	case "return" :
+		var v Value = NullValue
+		if len(c.Args) > 0 {
+			var err error
+			if v, err = this.eval(c.Args[0].Value, env); err != nil {
+				return nil, err
+			}
+		}
+		return nil, &ReturnSignal{ env, v }
+	case "missing" :
+		m, err := Missing(env, c.Args[0].Value.(*Symbol).Name)
+		if err != nil {
+			return nil, err
+		}
+		return &Logical{ Data: []int32{ boolean(m) } }, nil
+	case "sys.call" :
+		which, err := this.arg(c, 0, env, 0)
+		if err != nil {
+			return nil, err
+		}
+		call, err := this.SysCall(env, int(which))
+		if call == nil {
+			return NullValue, err
+		}
+		return call, nil
+	case "sys.function" :
+		which, err := this.arg(c, 0, env, 0)
+		if err != nil {
+			return nil, err
+		}
+		fun, err := this.SysFunction(env, int(which))
+		if err != nil {
+			return nil, err
+		}
+		return fun, nil
+	case "parent.frame" :
+		n, err := this.arg(c, 0, env, 1)
+		if err != nil {
+			return nil, err
+		}
+		return this.ParentFrame(env, int(n))
+	case "environment" :
+		return env, nil
+	case "trace" :
+		this.trace = append(this.trace, c.Args[0].Value.(*Character).Data[0])
+		return this.eval(c.Args[1].Value, env)
+	case "+", "-" :
+		p, err := this.arg(c, 0, env, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.Args) == 1 {
+			p = -p
+		}
+		q, err := this.arg(c, 1, env, 0)
+		if err != nil {
+			return nil, err
+		}
+		if s.Name == "-" {
+			q = -q
+		}
+		return &Real{ Data: []float64{ p + q } }, nil
+	case "stop" :
+		return nil, errors.New(c.Args[0].Value.(*Character).Data[0])
+	}
+	f, err := this.FindFunction(env, s.Name)
+	if err != nil {
+		return nil, err
+	}
+	fun, ok := f.(*Closure)
+	if !ok {
+		return nil, fmt.Errorf("builtin %s", s.Name)
+	}
+	args, err := PromiseArgs(c.Args, env)
+	if err != nil {
+		return nil, err
+	}
+	return this.Apply(fun, c, args, env)
+}
+
+// run evaluates the R code in a new environment enclosed by the global environment, and returns the result as a
+// string: the S-expression of the value, the error message after "Error: ", the traces after "|".
+func run(e *testing.T, src string) string {
+	x, err := ParseExpr(src)
+	if err != nil {
+		e.Fatal(err)
+	}
+	v, err := ExprValue(x)
+	if err != nil {
+		e.Fatal(err)
+	}
+	ev := newEvaluator()
+	env := NewEnvironment(ev.Session.GlobalEnv)
+	env.Name = "top"
+	v, err = ev.eval(v, env)
+	var b strings.Builder
+	switch x := v.(type) {
+	case nil :
+		b.WriteString("Error: " + err.Error())
+	case *Environment :
+		b.WriteString("<environment " + x.Name + ">")
+	default:
+		writeSexp(&b, v)
+	}
+	if len(ev.Frames) != 0 {
+		b.WriteString(" (frames left)")
+	}
+	if len(ev.trace) > 0 {
+		b.WriteString("|" + strings.Join(ev.trace, " "))
+	}
+	return b.String()
+}
+
+func TestApply(e *testing.T) {
+	tests := []struct {
+		src, result string
+	}{
+		// closures capture their defining environment
+		{ "{ x <- 1; f <- function() x; x <- 2; f() }", "2" },
+		{ "{ make <- function(n) function() n; g <- make(3); n <- 4; g() }", "3" },
+		{ "{ counter <- function() { i <- 0; function() { i <<- i + 1; i } }; f <- counter(); f(); f(); f() }", "3" },
+		{ "{ f <- function() { x <<- 5; 0 }; f(); x }", "5" },
+		// argument matching
+		{ "{ f <- function(x, y) x - y; f(y = 1, 3) }", "2" },
+		{ "{ f <- function(value, other) value; f(val = 1, 2) }", "1" },
+		{ "{ f <- function(x, ...) g(...); g <- function(a, b) a - b; f(0, b = 1, 3) }", "2" },
+		{ "{ f <- function(x) x; f(1, 2) }", "Error: unused argument (2)" },
+		{ "{ f <- function(x) x; f(1, y = 2, 3) }", "Error: unused arguments (y = 2, 3)" },
+		{ "{ f <- function(x, y) x; f(x = 1, x = 2) }", "Error: formal argument \"x\" matched by multiple actual arguments" },
+		{ "{ f <- function(value, verbose) 0; f(v = 1) }", "Error: argument 1 matches multiple formal arguments" },
+		{ "{ f <- function(alpha, ...) 0; f(al = 1, alp = 2) }", "Error: formal argument \"alpha\" matched by multiple actual arguments" },
+		{ "{ f <- function(alpha, ...) g(...); g <- function(al) al; f(alpha = 1, al = 2) }", "2" },
+		{ "{ f <- function() ...; f() }", "Error: '...' used in an incorrect context" },
+		{ "{ f <- function() g(...); g <- function(...) 0; f() }", "Error: '...' used in an incorrect context" },
+		// lookup of the functions and of the variables
+		{ "{ f <- function() 1; g <- function(f) f(); g(2) }", "1" },
+		{ "h()", "Error: could not find function \"h\"" },
+		{ "y", "Error: object 'y' not found" },
+		// return
+		{ "{ f <- function() { return(1); stop(\"not reached\") }; f() }", "1" },
+		{ "{ f <- function(x) x; g <- function() { f(return(2)); 3 }; g() }", "2" },
+		{ "return(1)", "Error: no function to return from, jumping to top level" },
+		{ "{ f <- function() stop(\"failed\"); f() }", "Error: failed" },
+	}
+	for i, test := range tests {
+		if result := run(e, test.src); result != test.result {
+			e.Error("Test Apply[", i, "] Failed:", test.src, "returns", result, "instead of", test.result)
+		}
+	}
+}
+
+func TestPromises(e *testing.T) {
+	tests := []struct {
+		src, result string
+	}{
+		// lazy evaluation, at most once
+		{ "{ f <- function(x) 0; f(trace(\"x\", 1)) }", "0" },
+		{ "{ f <- function(x) x + x; f(trace(\"x\", 1)) }", "2|x" },
+		{ "{ f <- function(x) g(x); g <- function(y) y + y; f(trace(\"x\", 1)) }", "2|x" },
+		{ "{ f <- function(a, b) b - a; f(trace(\"a\", 1), trace(\"b\", 3)) }", "2|b a" },
+		{ "{ f <- function(x) { y <- 2; x }; y <- 1; f(y) }", "1" },
+		{ "{ f <- function(...) g(...); g <- function(a, b) b; f(trace(\"a\", 1), trace(\"b\", 2)) }", "2|b" },
+		// default arguments are evaluated in the frame of the callee
+		{ "{ f <- function(x, y = x + 1) { x <- 10; y }; f(1) }", "11" },
+		{ "{ y <- 5; f <- function(x = y) { y <- 1; x }; f() }", "1" },
+		{ "{ f <- function(x = y, y = x) x; f() }", "Error: promise already under evaluation: recursive default argument reference or earlier problems?" },
+		{ "{ f <- function(x) x; f() }", "Error: argument \"x\" is missing, with no default" },
+		{ "{ f <- function(x, y = 2) y; f(1, ) }", "2" },
+		// a failed promise is forced again
+		{ "{ f <- function(x) { g(x); x }; g <- function(x) { x; 0 }; f(stop(\"failed\")) }", "Error: failed" },
+	}
+	for i, test := range tests {
+		if result := run(e, test.src); result != test.result {
+			e.Error("Test Promises[", i, "] Failed:", test.src, "returns", result, "instead of", test.result)
+		}
+	}
+}
+
+func TestMissing(e *testing.T) {
+	tests := []struct {
+		src, result string
+	}{
+		{ "{ f <- function(x) missing(x); f() }", "TRUE" },
+		{ "{ f <- function(x) missing(x); f(1) }", "FALSE" },
+		{ "{ f <- function(x = 1) missing(x); f() }", "TRUE" },
+		{ "{ f <- function(x = 1) { x; missing(x) }; f() }", "TRUE" },
+		{ "{ f <- function(x = 1) missing(x); f(1) }", "FALSE" },
+		{ "{ f <- function(x) g(x); g <- function(y) missing(y); f() }", "TRUE" },
+		{ "{ f <- function(x = 1) g(x); g <- function(y) missing(y); f() }", "TRUE" },
+		{ "{ f <- function(...) missing(...); f() }", "TRUE" },
+		{ "{ f <- function(...) missing(...); f(1) }", "FALSE" },
+		{ "{ f <- function(x) missing(y); f() }", "Error: 'missing' can only be used for arguments" },
+		{ "{ f <- function(x, y) missing(y); f(1, ) }", "TRUE" },
+	}
+	for i, test := range tests {
+		if result := run(e, test.src); result != test.result {
+			e.Error("Test Missing[", i, "] Failed:", test.src, "returns", result, "instead of", test.result)
+		}
+	}
+}
+
+func TestSysCall(e *testing.T) {
+	tests := []struct {
+		src, result string
+	}{
+		{ "sys.call()", "NULL" },
+		{ "{ f <- function(x) sys.call(); f(y) }", "f(y)" },
+		{ "{ f <- function(x) g(); g <- function() sys.call(-1); f(1) }", "f(1)" },
+		{ "{ f <- function(x) g(); g <- function() sys.call(1); f(1) }", "f(1)" },
+		{ "{ f <- function(x) g(); g <- function() sys.call(2); f(1) }", "g()" },
+		{ "{ f <- function(x) g(); g <- function() sys.call(3); f(1) }", "Error: not that many frames on the stack" },
+		{ "{ f <- function(x) g(); g <- function() sys.call(-2); f(1) }", "NULL" },
+		{ "{ f <- function(x) g(); g <- function() sys.call(-3); f(1) }", "Error: not that many frames on the stack" },
+		// the call of the frame of the promise, not of the frame forcing it
+		{ "{ f <- function(x) g(x); g <- function(y) y; f(sys.call()) }", "NULL" },
+		{ "{ f <- function(x) g(sys.call()); g <- function(y) y; f(1) }", "f(1)" },
+		{ "{ f <- function() sys.function(); g <- f; g() }", "<closure>" },
+		{ "sys.function()", "Error: not that many frames on the stack" },
+	}
+	for i, test := range tests {
+		if result := run(e, test.src); result != test.result {
+			e.Error("Test SysCall[", i, "] Failed:", test.src, "returns", result, "instead of", test.result)
+		}
+	}
+}
+
+func TestParentFrame(e *testing.T) {
+	tests := []struct {
+		src, result string
+	}{
+		{ "parent.frame()", "<environment R_GlobalEnv>" },
+		{ "{ f <- function() parent.frame(); f() }", "<environment top>" },
+		{ "{ f <- function() g(); g <- function() parent.frame(); f() }", "<environment >" },
+		{ "{ f <- function() { environment() -> e; g(e) }; g <- function(e) parent.frame(); f() }", "<environment >" },
+		{ "{ f <- function() g(); g <- function() parent.frame(2); f() }", "<environment top>" },
+		{ "{ f <- function() g(); g <- function() parent.frame(3); f() }", "<environment R_GlobalEnv>" },
+		// the parent frame of a promise is the one of the function it is evaluated in
+		{ "{ f <- function(x) g(x); g <- function(y) y; f(parent.frame()) }", "<environment R_GlobalEnv>" },
+		{ "{ f <- function() g(parent.frame()); g <- function(y) y; f() }", "<environment top>" },
+		{ "{ f <- function() parent.frame(0); f() }", "Error: invalid 'n' value" },
+	}
+	for i, test := range tests {
+		if result := run(e, test.src); result != test.result {
+			e.Error("Test ParentFrame[", i, "] Failed:", test.src, "returns", result, "instead of", test.result)
+		}
+	}
+}
+
+func TestApplySession(e *testing.T) {
+	// a closure read from a serialized object refers to the global environment: it is the one of the session
+	ev := newEvaluator()
+	ev.Session.GlobalEnv.Assign("x", &Real{ Data: []float64{ 1 } })
+	body, _ := ParseExpr("{ y <<- x + 1; y }")
+	v, _ := ExprValue(body)
+	fun := &Closure{ Body: v, Env: GlobalEnv }
+	r, err := ev.Apply(fun, &Language{ Fun: &Symbol{ Name: "f" } }, nil, ev.Session.GlobalEnv)
+	if x, ok := r.(*Real); err != nil || !ok || x.Data[0] != 2 {
+		e.Error("Test ApplySession Failed:", r, err)
+	}
+	if ev.Session.GlobalEnv.Get("y") == nil || GlobalEnv.Get("y") != nil {
+		e.Error("Test ApplySession Failed: <<- does not assign in the global environment of the session")
+	}
+	if newEvaluator().Session.GlobalEnv.Get("x") != nil {
+		e.Error("Test ApplySession Failed: the sessions share their global environment")
+	}
+}
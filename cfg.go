@@ -0,0 +1,569 @@
+package r
+
+import "fmt"
+import "io"
+import "strings"
+
+// EdgeKind tells why the control flows along an edge of a control flow graph.
+type EdgeKind int
+
+const (
+	EDGE_NORMAL EdgeKind = iota
+	// Condition of if, while and for (more elements), operand of && and ||
+	EDGE_TRUE
+	EDGE_FALSE
+	// End of the body of a loop back to its head
+	EDGE_LOOP
+	EDGE_NEXT
+	EDGE_BREAK
+	// return(), or invisible() as last expression
+	EDGE_RETURN
+	// stop() without handler
+	EDGE_ERROR
+	// Condition signaled inside tryCatch(), to its handler
+	EDGE_CONDITION
+)
+
+func (this EdgeKind) String() (s string) {
+	switch this {
+	case EDGE_NORMAL : s = "normal"
+	case EDGE_TRUE : s = "true"
+	case EDGE_FALSE : s = "false"
+	case EDGE_LOOP : s = "loop"
+	case EDGE_NEXT : s = "next"
+	case EDGE_BREAK : s = "break"
+	case EDGE_RETURN : s = "return"
+	case EDGE_ERROR : s = "error"
+	case EDGE_CONDITION : s = "condition"
+	}
+	return
+}
+
+// Block is a basic block: expressions evaluated in sequence.
+type Block struct {
+	Index int
+	// What the block is: entry, exit, body, if.then, for.head, tryCatch.error...
+	Label string
+	// The expressions evaluated, in order: statements, conditions, the ForExpr of a loop head, and the calls to
+	// return(), stop(), on.exit() and tryCatch() handlers
+	Nodes []Expr
+	Preds []*Edge
+	Succs []*Edge
+}
+
+// Edge is a transfer of control between two blocks.
+type Edge struct {
+	From *Block
+	To   *Block
+	Kind EdgeKind
+}
+
+// CFG is the control flow graph of an expression, usually the body of a function. The blocks of the expressions
+// registered by on.exit() are run before the exit block.
+type CFG struct {
+	Blocks []*Block
+	Entry  *Block
+	Exit   *Block
+	// Subexpressions of the nodes evaluated by blocks of their own before the node: the value of an assignment
+	// or an argument of a call holding an if, a tryCatch(), a return() or a stop(). The node uses their value.
+	Evaluated map[Expr]bool
+}
+
+// loop is the targets of next and break of a loop.
+type loop struct {
+	head, after *Block
+}
+
+// cfgBuilder builds a CFG.
+type cfgBuilder struct {
+	cfg     *CFG
+	cur     *Block
+	loops   []loop
+	// Targets of stop(): the error handlers of the enclosing tryCatch() calls, nil for a call without one
+	errors  []*Block
+	onExits []Expr
+}
+
+// NewCFG returns the control flow graph of x, the body of a function or a top level expression. The function
+// definitions inside x are values, their bodies have their own graph.
+func NewCFG(x Expr) *CFG {
+	b := &cfgBuilder{ cfg: &CFG{ Evaluated: make(map[Expr]bool) } }
+	b.cfg.Entry = b.newBlock("entry")
+	b.cfg.Exit = b.newBlock("exit")
+	b.cur = b.newBlock("body")
+	b.edge(b.cfg.Entry, b.cur, EDGE_NORMAL)
+	b.stmt(x, true)
+	b.edge(b.cur, b.cfg.Exit, EDGE_NORMAL)
+	b.finish()
+	return b.cfg
+}
+
+func (this *cfgBuilder) newBlock(label string) *Block {
+	b := &Block{ Label: label }
+	this.cfg.Blocks = append(this.cfg.Blocks, b)
+	return b
+}
+
+func (this *cfgBuilder) edge(from, to *Block, kind EdgeKind) {
+	e := &Edge{ from, to, kind }
+	from.Succs = append(from.Succs, e)
+	to.Preds = append(to.Preds, e)
+}
+
+// jump ends the current block with an edge to target, the code following it is unreachable.
+func (this *cfgBuilder) jump(target *Block, kind EdgeKind) {
+	this.edge(this.cur, target, kind)
+	this.cur = this.newBlock("unreachable")
+}
+
+// stmt adds the evaluation of x, tail tells if the value of x is the value of the function.
+func (this *cfgBuilder) stmt(x Expr, tail bool) {
+	switch v := x.(type) {
+	case *BlockExpr :
+		for i, s := range v.List {
+			this.stmt(s, tail && i == len(v.List)-1)
+		}
+	case *ParenExpr :
+		this.stmt(v.X, tail)
+	case *IfExpr :
+		then, after := this.newBlock("if.then"), this.newBlock("if.after")
+		otherwise := after
+		if v.Else != nil {
+			otherwise = this.newBlock("if.else")
+		}
+		this.cond(v.Cond, then, otherwise)
+		this.cur = then
+		this.stmt(v.Then, tail)
+		this.edge(this.cur, after, EDGE_NORMAL)
+		if v.Else != nil {
+			this.cur = otherwise
+			this.stmt(v.Else, tail)
+			this.edge(this.cur, after, EDGE_NORMAL)
+		}
+		this.cur = after
+	case *ForExpr :
+		this.cur.Nodes = append(this.cur.Nodes, v.Seq)
+		head := this.newBlock("for.head")
+		this.edge(this.cur, head, EDGE_NORMAL)
+		head.Nodes = append(head.Nodes, v)
+		body, after := this.newBlock("for.body"), this.newBlock("for.after")
+		this.edge(head, body, EDGE_TRUE)
+		this.edge(head, after, EDGE_FALSE)
+		this.body(v.Body, body, head, after)
+	case *WhileExpr :
+		head := this.newBlock("while.head")
+		this.edge(this.cur, head, EDGE_NORMAL)
+		body, after := this.newBlock("while.body"), this.newBlock("while.after")
+		this.cur = head
+		this.cond(v.Cond, body, after)
+		this.body(v.Body, body, head, after)
+	case *RepeatExpr :
+		body := this.newBlock("repeat.body")
+		this.edge(this.cur, body, EDGE_NORMAL)
+		this.body(v.Body, body, body, this.newBlock("repeat.after"))
+	case *NextExpr :
+		this.cur.Nodes = append(this.cur.Nodes, v)
+		if len(this.loops) > 0 {
+			this.jump(this.loops[len(this.loops)-1].head, EDGE_NEXT)
+		}
+	case *BreakExpr :
+		this.cur.Nodes = append(this.cur.Nodes, v)
+		if len(this.loops) > 0 {
+			this.jump(this.loops[len(this.loops)-1].after, EDGE_BREAK)
+		}
+	case *BinaryExpr :
+		if v.Op.Type == OP_AND2 || v.Op.Type == OP_OR2 {
+			// cond && action, cond || stop(...)
+			var rhs *Block
+			after := this.newBlock("after")
+			if v.Op.Type == OP_AND2 {
+				rhs = this.newBlock("and.rhs")
+				this.cond(v.X, rhs, after)
+			} else {
+				rhs = this.newBlock("or.rhs")
+				this.cond(v.X, after, rhs)
+			}
+			this.cur = rhs
+			this.stmt(v.Y, false)
+			this.edge(this.cur, after, EDGE_NORMAL)
+			this.cur = after
+			return
+		}
+		if value := assignedValue(v); value != nil && hasControlFlow(value) {
+			// y <- if (a) 1 else stop("no"): the value then the assignment
+			this.value(value)
+		}
+		this.cur.Nodes = append(this.cur.Nodes, v)
+	case *CallExpr :
+		this.call(v, tail)
+	default:
+		this.cur.Nodes = append(this.cur.Nodes, x)
+	}
+}
+
+// value adds the evaluation of x, a subexpression of the next node holding control flow, in blocks of its own.
+func (this *cfgBuilder) value(x Expr) {
+	this.stmt(x, false)
+	this.cfg.Evaluated[x] = true
+}
+
+// args adds the evaluation of the arguments of the call holding control flow.
+func (this *cfgBuilder) args(c *CallExpr) {
+	for _, a := range c.Args {
+		if a.Value != nil && hasControlFlow(a.Value) {
+			this.value(a.Value)
+		}
+	}
+}
+
+// assignedValue returns the value of an assignment, nil if x is not an assignment.
+func assignedValue(x *BinaryExpr) Expr {
+	switch x.Op.Type {
+	case OP_LEFT_ASSIGN, OP_LEFT_ASSIGN2, OP_EQUAL_ASSIGN :
+		return x.Y
+	case OP_RIGHT_ASSIGN, OP_RIGHT_ASSIGN2 :
+		return x.X
+	}
+	return nil
+}
+
+// quotingFunctions are the functions that do not evaluate their arguments where they are called.
+var quotingFunctions = map[string]bool{
+	"quote": true, "bquote": true, "substitute": true, "expression": true, "on.exit": true, "~": true,
+}
+
+// hasControlFlow reports whether the value x holds an if, a tryCatch(), a return() or a stop(), directly or in the
+// value of an assignment, an operand of && and || or an argument of a call.
+func hasControlFlow(x Expr) bool {
+	switch v := x.(type) {
+	case *IfExpr :
+		return true
+	case *ParenExpr :
+		return hasControlFlow(v.X)
+	case *BlockExpr :
+		for _, s := range v.List {
+			if hasControlFlow(s) {
+				return true
+			}
+		}
+	case *BinaryExpr :
+		if value := assignedValue(v); value != nil {
+			return hasControlFlow(value)
+		}
+		if v.Op.Type == OP_AND2 || v.Op.Type == OP_OR2 {
+			return hasControlFlow(v.X) || hasControlFlow(v.Y)
+		}
+	case *CallExpr :
+		switch name := v.FunctionName(); {
+		case name == "return" || name == "stop" || name == "tryCatch" :
+			return true
+		case quotingFunctions[name] :
+			return false
+		}
+		for _, a := range v.Args {
+			if a.Value != nil && hasControlFlow(a.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// body adds the body of a loop starting at the block start, head is the target of next and of the end of the
+// body, after the target of break.
+func (this *cfgBuilder) body(x Expr, start, head, after *Block) {
+	this.loops = append(this.loops, loop{ head, after })
+	this.cur = start
+	this.stmt(x, false)
+	this.edge(this.cur, head, EDGE_LOOP)
+	this.loops = this.loops[:len(this.loops)-1]
+	this.cur = after
+}
+
+// cond adds the evaluation of the condition x, with the short-circuits of &&, || and !, jumping to t when it is
+// TRUE and to f otherwise.
+func (this *cfgBuilder) cond(x Expr, t, f *Block) {
+	switch v := x.(type) {
+	case *ParenExpr :
+		this.cond(v.X, t, f)
+		return
+	case *UnaryExpr :
+		if v.Op.Type == OP_NOT {
+			this.cond(v.X, f, t)
+			return
+		}
+	case *BinaryExpr :
+		switch v.Op.Type {
+		case OP_AND2 :
+			rhs := this.newBlock("and.rhs")
+			this.cond(v.X, rhs, f)
+			this.cur = rhs
+			this.cond(v.Y, t, f)
+			return
+		case OP_OR2 :
+			rhs := this.newBlock("or.rhs")
+			this.cond(v.X, t, rhs)
+			this.cur = rhs
+			this.cond(v.Y, t, f)
+			return
+		}
+	}
+	this.cur.Nodes = append(this.cur.Nodes, x)
+	this.edge(this.cur, t, EDGE_TRUE)
+	this.edge(this.cur, f, EDGE_FALSE)
+}
+
+// call adds a call: return(), stop() and invisible() as last expression leave the function, tryCatch() runs its
+// handlers on conditions and on.exit() registers an expression to run at exit.
+func (this *cfgBuilder) call(c *CallExpr, tail bool) {
+	switch c.FunctionName() {
+	case "return" :
+		this.args(c)
+		this.cur.Nodes = append(this.cur.Nodes, c)
+		this.jump(this.cfg.Exit, EDGE_RETURN)
+	case "invisible" :
+		this.args(c)
+		this.cur.Nodes = append(this.cur.Nodes, c)
+		if tail {
+			this.jump(this.cfg.Exit, EDGE_RETURN)
+		}
+	case "stop" :
+		this.args(c)
+		this.cur.Nodes = append(this.cur.Nodes, c)
+		for i := len(this.errors)-1; i >= 0; i-- {
+			if this.errors[i] != nil {
+				this.jump(this.errors[i], EDGE_CONDITION)
+				return
+			}
+		}
+		this.jump(this.cfg.Exit, EDGE_ERROR)
+	case "on.exit" :
+		this.cur.Nodes = append(this.cur.Nodes, c)
+		if !onExitAdd(c) {
+			// without add = TRUE the expression replaces the registered ones
+			this.onExits = nil
+		}
+		for _, a := range c.Args {
+			if a.Value != nil && (a.Name == nil || a.Name.stringvalue == "expr") {
+				this.onExits = append(this.onExits, a.Value)
+				break
+			}
+		}
+	case "tryCatch" :
+		this.tryCatch(c, tail)
+	default:
+		if !quotingFunctions[c.FunctionName()] {
+			this.args(c)
+		}
+		this.cur.Nodes = append(this.cur.Nodes, c)
+	}
+}
+
+// onExitAdd reports whether on.exit() is called with add = TRUE.
+func onExitAdd(c *CallExpr) bool {
+	for _, a := range c.Args {
+		if a.Name != nil && a.Name.stringvalue == "add" {
+			if v, ok := a.Value.(*Constant); ok && v.Token.Type == CONST_TRUE {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tryCatch adds tryCatch(expr, cond = handler, finally = expr): a condition signaled anywhere in expr runs its
+// handler, the value of the handler or of expr is the value of the call, finally is run after both and before
+// the jumps out of expr.
+func (this *cfgBuilder) tryCatch(c *CallExpr, tail bool) {
+	var expr, finally Expr
+	var handlers []*Arg
+	for _, a := range c.Args {
+		switch {
+		case a.Value == nil :
+		case a.Name == nil && expr == nil, a.Name != nil && a.Name.stringvalue == "expr" :
+			expr = a.Value
+		case a.Name != nil && a.Name.stringvalue == "finally" :
+			finally = a.Value
+		case a.Name != nil :
+			handlers = append(handlers, a)
+			// the handlers are evaluated before expr
+			this.cur.Nodes = append(this.cur.Nodes, a.Value)
+		}
+	}
+	try, after := this.newBlock("tryCatch.expr"), this.newBlock("tryCatch.after")
+	this.edge(this.cur, try, EDGE_NORMAL)
+	own := map[*Block]bool{ after: true }
+	var onError *Block
+	var conds []*Block
+	for _, h := range handlers {
+		b := this.newBlock("tryCatch." + h.Name.stringvalue)
+		b.Nodes = append(b.Nodes, h.Value)
+		this.edge(try, b, EDGE_CONDITION)
+		this.edge(b, after, EDGE_NORMAL)
+		if h.Name.stringvalue == "error" || h.Name.stringvalue == "condition" {
+			onError = b
+		}
+		conds = append(conds, b)
+		own[b] = true
+	}
+	this.cur = try
+	first := len(this.cfg.Blocks)
+	if expr != nil {
+		this.errors = append(this.errors, onError)
+		this.stmt(expr, tail && finally == nil)
+		this.errors = this.errors[:len(this.errors)-1]
+	}
+	this.edge(this.cur, after, EDGE_NORMAL)
+	blocks := append([]*Block{ try }, this.cfg.Blocks[first:]...)
+	for _, b := range blocks {
+		own[b] = true
+		for _, h := range conds {
+			if !hasEdge(b, h, EDGE_CONDITION) {
+				this.edge(b, h, EDGE_CONDITION)
+			}
+		}
+	}
+	if finally != nil {
+		// return(), stop(), next and break jump out of expr through a copy of finally, one per target
+		type target struct {
+			to   *Block
+			kind EdgeKind
+		}
+		copies := make(map[target]*Block)
+		for _, b := range blocks {
+			for _, e := range b.Succs {
+				if own[e.To] {
+					continue
+				}
+				t := target{ e.To, e.Kind }
+				f := copies[t]
+				if f == nil {
+					f = this.newBlock("tryCatch.finally")
+					this.cur = f
+					this.stmt(finally, false)
+					this.edge(this.cur, t.to, t.kind)
+					copies[t] = f
+				}
+				e.To.Preds = removeEdge(e.To.Preds, e)
+				e.To = f
+				f.Preds = append(f.Preds, e)
+			}
+		}
+	}
+	this.cur = after
+	if finally != nil {
+		this.stmt(finally, false)
+	}
+}
+
+// hasEdge reports whether there is an edge of the kind from a block to another.
+func hasEdge(from, to *Block, kind EdgeKind) bool {
+	for _, e := range from.Succs {
+		if e.To == to && e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// finish runs the on.exit() expressions before the exit, removes the empty unreachable blocks and numbers the
+// blocks.
+func (this *cfgBuilder) finish() {
+	g := this.cfg
+	if len(this.onExits) > 0 {
+		b := this.newBlock("on.exit")
+		b.Nodes = this.onExits
+		b.Preds, g.Exit.Preds = g.Exit.Preds, nil
+		for _, e := range b.Preds {
+			e.To = b
+		}
+		this.edge(b, g.Exit, EDGE_NORMAL)
+	}
+	for removed := true; removed; {
+		removed = false
+		blocks := g.Blocks[:0]
+		for _, b := range g.Blocks {
+			if b != g.Entry && b != g.Exit && len(b.Preds) == 0 && len(b.Nodes) == 0 {
+				for _, e := range b.Succs {
+					e.To.Preds = removeEdge(e.To.Preds, e)
+				}
+				removed = true
+				continue
+			}
+			blocks = append(blocks, b)
+		}
+		g.Blocks = blocks
+	}
+	for i, b := range g.Blocks {
+		b.Index = i
+	}
+}
+
+func removeEdge(edges []*Edge, e *Edge) []*Edge {
+	for i, x := range edges {
+		if x == e {
+			return append(edges[:i], edges[i+1:]...)
+		}
+	}
+	return edges
+}
+
+// Unreachable returns the blocks with expressions that the entry does not reach.
+func (this *CFG) Unreachable() (blocks []*Block) {
+	seen := make(map[*Block]bool)
+	var visit func(b *Block)
+	visit = func(b *Block) {
+		if seen[b] {
+			return
+		}
+		seen[b] = true
+		for _, e := range b.Succs {
+			visit(e.To)
+		}
+	}
+	visit(this.Entry)
+	for _, b := range this.Blocks {
+		if !seen[b] && len(b.Nodes) > 0 {
+			blocks = append(blocks, b)
+		}
+	}
+	return
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz, one box per block listing its expressions in the
+// call form of FormatSexp.
+func (this *CFG) WriteDOT(w io.Writer, name string) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n\tnode [shape=box fontname=monospace];\n", name)
+	for _, blk := range this.Blocks {
+		label := fmt.Sprintf("%d: %s\\l", blk.Index, blk.Label)
+		for _, x := range blk.Nodes {
+			s, err := FormatSexp(x, SexpOptions{})
+			if err != nil {
+				s = fmt.Sprintf("<%d:%d>", x.Pos().Line, x.Pos().Column)
+			}
+			if r := []rune(s); len(r) > 60 {
+				s = string(r[:57]) + "..."
+			}
+			label += dotEscape(s) + "\\l"
+		}
+		fmt.Fprintf(&b, "\tb%d [label=\"%s\"];\n", blk.Index, label)
+	}
+	for _, blk := range this.Blocks {
+		for _, e := range blk.Succs {
+			if e.Kind == EDGE_NORMAL {
+				fmt.Fprintf(&b, "\tb%d -> b%d;\n", e.From.Index, e.To.Index)
+			} else {
+				fmt.Fprintf(&b, "\tb%d -> b%d [label=\"%s\"];\n", e.From.Index, e.To.Index, e.Kind)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+// dotEscape escapes s for a double quoted DOT string.
+func dotEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(s)
+}
@@ -0,0 +1,122 @@
+package r
+
+import "bytes"
+import "fmt"
+import "strings"
+import "testing"
+
+// cfgString returns the blocks of the graph of the body of the function src: label, number of expressions and
+// successors.
+func cfgString(e *testing.T, src string) (*CFG, string) {
+	x, err := ParseExpr(src)
+	if err != nil {
+		e.Fatal(err)
+	}
+	g := NewCFG(x.(*FunctionExpr).Body)
+	var blocks []string
+	for _, b := range g.Blocks {
+		s := fmt.Sprintf("%d:%s/%d", b.Index, b.Label, len(b.Nodes))
+		for _, e := range b.Succs {
+			if e.Kind == EDGE_NORMAL {
+				s += fmt.Sprintf(" %d", e.To.Index)
+			} else {
+				s += fmt.Sprintf(" %d%s", e.To.Index, e.Kind)
+			}
+		}
+		blocks = append(blocks, s)
+	}
+	return g, strings.Join(blocks, "; ")
+}
+
+func TestCFG(e *testing.T) {
+	tests := []struct {
+		src    string
+		result string
+	}{
+		{ "function(x) { y <- x; y }",
+			"0:entry/0 2; 1:exit/0; 2:body/2 1" },
+		{ "function(x) if (x) 1 else 2",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 5false; 3:if.then/1 4; 4:if.after/0 1; 5:if.else/1 4" },
+		{ "function(a, b) { if (a && !b) f(); g() }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 5true 4false; 3:if.then/1 4; 4:if.after/1 1; 5:and.rhs/1 4true 3false" },
+		{ "function(a, b) if (a || b) f()",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 5false; 3:if.then/1 4; 4:if.after/0 1; 5:or.rhs/1 3true 4false" },
+		{ "function(x) { is.null(x) || stop('x') ; x }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 4false; 3:after/1 1; 4:or.rhs/1 1error" },
+		{ "function(x) for (i in x) { if (i) next; if (!i) break; f(i) }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3; 3:for.head/1 4true 5false; 4:for.body/1 6true 7false; 5:for.after/0 1; 6:if.then/1 3next; 7:if.after/1 9true 8false; 8:if.then/1 5break; 9:if.after/1 3loop" },
+		{ "function(x) while (x > 0) x <- x - 1",
+			"0:entry/0 2; 1:exit/0; 2:body/0 3; 3:while.head/1 4true 5false; 4:while.body/1 3loop; 5:while.after/0 1" },
+		{ "function() repeat { if (done()) break }",
+			"0:entry/0 2; 1:exit/0; 2:body/0 3; 3:repeat.body/1 5true 6false; 4:repeat.after/0 1; 5:if.then/1 4break; 6:if.after/0 3loop" },
+		{ "function(x) { if (x) return(1); stop('no'); f() }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 4false; 3:if.then/1 1return; 4:if.after/1 1error; 5:unreachable/1 1" },
+		{ "function(x) { f(x); invisible(x) }",
+			"0:entry/0 2; 1:exit/0; 2:body/2 1return" },
+		{ "function(x) { invisible(x); 1 }",
+			"0:entry/0 2; 1:exit/0; 2:body/2 1" },
+		{ "function(x) tryCatch(f(x), error = function(e) NULL, finally = close(x))",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3; 3:tryCatch.expr/1 5condition 4; 4:tryCatch.after/1 1; 5:tryCatch.error/1 4" },
+		{ "function(x) tryCatch({ if (x) stop('e'); 1 }, error = function(e) 2)",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3; 3:tryCatch.expr/1 5condition 6true 7false; 4:tryCatch.after/0 1; 5:tryCatch.error/1 4; 6:if.then/1 5condition; 7:if.after/1 4 5condition" },
+		// the conditions signaled after a branch, finally before return() and stop()
+		{ "function(x) tryCatch({ if (x) f(); g() }, warning = function(w) NULL)",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3; 3:tryCatch.expr/1 5condition 6true 7false; 4:tryCatch.after/0 1; 5:tryCatch.warning/1 4; 6:if.then/1 7 5condition; 7:if.after/1 4 5condition" },
+		{ "function(a) { tryCatch({ x <- f(); return(x) }, finally = cleanup()); 2 }",
+			"0:entry/0 2; 1:exit/0; 2:body/0 3; 3:tryCatch.expr/2 5return; 4:tryCatch.after/2 1; 5:tryCatch.finally/1 1return" },
+		{ "function(a) { tryCatch({ if (a) stop('no'); g() }, finally = cleanup()); 2 }",
+			"0:entry/0 2; 1:exit/0; 2:body/0 3; 3:tryCatch.expr/1 5true 6false; 4:tryCatch.after/2 1; 5:if.then/1 7error; 6:if.after/1 4; 7:tryCatch.finally/1 1error" },
+		{ "function(f) { con <- file(f); on.exit(close(con)); if (bad(con)) stop('bad'); read(con) }",
+			"0:entry/0 2; 1:exit/0; 2:body/3 3true 4false; 3:if.then/1 5error; 4:if.after/1 5; 5:on.exit/1 1" },
+		// control flow in the value of an assignment or in the arguments of a call, the assignment is after it
+		{ "function(x) { y <- tryCatch(g(x), error = function(e) NULL); y }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3; 3:tryCatch.expr/1 5condition 4; 4:tryCatch.after/2 1; 5:tryCatch.error/1 4" },
+		{ "function(a) { z <- if (a) 1 else stop('no'); z }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 5false; 3:if.then/1 4; 4:if.after/2 1; 5:if.else/1 1error" },
+		{ "function(x) { y <- f(x, if (x) 1 else 2); y }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 5false; 3:if.then/1 4; 4:if.after/3 1; 5:if.else/1 4" },
+		{ "function(x) { ok <- is.null(x) || stop('x'); q <- quote(if (x) 1); x }",
+			"0:entry/0 2; 1:exit/0; 2:body/1 3true 4false; 3:after/3 1; 4:or.rhs/1 1error" },
+	}
+	for i, test := range tests {
+		if _, s := cfgString(e, test.src); s != test.result {
+			e.Error("Test CFG[", i, "] Failed:", s)
+		}
+	}
+}
+
+func TestCFGUnreachable(e *testing.T) {
+	g, _ := cfgString(e, "function(x) { return(x); y <- 1; if (y) f() }")
+	u := g.Unreachable()
+	if len(u) != 2 || u[0].Label != "unreachable" || u[1].Label != "if.then" {
+		e.Error("Test CFGUnreachable Failed:", len(u))
+	}
+	g, _ = cfgString(e, "function(x) if (x) return(1) else return(2)")
+	if len(g.Unreachable()) != 0 {
+		e.Error("Test CFGUnreachable Failed: empty blocks")
+	}
+}
+
+func TestCFGWriteDOT(e *testing.T) {
+	g, _ := cfgString(e, "function(x) if (x == \"a\") 1")
+	var b bytes.Buffer
+	if err := g.WriteDOT(&b, "f"); err != nil {
+		e.Fatal(err)
+	}
+	expected := "digraph \"f\" {\n" +
+		"\tnode [shape=box fontname=monospace];\n" +
+		"\tb0 [label=\"0: entry\\l\"];\n" +
+		"\tb1 [label=\"1: exit\\l\"];\n" +
+		"\tb2 [label=\"2: body\\l`==`(x, \\\"a\\\")\\l\"];\n" +
+		"\tb3 [label=\"3: if.then\\l1\\l\"];\n" +
+		"\tb4 [label=\"4: if.after\\l\"];\n" +
+		"\tb0 -> b2;\n" +
+		"\tb2 -> b3 [label=\"true\"];\n" +
+		"\tb2 -> b4 [label=\"false\"];\n" +
+		"\tb3 -> b4;\n" +
+		"\tb4 -> b1;\n" +
+		"}\n"
+	if b.String() != expected {
+		e.Error("Test CFGWriteDOT Failed:", b.String())
+	}
+}
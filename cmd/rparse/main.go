@@ -0,0 +1,122 @@
+// Command rparse parses R source files and dumps their syntax tree.
+//
+// Usage:
+//
+//	rparse [-format json|yaml|sexp|ast|cfg] [-srcref] [-encoding name] [file...]
+//
+// The standard input is parsed when no file is given. The json and yaml formats are the versioned documents
+// described by ast.schema.json, one per file; the sexp format writes one line per top level expression with every
+// operator as a call, as R sees it, the ast format writes the trees of lobstr::ast() and the cfg format writes in
+// the DOT language the control flow graph of the top level and of each function. With -srcref the function
+// definitions have the srcref R keeps with options(keep.source = TRUE). The files are read in UTF-8, or in the
+// encoding of their byte order mark, unless -encoding gives latin1, CP1252 or UTF-16LE.
+package main
+
+import "bytes"
+import "flag"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "os"
+
+import "github.com/romain-jacotin/r"
+
+func main() {
+	format := flag.String("format", "json", "output format: json, yaml, sexp, ast or cfg")
+	srcref := flag.Bool("srcref", false, "write the srcref of the functions in the sexp and ast formats")
+	encoding := flag.String("encoding", "", "encoding of the files: UTF-8, latin1, CP1252 or UTF-16LE")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: rparse [-format json|yaml|sexp|ast|cfg] [-srcref] [-encoding name] [file...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch *format {
+	case "json", "yaml", "sexp", "ast", "cfg" :
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	enc, err := r.ParseEncoding(*encoding)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rparse:", err)
+		os.Exit(2)
+	}
+
+	status := 0
+	if flag.NArg() == 0 {
+		if err := dump(os.Stdout, os.Stdin, *format, enc, r.SexpOptions{ Srcref: *srcref, File: "<stdin>" }); err != nil {
+			fmt.Fprintln(os.Stderr, "rparse: <stdin>:", err)
+			status = 1
+		}
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rparse:", err)
+			status = 1
+			continue
+		}
+		if err = dump(os.Stdout, f, *format, enc, r.SexpOptions{ Srcref: *srcref, File: name }); err != nil {
+			fmt.Fprintf(os.Stderr, "rparse: %s: %s\n", name, err)
+			status = 1
+		}
+		f.Close()
+	}
+	os.Exit(status)
+}
+
+// dump parses the source read from in and writes its syntax tree to out.
+func dump(out io.Writer, in io.Reader, format string, enc r.Encoding, opts r.SexpOptions) error {
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	f, err := r.NewParser(bytes.NewReader(src), enc).Parse()
+	if err != nil {
+		return err
+	}
+	opts.Source = src
+	switch format {
+	case "yaml" :
+		return r.WriteYAML(out, f)
+	case "sexp", "ast" :
+		opts.Tree = format == "ast"
+		for _, x := range f.Exprs {
+			s, err := r.FormatSexp(x, opts)
+			if err != nil {
+				return err
+			}
+			if opts.Tree {
+				fmt.Fprint(out, s)
+			} else {
+				fmt.Fprintln(out, s)
+			}
+		}
+		return nil
+	case "cfg" :
+		return writeCFG(out, f)
+	}
+	return r.WriteJSON(out, f)
+}
+
+// writeCFG writes the control flow graph of the top level expressions, then of the functions in source order.
+func writeCFG(out io.Writer, f *r.File) error {
+	if err := r.NewCFG(&r.BlockExpr{ List: f.Exprs }).WriteDOT(out, "top level"); err != nil {
+		return err
+	}
+	for _, x := range f.Exprs {
+		var err error
+		r.Inspect(x, func(n r.Node) bool {
+			if fn, ok := n.(*r.FunctionExpr); ok && err == nil {
+				pos := fn.Pos()
+				err = r.NewCFG(fn.Body).WriteDOT(out, fmt.Sprintf("function %d:%d", pos.Line, pos.Column))
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
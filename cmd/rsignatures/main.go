@@ -0,0 +1,58 @@
+// Command rsignatures writes the signature database file of an R package from its R sources.
+//
+// Usage:
+//
+//	rsignatures [-version x.y.z] package file...
+//
+// The functions assigned at the top level of the files are written in file order, with the primitives zzz.R of
+// base defines in .ArgsEnv and .GenericArgsEnv; a function defined in several files keeps its last definition.
+// The output is a signatures/<package>.R file of the github.com/romain-jacotin/r package.
+package main
+
+import "flag"
+import "fmt"
+import "os"
+
+import "github.com/romain-jacotin/r"
+
+func main() {
+	version := flag.String("version", "", "version of R of the sources")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: rsignatures [-version x.y.z] package file...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	pkg := flag.Arg(0)
+	var sigs []*r.Signature
+	index := make(map[string]int)
+	for _, name := range flag.Args()[1:] {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "rsignatures:", err)
+			os.Exit(1)
+		}
+		s, err := r.ExtractSignatures(pkg, f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rsignatures: %s: %s\n", name, err)
+			os.Exit(1)
+		}
+		for _, s := range s {
+			if i, ok := index[s.Name]; ok {
+				sigs[i] = s
+			} else {
+				index[s.Name] = len(sigs)
+				sigs = append(sigs, s)
+			}
+		}
+	}
+	if err := r.WriteSignatures(os.Stdout, pkg, *version, sigs); err != nil {
+		fmt.Fprintln(os.Stderr, "rsignatures:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,323 @@
+package r
+
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+
+
+// Coerce converts the atomic vector v to the type typ (LGLSXP, INTSXP, REALSXP, CPLXSXP, STRSXP or RAWSXP) as
+// as.vector() does: the result has no attributes, and the elements that can not be converted are NA with a
+// warning. NULL converts to an empty vector.
+func Coerce(v Value, typ SexpType) (Value, []string, error) {
+	var w warnings
+	switch v.Type() {
+	case NILSXP, LGLSXP, INTSXP, REALSXP, CPLXSXP, STRSXP, RAWSXP :
+	default:
+		return nil, nil, fmt.Errorf("cannot coerce type '%s' to vector of type '%s'", v.Type(), typ)
+	}
+	n := Length(v)
+	switch typ {
+	case LGLSXP :
+		l := &Logical{ Data: make([]int32, n) }
+		for i := range l.Data {
+			l.Data[i] = logicalAt(v, i)
+		}
+		return l, nil, nil
+	case INTSXP :
+		k := &Integer{ Data: make([]int32, n) }
+		for i := range k.Data {
+			k.Data[i] = integerAt(v, i, &w)
+		}
+		return k, w, nil
+	case REALSXP :
+		f := &Real{ Data: make([]float64, n) }
+		for i := range f.Data {
+			f.Data[i] = realAt(v, i, &w)
+		}
+		return f, w, nil
+	case CPLXSXP :
+		c := &Complex{ Data: make([]complex128, n) }
+		for i := range c.Data {
+			c.Data[i] = complexAt(v, i, &w)
+		}
+		return c, w, nil
+	case STRSXP :
+		s := &Character{ Data: make([]string, n) }
+		for i := range s.Data {
+			if IsNA(v, i) && !isNaN(v, i) {
+				if s.NA == nil {
+					s.NA = make([]bool, n)
+				}
+				s.NA[i] = true
+				continue
+			}
+			s.Data[i] = stringAt(v, i)
+		}
+		return s, nil, nil
+	case RAWSXP :
+		b := &Raw{ Data: make([]byte, n) }
+		for i := range b.Data {
+			b.Data[i] = rawAt(v, i, &w)
+		}
+		return b, w, nil
+	}
+	return nil, nil, fmt.Errorf("cannot coerce type '%s' to vector of type '%s'", v.Type(), typ)
+}
+
+// isNaN reports whether the element i of v is a double or complex NaN that is not NA: as.character(NaN) is "NaN".
+func isNaN(v Value, i int) bool {
+	switch x := v.(type) {
+	case *Real :
+		return math.IsNaN(x.Data[i]) && !IsNaReal(x.Data[i])
+	case *Complex :
+		return !IsNaReal(real(x.Data[i])) && !IsNaReal(imag(x.Data[i]))
+	}
+	return false
+}
+
+// logicalAt converts the element i of the atomic vector v to a logical.
+func logicalAt(v Value, i int) int32 {
+	switch x := v.(type) {
+	case *Logical :
+		return x.Data[i]
+	case *Integer :
+		if x.Data[i] == NaInteger {
+			return NaLogical
+		}
+		return boolean(x.Data[i] != 0)
+	case *Real :
+		if math.IsNaN(x.Data[i]) {
+			return NaLogical
+		}
+		return boolean(x.Data[i] != 0)
+	case *Complex :
+		if IsNA(x, i) {
+			return NaLogical
+		}
+		return boolean(x.Data[i] != 0)
+	case *Character :
+		if !x.IsNA(i) {
+			switch x.Data[i] {
+			case "TRUE", "true", "True", "T" :
+				return 1
+			case "FALSE", "false", "False", "F" :
+				return 0
+			}
+		}
+	case *Raw :
+		return boolean(x.Data[i] != 0)
+	}
+	return NaLogical
+}
+
+func boolean(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// integerAt converts the element i of the atomic vector v to an integer, truncating the doubles.
+func integerAt(v Value, i int, w *warnings) int32 {
+	switch x := v.(type) {
+	case *Logical :
+		return x.Data[i]
+	case *Integer :
+		return x.Data[i]
+	case *Raw :
+		return int32(x.Data[i])
+	}
+	f := realAt(v, i, w)
+	switch {
+	case math.IsNaN(f) :
+		return NaInteger
+	case f >= math.MaxInt32 + 1 || f <= math.MinInt32 :
+		w.add(WarnIntegerRange)
+		return NaInteger
+	}
+	return int32(f)
+}
+
+// realAt converts the element i of the atomic vector v to a double.
+func realAt(v Value, i int, w *warnings) float64 {
+	switch x := v.(type) {
+	case *Logical :
+		if x.Data[i] == NaLogical {
+			return NaReal
+		}
+		return float64(x.Data[i])
+	case *Integer :
+		if x.Data[i] == NaInteger {
+			return NaReal
+		}
+		return float64(x.Data[i])
+	case *Real :
+		return x.Data[i]
+	case *Complex :
+		c := x.Data[i]
+		switch {
+		case math.IsNaN(real(c)) :
+			return real(c)
+		case math.IsNaN(imag(c)) :
+			return NaReal
+		case imag(c) != 0 :
+			w.add(WarnImaginary)
+		}
+		return real(c)
+	case *Character :
+		if x.IsNA(i) {
+			return NaReal
+		}
+		f, ok := parseReal(x.Data[i])
+		if !ok {
+			w.add(WarnCoercion)
+		}
+		return f
+	case *Raw :
+		return float64(x.Data[i])
+	}
+	return NaReal
+}
+
+// complexAt converts the element i of the atomic vector v to a complex number.
+func complexAt(v Value, i int, w *warnings) complex128 {
+	switch x := v.(type) {
+	case *Complex :
+		return x.Data[i]
+	case *Character :
+		if x.IsNA(i) {
+			return complex(NaReal, 0)
+		}
+		c, ok := parseComplex(x.Data[i])
+		if !ok {
+			w.add(WarnCoercion)
+		}
+		return c
+	}
+	return complex(realAt(v, i, w), 0)
+}
+
+// stringAt converts the element i of the atomic vector v, that is not NA, to a string as as.character() does: 15
+// significant digits for the doubles.
+func stringAt(v Value, i int) string {
+	switch x := v.(type) {
+	case *Logical :
+		if x.Data[i] != 0 {
+			return "TRUE"
+		}
+		return "FALSE"
+	case *Integer :
+		return strconv.Itoa(int(x.Data[i]))
+	case *Real :
+		return FormatReal(x.Data[i])
+	case *Complex :
+		re, im := real(x.Data[i]), imag(x.Data[i])
+		sign := "+"
+		if im < 0 {
+			sign, im = "-", -im
+		}
+		return FormatReal(re) + sign + FormatReal(im) + "i"
+	case *Character :
+		return x.Data[i]
+	case *Raw :
+		return fmt.Sprintf("%02x", x.Data[i])
+	}
+	return ""
+}
+
+// rawAt converts the element i of the atomic vector v to a byte, the NA and the values out of 0..255 are 0.
+func rawAt(v Value, i int, w *warnings) byte {
+	if x, ok := v.(*Raw); ok {
+		return x.Data[i]
+	}
+	k := integerAt(v, i, w)
+	if k == NaInteger || k < 0 || k > 255 {
+		w.add(WarnRaw)
+		return 0
+	}
+	return byte(k)
+}
+
+// parseReal converts a string to a double as R_strtod does: surrounding white space, decimal and hexadecimal
+// numbers, NA, NaN, Inf and infinity. A blank string is NA, any other string is NA and not ok.
+func parseReal(s string) (f float64, ok bool) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == "NA" :
+		return NaReal, true
+	case strings.Contains(s, "_") :
+		return NaReal, false
+	}
+	t := strings.TrimLeft(s, "+-")
+	if len(s) - len(t) > 1 {
+		return NaReal, false
+	}
+	if len(t) > 2 && t[0] == '0' && (t[1] == 'x' || t[1] == 'X') && !strings.ContainsAny(t, "pP") {
+		n, err := strconv.ParseUint(t[2:], 16, 64)
+		if err != nil {
+			return NaReal, false
+		}
+		if f = float64(n); s[0] == '-' {
+			f = -f
+		}
+		return f, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !strings.Contains(err.Error(), "range") {
+		return NaReal, false
+	}
+	return f, true
+}
+
+// parseComplex converts a string to a complex number: a double, or a double followed by a signed double and i.
+func parseComplex(s string) (complex128, bool) {
+	s = strings.TrimSpace(s)
+	if f, ok := parseReal(s); ok {
+		if IsNaReal(f) {
+			return complex(NaReal, 0), true
+		}
+		return complex(f, 0), true
+	}
+	if strings.HasSuffix(s, "i") {
+		for k := 1; k < len(s) - 1; k++ {
+			if (s[k] == '+' || s[k] == '-') && s[k-1] != 'e' && s[k-1] != 'E' {
+				re, ok1 := parseReal(s[:k])
+				im, ok2 := parseReal(s[k:len(s)-1])
+				if ok1 && ok2 && !IsNaReal(re) && !IsNaReal(im) {
+					return complex(re, im), true
+				}
+			}
+		}
+	}
+	return complex(NaReal, 0), false
+}
+
+// asInts returns the elements of a NULL, logical or integer vector.
+func asInts(v Value) []int32 {
+	switch x := v.(type) {
+	case *Logical :
+		return x.Data
+	case *Integer :
+		return x.Data
+	}
+	return nil
+}
+
+// asReals returns the elements of a NULL, logical, integer or double vector as doubles.
+func asReals(v Value) []float64 {
+	if x, ok := v.(*Real); ok {
+		return x.Data
+	}
+	f, _, _ := Coerce(v, REALSXP)
+	return f.(*Real).Data
+}
+
+// asComplexes returns the elements of a NULL or numeric vector as complex numbers.
+func asComplexes(v Value) []complex128 {
+	if x, ok := v.(*Complex); ok {
+		return x.Data
+	}
+	c, _, _ := Coerce(v, CPLXSXP)
+	return c.(*Complex).Data
+}
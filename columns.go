@@ -0,0 +1,290 @@
+package r
+
+import "errors"
+import "fmt"
+import "math"
+
+// ColumnType is the type of a Column, after the Apache Arrow types.
+type ColumnType int
+
+const (
+	COLUMN_BOOL ColumnType = iota
+	COLUMN_INT32
+	COLUMN_FLOAT64
+	COLUMN_STRING
+	// Int32 indices of a Dictionary of strings: a factor
+	COLUMN_DICTIONARY
+	// Int32 days since 1970-01-01: a Date
+	COLUMN_DATE32
+)
+
+var columnTypes = [...]string{
+	COLUMN_BOOL:       "bool",
+	COLUMN_INT32:      "int32",
+	COLUMN_FLOAT64:    "float64",
+	COLUMN_STRING:     "string",
+	COLUMN_DICTIONARY: "dictionary",
+	COLUMN_DATE32:     "date32",
+}
+
+func (this ColumnType) String() string {
+	if this >= 0 && int(this) < len(columnTypes) {
+		return columnTypes[this]
+	}
+	return fmt.Sprintf("ColumnType(%d)", int(this))
+}
+
+// Column is an Arrow-style column: the buffers follow the Arrow memory layout so that they can be handed to an
+// Arrow library without conversion.
+type Column struct {
+	Name      string
+	Type      ColumnType
+	Length    int
+	NullCount int
+	// Validity bitmap, least significant bit first, a set bit is a valid value; nil if there is no null
+	Validity []byte
+	// Values of COLUMN_BOOL, as a bitmap
+	Bits []byte
+	// Values of COLUMN_INT32 and COLUMN_DATE32, indices of COLUMN_DICTIONARY (0-based)
+	Int32 []int32
+	// Values of COLUMN_FLOAT64
+	Float64 []float64
+	// Values of COLUMN_STRING: the value i is Data[Offsets[i]:Offsets[i+1]]
+	Offsets []int32
+	Data    []byte
+	// Levels of COLUMN_DICTIONARY, a COLUMN_STRING without null
+	Dictionary *Column
+	// COLUMN_DICTIONARY of an ordered factor
+	Ordered bool
+}
+
+// IsValid reports whether the value i is not null.
+func (this *Column) IsValid(i int) bool {
+	return this.Validity == nil || this.Validity[i/8] & (1 << uint(i%8)) != 0
+}
+
+// String returns the value i of a COLUMN_STRING.
+func (this *Column) String(i int) string {
+	return string(this.Data[this.Offsets[i]:this.Offsets[i+1]])
+}
+
+// setNull marks the value i as null.
+func (this *Column) setNull(i int) {
+	if this.Validity == nil {
+		this.Validity = make([]byte, (this.Length + 7) / 8)
+		for j := range this.Validity {
+			this.Validity[j] = 0xff
+		}
+	}
+	this.Validity[i/8] &^= 1 << uint(i%8)
+	this.NullCount++
+}
+
+// stringColumn returns the COLUMN_STRING of the strings, na gives the nulls and may be nil.
+func stringColumn(name string, s []string, na []bool) *Column {
+	c := &Column{ Name: name, Type: COLUMN_STRING, Length: len(s), Offsets: make([]int32, 1, len(s)+1) }
+	for i, v := range s {
+		if na != nil && na[i] {
+			c.setNull(i)
+		} else {
+			c.Data = append(c.Data, v...)
+		}
+		c.Offsets = append(c.Offsets, int32(len(c.Data)))
+	}
+	return c
+}
+
+// ToColumns converts a data.frame to columns. The logical, integer, double and character columns become
+// COLUMN_BOOL, COLUMN_INT32, COLUMN_FLOAT64 and COLUMN_STRING, the factors COLUMN_DICTIONARY and the Dates
+// COLUMN_DATE32; NA becomes null. NaN, which is not NA for Arrow, stays a valid float64.
+func ToColumns(v Value) ([]*Column, error) {
+	df, err := AsDataFrame(v)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]*Column, len(df.Columns))
+	for i, x := range df.Columns {
+		if cols[i], err = toColumn(df.Names[i], x); err != nil {
+			return nil, err
+		}
+	}
+	return cols, nil
+}
+
+func toColumn(name string, v Value) (c *Column, err error) {
+	n := Length(v)
+	c = &Column{ Name: name, Length: n }
+
+	if Inherits(v, "factor") {
+		f, err := AsFactor(v)
+		if err != nil {
+			return nil, err
+		}
+		c.Type, c.Ordered = COLUMN_DICTIONARY, f.Ordered
+		c.Dictionary = stringColumn("", f.Levels, nil)
+		c.Int32 = make([]int32, n)
+		for i, code := range f.Codes {
+			if code == NaInteger {
+				c.setNull(i)
+			} else {
+				c.Int32[i] = code - 1
+			}
+		}
+		return c, nil
+	}
+	if Inherits(v, "Date") {
+		c.Type, c.Int32 = COLUMN_DATE32, make([]int32, n)
+		for i := 0; i < n; i++ {
+			var d float64
+			switch x := v.(type) {
+			case *Real :
+				d = x.Data[i]
+			case *Integer :
+				d = float64(x.Data[i])
+				if x.Data[i] == NaInteger {
+					d = NaReal
+				}
+			default:
+				return nil, fmt.Errorf("column %s: invalid Date", name)
+			}
+			if math.IsNaN(d) || math.IsInf(d, 0) {
+				c.setNull(i)
+			} else {
+				c.Int32[i] = int32(math.Floor(d))
+			}
+		}
+		return c, nil
+	}
+
+	switch x := v.(type) {
+	case *Logical :
+		c.Type, c.Bits = COLUMN_BOOL, make([]byte, (n + 7) / 8)
+		for i, b := range x.Data {
+			if b == NaLogical {
+				c.setNull(i)
+			} else if b != 0 {
+				c.Bits[i/8] |= 1 << uint(i%8)
+			}
+		}
+	case *Integer :
+		c.Type, c.Int32 = COLUMN_INT32, x.Data
+		for i, n := range x.Data {
+			if n == NaInteger {
+				c.setNull(i)
+			}
+		}
+	case *Real :
+		c.Type, c.Float64 = COLUMN_FLOAT64, x.Data
+		for i, f := range x.Data {
+			if IsNaReal(f) {
+				c.setNull(i)
+			}
+		}
+	case *Character :
+		c = stringColumn(name, x.Data, x.NA)
+	default:
+		return nil, fmt.Errorf("column %s: %s can not be converted", name, v.Type())
+	}
+	return c, nil
+}
+
+// FromColumns converts columns to a data.frame, the nulls become NA.
+func FromColumns(cols []*Column) (*List, error) {
+	names := make([]string, len(cols))
+	values := make([]Value, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+		v, err := fromColumn(c)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return NewDataFrame(names, values)
+}
+
+func fromColumn(c *Column) (Value, error) {
+	switch c.Type {
+	case COLUMN_BOOL :
+		l := &Logical{ Data: make([]int32, c.Length) }
+		for i := range l.Data {
+			switch {
+			case !c.IsValid(i) :
+				l.Data[i] = NaLogical
+			case c.Bits[i/8] & (1 << uint(i%8)) != 0 :
+				l.Data[i] = 1
+			}
+		}
+		return l, nil
+	case COLUMN_INT32, COLUMN_DATE32, COLUMN_DICTIONARY :
+		x := &Integer{ Data: make([]int32, c.Length) }
+		for i := range x.Data {
+			if c.IsValid(i) {
+				x.Data[i] = c.Int32[i]
+			} else {
+				x.Data[i] = NaInteger
+			}
+		}
+		switch c.Type {
+		case COLUMN_DATE32 :
+			d := &Real{ Data: make([]float64, c.Length) }
+			for i, n := range x.Data {
+				if n == NaInteger {
+					d.Data[i] = NaReal
+				} else {
+					d.Data[i] = float64(n)
+				}
+			}
+			d.Set("class", NewCharacter("Date"))
+			return d, nil
+		case COLUMN_DICTIONARY :
+			if c.Dictionary == nil || c.Dictionary.Type != COLUMN_STRING {
+				return nil, fmt.Errorf("column %s: dictionary of strings expected", c.Name)
+			}
+			levels := make([]string, c.Dictionary.Length)
+			for i := range levels {
+				levels[i] = c.Dictionary.String(i)
+			}
+			for i, n := range x.Data {
+				if n == NaInteger {
+					continue
+				}
+				if n < 0 || int(n) >= len(levels) {
+					return nil, fmt.Errorf("column %s: index %d out of the dictionary", c.Name, n)
+				}
+				x.Data[i] = n + 1
+			}
+			x.Set("levels", NewCharacter(levels...))
+			if c.Ordered {
+				x.Set("class", NewCharacter("ordered", "factor"))
+			} else {
+				x.Set("class", NewCharacter("factor"))
+			}
+		}
+		return x, nil
+	case COLUMN_FLOAT64 :
+		r := &Real{ Data: make([]float64, c.Length) }
+		for i := range r.Data {
+			if c.IsValid(i) {
+				r.Data[i] = c.Float64[i]
+			} else {
+				r.Data[i] = NaReal
+			}
+		}
+		return r, nil
+	case COLUMN_STRING :
+		s := &Character{ Data: make([]string, c.Length) }
+		for i := range s.Data {
+			if !c.IsValid(i) {
+				if s.NA == nil {
+					s.NA = make([]bool, c.Length)
+				}
+				s.NA[i] = true
+				continue
+			}
+			s.Data[i] = c.String(i)
+		}
+		return s, nil
+	}
+	return nil, errors.New("column " + c.Name + ": unknown type " + c.Type.String())
+}
@@ -0,0 +1,91 @@
+package r
+
+import "testing"
+import "math"
+import "strings"
+
+func TestColumns(e *testing.T) {
+	b := &Logical{ Data: []int32{ 1, 0, NaLogical } }
+	n := &Integer{ Data: []int32{ 7, NaInteger, -1 } }
+	x := &Real{ Data: []float64{ 0.5, math.NaN(), NaReal } }
+	s := &Character{ Data: []string{ "é", "", "" }, NA: []bool{ false, false, true } }
+	f := NewFactor([]string{ "b", "z", "a" }, []string{ "a", "b" })
+	d := &Real{ Data: []float64{ 0, NaReal, 19782 } }
+	d.Set("class", NewCharacter("Date"))
+	v, _ := NewDataFrame([]string{ "b", "n", "x", "s", "f", "d" }, []Value{ b, n, x, s, f, d })
+
+	cols, err := ToColumns(v)
+	if err != nil {
+		e.Fatal(err)
+	}
+	var tests = []struct {
+		typ   ColumnType
+		nulls int
+		valid string
+	}{
+		{ COLUMN_BOOL, 1, "110" },
+		{ COLUMN_INT32, 1, "101" },
+		{ COLUMN_FLOAT64, 1, "110" },
+		{ COLUMN_STRING, 1, "110" },
+		{ COLUMN_DICTIONARY, 1, "101" },
+		{ COLUMN_DATE32, 1, "101" },
+	}
+	for i, test := range tests {
+		c := cols[i]
+		valid := ""
+		for j := 0; j < c.Length; j++ {
+			if c.IsValid(j) {
+				valid += "1"
+			} else {
+				valid += "0"
+			}
+		}
+		if c.Type != test.typ || c.Length != 3 || c.NullCount != test.nulls || valid != test.valid {
+			e.Error("Test Columns[", i, "] Failed:", c.Name, c.Type, c.NullCount, valid)
+		}
+	}
+	if cols[0].Bits[0] != 1 || cols[3].String(0) != "é" || len(cols[3].Offsets) != 4 || cols[3].Offsets[3] != 2 {
+		e.Error("Test Columns Failed: buffers")
+	}
+	if cols[4].Int32[0] != 1 || cols[4].Int32[2] != 0 || cols[4].Dictionary.String(1) != "b" || cols[5].Int32[2] != 19782 {
+		e.Error("Test Columns Failed: dictionary or dates")
+	}
+
+	// Round trip, NaN stays NaN and not NA
+	w, err := FromColumns(cols)
+	if err != nil {
+		e.Fatal(err)
+	}
+	df, err := AsDataFrame(w)
+	if err != nil {
+		e.Fatal(err)
+	}
+	if strings.Join(df.Names, " ") != "b n x s f d" || df.NRow != 3 {
+		e.Error("Test Columns Failed: round trip", df.Names)
+	}
+	rx := df.Column("x").(*Real).Data
+	if rx[0] != 0.5 || !math.IsNaN(rx[1]) || IsNaReal(rx[1]) || !IsNaReal(rx[2]) {
+		e.Error("Test Columns Failed: round trip of the doubles", rx)
+	}
+	rs := df.Column("s").(*Character)
+	if rs.Data[0] != "é" || rs.IsNA(1) || !rs.IsNA(2) {
+		e.Error("Test Columns Failed: round trip of the strings", rs.Data)
+	}
+	rf, err := AsFactor(df.Column("f"))
+	if err != nil || rf.Codes[0] != 2 || rf.Codes[1] != NaInteger || strings.Join(rf.Levels, " ") != "a b" {
+		e.Error("Test Columns Failed: round trip of the factor", rf, err)
+	}
+	rb := df.Column("b").(*Logical).Data
+	if rb[0] != 1 || rb[1] != 0 || rb[2] != NaLogical || !Inherits(df.Column("d"), "Date") || !IsNA(df.Column("n"), 1) {
+		e.Error("Test Columns Failed: round trip", rb)
+	}
+
+	// Errors
+	bad, _ := NewDataFrame([]string{ "l" }, []Value{ &List{ Data: []Value{ NullValue } } })
+	if _, err = ToColumns(bad); err == nil {
+		e.Error("Test Columns Failed: no error on a list column")
+	}
+	if _, err = FromColumns([]*Column{ { Name: "f", Type: COLUMN_DICTIONARY, Length: 1, Int32: []int32{ 0 } } }); err == nil {
+		e.Error("Test Columns Failed: no error without dictionary")
+	}
+}
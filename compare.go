@@ -0,0 +1,159 @@
+package r
+
+import "errors"
+import "fmt"
+import "math"
+import "strings"
+
+
+// Collate compares two strings for the ordering comparisons <, >, <= and >=, as strcmp() in the C locale by
+// default. Set it to compare in another locale: R uses strcoll() of the collation locale.
+var Collate = strings.Compare
+
+// Compare applies the comparison operator op (==, !=, <, >, <= or >=) to x and y.
+//
+// The operands are compared as strings if one of them is a character vector, the numbers converted as by
+// as.character(), else as complex numbers, doubles or integers. A comparison with NA or NaN is NA. The result keeps
+// the names, dim and dimnames of the operands.
+func Compare(op string, x, y Value) (Value, []string, error) {
+	var w warnings
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=" :
+	default:
+		return nil, nil, fmt.Errorf("%s is not a comparison operator", op)
+	}
+	for _, v := range []Value{ x, y } {
+		switch v.Type() {
+		case NILSXP, LGLSXP, INTSXP, REALSXP, CPLXSXP, STRSXP, RAWSXP :
+		case VECSXP, EXPRSXP :
+			return nil, nil, errors.New("comparison of these types is not implemented")
+		default:
+			return nil, nil, fmt.Errorf("comparison (%s) is possible only for atomic and list types", op)
+		}
+	}
+	if Inherits(x, "factor") || Inherits(y, "factor") {
+		// the factors compare their levels for equality only
+		if op != "==" && op != "!=" {
+			return factorNA(Length(x), Length(y)), []string{ fmt.Sprintf("‘%s’ not meaningful for factors", op) }, nil
+		}
+		x, y = factorValues(x), factorValues(y)
+	}
+	dim, dimnames, err := binaryDims(x, y, &w)
+	if err != nil {
+		return nil, nil, err
+	}
+	nx, ny := Length(x), Length(y)
+	n := recycle(nx, ny, &w)
+
+	ans := &Logical{ Data: make([]int32, n) }
+	switch {
+	case x.Type() == STRSXP || y.Type() == STRSXP :
+		a, _, _ := Coerce(x, STRSXP)
+		b, _, _ := Coerce(y, STRSXP)
+		sa, sb := a.(*Character), b.(*Character)
+		for i := range ans.Data {
+			if sa.IsNA(i % nx) || sb.IsNA(i % ny) {
+				ans.Data[i] = NaLogical
+				continue
+			}
+			p, q := sa.Data[i % nx], sb.Data[i % ny]
+			c := 0
+			if p != q {
+				if c = 1; op != "==" && op != "!=" {
+					c = Collate(p, q)
+				}
+			}
+			ans.Data[i] = compared(op, c)
+		}
+	case x.Type() == CPLXSXP || y.Type() == CPLXSXP :
+		if op != "==" && op != "!=" {
+			return nil, nil, errors.New("invalid comparison with complex values")
+		}
+		a, b := asComplexes(x), asComplexes(y)
+		for i := range ans.Data {
+			p, q := a[i % nx], b[i % ny]
+			switch {
+			case isNaComplex(p) || isNaComplex(q) :
+				ans.Data[i] = NaLogical
+			case p == q :
+				ans.Data[i] = compared(op, 0)
+			default:
+				ans.Data[i] = compared(op, 1)
+			}
+		}
+	case x.Type() == REALSXP || y.Type() == REALSXP :
+		a, b := asReals(x), asReals(y)
+		for i := range ans.Data {
+			p, q := a[i % nx], b[i % ny]
+			switch {
+			case math.IsNaN(p) || math.IsNaN(q) :
+				ans.Data[i] = NaLogical
+			case p < q :
+				ans.Data[i] = compared(op, -1)
+			case p > q :
+				ans.Data[i] = compared(op, 1)
+			default:
+				ans.Data[i] = compared(op, 0)
+			}
+		}
+	default:
+		a, b := rawInts(x), rawInts(y)
+		for i := range ans.Data {
+			p, q := a[i % nx], b[i % ny]
+			switch {
+			case p == NaInteger || q == NaInteger :
+				ans.Data[i] = NaLogical
+			case p < q :
+				ans.Data[i] = compared(op, -1)
+			case p > q :
+				ans.Data[i] = compared(op, 1)
+			default:
+				ans.Data[i] = compared(op, 0)
+			}
+		}
+	}
+	setAttributes(ans, x, y, dim, dimnames, false)
+	return ans, w, nil
+}
+
+// compared returns the result of the comparison op for the order c of the operands: negative, 0 or positive.
+func compared(op string, c int) int32 {
+	switch op {
+	case "==" :
+		return boolean(c == 0)
+	case "!=" :
+		return boolean(c != 0)
+	case "<" :
+		return boolean(c < 0)
+	case ">" :
+		return boolean(c > 0)
+	case "<=" :
+		return boolean(c <= 0)
+	}
+	return boolean(c >= 0)
+}
+
+// isNaComplex reports whether a part of the complex number c is NA or NaN.
+func isNaComplex(c complex128) bool {
+	return math.IsNaN(real(c)) || math.IsNaN(imag(c))
+}
+
+// rawInts returns the elements of a NULL, raw, logical or integer vector as integers.
+func rawInts(v Value) []int32 {
+	if x, ok := v.(*Raw); ok {
+		k := make([]int32, len(x.Data))
+		for i, b := range x.Data {
+			k[i] = int32(b)
+		}
+		return k
+	}
+	return asInts(v)
+}
+
+// factorValues returns the levels of the elements of a factor, v itself if it is not a factor.
+func factorValues(v Value) Value {
+	if f, err := AsFactor(v); err == nil {
+		return f.Values()
+	}
+	return v
+}
@@ -0,0 +1,722 @@
+package r
+
+import "fmt"
+import "sort"
+
+// DefKind tells how a variable is defined.
+type DefKind int
+
+const (
+	// x <- v, x = v, v -> x and the replacements x[i] <- v, names(x) <- v, x$a <- v
+	DEF_ASSIGN DefKind = iota
+	// Formal argument, defined at the entry
+	DEF_PARAM
+	// Variable of a for loop, defined at the head of the loop
+	DEF_FOR
+	// Local variable not yet assigned, defined at the entry: R looks it up in the enclosing environments
+	DEF_UNDEFINED
+	// assign(), rm()... may define or remove any variable
+	DEF_UNKNOWN
+	// x <<- v and v ->> x: a write to an enclosing environment, not a definition of the function
+	DEF_SUPER
+)
+
+func (this DefKind) String() (s string) {
+	switch this {
+	case DEF_ASSIGN : s = "assign"
+	case DEF_PARAM : s = "param"
+	case DEF_FOR : s = "for"
+	case DEF_UNDEFINED : s = "undefined"
+	case DEF_UNKNOWN : s = "unknown"
+	case DEF_SUPER : s = "super"
+	}
+	return
+}
+
+// Def is a definition of a variable.
+type Def struct {
+	Kind DefKind
+	// Name of the variable, "" for DEF_UNKNOWN
+	Name string
+	// Symbol of the variable, nil for DEF_UNDEFINED and DEF_UNKNOWN
+	Token *Token
+	// Expression defining the variable, nil for DEF_PARAM and DEF_UNDEFINED
+	Node  Expr
+	Block *Block
+	index int
+}
+
+// Use is a read of a variable.
+type Use struct {
+	Token *Token
+	// Expression reading the variable: the node of a block
+	Node  Expr
+	Block *Block
+	// The definitions reaching the use: the def-use chain
+	Defs []*Def
+	// The read is done by a function defined in the node, at an unknown time
+	Nested bool
+}
+
+// DataflowError is a problem found by the dataflow analyses.
+type DataflowError struct {
+	Pos Position
+	Msg string
+}
+
+func (this *DataflowError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+// access is the variables defined and read by a node of a block, the uses are done before the definitions.
+type access struct {
+	defs    []*Token
+	supers  []*Token
+	uses    []*Token
+	nested  []*Token
+	// Names of the called functions, uses when they are local variables
+	calls   []*Token
+	// The node calls a function reading or writing any variable
+	unknownDefs bool
+	unknownUses bool
+	// The node is the head of a for loop
+	loop bool
+	// The node, its subexpressions evaluated by other nodes are not collected
+	node Expr
+}
+
+// unknownEffects are the functions reading (false) or writing (true) the variables by their name.
+var unknownEffects = map[string]bool{
+	"assign": true, "delayedAssign": true, "makeActiveBinding": true, "list2env": true, "rm": true, "remove": true,
+	"get": false, "get0": false, "mget": false, "exists": false, "eval": false, "evalq": false, "environment": false,
+	"sys.frame": false, "sys.function": false, "parent.frame": false, "as.list": false, "ls": false,
+}
+
+// Dataflow is the result of the dataflow analyses of a function: reaching definitions, def-use chains and live
+// variables. The calls to assign() and get() are unknown effects: they may define or read any variable.
+type Dataflow struct {
+	Function *FunctionExpr
+	CFG      *CFG
+	Defs     []*Def
+	Uses     []*Use
+	// Variables read by nested functions or written by <<- inside them
+	Escapes map[string]bool
+	access  map[Expr]*access
+	defs    map[Expr][]*Def
+	names   map[string]bool
+	// Reaching definitions and live variables at the entry of the blocks
+	reachIn map[*Block][]bool
+	liveIn  map[*Block]map[string]bool
+	liveOut map[*Block]map[string]bool
+}
+
+// NewDataflow returns the dataflow analyses of the function fn.
+func NewDataflow(fn *FunctionExpr) *Dataflow {
+	d := &Dataflow{ Function: fn, CFG: NewCFG(fn.Body), Escapes: make(map[string]bool), access: make(map[Expr]*access),
+		defs: make(map[Expr][]*Def), names: make(map[string]bool) }
+	defaults := make(map[string][]*Token)
+	for _, f := range fn.Formals {
+		if f.Default != nil {
+			a := &access{}
+			d.collect(f.Default, a)
+			defaults[f.Name.stringvalue] = append(a.uses, a.nested...)
+		}
+	}
+	entry := d.CFG.Entry
+	params := make(map[string]bool)
+	for _, f := range fn.Formals {
+		if f.Name.Type == SYMBOL {
+			params[f.Name.stringvalue] = true
+			d.addDef(&Def{ Kind: DEF_PARAM, Name: f.Name.stringvalue, Token: f.Name, Block: entry })
+		}
+	}
+	// accesses of the nodes
+	assigned := make(map[string]bool)
+	for _, b := range d.CFG.Blocks {
+		for _, x := range b.Nodes {
+			a := &access{ node: x }
+			if f, ok := x.(*ForExpr); ok {
+				a.loop = true
+				a.defs = append(a.defs, f.Var)
+			} else {
+				d.collect(x, a)
+			}
+			for _, t := range a.defs {
+				assigned[t.stringvalue] = true
+			}
+			d.access[x] = a
+		}
+	}
+	// the calls of local functions are uses, the uses of a formal force its default value
+	for _, a := range d.access {
+		var uses []*Token
+		for _, t := range a.calls {
+			if params[t.stringvalue] || assigned[t.stringvalue] {
+				uses = append(uses, t)
+			}
+		}
+		uses = append(uses, a.uses...)
+		var forced []*Token
+		for _, t := range uses {
+			forced = append(forced, defaults[t.stringvalue]...)
+		}
+		a.uses = append(forced, uses...)
+	}
+	var locals []string
+	seen := make(map[string]bool)
+	for _, b := range d.CFG.Blocks {
+		for _, x := range b.Nodes {
+			a := d.access[x]
+			for _, t := range a.supers {
+				d.addDef(&Def{ Kind: DEF_SUPER, Name: t.stringvalue, Token: t, Node: x, Block: b })
+			}
+			for _, t := range a.defs {
+				kind := DEF_ASSIGN
+				if a.loop {
+					kind = DEF_FOR
+				}
+				d.defs[x] = append(d.defs[x], d.addDef(&Def{ Kind: kind, Name: t.stringvalue, Token: t, Node: x, Block: b }))
+				if !params[t.stringvalue] && !seen[t.stringvalue] {
+					locals = append(locals, t.stringvalue)
+					seen[t.stringvalue] = true
+				}
+				d.names[t.stringvalue] = true
+			}
+			if a.unknownDefs {
+				d.defs[x] = append(d.defs[x], d.addDef(&Def{ Kind: DEF_UNKNOWN, Node: x, Block: b }))
+			}
+			for _, t := range a.uses {
+				d.names[t.stringvalue] = true
+			}
+			for _, t := range a.nested {
+				d.names[t.stringvalue] = true
+			}
+		}
+	}
+	for _, name := range locals {
+		d.addDef(&Def{ Kind: DEF_UNDEFINED, Name: name, Block: entry })
+	}
+	for name := range params {
+		d.names[name] = true
+	}
+
+	d.reaching()
+	d.liveness()
+	return d
+}
+
+func (this *Dataflow) addDef(def *Def) *Def {
+	def.index = len(this.Defs)
+	this.Defs = append(this.Defs, def)
+	return def
+}
+
+// collect adds to a the variables defined and read by x.
+func (this *Dataflow) collect(x Expr, a *access) {
+	if x != a.node && this.CFG.Evaluated[x] {
+		return
+	}
+	switch v := x.(type) {
+	case *Ident :
+		if v.Token.Type == SYMBOL {
+			a.uses = append(a.uses, v.Token)
+		}
+	case *BinaryExpr :
+		switch v.Op.Type {
+		case OP_LEFT_ASSIGN, OP_EQUAL_ASSIGN :
+			this.collect(v.Y, a)
+			this.target(v.X, a, false, false)
+		case OP_RIGHT_ASSIGN :
+			this.collect(v.X, a)
+			this.target(v.Y, a, false, false)
+		case OP_LEFT_ASSIGN2 :
+			this.collect(v.Y, a)
+			this.target(v.X, a, false, true)
+		case OP_RIGHT_ASSIGN2 :
+			this.collect(v.X, a)
+			this.target(v.Y, a, false, true)
+		case OP_DOLLAR, OP_AT :
+			this.collect(v.X, a)
+		case OP_NAMESPACE, OP_NAMESPACE_INTERNAL :
+		default:
+			this.collect(v.X, a)
+			this.collect(v.Y, a)
+		}
+	case *CallExpr :
+		if write, ok := unknownEffects[v.FunctionName()]; ok {
+			if write {
+				a.unknownDefs = true
+			} else {
+				a.unknownUses = true
+			}
+		}
+		if f, ok := v.Fun.(*Ident); ok && f.Token.Type == SYMBOL {
+			a.calls = append(a.calls, f.Token)
+		} else {
+			this.collect(v.Fun, a)
+		}
+		this.collectArgs(v.Args, a)
+	case *IndexExpr :
+		this.collect(v.X, a)
+		this.collectArgs(v.Args, a)
+	case *FunctionExpr :
+		this.nested(v, a)
+	default:
+		Inspect(x, func(n Node) bool {
+			if n == x {
+				return true
+			}
+			if e, ok := n.(Expr); ok {
+				this.collect(e, a)
+			}
+			return false
+		})
+		if f, ok := x.(*ForExpr); ok {
+			a.defs = append(a.defs, f.Var)
+		}
+	}
+}
+
+func (this *Dataflow) collectArgs(args []*Arg, a *access) {
+	for _, arg := range args {
+		if arg.Value != nil {
+			this.collect(arg.Value, a)
+		}
+	}
+}
+
+// target adds the assignment to x: a symbol, a string, or the target of a replacement which is also read.
+func (this *Dataflow) target(x Expr, a *access, replace, super bool) {
+	switch v := x.(type) {
+	case *Ident :
+		if !isSymbol(v.Token) {
+			return
+		}
+		switch {
+		case super :
+			a.supers = append(a.supers, v.Token)
+		case replace :
+			a.uses = append(a.uses, v.Token)
+			a.defs = append(a.defs, v.Token)
+		default:
+			a.defs = append(a.defs, v.Token)
+		}
+	case *Constant :
+		if v.Token.Type == CONST_CHARACTER && !super {
+			if replace {
+				a.uses = append(a.uses, v.Token)
+			}
+			a.defs = append(a.defs, v.Token)
+		}
+	case *ParenExpr :
+		this.target(v.X, a, replace, super)
+	case *CallExpr :
+		// names(x) <- v
+		if len(v.Args) > 0 && v.Args[0].Value != nil {
+			this.collectArgs(v.Args[1:], a)
+			this.target(v.Args[0].Value, a, true, super)
+		}
+	case *IndexExpr :
+		this.collectArgs(v.Args, a)
+		this.target(v.X, a, true, super)
+	case *BinaryExpr :
+		if v.Op.Type == OP_DOLLAR || v.Op.Type == OP_AT {
+			this.target(v.X, a, true, super)
+		}
+	}
+}
+
+// nested adds the variables read by the function fn defined in the node: the symbols of its body and defaults
+// other than its formals, and the variables it writes with <<-. They escape.
+func (this *Dataflow) nested(fn *FunctionExpr, a *access) {
+	formals := make(map[string]bool)
+	for _, f := range fn.Formals {
+		formals[f.Name.stringvalue] = true
+	}
+	var visit func(n Node) bool
+	visit = func(n Node) bool {
+		switch v := n.(type) {
+		case *Ident :
+			if v.Token.Type == SYMBOL && !formals[v.Token.stringvalue] {
+				a.nested = append(a.nested, v.Token)
+				this.Escapes[v.Token.stringvalue] = true
+			}
+		case *BinaryExpr :
+			switch v.Op.Type {
+			case OP_DOLLAR, OP_AT :
+				Inspect(v.X, visit)
+				return false
+			case OP_NAMESPACE, OP_NAMESPACE_INTERNAL :
+				return false
+			}
+		}
+		return true
+	}
+	Inspect(fn, visit)
+}
+
+// reaching computes the definitions reaching the entry of the blocks, and the def-use chains.
+func (this *Dataflow) reaching() {
+	n := len(this.Defs)
+	this.reachIn = make(map[*Block][]bool)
+	out := make(map[*Block][]bool)
+	for _, b := range this.CFG.Blocks {
+		this.reachIn[b], out[b] = make([]bool, n), make([]bool, n)
+	}
+	for _, def := range this.Defs {
+		if def.Kind == DEF_PARAM || def.Kind == DEF_UNDEFINED {
+			out[this.CFG.Entry][def.index] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range this.CFG.Blocks {
+			if b == this.CFG.Entry {
+				continue
+			}
+			in := make([]bool, n)
+			for _, e := range b.Preds {
+				for i, r := range out[e.From] {
+					in[i] = in[i] || r
+				}
+			}
+			this.reachIn[b] = in
+			o := this.transfer(b, in, nil)
+			for i := range o {
+				if o[i] != out[b][i] {
+					out[b], changed = o, true
+					break
+				}
+			}
+		}
+	}
+	for _, b := range this.CFG.Blocks {
+		this.transfer(b, this.reachIn[b], func(x Expr, t *Token, nested bool, reach []bool) {
+			u := &Use{ Token: t, Node: x, Block: b, Nested: nested }
+			for _, def := range this.Defs {
+				if reach[def.index] && (def.Name == t.stringvalue || def.Kind == DEF_UNKNOWN) {
+					u.Defs = append(u.Defs, def)
+				}
+			}
+			this.Uses = append(this.Uses, u)
+		})
+	}
+}
+
+// transfer returns the definitions reaching the end of the block b from the ones reaching its entry, calling use
+// for each read of a variable.
+func (this *Dataflow) transfer(b *Block, in []bool, use func(x Expr, t *Token, nested bool, reach []bool)) []bool {
+	reach := append([]bool(nil), in...)
+	for _, x := range b.Nodes {
+		a := this.access[x]
+		if use != nil {
+			for _, t := range a.uses {
+				use(x, t, false, reach)
+			}
+			for _, t := range a.nested {
+				use(x, t, true, reach)
+			}
+		}
+		for _, def := range this.defs[x] {
+			if def.Kind != DEF_UNKNOWN {
+				for _, other := range this.Defs {
+					if other.Name == def.Name && other.Kind != DEF_SUPER {
+						reach[other.index] = false
+					}
+				}
+			}
+		}
+		for _, def := range this.defs[x] {
+			reach[def.index] = true
+		}
+	}
+	return reach
+}
+
+// liveness computes the variables live at the entry and at the end of the blocks.
+func (this *Dataflow) liveness() {
+	this.liveIn = make(map[*Block]map[string]bool)
+	this.liveOut = make(map[*Block]map[string]bool)
+	for _, b := range this.CFG.Blocks {
+		this.liveIn[b], this.liveOut[b] = make(map[string]bool), make(map[string]bool)
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(this.CFG.Blocks)-1; i >= 0; i-- {
+			b := this.CFG.Blocks[i]
+			out := make(map[string]bool)
+			for _, e := range b.Succs {
+				for name := range this.liveIn[e.To] {
+					out[name] = true
+				}
+			}
+			this.liveOut[b] = out
+			in := this.backward(b, out, nil)
+			if len(in) != len(this.liveIn[b]) {
+				this.liveIn[b], changed = in, true
+			}
+		}
+	}
+}
+
+// backward returns the variables live at the entry of the block b from the ones live at its end, calling def
+// for each definition with the variables live after it.
+func (this *Dataflow) backward(b *Block, out map[string]bool, def func(d *Def, live map[string]bool)) map[string]bool {
+	live := make(map[string]bool)
+	for name := range out {
+		live[name] = true
+	}
+	for i := len(b.Nodes)-1; i >= 0; i-- {
+		x := b.Nodes[i]
+		a := this.access[x]
+		if def != nil {
+			for _, d := range this.defs[x] {
+				def(d, live)
+			}
+		}
+		for _, t := range a.defs {
+			delete(live, t.stringvalue)
+		}
+		for _, t := range a.uses {
+			live[t.stringvalue] = true
+		}
+		for _, t := range a.nested {
+			live[t.stringvalue] = true
+		}
+		if a.unknownUses {
+			for name := range this.names {
+				live[name] = true
+			}
+		}
+	}
+	return live
+}
+
+// Reaching returns the definitions reaching the entry of the block b.
+func (this *Dataflow) Reaching(b *Block) (defs []*Def) {
+	for _, def := range this.Defs {
+		if this.reachIn[b][def.index] {
+			defs = append(defs, def)
+		}
+	}
+	return
+}
+
+// LiveIn returns the variables live at the entry of the block b, sorted.
+func (this *Dataflow) LiveIn(b *Block) []string {
+	return sortedNames(this.liveIn[b])
+}
+
+// LiveOut returns the variables live at the end of the block b, sorted.
+func (this *Dataflow) LiveOut(b *Block) []string {
+	return sortedNames(this.liveOut[b])
+}
+
+func sortedNames(set map[string]bool) (names []string) {
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// DefUses returns the uses reached by the definition def: the use-def chains reversed.
+func (this *Dataflow) DefUses(def *Def) (uses []*Use) {
+	for _, u := range this.Uses {
+		for _, d := range u.Defs {
+			if d == def {
+				uses = append(uses, u)
+				break
+			}
+		}
+	}
+	return
+}
+
+// DeadStores returns the assignments whose value is read on no path: the variable is not live after them. The
+// variables read by nested functions are never dead, neither is the last assignment of the function, whose value
+// is the value of the call.
+func (this *Dataflow) DeadStores() (defs []*Def) {
+	for _, b := range this.CFG.Blocks {
+		last := len(b.Nodes) > 0 && this.returns(b)
+		this.backward(b, this.liveOut[b], func(d *Def, live map[string]bool) {
+			if d.Kind != DEF_ASSIGN || live[d.Name] || this.Escapes[d.Name] || (last && d.Node == b.Nodes[len(b.Nodes)-1]) {
+				return
+			}
+			defs = append(defs, d)
+		})
+	}
+	sort.SliceStable(defs, func(i, j int) bool { return defs[i].Token.offset < defs[j].Token.offset })
+	return
+}
+
+// returns reports whether the value of the last node of the block b is the value of the function.
+func (this *Dataflow) returns(b *Block) bool {
+	for _, e := range b.Succs {
+		if e.To == this.CFG.Exit && (e.Kind == EDGE_NORMAL || e.Kind == EDGE_RETURN) {
+			return true
+		}
+		if e.To.Label == "on.exit" {
+			return true
+		}
+	}
+	return false
+}
+
+// UsedBeforeAssignment returns the uses of local variables that are not assigned on some path from the entry:
+// R then looks the variable up in the enclosing environments. The reads done by nested functions and the ones an
+// assign() may define are not reported.
+func (this *Dataflow) UsedBeforeAssignment() (uses []*Use) {
+	for _, u := range this.Uses {
+		if u.Nested {
+			continue
+		}
+		undefined, unknown := false, false
+		for _, d := range u.Defs {
+			switch d.Kind {
+			case DEF_UNDEFINED : undefined = true
+			case DEF_UNKNOWN : unknown = true
+			}
+		}
+		if undefined && !unknown {
+			uses = append(uses, u)
+		}
+	}
+	return
+}
+
+// pureFunctions are functions without side effects, whose calls can be moved out of a loop.
+var pureFunctions = map[string]bool{
+	"c": true, "list": true, "length": true, "nrow": true, "ncol": true, "dim": true, "names": true,
+	"colnames": true, "rownames": true, "sqrt": true, "exp": true, "log": true, "abs": true, "sum": true,
+	"mean": true, "max": true, "min": true, "range": true, "round": true, "seq": true, "seq_len": true,
+	"seq_along": true, "rep": true, "paste": true, "paste0": true, "sprintf": true, "nchar": true,
+	"toupper": true, "tolower": true, "matrix": true, "t": true, "is.null": true, "is.na": true, "unique": true,
+	"sort": true, "rev": true, "which": true, "numeric": true, "character": true, "integer": true,
+	"logical": true, "vector": true,
+}
+
+// LoopInvariants returns the assignments inside loops that compute the same value at each iteration: a variable
+// assigned once in the loop, from an expression of operators and pure function calls reading only variables not
+// assigned in the loop.
+func (this *Dataflow) LoopInvariants() (defs []*Def) {
+	for _, head := range this.CFG.Blocks {
+		var tails []*Block
+		for _, e := range head.Preds {
+			if e.Kind == EDGE_LOOP || e.Kind == EDGE_NEXT {
+				tails = append(tails, e.From)
+			}
+		}
+		if len(tails) == 0 {
+			continue
+		}
+		// the natural loop: the blocks reaching a tail without going through the head
+		body := map[*Block]bool{ head: true }
+		for len(tails) > 0 {
+			b := tails[len(tails)-1]
+			tails = tails[:len(tails)-1]
+			if body[b] {
+				continue
+			}
+			body[b] = true
+			for _, e := range b.Preds {
+				tails = append(tails, e.From)
+			}
+		}
+		assigned := make(map[string]int)
+		for _, def := range this.Defs {
+			if body[def.Block] && def.Node != nil {
+				assigned[def.Name]++
+			}
+		}
+		for _, def := range this.Defs {
+			if def.Kind != DEF_ASSIGN || !body[def.Block] || assigned[def.Name] != 1 || assigned[""] > 0 || this.Escapes[def.Name] {
+				continue
+			}
+			if this.invariant(def, body) {
+				defs = append(defs, def)
+			}
+		}
+	}
+	sort.SliceStable(defs, func(i, j int) bool { return defs[i].Token.offset < defs[j].Token.offset })
+	return
+}
+
+// invariant reports whether the value of the assignment def is the same at each iteration of the loop body.
+func (this *Dataflow) invariant(def *Def, body map[*Block]bool) bool {
+	b, ok := def.Node.(*BinaryExpr)
+	if !ok {
+		return false
+	}
+	target, value := b.X, b.Y
+	if b.Op.Type == OP_RIGHT_ASSIGN {
+		target, value = b.Y, b.X
+	}
+	if _, ok := target.(*Ident); !ok {
+		return false
+	}
+	switch value.(type) {
+	case *Constant, *Ident, *FunctionExpr :
+		return false
+	}
+	pure := true
+	Inspect(value, func(n Node) bool {
+		switch v := n.(type) {
+		case *CallExpr :
+			pure = pure && pureFunctions[v.FunctionName()]
+		case *FunctionExpr, *BlockExpr, *IfExpr, *ForExpr, *WhileExpr, *RepeatExpr :
+			pure = false
+		case *BinaryExpr :
+			switch v.Op.Type {
+			case OP_LEFT_ASSIGN, OP_LEFT_ASSIGN2, OP_EQUAL_ASSIGN, OP_RIGHT_ASSIGN, OP_RIGHT_ASSIGN2 :
+				pure = false
+			}
+		}
+		return pure
+	})
+	if !pure {
+		return false
+	}
+	for _, u := range this.Uses {
+		if u.Node != def.Node {
+			continue
+		}
+		for _, d := range u.Defs {
+			if body[d.Block] && d.Node != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// CheckDataflow returns the problems found by the dataflow analyses of the functions of the file: the dead
+// assignments, the local variables used before their assignment on some path, and the loop invariant
+// computations.
+func CheckDataflow(f *File) (errs []*DataflowError) {
+	for _, x := range f.Exprs {
+		Inspect(x, func(n Node) bool {
+			fn, ok := n.(*FunctionExpr)
+			if !ok {
+				return true
+			}
+			d := NewDataflow(fn)
+			for _, def := range d.DeadStores() {
+				errs = append(errs, &DataflowError{ def.Token.Pos(), "value assigned to " + def.Name + " is never used" })
+			}
+			seen := make(map[*Token]bool)
+			for _, u := range d.UsedBeforeAssignment() {
+				if !seen[u.Token] {
+					seen[u.Token] = true
+					errs = append(errs, &DataflowError{ u.Token.Pos(), u.Token.stringvalue + " may be used before assignment" })
+				}
+			}
+			for _, def := range d.LoopInvariants() {
+				errs = append(errs, &DataflowError{ def.Token.Pos(), "loop invariant computation of " + def.Name })
+			}
+			return true
+		})
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Pos.Offset < errs[j].Pos.Offset })
+	return
+}
@@ -0,0 +1,108 @@
+package r
+
+import "strings"
+import "testing"
+
+// dataflow returns the dataflow analyses of the function src.
+func dataflow(e *testing.T, src string) *Dataflow {
+	x, err := ParseExpr(src)
+	if err != nil {
+		e.Fatal(err)
+	}
+	return NewDataflow(x.(*FunctionExpr))
+}
+
+func TestDataflowChains(e *testing.T) {
+	tests := []struct {
+		src    string
+		chains string
+	}{
+		{ "function(x) { y <- x; y <- y + 1; y }", "x:param y:assign y:assign" },
+		{ "function(x) { if (x) y <- 1 else y <- 2; y }", "x:param y:assign,assign" },
+		{ "function(x) { if (x) y <- 1; y }", "x:param y:assign,undefined" },
+		{ "function(x) { for (i in x) s <- i; s }", "x:param i:for s:assign,undefined" },
+		{ "function(x) { x[1] <- 0; names(x) <- 'a'; x$b <- 2; x }", "x:param x:assign x:assign x:assign" },
+		{ "function() { y <- 1; assign('y', 2); y }", "y:assign,unknown" },
+		{ "function() { n <<- 1; n }", "n:" },
+		{ "function(x, n = length(x)) n", "x:param n:param" },
+		// the value of the assignment is read in its own blocks, the assignment only defines z
+		{ "function(a) { z <- if (a) 1 else stop('no'); z }", "a:param z:assign" },
+		{ "function(x) { y <- f(x, if (x) 1 else 2); y }", "x:param x:param y:assign" },
+	}
+	for i, test := range tests {
+		d := dataflow(e, test.src)
+		var chains []string
+		for _, u := range d.Uses {
+			var kinds []string
+			for _, def := range u.Defs {
+				kinds = append(kinds, def.Kind.String())
+			}
+			chains = append(chains, u.Token.Value() + ":" + strings.Join(kinds, ","))
+		}
+		if strings.Join(chains, " ") != test.chains {
+			e.Error("Test DataflowChains[", i, "] Failed:", strings.Join(chains, " "))
+		}
+	}
+
+	d := dataflow(e, "function(x) { y <- x; f(y); y }")
+	if uses := d.DefUses(d.Defs[1]); len(uses) != 2 || uses[0].Token.Pos().Column != 25 {
+		e.Error("Test DataflowChains Failed: def-use", len(uses))
+	}
+	if d.Defs[2].Kind != DEF_UNDEFINED || len(d.DefUses(d.Defs[2])) != 0 {
+		e.Error("Test DataflowChains Failed: undefined")
+	}
+}
+
+func TestDataflowLiveness(e *testing.T) {
+	d := dataflow(e, "function(x) { y <- 1; if (x) z <- y else z <- 2; z }")
+	var live []string
+	for _, b := range d.CFG.Blocks {
+		live = append(live, b.Label + ":" + strings.Join(d.LiveIn(b), ",") + "/" + strings.Join(d.LiveOut(b), ","))
+	}
+	if strings.Join(live, " ") != "entry:x/x exit:/ body:x/y if.then:y/z if.after:z/ if.else:/z" {
+		e.Error("Test DataflowLiveness Failed:", strings.Join(live, " "))
+	}
+	d = dataflow(e, "function(x) { get('y'); y <- 1; y }")
+	if strings.Join(d.LiveOut(d.CFG.Entry), ",") != "x,y" {
+		e.Error("Test DataflowLiveness Failed: get", d.LiveOut(d.CFG.Entry))
+	}
+	if len(d.Reaching(d.CFG.Exit)) != 2 {
+		e.Error("Test DataflowLiveness Failed: reaching", len(d.Reaching(d.CFG.Exit)))
+	}
+}
+
+func TestCheckDataflow(e *testing.T) {
+	tests := []struct {
+		src    string
+		errors string
+	}{
+		{ "f <- function(x) { y <- 1; y <- x; y }", "1:20: value assigned to y is never used;" },
+		{ "f <- function(x) { y <- 1; g <- function() y; y <- 2; g() }", "" },
+		{ "f <- function(x) { y <- 1; get(\"y\") }", "" },
+		{ "f <- function(x) y <- x", "" },
+		{ "f <- function() { n <<- 1; n <- 2 }", "" },
+		{ "f <- function(x) { if (x) y <- 1; y }", "1:35: y may be used before assignment;" },
+		{ "f <- function(a) { repeat { if (a) break; z <- 1 }; z }", "1:53: z may be used before assignment;" },
+		{ "f <- function(a) { while (TRUE) { if (a) break; z <- 1 }; z }", "1:59: z may be used before assignment;" },
+		{ "f <- function(x) { if (x) assign('y', 1); y }", "" },
+		{ "f <- function(x) { for (i in x) { n <- length(x) * 2; print(i + n) } }", "1:35: loop invariant computation of n;" },
+		{ "f <- function(x) { for (i in x) { n <- i * 2; print(n) } }", "" },
+		{ "f <- function(x) { while (x > 0) { v <- c(x); x <- x - 1; print(v) } }", "" },
+		{ "f <- function(x) { for (i in x) { s <- numeric(0); s <- c(s, i) } ; s }", "1:69: s may be used before assignment;" },
+		{ "f <- function(x) { for (i in x) { n <- g(x); print(n) } }", "" },
+		{ "f <- function(x) { z <- 1 ; function() { w <- 2; x } }", "1:20: value assigned to z is never used;1:42: value assigned to w is never used;" },
+	}
+	for i, test := range tests {
+		f, err := ParseFile(strings.NewReader(test.src))
+		if err != nil {
+			e.Fatal(err)
+		}
+		var errors string
+		for _, err := range CheckDataflow(f) {
+			errors += err.Error() + ";"
+		}
+		if errors != test.errors {
+			e.Error("Test CheckDataflow[", i, "] Failed:", errors)
+		}
+	}
+}
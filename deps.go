@@ -0,0 +1,314 @@
+package r
+
+import "io"
+import "os"
+import "sort"
+import "encoding/json"
+
+// DependencyKind tells how a package is referenced by R code.
+type DependencyKind string
+
+// The list of dependency kinds.
+const (
+	DEPENDENCY_LIBRARY            DependencyKind = "library"          // library(pkg)
+	DEPENDENCY_REQUIRE            DependencyKind = "require"          // require(pkg)
+	DEPENDENCY_REQUIRE_NAMESPACE  DependencyKind = "requireNamespace" // requireNamespace("pkg")
+	DEPENDENCY_LOAD_NAMESPACE     DependencyKind = "loadNamespace"    // loadNamespace("pkg")
+	DEPENDENCY_NAMESPACE          DependencyKind = "::"               // pkg::fn
+	DEPENDENCY_NAMESPACE_INTERNAL DependencyKind = ":::"              // pkg:::fn
+	DEPENDENCY_BOX_USE            DependencyKind = "box::use"         // box::use(pkg, pkg[fn])
+	DEPENDENCY_IMPORT_FROM        DependencyKind = "import::from"     // import::from(pkg, fn)
+)
+
+// Dependency is a single reference to a package found in R code.
+type Dependency struct {
+	// Name of the package
+	Package string `json:"package"`
+	// How the package is referenced
+	Kind DependencyKind `json:"kind"`
+	// Name of the function for pkg::fn and pkg:::fn
+	Function string `json:"function,omitempty"`
+	// Text editor position of the reference
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// FileDependencies is the list of package references found in one file.
+type FileDependencies struct {
+	File         string       `json:"file"`
+	Dependencies []Dependency `json:"dependencies"`
+	Packages     []string     `json:"packages"`
+}
+
+// DependencyReport aggregates the package references of several files.
+type DependencyReport struct {
+	Files []*FileDependencies `json:"files"`
+	// Sorted list of all the packages referenced by the files
+	Packages []string `json:"packages"`
+	// Sorted list of the packages used with :: or ::: that are not declared in Imports (see CheckImports)
+	MissingImports []string `json:"missing_imports,omitempty"`
+}
+
+// ExtractDependencies returns all the package references found in the R code read from r, in source order.
+func ExtractDependencies(r io.Reader) (deps []Dependency) {
+	var tokens []*Token
+
+	// Keep only the significant tokens: newlines, comments and lexical errors do not change the shape of a reference
+	s := NewScanner(r)
+	for {
+		t := s.NextToken()
+		if t.Type == END_OF_INPUT {
+			break
+		}
+		if t.Type != END_OF_LINE && t.Type != COMMENT && t.Type != LINE_DIRECTIVE && t.Type != ERROR {
+			tokens = append(tokens, t)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if !isPackageName(t) {
+			continue
+		}
+
+		// pkg::fn or pkg:::fn
+		if i+2 < len(tokens) && (tokens[i+1].Type == OP_NAMESPACE || tokens[i+1].Type == OP_NAMESPACE_INTERNAL) && isPackageName(tokens[i+2]) {
+			d := Dependency{ Package: t.stringvalue, Kind: DEPENDENCY_NAMESPACE, Function: tokens[i+2].stringvalue, Line: t.nline, Column: t.ncol }
+			if tokens[i+1].Type == OP_NAMESPACE_INTERNAL {
+				d.Kind = DEPENDENCY_NAMESPACE_INTERNAL
+			}
+			deps = append(deps, d)
+
+			// box::use(...) and import::from(...) reference other packages in their arguments
+			if i+3 < len(tokens) && tokens[i+3].Type == OP_LEFT_ROUND {
+				args := splitArguments(tokens, i+3)
+				if t.stringvalue == "box" && d.Function == "use" {
+					deps = append(deps, boxDependencies(args)...)
+				} else if t.stringvalue == "import" && (d.Function == "from" || d.Function == "here" || d.Function == "into") {
+					deps = append(deps, importDependencies(d.Function, args)...)
+				}
+			}
+			i += 2
+			continue
+		}
+
+		// library(pkg), require(pkg), requireNamespace("pkg") and loadNamespace("pkg")
+		if t.Type != SYMBOL || i+1 >= len(tokens) || tokens[i+1].Type != OP_LEFT_ROUND {
+			continue
+		}
+		if i > 0 && (tokens[i-1].Type == OP_DOLLAR || tokens[i-1].Type == OP_AT) {
+			continue
+		}
+		var kind DependencyKind
+		switch t.stringvalue {
+		case "library" : kind = DEPENDENCY_LIBRARY
+		case "require" : kind = DEPENDENCY_REQUIRE
+		case "requireNamespace" : kind = DEPENDENCY_REQUIRE_NAMESPACE
+		case "loadNamespace" : kind = DEPENDENCY_LOAD_NAMESPACE
+		default:
+			continue
+		}
+		if p := packageArgument(kind, splitArguments(tokens, i+1)); p != nil {
+			deps = append(deps, Dependency{ Package: p.stringvalue, Kind: kind, Line: p.nline, Column: p.ncol })
+		}
+	}
+	return
+}
+
+// isPackageName reports whether the token can be the name of a package: a symbol or a string.
+func isPackageName(t *Token) bool {
+	return t.Type == SYMBOL || t.Type == CONST_CHARACTER
+}
+
+// argument is the list of tokens of one argument of a call, split in its optional name and its value.
+type argument struct {
+	name  *Token
+	value []*Token
+}
+
+// splitArguments returns the arguments of the call whose opening parenthesis is tokens[open].
+func splitArguments(tokens []*Token, open int) (args []argument) {
+	var current []*Token
+	var depth int
+
+	for i := open + 1; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.Type {
+		case OP_LEFT_ROUND, OP_LEFT_SQUARE, OP_LEFT_SQUARE2, OP_LEFT_CURLY :
+			depth++
+		case OP_RIGHT_ROUND, OP_RIGHT_SQUARE, OP_RIGHT_CURLY :
+			if depth == 0 {
+				if len(current) > 0 || len(args) > 0 {
+					args = append(args, newArgument(current))
+				}
+				return
+			}
+			depth--
+		case OP_COMMA :
+			if depth == 0 {
+				args = append(args, newArgument(current))
+				current = nil
+				continue
+			}
+		}
+		current = append(current, t)
+	}
+	// unbalanced parenthesis: keep what has been read
+	if len(current) > 0 {
+		args = append(args, newArgument(current))
+	}
+	return
+}
+
+func newArgument(tokens []*Token) (a argument) {
+	if len(tokens) >= 2 && (tokens[0].Type == SYMBOL || tokens[0].Type == CONST_CHARACTER) && tokens[1].Type == OP_EQUAL_ASSIGN {
+		a.name = tokens[0]
+		a.value = tokens[2:]
+	} else {
+		a.value = tokens
+	}
+	return
+}
+
+// single returns the value of the argument if it is made of a single token of one of the given types.
+func (this argument) single(types ...TokenType) *Token {
+	if len(this.value) != 1 {
+		return nil
+	}
+	for _, tt := range types {
+		if this.value[0].Type == tt {
+			return this.value[0]
+		}
+	}
+	return nil
+}
+
+// packageArgument returns the token naming the package in a call to library, require, requireNamespace or loadNamespace.
+func packageArgument(kind DependencyKind, args []argument) (p *Token) {
+	var characterOnly bool
+	var positional []argument
+
+	for _, a := range args {
+		if a.name == nil {
+			positional = append(positional, a)
+			continue
+		}
+		switch a.name.stringvalue {
+		case "package" :
+			p = a.single(SYMBOL, CONST_CHARACTER)
+		case "character.only" :
+			characterOnly = a.single(CONST_TRUE) != nil
+		}
+	}
+	if p == nil && len(positional) > 0 {
+		p = positional[0].single(SYMBOL, CONST_CHARACTER)
+	}
+
+	// library and require quote a symbol argument unless character.only = TRUE, the others evaluate it
+	if p != nil && p.Type == SYMBOL && (characterOnly || (kind != DEPENDENCY_LIBRARY && kind != DEPENDENCY_REQUIRE)) {
+		p = nil
+	}
+	return
+}
+
+// boxDependencies returns the packages of box::use(pkg, alias = pkg, pkg[fn, ...]), local modules like ./mod or a/b are ignored.
+func boxDependencies(args []argument) (deps []Dependency) {
+	for _, a := range args {
+		if len(a.value) == 0 || !isPackageName(a.value[0]) {
+			continue
+		}
+		if len(a.value) > 1 && a.value[1].Type != OP_LEFT_SQUARE {
+			continue
+		}
+		t := a.value[0]
+		deps = append(deps, Dependency{ Package: t.stringvalue, Kind: DEPENDENCY_BOX_USE, Line: t.nline, Column: t.ncol })
+	}
+	return
+}
+
+// importDependencies returns the package of import::from(pkg, ...), import::here(pkg, ...) or import::into(env, ..., .from = pkg).
+func importDependencies(function string, args []argument) (deps []Dependency) {
+	var p *Token
+
+	for _, a := range args {
+		if a.name != nil && a.name.stringvalue == ".from" {
+			p = a.single(SYMBOL, CONST_CHARACTER)
+		}
+	}
+	if p == nil && function != "into" {
+		for _, a := range args {
+			if a.name == nil {
+				p = a.single(SYMBOL, CONST_CHARACTER)
+				break
+			}
+		}
+	}
+	if p != nil {
+		deps = append(deps, Dependency{ Package: p.stringvalue, Kind: DEPENDENCY_IMPORT_FROM, Line: p.nline, Column: p.ncol })
+	}
+	return
+}
+
+// packageNames returns the sorted list of distinct packages of deps.
+func packageNames(deps []Dependency) (names []string) {
+	seen := make(map[string]bool)
+	for _, d := range deps {
+		if !seen[d.Package] {
+			seen[d.Package] = true
+			names = append(names, d.Package)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// Add extracts the package references of the R code read from r and adds them to the report under the given file name.
+func (this *DependencyReport) Add(file string, r io.Reader) {
+	f := new(FileDependencies)
+	f.File = file
+	f.Dependencies = ExtractDependencies(r)
+	f.Packages = packageNames(f.Dependencies)
+	this.Files = append(this.Files, f)
+
+	var all []Dependency
+	for _, f := range this.Files {
+		all = append(all, f.Dependencies...)
+	}
+	this.Packages = packageNames(all)
+}
+
+// AddFile opens the R file at path and adds its package references to the report.
+func (this *DependencyReport) AddFile(path string) (err error) {
+	var f *os.File
+
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	this.Add(path, f)
+	return
+}
+
+// CheckImports sets MissingImports to the packages used with :: or ::: that are not in the imports list
+// (typically the Imports field of the DESCRIPTION file). The base package is always available.
+func (this *DependencyReport) CheckImports(imports []string) {
+	declared := make(map[string]bool)
+	for _, p := range imports {
+		declared[p] = true
+	}
+
+	var missing []Dependency
+	for _, f := range this.Files {
+		for _, d := range f.Dependencies {
+			if (d.Kind == DEPENDENCY_NAMESPACE || d.Kind == DEPENDENCY_NAMESPACE_INTERNAL) && d.Package != "base" && !declared[d.Package] {
+				missing = append(missing, d)
+			}
+		}
+	}
+	this.MissingImports = packageNames(missing)
+}
+
+// JSON returns the indented JSON encoding of the report.
+func (this *DependencyReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(this, "", "  ")
+}
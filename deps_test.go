@@ -0,0 +1,54 @@
+package r
+
+import "testing"
+import "strings"
+
+func TestExtractDependencies(e *testing.T) {
+	var str = `# library(commented)
+library(dplyr)
+suppressPackageStartupMessages(require("ggplot2", quietly = TRUE))
+library(pkg, character.only = TRUE)
+if (requireNamespace('data.table', quietly = TRUE)) loadNamespace(name)
+x <- stats::median(y) + utils:::head.default(z)
+obj$library(notapackage)
+box::use(purrr[map, reduce], rl = rlang, ./local/module)
+import::from(magrittr, "%>%", "%<>%")
+library(package = "tidyr")
+base::paste`
+	var tests []Dependency = []Dependency{
+		{ "dplyr", DEPENDENCY_LIBRARY, "", 2, 9 },
+		{ "ggplot2", DEPENDENCY_REQUIRE, "", 3, 40 },
+		{ "data.table", DEPENDENCY_REQUIRE_NAMESPACE, "", 5, 22 },
+		{ "stats", DEPENDENCY_NAMESPACE, "median", 6, 6 },
+		{ "utils", DEPENDENCY_NAMESPACE_INTERNAL, "head.default", 6, 25 },
+		{ "box", DEPENDENCY_NAMESPACE, "use", 8, 1 },
+		{ "purrr", DEPENDENCY_BOX_USE, "", 8, 10 },
+		{ "rlang", DEPENDENCY_BOX_USE, "", 8, 35 },
+		{ "import", DEPENDENCY_NAMESPACE, "from", 9, 1 },
+		{ "magrittr", DEPENDENCY_IMPORT_FROM, "", 9, 14 },
+		{ "tidyr", DEPENDENCY_LIBRARY, "", 10, 19 },
+		{ "base", DEPENDENCY_NAMESPACE, "paste", 11, 1 },
+	}
+
+	deps := ExtractDependencies(strings.NewReader(str))
+	if len(deps) != len(tests) { e.Error("Test Dependencies count Failed", len(deps), deps) }
+	for i := 0; i < len(tests) && i < len(deps); i++ {
+		if deps[i] != tests[i] { e.Error("Test Dependencies[", i, "] Failed", deps[i]) }
+	}
+}
+
+func TestDependencyReport(e *testing.T) {
+	var report DependencyReport
+
+	report.Add("a.R", strings.NewReader("library(dplyr)\nstats::sd(x)"))
+	report.Add("b.R", strings.NewReader("rlang::abort('x')\nbase::paste0('a')\nstats::var(y)\n"))
+	report.CheckImports([]string{ "dplyr", "stats" })
+
+	if strings.Join(report.Packages, ",") != "base,dplyr,rlang,stats" { e.Error("Test Report Packages Failed", report.Packages) }
+	if strings.Join(report.Files[1].Packages, ",") != "base,rlang,stats" { e.Error("Test Report File Packages Failed", report.Files[1].Packages) }
+	if strings.Join(report.MissingImports, ",") != "rlang" { e.Error("Test Report MissingImports Failed", report.MissingImports) }
+
+	b, err := report.JSON()
+	if err != nil { e.Error("Test Report JSON Failed", err) }
+	if !strings.Contains(string(b), `"missing_imports": [`) || !strings.Contains(string(b), `"file": "b.R"`) { e.Error("Test Report JSON content Failed", string(b)) }
+}
@@ -0,0 +1,292 @@
+package r
+
+import "io"
+import "bufio"
+import "errors"
+import "fmt"
+import "strings"
+
+// Description is the content of the DESCRIPTION file of an R package.
+type Description struct {
+	// All the fields of the file, continuation lines are joined with a newline
+	Fields map[string]string
+	// Names of the fields in file order
+	Names []string
+	// Most used fields
+	Package string
+	Version string
+	Title   string
+	License string
+	// Package dependencies
+	Depends   []Requirement
+	Imports   []Requirement
+	LinkingTo []Requirement
+	Suggests  []Requirement
+	Enhances  []Requirement
+	// Persons of the Authors@R field
+	Authors []Person
+}
+
+// Requirement is a package name with an optional version constraint: dplyr (>= 1.0.0)
+type Requirement struct {
+	Package string
+	// Comparison operator of the version constraint: >= > == <= < or "" if there is no constraint
+	Operator string
+	Version  string
+}
+
+// Person is an author of a package, as built by the person() function of the utils package.
+type Person struct {
+	Given  string
+	Family string
+	Email  string
+	Roles  []string
+	// Comments indexed by name, an unnamed comment has the key ""
+	Comment map[string]string
+}
+
+// ParseDESCRIPTION parses the Debian Control File format of a DESCRIPTION file.
+func ParseDESCRIPTION(r io.Reader) (d *Description, err error) {
+	var name string
+
+	d = new(Description)
+	d.Fields = make(map[string]string)
+
+	s := bufio.NewScanner(r)
+	for nline := 1; s.Scan(); nline++ {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			// A blank line ends the record
+			if len(d.Names) > 0 {
+				break
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// Continuation line of the previous field, a single "." stands for an empty line
+			if name == "" {
+				return nil, fmt.Errorf("DESCRIPTION line %d: continuation line without field", nline)
+			}
+			line = strings.TrimSpace(line)
+			if line == "." {
+				line = ""
+			}
+			d.Fields[name] += "\n" + line
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("DESCRIPTION line %d: malformed field", nline)
+		}
+		name = line[:i]
+		if _, ok := d.Fields[name]; !ok {
+			d.Names = append(d.Names, name)
+		}
+		d.Fields[name] = strings.TrimSpace(line[i+1:])
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+
+	d.Package = d.Fields["Package"]
+	d.Version = d.Fields["Version"]
+	d.Title = d.Fields["Title"]
+	d.License = d.Fields["License"]
+
+	if d.Depends, err = ParseRequirements(d.Fields["Depends"]); err != nil {
+		return nil, err
+	}
+	if d.Imports, err = ParseRequirements(d.Fields["Imports"]); err != nil {
+		return nil, err
+	}
+	if d.LinkingTo, err = ParseRequirements(d.Fields["LinkingTo"]); err != nil {
+		return nil, err
+	}
+	if d.Suggests, err = ParseRequirements(d.Fields["Suggests"]); err != nil {
+		return nil, err
+	}
+	if d.Enhances, err = ParseRequirements(d.Fields["Enhances"]); err != nil {
+		return nil, err
+	}
+	if a, ok := d.Fields["Authors@R"]; ok {
+		if d.Authors, err = ParseAuthors(a); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// ImportedPackages returns the names of the packages of the Imports field.
+func (this *Description) ImportedPackages() (names []string) {
+	for _, r := range this.Imports {
+		names = append(names, r.Package)
+	}
+	return
+}
+
+// ParseRequirements parses a comma separated list of packages with optional version constraints: R (>= 3.5.0), dplyr, rlang (>= 1.0)
+func ParseRequirements(field string) (reqs []Requirement, err error) {
+	for _, item := range strings.Split(field, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r Requirement
+		if i := strings.IndexByte(item, '('); i < 0 {
+			r.Package = item
+		} else {
+			j := strings.IndexByte(item, ')')
+			if j < i {
+				return nil, errors.New("malformed version constraint: " + item)
+			}
+			r.Package = strings.TrimSpace(item[:i])
+			c := strings.TrimSpace(item[i+1:j])
+			for _, op := range []string{ ">=", "<=", "==", ">", "<" } {
+				if strings.HasPrefix(c, op) {
+					r.Operator = op
+					r.Version = strings.TrimSpace(c[len(op):])
+					break
+				}
+			}
+			if r.Operator == "" || r.Version == "" {
+				return nil, errors.New("malformed version constraint: " + item)
+			}
+		}
+		if strings.ContainsAny(r.Package, " \t\n") {
+			return nil, errors.New("malformed package name: " + item)
+		}
+		reqs = append(reqs, r)
+	}
+	return
+}
+
+// ParseAuthors evaluates the R expression of an Authors@R field: a call to person() or a c() of calls to person().
+func ParseAuthors(field string) (persons []Person, err error) {
+	var x Expr
+
+	if x, err = ParseExpr(field); err != nil {
+		return nil, errors.New("Authors@R: " + err.Error())
+	}
+	return evalPersons(x)
+}
+
+func unsupportedAuthor(x Expr) error {
+	p := x.Pos()
+	return fmt.Errorf("Authors@R: %d:%d: unsupported expression", p.Line, p.Column)
+}
+
+// evalPersons evaluates person(...), c(...) and NULL.
+func evalPersons(x Expr) (persons []Person, err error) {
+	var p []Person
+
+	switch n := x.(type) {
+	case *ParenExpr :
+		return evalPersons(n.X)
+	case *Constant :
+		if n.Token.Type == CONST_NULL {
+			return
+		}
+	case *CallExpr :
+		switch n.FunctionName() {
+		case "c" :
+			for _, a := range n.Args {
+				if a.Value == nil {
+					continue
+				}
+				if p, err = evalPersons(a.Value); err != nil {
+					return
+				}
+				persons = append(persons, p...)
+			}
+			return
+		case "person", "as.person" :
+			return evalPerson(n)
+		}
+	}
+	return nil, unsupportedAuthor(x)
+}
+
+// personFormals are the formals of utils::person().
+var personFormals = func() []*Formal {
+	x, _ := ParseExpr("function(given = NULL, family = NULL, middle = NULL, email = NULL, role = NULL, comment = NULL, first, last) NULL")
+	return x.(*FunctionExpr).Formals
+}()
+
+// evalPerson evaluates a call to person(given, family, middle, email, role, comment, first, last), its arguments
+// matched to the formals as R does: by name first, then by position.
+func evalPerson(c *CallExpr) (persons []Person, err error) {
+	var values = make(map[string][]string)
+	var names []string
+
+	m := MatchArgs(c, personFormals)
+	for _, e := range m.Errors {
+		if !e.Warning {
+			return nil, fmt.Errorf("Authors@R: %s", e)
+		}
+	}
+	p := Person{}
+	for _, am := range m.Args {
+		a, formal := am.Arg, am.Formal
+		if a.Value == nil {
+			continue
+		}
+		var v []string
+		if v, names, err = evalStrings(a.Value); err != nil {
+			return
+		}
+		values[formal] = v
+		if formal == "comment" {
+			p.Comment = make(map[string]string)
+			for i := range v {
+				p.Comment[names[i]] = v[i]
+			}
+		}
+	}
+
+	var given, family []string
+	given = append(given, values["given"]...)
+	given = append(given, values["first"]...)
+	given = append(given, values["middle"]...)
+	family = append(family, values["family"]...)
+	family = append(family, values["last"]...)
+	p.Given = strings.Join(given, " ")
+	p.Family = strings.Join(family, " ")
+	p.Email = strings.Join(values["email"], ", ")
+	p.Roles = values["role"]
+	persons = append(persons, p)
+	return
+}
+
+// evalStrings evaluates a string, NULL or a c() of strings, the names of the elements are "" if not given.
+func evalStrings(x Expr) (values []string, names []string, err error) {
+	switch n := x.(type) {
+	case *ParenExpr :
+		return evalStrings(n.X)
+	case *Constant :
+		switch n.Token.Type {
+		case CONST_CHARACTER :
+			return []string{ n.Token.stringvalue }, []string{ "" }, nil
+		case CONST_NULL :
+			return
+		}
+	case *CallExpr :
+		if n.FunctionName() == "c" {
+			for _, a := range n.Args {
+				if a.Value == nil {
+					continue
+				}
+				var v, nm []string
+				if v, nm, err = evalStrings(a.Value); err != nil {
+					return
+				}
+				if a.Name != nil && len(v) == 1 {
+					nm[0] = a.Name.stringvalue
+				}
+				values = append(values, v...)
+				names = append(names, nm...)
+			}
+			return
+		}
+	}
+	return nil, nil, unsupportedAuthor(x)
+}
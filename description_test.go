@@ -0,0 +1,93 @@
+package r
+
+import "testing"
+import "strings"
+
+func TestParseDESCRIPTION(e *testing.T) {
+	var str = `Package: mypkg
+Type: Package
+Title: What the Package Does
+Version: 0.1.0
+Authors@R: c(
+    person("Jane", "Doe", email = "jane@example.com", role = c("aut", "cre"),
+           comment = c(ORCID = "0000-0001-2345-6789")),
+    person(given = c("John", "Q."), family = "Public", role = "ctb"),
+    person("R Core Team", role = "cph"))
+Description: A longer description
+    on two lines.
+    .
+    With an empty line.
+License: MIT + file LICENSE
+Depends: R (>= 3.5.0)
+Imports:
+    dplyr (>= 1.0.0),
+    rlang,
+    stats
+Suggests: testthat (>= 3.0.0)
+
+Ignored: second record
+`
+	d, err := ParseDESCRIPTION(strings.NewReader(str))
+	if err != nil { e.Fatal("Test DESCRIPTION Failed", err) }
+
+	if d.Package != "mypkg" || d.Version != "0.1.0" || d.License != "MIT + file LICENSE" { e.Error("Test DESCRIPTION fields Failed", d.Package, d.Version, d.License) }
+	if d.Fields["Description"] != "A longer description\non two lines.\n\nWith an empty line." { e.Error("Test DESCRIPTION continuation Failed", d.Fields["Description"]) }
+	if _, ok := d.Fields["Ignored"]; ok { e.Error("Test DESCRIPTION record Failed") }
+	if strings.Join(d.Names, ",") != "Package,Type,Title,Version,Authors@R,Description,License,Depends,Imports,Suggests" { e.Error("Test DESCRIPTION names Failed", d.Names) }
+
+	if len(d.Depends) != 1 || d.Depends[0] != (Requirement{ "R", ">=", "3.5.0" }) { e.Error("Test DESCRIPTION Depends Failed", d.Depends) }
+	if len(d.Imports) != 3 || d.Imports[0] != (Requirement{ "dplyr", ">=", "1.0.0" }) || d.Imports[1] != (Requirement{ "rlang", "", "" }) { e.Error("Test DESCRIPTION Imports Failed", d.Imports) }
+	if strings.Join(d.ImportedPackages(), ",") != "dplyr,rlang,stats" { e.Error("Test DESCRIPTION ImportedPackages Failed") }
+	if len(d.Suggests) != 1 || d.Suggests[0].Version != "3.0.0" { e.Error("Test DESCRIPTION Suggests Failed", d.Suggests) }
+
+	if len(d.Authors) != 3 { e.Fatal("Test DESCRIPTION Authors Failed", d.Authors) }
+	a := d.Authors[0]
+	if a.Given != "Jane" || a.Family != "Doe" || a.Email != "jane@example.com" || strings.Join(a.Roles, ",") != "aut,cre" || a.Comment["ORCID"] != "0000-0001-2345-6789" { e.Error("Test DESCRIPTION Author[0] Failed", a) }
+	a = d.Authors[1]
+	if a.Given != "John Q." || a.Family != "Public" || strings.Join(a.Roles, ",") != "ctb" { e.Error("Test DESCRIPTION Author[1] Failed", a) }
+	a = d.Authors[2]
+	if a.Given != "R Core Team" || a.Family != "" || strings.Join(a.Roles, ",") != "cph" { e.Error("Test DESCRIPTION Author[2] Failed", a) }
+}
+
+func TestParsePersons(e *testing.T) {
+	var tests = []struct {
+		src    string
+		given  string
+		family string
+		email  string
+	}{
+		// the named arguments are matched first, "A" is the first unmatched formal
+		{ `person("A", given = "B")`, "B", "A", "" },
+		{ `person("A", "x@y.org", given = "B", family = "C")`, "B A", "C", "x@y.org" },
+		{ `person(fam = "Doe", "Jane", ema = "j@d.org")`, "Jane", "Doe", "j@d.org" },
+		{ `person("Jane", , "M.")`, "Jane M.", "", "" },
+	}
+	for i, test := range tests {
+		d, err := ParseDESCRIPTION(strings.NewReader("Authors@R: " + test.src + "\n"))
+		if err != nil || len(d.Authors) != 1 {
+			e.Error("Test ParsePersons[", i, "] Failed with error", err)
+			continue
+		}
+		if a := d.Authors[0]; a.Given != test.given || a.Family != test.family || a.Email != test.email {
+			e.Error("Test ParsePersons[", i, "] Failed:", a)
+		}
+	}
+}
+
+func TestParseDESCRIPTIONErrors(e *testing.T) {
+	var tests = []string{
+		"  continuation\n",
+		"Package mypkg\n",
+		"Imports: dplyr (1.0)\n",
+		"Imports: dplyr (>= 1.0\n",
+		"Authors@R: person(paste0('a', 'b'))\n",
+		"Authors@R: c(person('a'),\n",
+		"Authors@R: person('a', given = 'b', given = 'c')\n",
+		"Authors@R: person('a', nickname = 'b')\n",
+	}
+	for i, str := range tests {
+		if _, err := ParseDESCRIPTION(strings.NewReader(str)); err == nil {
+			e.Error("Test DESCRIPTION Errors[", i, "] Failed")
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package r
+
+import "fmt"
+
+// DotsError is a use of ... or of ..N that R rejects when it is evaluated.
+type DotsError struct {
+	Pos Position
+	Msg string
+}
+
+func (this *DotsError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+// CheckDots returns the uses of ... and ..N outside of any function having a ... formal, with the error R
+// reports when it evaluates them. A function sees the ... of the functions it is nested in. ..0 is always an
+// error. The names after $, @, :: and ::: are not uses, nor the arguments of quote, bquote and ~ which are not
+// evaluated.
+func CheckDots(f *File) (errs []*DotsError) {
+	for _, x := range f.Exprs {
+		errs = checkDots(x, false, errs)
+	}
+	return
+}
+
+// checkDots appends to errs the errors of x, dots tells if x is inside a function with a ... formal.
+func checkDots(x Node, dots bool, errs []*DotsError) []*DotsError {
+	Inspect(x, func(n Node) bool {
+		switch v := n.(type) {
+		case *Ident :
+			t := v.Token
+			switch {
+			case t.Type == DOTDOT_N && t.intvalue == 0 :
+				errs = append(errs, &DotsError{ t.Pos(), "indexing '...' with non-positive index 0" })
+			case t.Type == DOTDOT_N && !dots :
+				errs = append(errs, &DotsError{ t.Pos(), t.stringvalue + " used in an incorrect context, no ... to look in" })
+			case t.Type == DOTS && !dots :
+				errs = append(errs, &DotsError{ t.Pos(), "'...' used in an incorrect context" })
+			}
+		case *BinaryExpr :
+			switch v.Op.Type {
+			case OP_DOLLAR, OP_AT, OP_NAMESPACE, OP_NAMESPACE_INTERNAL :
+				errs = checkDots(v.X, dots, errs)
+				return false
+			case OP_TILDE :
+				return false
+			}
+		case *UnaryExpr :
+			return v.Op.Type != OP_TILDE
+		case *CallExpr :
+			switch v.FunctionName() {
+			case "quote", "bquote", "~" :
+				return false
+			}
+		case *FunctionExpr :
+			inner := dots
+			for _, p := range v.Formals {
+				if p.Name.Type == DOTS {
+					inner = true
+				}
+			}
+			for _, p := range v.Formals {
+				if p.Default != nil {
+					errs = checkDots(p.Default, inner, errs)
+				}
+			}
+			errs = checkDots(v.Body, inner, errs)
+			return false
+		}
+		return true
+	})
+	return errs
+}
@@ -0,0 +1,73 @@
+package r
+
+import "strings"
+import "testing"
+
+func TestDotsTokens(e *testing.T) {
+	tests := []struct {
+		src string
+		typ TokenType
+		n   int64
+	}{
+		{ "...", DOTS, 0 },
+		{ "..1", DOTDOT_N, 1 },
+		{ "..12", DOTDOT_N, 12 },
+		{ "`..2`", DOTDOT_N, 2 },
+		{ "`...`", DOTS, 0 },
+		{ "..", SYMBOL, 0 },
+		{ "....", SYMBOL, 0 },
+		{ "..1x", SYMBOL, 0 },
+		{ "..x", SYMBOL, 0 },
+	}
+	for i, test := range tests {
+		t := NewScanner(strings.NewReader(test.src)).NextToken()
+		if t.Type != test.typ || t.Int() != test.n {
+			e.Error("Test DotsTokens[", i, "] Failed:", t.Type, t.Int())
+		}
+	}
+
+	x, err := ParseExpr("function(x, ...) f(..., ..2, a = ..1)")
+	if err != nil {
+		e.Fatal("Test DotsTokens Failed:", err)
+	}
+	fn := x.(*FunctionExpr)
+	call := fn.Body.(*CallExpr)
+	if fn.Formals[1].Name.Type != DOTS || call.Args[0].Value.(*Ident).Token.Type != DOTS || call.Args[2].Value.(*Ident).Token.Int() != 1 {
+		e.Error("Test DotsTokens Failed: parser")
+	}
+	if s, _ := Sexp(x); s != "`function`(pairlist(x =, ... =), f(..., ..2, a = ..1), NULL)" {
+		e.Error("Test DotsTokens Failed: sexp", s)
+	}
+}
+
+func TestCheckDots(e *testing.T) {
+	tests := []struct {
+		src    string
+		errors string
+	}{
+		{ "f <- function(x, ...) g(..., ..1)", "" },
+		{ "f <- function(...) function(y = ..2) list(...)", "" },
+		{ "f <- function(...) { h <- function() ..1; h() }", "" },
+		{ "list(...)", "1:6: '...' used in an incorrect context;" },
+		{ "f <- function(x) x + ..1", "1:22: ..1 used in an incorrect context, no ... to look in;" },
+		{ "function(...) ..0", "1:15: indexing '...' with non-positive index 0;" },
+		{ "x$..1 + pkg::...", "" },
+		{ "function(a = ..1) a", "1:14: ..1 used in an incorrect context, no ... to look in;" },
+		{ "e <- quote(f(..1, ...)); b <- base::bquote(g(..2)); y ~ ..1 + x; ~ ..3; `~`(...)", "" },
+		{ "quote(x) + ..1", "1:12: ..1 used in an incorrect context, no ... to look in;" },
+	}
+	for i, test := range tests {
+		f, err := ParseFile(strings.NewReader(test.src))
+		if err != nil {
+			e.Error("Test CheckDots[", i, "] Failed with parse error", err)
+			continue
+		}
+		var errors string
+		for _, err := range CheckDots(f) {
+			errors += err.Error() + ";"
+		}
+		if errors != test.errors {
+			e.Error("Test CheckDots[", i, "] Failed:", errors)
+		}
+	}
+}
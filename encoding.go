@@ -0,0 +1,196 @@
+package r
+
+import "bufio"
+import "fmt"
+import "io"
+import "strings"
+import "unicode/utf16"
+import "unicode/utf8"
+
+// Encoding is the character encoding of an R source read by a Scanner.
+type Encoding int
+
+const (
+	// UTF-8, or the encoding of the byte order mark at the beginning of the source
+	ENCODING_AUTO Encoding = iota
+	ENCODING_UTF8
+	// ISO-8859-1
+	ENCODING_LATIN1
+	// Windows-1252, the latin1 of Windows
+	ENCODING_CP1252
+	ENCODING_UTF16LE
+)
+
+func (this Encoding) String() (s string) {
+	switch this {
+	case ENCODING_AUTO : s = "auto"
+	case ENCODING_UTF8 : s = "UTF-8"
+	case ENCODING_LATIN1 : s = "latin1"
+	case ENCODING_CP1252 : s = "CP1252"
+	case ENCODING_UTF16LE : s = "UTF-16LE"
+	}
+	return
+}
+
+// ParseEncoding returns the Encoding of a name as given to the encoding argument of R's file() or in the Encoding
+// field of a DESCRIPTION file: UTF-8, latin1, ISO-8859-1, CP1252, windows-1252 or UTF-16LE, in any case. The empty
+// name, "unknown" and "auto" are ENCODING_AUTO.
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(strings.Replace(name, "_", "-", -1)) {
+	case "", "unknown", "auto", "native.enc" :
+		return ENCODING_AUTO, nil
+	case "utf-8", "utf8" :
+		return ENCODING_UTF8, nil
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1" :
+		return ENCODING_LATIN1, nil
+	case "cp1252", "windows-1252" :
+		return ENCODING_CP1252, nil
+	case "utf-16le", "utf16le" :
+		return ENCODING_UTF16LE, nil
+	}
+	return ENCODING_AUTO, fmt.Errorf("unsupported encoding %q", name)
+}
+
+// EncodingError is a sequence of bytes of the source that is not valid in its encoding. The Scanner reads it as
+// the replacement character U+FFFD.
+type EncodingError struct {
+	Offset   int
+	Bytes    []byte
+	Encoding Encoding
+}
+
+func (this *EncodingError) Error() string {
+	return fmt.Sprintf("offset %d: invalid %s sequence % x", this.Offset, this.Encoding, this.Bytes)
+}
+
+// Encoding marks of the CONST_CHARACTER tokens, saved in their intvalue.
+const (
+	markUnknown = iota
+	markLatin1
+	markUTF8
+)
+
+// Encoding returns the mark R gives to the string of a CONST_CHARACTER token, as returned by Encoding():
+// "unknown" for the ASCII strings and the strings with \x or octal escapes, "UTF-8" for the strings with \u
+// escapes or read from a UTF-8 source, "latin1" for the strings read from a latin1 or CP1252 source.
+func (this *Token) Encoding() string {
+	if this.Type == CONST_CHARACTER {
+		switch this.intvalue {
+		case markLatin1 :
+			return "latin1"
+		case markUTF8 :
+			return "UTF-8"
+		}
+	}
+	return "unknown"
+}
+
+// cp1252 gives the runes of the bytes 0x80 to 0x9F in Windows-1252, the 5 unassigned bytes are the C1 controls
+// as in latin1.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// detectBOM skips the byte order mark at the beginning of the source and returns the encoding of the source: the
+// UTF-8 mark is skipped in ENCODING_AUTO and ENCODING_UTF8, the UTF-16LE mark in ENCODING_AUTO and
+// ENCODING_UTF16LE. It returns the number of bytes skipped.
+func detectBOM(r *bufio.Reader, encoding Encoding) (Encoding, int) {
+	if encoding == ENCODING_AUTO || encoding == ENCODING_UTF8 {
+		if b, _ := r.Peek(3); len(b) == 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+			r.Discard(3)
+			return ENCODING_UTF8, 3
+		}
+	}
+	if encoding == ENCODING_AUTO || encoding == ENCODING_UTF16LE {
+		if b, _ := r.Peek(2); len(b) == 2 && b[0] == 0xFF && b[1] == 0xFE {
+			r.Discard(2)
+			return ENCODING_UTF16LE, 2
+		}
+	}
+	if encoding == ENCODING_AUTO {
+		encoding = ENCODING_UTF8
+	}
+	return encoding, 0
+}
+
+// readRune reads the next rune of the source in its encoding and returns it with its number of bytes. An invalid
+// sequence is read as U+FFFD and recorded in the encoding errors of the Scanner.
+func (this *Scanner) readRune() (r rune, size int, err error) {
+	var b byte
+
+	switch this.encoding {
+	case ENCODING_LATIN1, ENCODING_CP1252 :
+		if b, err = this.reader.ReadByte(); err != nil {
+			return
+		}
+		r, size = rune(b), 1
+		if this.encoding == ENCODING_CP1252 && b >= 0x80 && b < 0xA0 {
+			r = cp1252[b-0x80]
+		}
+
+	case ENCODING_UTF16LE :
+		var u [4]byte
+		if size, err = io.ReadFull(this.reader, u[:2]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				// odd number of bytes
+				this.invalid(u[:1])
+				return utf8.RuneError, 1, nil
+			}
+			return
+		}
+		r = rune(u[0]) | rune(u[1]) << 8
+		if utf16.IsSurrogate(r) {
+			if next, _ := this.reader.Peek(2); r < 0xDC00 && len(next) == 2 {
+				r2 := rune(next[0]) | rune(next[1]) << 8
+				if d := utf16.DecodeRune(r, r2); d != utf8.RuneError {
+					this.reader.Discard(2)
+					return d, 4, nil
+				}
+			}
+			this.invalid(u[:2])
+			r = utf8.RuneError
+		}
+
+	default:
+		if r, size, err = this.reader.ReadRune(); err == nil && r == utf8.RuneError && size == 1 {
+			this.reader.UnreadRune()
+			b, _ = this.reader.ReadByte()
+			this.invalid([]byte{ b })
+		}
+	}
+	return
+}
+
+// invalid records an invalid sequence of bytes found at the current offset.
+func (this *Scanner) invalid(b []byte) {
+	this.encodingErrors = append(this.encodingErrors, &EncodingError{ this.currentOffset, append([]byte(nil), b...), this.encoding })
+}
+
+// EncodingErrors returns the invalid sequences of bytes read so far.
+func (this *Scanner) EncodingErrors() []*EncodingError {
+	return this.encodingErrors
+}
+
+// Encoding returns the encoding of the source: the encoding given to NewScanner, or the one of the byte order
+// mark in ENCODING_AUTO.
+func (this *Scanner) Encoding() Encoding {
+	return this.encoding
+}
+
+// stringMark returns the encoding mark of a string read by the Scanner. A string with \x or octal escapes is
+// not marked, as R does: its bytes are in no known encoding.
+func (this *Scanner) stringMark(s string, hasunicode, escaped bool) int64 {
+	if escaped {
+		return markUnknown
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			if !hasunicode && (this.encoding == ENCODING_LATIN1 || this.encoding == ENCODING_CP1252) {
+				return markLatin1
+			}
+			return markUTF8
+		}
+	}
+	return markUnknown
+}
@@ -0,0 +1,115 @@
+package r
+
+import "bytes"
+import "testing"
+import "unicode/utf16"
+
+// utf16le returns s encoded in UTF-16LE.
+func utf16le(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u >> 8))
+	}
+	return b
+}
+
+func TestEncoding(e *testing.T) {
+	tests := []struct {
+		src      []byte
+		encoding Encoding
+		detected Encoding
+		value    string
+		mark     string
+		offset   int
+		end      int
+	}{
+		{ []byte("x <- 'café'"), ENCODING_AUTO, ENCODING_UTF8, "café", "UTF-8", 5, 12 },
+		{ []byte("\xEF\xBB\xBFx <- 'a'"), ENCODING_AUTO, ENCODING_UTF8, "a", "unknown", 8, 11 },
+		{ []byte("\xEF\xBB\xBFx <- 'a'"), ENCODING_UTF8, ENCODING_UTF8, "a", "unknown", 8, 11 },
+		{ []byte("x <- 'caf\xE9'"), ENCODING_LATIN1, ENCODING_LATIN1, "café", "latin1", 5, 11 },
+		{ []byte("x <- '\x80 \x93\x94 \x81'"), ENCODING_CP1252, ENCODING_CP1252, "€ “” \u0081", "latin1", 5, 13 },
+		{ []byte("x <- '\\u00e9 \xE9'"), ENCODING_LATIN1, ENCODING_LATIN1, "é é", "UTF-8", 5, 15 },
+		{ []byte("x <- '\\x41'"), ENCODING_LATIN1, ENCODING_LATIN1, "A", "unknown", 5, 11 },
+		{ []byte("x <- '\\xe9'"), ENCODING_UTF8, ENCODING_UTF8, "\xe9", "unknown", 5, 11 },
+		{ []byte("x <- 'é\\351'"), ENCODING_UTF8, ENCODING_UTF8, "é\xe9", "unknown", 5, 13 },
+		{ append([]byte{ 0xFF, 0xFE }, utf16le("x <- 'é😀'")...), ENCODING_AUTO, ENCODING_UTF16LE, "é😀", "UTF-8", 12, 22 },
+		{ utf16le("x <- 'a'"), ENCODING_UTF16LE, ENCODING_UTF16LE, "a", "unknown", 10, 16 },
+	}
+	for i, test := range tests {
+		s := NewScanner(bytes.NewReader(test.src), test.encoding)
+		if s.Encoding() != test.detected {
+			e.Error("Test Encoding[", i, "] Failed: encoding", s.Encoding())
+		}
+		var types []TokenType
+		var str *Token
+		for t := s.NextToken(); t.Type != END_OF_INPUT; t = s.NextToken() {
+			types = append(types, t.Type)
+			if t.Type == CONST_CHARACTER {
+				str = t
+			}
+		}
+		if len(types) != 3 || types[0] != SYMBOL || types[1] != OP_LEFT_ASSIGN || str == nil {
+			e.Error("Test Encoding[", i, "] Failed: tokens", types)
+			continue
+		}
+		if str.Value() != test.value || str.Encoding() != test.mark || str.offset != test.offset || str.End() != test.end {
+			e.Error("Test Encoding[", i, "] Failed:", str.Value(), str.Encoding(), str.offset, str.End())
+		}
+		if len(s.EncodingErrors()) != 0 {
+			e.Error("Test Encoding[", i, "] Failed: errors", s.EncodingErrors())
+		}
+	}
+
+	if x, _ := ParseExpr("x"); x.(*Ident).Token.Encoding() != "unknown" {
+		e.Error("Test Encoding Failed: mark of a symbol")
+	}
+}
+
+func TestEncodingErrors(e *testing.T) {
+	tests := []struct {
+		src      []byte
+		encoding Encoding
+		errors   string
+	}{
+		{ []byte("a <- 'x\xE9y'\nb\xFF"), ENCODING_UTF8, "offset 7: invalid UTF-8 sequence e9;offset 12: invalid UTF-8 sequence ff;" },
+		{ append(utf16le("ab"), 'c'), ENCODING_UTF16LE, "offset 4: invalid UTF-16LE sequence 63;" },
+		{ append(utf16le("'"), append([]byte{ 0x3D, 0xD8 }, utf16le("'")...)...), ENCODING_UTF16LE, "offset 2: invalid UTF-16LE sequence 3d d8;" },
+		{ []byte("'\xE9'"), ENCODING_LATIN1, "" },
+	}
+	for i, test := range tests {
+		p := NewParser(bytes.NewReader(test.src), test.encoding)
+		p.Parse()
+		var errors string
+		for _, err := range p.EncodingErrors() {
+			errors += err.Error() + ";"
+		}
+		if errors != test.errors {
+			e.Error("Test EncodingErrors[", i, "] Failed:", errors)
+		}
+	}
+}
+
+func TestParseEncoding(e *testing.T) {
+	tests := []struct {
+		name     string
+		encoding Encoding
+	}{
+		{ "", ENCODING_AUTO },
+		{ "unknown", ENCODING_AUTO },
+		{ "UTF-8", ENCODING_UTF8 },
+		{ "utf8", ENCODING_UTF8 },
+		{ "latin1", ENCODING_LATIN1 },
+		{ "ISO-8859-1", ENCODING_LATIN1 },
+		{ "CP1252", ENCODING_CP1252 },
+		{ "windows-1252", ENCODING_CP1252 },
+		{ "UTF-16LE", ENCODING_UTF16LE },
+	}
+	for i, test := range tests {
+		if enc, err := ParseEncoding(test.name); err != nil || enc != test.encoding {
+			e.Error("Test ParseEncoding[", i, "] Failed:", enc, err)
+		}
+	}
+	if _, err := ParseEncoding("EBCDIC"); err == nil {
+		e.Error("Test ParseEncoding Failed: no error")
+	}
+}
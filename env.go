@@ -0,0 +1,357 @@
+package r
+
+import "errors"
+import "fmt"
+import "sort"
+import "strings"
+import "sync"
+
+// NewEnvironment returns a new environment without variables enclosed by enclos, as new.env(parent = enclos) does.
+func NewEnvironment(enclos *Environment) *Environment {
+	return &Environment{ Enclos: enclos }
+}
+
+// Parent returns the enclosure of the environment, as parent.env() does: nil for the empty environment.
+func (this *Environment) Parent() *Environment {
+	if e, ok := this.Enclos.(*Environment); ok {
+		return e
+	}
+	if this == EmptyEnv {
+		return nil
+	}
+	// the serialized environments without enclosure are enclosed by the global environment
+	return GlobalEnv
+}
+
+// frame returns the environment holding the variables of e: the base namespace shares those of the base
+// environment.
+func frame(e *Environment) *Environment {
+	if e.vars != nil {
+		return e.vars
+	}
+	return e
+}
+
+// Lookup returns the value of the variable name in the environment or in its enclosures, as get() does, and the
+// environment defining it: nil if none does.
+func (this *Environment) Lookup(name string) (Value, *Environment) {
+	for e := this; e != nil; e = e.Parent() {
+		if v := frame(e).Get(name); v != nil {
+			return v, e
+		}
+	}
+	return nil, nil
+}
+
+// Assign sets the variable name of the environment, as assign() does: the variable is added to the frame if it
+// is not defined in it.
+func (this *Environment) Assign(name string, v Value) error {
+	e := frame(this)
+	for i := range e.Frame {
+		if e.Frame[i].Tag == name {
+			e.Frame[i].Value = v
+			return nil
+		}
+	}
+	switch {
+	case e == EmptyEnv :
+		return errors.New("cannot assign values in the empty environment")
+	case specialOf(e) == e :
+		return fmt.Errorf("cannot assign values in %s: it stands for the environment of a session", e.Name)
+	case e.Locked :
+		return errors.New("cannot add bindings to a locked environment")
+	}
+	e.Frame = append(e.Frame, Tagged{ name, v })
+	return nil
+}
+
+// SuperAssign sets the variable name as <<- does from the environment: in the first enclosure defining it, in the
+// global environment of the session enclosing it if none does.
+func (this *Environment) SuperAssign(name string, v Value) error {
+	global := GlobalEnv
+	for e := this.Parent(); e != nil && e != EmptyEnv; e = e.Parent() {
+		if frame(e).Get(name) != nil {
+			return e.Assign(name, v)
+		}
+		if e.special == GlobalEnv {
+			global = e
+		}
+	}
+	return global.Assign(name, v)
+}
+
+// Remove removes the variable name from the frame of the environment, as rm() does: false if it is not defined.
+func (this *Environment) Remove(name string) (bool, error) {
+	e := frame(this)
+	for i := range e.Frame {
+		if e.Frame[i].Tag == name {
+			if e.Locked {
+				return false, errors.New("cannot remove bindings from a locked environment")
+			}
+			e.Frame = append(e.Frame[:i], e.Frame[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Names returns the sorted names of the variables of the frame of the environment, as ls() does: the names
+// starting with a dot only if all.
+func (this *Environment) Names(all bool) (names []string) {
+	for _, t := range frame(this).Frame {
+		if all || !strings.HasPrefix(t.Tag, ".") {
+			names = append(names, t.Tag)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// Session is the state of an R process: its global environment, its base environment and namespace, its search
+// path and its loaded namespaces. The search path and the loaded namespaces of a session are safe for concurrent
+// use, its environments are not.
+type Session struct {
+	GlobalEnv     *Environment
+	BaseEnv       *Environment
+	BaseNamespace *Environment
+	mutex         sync.Mutex
+	namespaces    map[string]*Environment
+}
+
+// NewSession returns a new session: the search path holds the global and base environments, the base namespace is
+// the only loaded namespace.
+func NewSession() *Session {
+	base := &Environment{ Name: "base", Enclos: EmptyEnv, special: BaseEnv }
+	global := &Environment{ Name: "R_GlobalEnv", Enclos: base, special: GlobalEnv }
+	ns := &Environment{ Name: "namespace:base", Enclos: global, special: BaseNamespace, vars: base }
+	return &Session{ GlobalEnv: global, BaseEnv: base, BaseNamespace: ns, namespaces: map[string]*Environment{ "base": ns } }
+}
+
+// Resolve returns the environment of the session env stands for: the global, base environment and base namespace
+// of the session for GlobalEnv, BaseEnv and BaseNamespace, env itself otherwise.
+func (this *Session) Resolve(env *Environment) *Environment {
+	switch env {
+	case GlobalEnv :
+		return this.GlobalEnv
+	case BaseEnv :
+		return this.BaseEnv
+	case BaseNamespace :
+		return this.BaseNamespace
+	}
+	return env
+}
+
+// Search returns the names of the environments of the search path, as search() does: .GlobalEnv, the attached
+// environments and package:base.
+func (this *Session) Search() []string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.search()
+}
+
+func (this *Session) search() []string {
+	names := []string{ ".GlobalEnv" }
+	for e := this.GlobalEnv.Parent(); e != nil && e != EmptyEnv; e = e.Parent() {
+		if e == this.BaseEnv {
+			names = append(names, "package:base")
+		} else {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// Attach inserts the environment in the search path at the position pos of Search() as attach() does: 2 is just
+// after the global environment, the base environment stays last. The environment is known by its Name.
+func (this *Session) Attach(env *Environment, pos int) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.attach(env, pos)
+}
+
+func (this *Session) attach(env *Environment, pos int) error {
+	if pos < 2 {
+		return errors.New("invalid 'pos' argument")
+	}
+	e := this.GlobalEnv
+	for ; pos > 2 && e.Parent() != this.BaseEnv; pos-- {
+		e = e.Parent()
+	}
+	env.Enclos, e.Enclos = e.Enclos, env
+	return nil
+}
+
+// Detach removes the environment name from the search path as detach() does, and returns it.
+func (this *Session) Detach(name string) (*Environment, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.detach(name)
+}
+
+func (this *Session) detach(name string) (*Environment, error) {
+	if name == "package:base" {
+		return nil, errors.New("detaching \"package:base\" is not allowed")
+	}
+	for e := this.GlobalEnv; e != this.BaseEnv; e = e.Parent() {
+		if next := e.Parent(); next.Name == name && next != this.BaseEnv {
+			e.Enclos, next.Enclos = next.Enclos, EmptyEnv
+			return next, nil
+		}
+	}
+	return nil, errors.New("invalid 'name' argument")
+}
+
+// namespaceInfo is the name of the variable of the namespaces holding their exports.
+const namespaceInfo = ".__NAMESPACE__."
+
+// NewNamespace creates and registers the namespace of the package name as loadNamespace() does. The namespace is
+// enclosed by its imports environment, itself enclosed by the base namespace.
+func (this *Session) NewNamespace(name, version string) (*Environment, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if _, ok := this.namespaces[name]; ok {
+		return nil, fmt.Errorf("namespace %s is already registered", name)
+	}
+	imports := &Environment{ Name: "imports:" + name, Enclos: this.BaseNamespace }
+	ns := &Environment{ Name: "namespace:" + name, Spec: []string{ name, version }, Enclos: imports }
+	info := &Environment{ Enclos: this.BaseNamespace }
+	info.Assign("spec", &Character{ Attrs: Attrs{ []Attribute{ { "names", NewCharacter("name", "version") } } }, Data: []string{ name, version } })
+	info.Assign("exports", &Character{})
+	ns.Assign(namespaceInfo, info)
+	this.namespaces[name] = ns
+	return ns, nil
+}
+
+// LoadedNamespace returns the loaded namespace name.
+func (this *Session) LoadedNamespace(name string) (*Environment, bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	ns, ok := this.namespaces[name]
+	return ns, ok
+}
+
+// LoadedNamespaces returns the sorted names of the loaded namespaces, as loadedNamespaces() does.
+func (this *Session) LoadedNamespaces() (names []string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	for name := range this.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// UnloadNamespace removes the namespace name from the loaded namespaces and its package from the search path.
+func (this *Session) UnloadNamespace(name string) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if name == "base" {
+		return errors.New("cannot unload the base namespace")
+	}
+	if _, ok := this.namespaces[name]; !ok {
+		return fmt.Errorf("namespace ‘%s’ is not loaded", name)
+	}
+	delete(this.namespaces, name)
+	this.detach("package:" + name)
+	return nil
+}
+
+// Exports returns the names exported by the namespace.
+func Exports(ns *Environment) []string {
+	if specialOf(ns) == BaseNamespace {
+		return ns.Names(true)
+	}
+	if info, ok := ns.Get(namespaceInfo).(*Environment); ok {
+		if c, ok := info.Get("exports").(*Character); ok {
+			return c.Data
+		}
+	}
+	return nil
+}
+
+// Export adds the names to the exports of the namespace, as the export() directive of the NAMESPACE file does.
+func Export(ns *Environment, names ...string) error {
+	info, ok := ns.Get(namespaceInfo).(*Environment)
+	if !ok {
+		return errors.New("not a namespace")
+	}
+	exports := Exports(ns)
+	for _, name := range names {
+		if ns.Get(name) == nil {
+			return fmt.Errorf("undefined exports: %s", name)
+		}
+		exports = append(exports, name)
+	}
+	return info.Assign("exports", NewCharacter(exports...))
+}
+
+// ImportFrom copies the exported variables names of the namespace from to the imports environment of the
+// namespace ns, as the importFrom() directive of the NAMESPACE file does: all of them if there are no names.
+func ImportFrom(ns, from *Environment, names ...string) error {
+	exports := Exports(from)
+	if len(names) == 0 {
+		names = exports
+	}
+	imports := ns.Parent()
+	for _, name := range names {
+		found := false
+		for _, e := range exports {
+			found = found || e == name
+		}
+		if !found {
+			return fmt.Errorf("object ‘%s’ is not exported by '%s'", name, from.Name)
+		}
+		if err := imports.Assign(name, frame(from).Get(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttachNamespace attaches the package environment of the namespace, holding its exported variables, at the
+// position 2 of the search path as library() does, and returns it.
+func (this *Session) AttachNamespace(ns *Environment) (*Environment, error) {
+	if specialOf(ns) == BaseNamespace {
+		return this.BaseEnv, nil
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	name := "package:" + ns.Spec[0]
+	for _, s := range this.search() {
+		if s == name {
+			return nil, fmt.Errorf("%s is already attached", name)
+		}
+	}
+	pkg := &Environment{ Name: name, Spec: []string{ name } }
+	for _, e := range Exports(ns) {
+		pkg.Assign(e, ns.Get(e))
+	}
+	pkg.Locked = true
+	return pkg, this.attach(pkg, 2)
+}
+
+// GetExported returns the exported variable name of the namespace pkg, as pkg::name does.
+func (this *Session) GetExported(pkg, name string) (Value, error) {
+	ns, ok := this.LoadedNamespace(pkg)
+	if !ok {
+		return nil, fmt.Errorf("there is no package called ‘%s’", pkg)
+	}
+	for _, e := range Exports(ns) {
+		if e == name {
+			return frame(ns).Get(name), nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not an exported object from 'namespace:%s'", name, pkg)
+}
+
+// GetInternal returns the variable name of the namespace pkg, exported or not, as pkg:::name does.
+func (this *Session) GetInternal(pkg, name string) (Value, error) {
+	ns, ok := this.LoadedNamespace(pkg)
+	if !ok {
+		return nil, fmt.Errorf("there is no package called ‘%s’", pkg)
+	}
+	if v := frame(ns).Get(name); v != nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("object '%s' not found", name)
+}
@@ -0,0 +1,213 @@
+package r
+
+import "strings"
+import "testing"
+
+func TestEnvironment(e *testing.T) {
+	s := NewSession()
+	f := NewEnvironment(s.GlobalEnv)
+	g := NewEnvironment(f)
+
+	f.Assign("x", NewCharacter("f"))
+	g.Assign("y", NewCharacter("g"))
+	g.Assign(".z", NewCharacter("g"))
+	s.GlobalEnv.Assign("x", NewCharacter("global"))
+	s.BaseEnv.Assign("T", &Logical{ Data: []int32{ 1 } })
+
+	tests := []struct {
+		env    *Environment
+		name   string
+		result *Environment
+	}{
+		{ g, "y", g },
+		{ g, "x", f },
+		{ f, "y", nil },
+		{ s.GlobalEnv, "x", s.GlobalEnv },
+		{ g, "T", s.BaseEnv },
+		{ s.BaseNamespace, "T", s.BaseNamespace },
+		{ EmptyEnv, "T", nil },
+	}
+	for i, test := range tests {
+		if _, env := test.env.Lookup(test.name); env != test.result {
+			e.Error("Test Environment[", i, "] Failed: lookup of", test.name, "returns", env, "instead of", test.result)
+		}
+	}
+
+	if s := strings.Join(g.Names(false), " "); s != "y" {
+		e.Error("Test Environment Failed: names", s)
+	}
+	if s := strings.Join(g.Names(true), " "); s != ".z y" {
+		e.Error("Test Environment Failed: all names", s)
+	}
+	g.SuperAssign("x", NewCharacter("super"))
+	if v := f.Get("x").(*Character).Data[0]; v != "super" {
+		e.Error("Test Environment Failed: <<- assigns", v)
+	}
+	g.SuperAssign("w", NewCharacter("super"))
+	if s.GlobalEnv.Get("w") == nil {
+		e.Error("Test Environment Failed: <<- does not assign in the global environment")
+	}
+	if ok, _ := g.Remove("y"); !ok || g.Get("y") != nil {
+		e.Error("Test Environment Failed: y not removed")
+	}
+	if ok, _ := g.Remove("y"); ok {
+		e.Error("Test Environment Failed: y removed twice")
+	}
+	if err := EmptyEnv.Assign("x", NullValue); err == nil || err.Error() != "cannot assign values in the empty environment" {
+		e.Error("Test Environment Failed: assign in the empty environment", err)
+	}
+	g.Locked = true
+	if err := g.Assign("v", NullValue); err == nil || err.Error() != "cannot add bindings to a locked environment" {
+		e.Error("Test Environment Failed: assign in a locked environment", err)
+	}
+	if err := g.Assign(".z", NullValue); err != nil {
+		e.Error("Test Environment Failed: assign of a binding of a locked environment", err)
+	}
+	if err := GlobalEnv.Assign("x", NullValue); err == nil {
+		e.Error("Test Environment Failed: assign in the special global environment")
+	}
+	if v, _ := BaseNamespace.Lookup("T"); v != nil {
+		e.Error("Test Environment Failed: the special base namespace holds variables")
+	}
+	if EmptyEnv.Parent() != nil || s.BaseEnv.Parent() != EmptyEnv || (&Environment{}).Parent() != GlobalEnv {
+		e.Error("Test Environment Failed: parent")
+	}
+}
+
+func TestSearch(e *testing.T) {
+	s := NewSession()
+	a := &Environment{ Name: "a" }
+	b := &Environment{ Name: "b" }
+	tests := []struct {
+		attach func() error
+		search string
+	}{
+		{ func() error { return nil }, ".GlobalEnv package:base" },
+		{ func() error { return s.Attach(a, 2) }, ".GlobalEnv a package:base" },
+		{ func() error { return s.Attach(b, 10) }, ".GlobalEnv a b package:base" },
+		{ func() error { _, err := s.Detach("a"); return err }, ".GlobalEnv b package:base" },
+		{ func() error { return s.Attach(a, 2) }, ".GlobalEnv a b package:base" },
+		{ func() error { _, err := s.Detach("b"); return err }, ".GlobalEnv a package:base" },
+		{ func() error { _, err := s.Detach("a"); return err }, ".GlobalEnv package:base" },
+		{ func() error { _, err := s.Detach("a"); return err }, "invalid 'name' argument" },
+		{ func() error { _, err := s.Detach("package:base"); return err }, "detaching \"package:base\" is not allowed" },
+		{ func() error { return s.Attach(a, 1) }, "invalid 'pos' argument" },
+	}
+	for i, test := range tests {
+		var result string
+		if err := test.attach(); err != nil {
+			result = err.Error()
+		} else {
+			result = strings.Join(s.Search(), " ")
+		}
+		if result != test.search {
+			e.Error("Test Search[", i, "] Failed:", result, "instead of", test.search)
+		}
+	}
+	if s.GlobalEnv.Enclos != s.BaseEnv {
+		e.Error("Test Search Failed: search path not restored")
+	}
+}
+
+func TestNamespace(e *testing.T) {
+	session := NewSession()
+	ns, err := session.NewNamespace("pkg", "1.0")
+	if err != nil {
+		e.Fatal(err)
+	}
+	dep, _ := session.NewNamespace("dep", "0.1")
+	dep.Assign("helper", NewCharacter("helper"))
+	dep.Assign("hidden", NewCharacter("hidden"))
+	Export(dep, "helper")
+	ns.Assign("f", NewCharacter("f"))
+	ns.Assign("g", NewCharacter("g"))
+
+	tests := []struct {
+		run    func() (Value, error)
+		result string
+	}{
+		{ func() (Value, error) { _, err := session.NewNamespace("pkg", "1.0"); return nil, err }, "namespace pkg is already registered" },
+		{ func() (Value, error) { return nil, Export(ns, "f", "h") }, "undefined exports: h" },
+		{ func() (Value, error) { return nil, Export(ns, "f") }, "" },
+		{ func() (Value, error) { return session.GetExported("pkg", "f") }, "f" },
+		{ func() (Value, error) { return session.GetExported("pkg", "g") }, "'g' is not an exported object from 'namespace:pkg'" },
+		{ func() (Value, error) { return session.GetInternal("pkg", "g") }, "g" },
+		{ func() (Value, error) { return session.GetInternal("pkg", "h") }, "object 'h' not found" },
+		{ func() (Value, error) { return session.GetExported("none", "f") }, "there is no package called ‘none’" },
+		{ func() (Value, error) { return nil, ImportFrom(ns, dep, "hidden") }, "object ‘hidden’ is not exported by 'namespace:dep'" },
+		{ func() (Value, error) { return nil, ImportFrom(ns, dep) }, "" },
+		// the namespace sees its imports, then the base namespace, then the search path
+		{ func() (Value, error) { v, _ := ns.Lookup("helper"); return v, nil }, "helper" },
+		{ func() (Value, error) { _, env := ns.Lookup("helper"); return NewCharacter(env.Name), nil }, "imports:pkg" },
+		{ func() (Value, error) { v, _ := ns.Lookup("hidden"); return v, nil }, "" },
+		{ func() (Value, error) { return nil, session.UnloadNamespace("base") }, "cannot unload the base namespace" },
+	}
+	for i, test := range tests {
+		v, err := test.run()
+		s := ""
+		switch {
+		case err != nil :
+			s = err.Error()
+		case v != nil :
+			s = v.(*Character).Data[0]
+		}
+		if s != test.result {
+			e.Error("Test Namespace[", i, "] Failed:", s, "instead of", test.result)
+		}
+	}
+
+	if s := strings.Join(session.LoadedNamespaces(), " "); s != "base dep pkg" {
+		e.Error("Test Namespace Failed: loaded namespaces", s)
+	}
+	pkg, err := session.AttachNamespace(ns)
+	if err != nil {
+		e.Fatal(err)
+	}
+	if s := strings.Join(session.Search(), " "); s != ".GlobalEnv package:pkg package:base" {
+		e.Error("Test Namespace Failed: search path", s)
+	}
+	if s := strings.Join(pkg.Names(true), " "); s != "f" {
+		e.Error("Test Namespace Failed: package environment", s)
+	}
+	if v, env := session.GlobalEnv.Lookup("f"); v == nil || env != pkg {
+		e.Error("Test Namespace Failed: f not found in the search path")
+	}
+	if _, err := session.AttachNamespace(ns); err == nil || err.Error() != "package:pkg is already attached" {
+		e.Error("Test Namespace Failed: attached twice", err)
+	}
+	if err := pkg.Assign("g", NullValue); err == nil {
+		e.Error("Test Namespace Failed: package environment not locked")
+	}
+	session.UnloadNamespace("pkg")
+	if _, ok := session.LoadedNamespace("pkg"); ok || len(session.Search()) != 2 {
+		e.Error("Test Namespace Failed: pkg not unloaded", session.Search())
+	}
+}
+
+func TestSessionConcurrency(e *testing.T) {
+	s := NewSession()
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(name string) {
+			ns, err := s.NewNamespace(name, "1.0")
+			if err == nil {
+				_, err = s.AttachNamespace(ns)
+			}
+			if err == nil {
+				err = s.UnloadNamespace(name)
+			}
+			done <- err
+		}(string(rune('a' + i)))
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-done; err != nil {
+			e.Error("Test SessionConcurrency Failed:", err)
+		}
+	}
+	if len(s.Search()) != 2 || len(s.LoadedNamespaces()) != 1 {
+		e.Error("Test SessionConcurrency Failed:", s.Search(), s.LoadedNamespaces())
+	}
+	if len(NewSession().Search()) != 2 {
+		e.Error("Test SessionConcurrency Failed: the sessions share their search path")
+	}
+}
@@ -0,0 +1,822 @@
+package r
+
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+
+// EvalError is an error of EvalConstant: the expression is not constant or its evaluation failed.
+type EvalError struct {
+	// Position of the expression that can not be evaluated
+	Pos Position
+	Msg string
+}
+
+func (this *EvalError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+func evalError(x Node, format string, a ...interface{}) error {
+	return &EvalError{ x.Pos(), fmt.Sprintf(format, a...) }
+}
+
+// EvalConstant evaluates an expression without side effects: the literals, ( ), the unary and binary arithmetic
+// operators, : and the calls of c, list, seq, seq_len, seq_along, rep, paste0, structure, character, numeric,
+// double, integer and logical (optionally prefixed by base::). Any other expression, a symbol included, is not
+// constant and returns an *EvalError. The arithmetic and the coercions are those of Arith, Unary and Coerce.
+func EvalConstant(x Expr) (Value, error) {
+	switch e := x.(type) {
+	case *Constant :
+		v, err := constantValue(e.Token)
+		if err != nil {
+			return nil, evalError(e, "unexpected %s constant", e.Token.Type)
+		}
+		return v, nil
+	case *ParenExpr :
+		return EvalConstant(e.X)
+	case *UnaryExpr :
+		v, err := EvalConstant(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return evalUnary(e, v)
+	case *BinaryExpr :
+		op := e.Op.stringvalue
+		switch e.Op.Type {
+		case OP_ADD, OP_SUB, OP_MUL, OP_DIV, OP_POW, OP_MUL2, OP_COLON :
+		case INFIX :
+			if op != "%%" && op != "%/%" {
+				return nil, evalError(e, "%s is not a constant operator", op)
+			}
+		default:
+			return nil, evalError(e, "%s is not a constant operator", op)
+		}
+		a, err := EvalConstant(e.X)
+		if err != nil {
+			return nil, err
+		}
+		b, err := EvalConstant(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op.Type == OP_COLON {
+			return evalColon(e, a, b)
+		}
+		if e.Op.Type == OP_MUL2 {
+			op = "^"
+		}
+		return evalArith(e, op, a, b)
+	case *CallExpr :
+		return evalCall(e)
+	case *Ident :
+		return nil, evalError(e, "symbol %s is not constant", e.Token.stringvalue)
+	case nil :
+		return nil, &EvalError{ Position{}, "empty expression" }
+	}
+	return nil, evalError(x, "expression is not constant")
+}
+
+// evalCall evaluates the call of a constant function.
+func evalCall(x *CallExpr) (Value, error) {
+	name := x.FunctionName()
+	if f, ok := x.Fun.(*BinaryExpr); ok {
+		if p, ok := f.X.(*Ident); !ok || p.Name() != "base" {
+			name = ""
+		}
+	}
+
+	var fun func(*CallExpr, []Tagged) (Value, error)
+	switch name {
+	case "c" :
+		fun = evalC
+	case "list" :
+		fun = evalList
+	case "seq" :
+		fun = evalSeq
+	case "seq_len" :
+		fun = evalSeqLen
+	case "seq_along" :
+		fun = evalSeqAlong
+	case "rep" :
+		fun = evalRep
+	case "paste0" :
+		fun = evalPaste0
+	case "structure" :
+		fun = evalStructure
+	case "character" :
+		fun = evalVector(STRSXP)
+	case "numeric", "double" :
+		fun = evalVector(REALSXP)
+	case "integer" :
+		fun = evalVector(INTSXP)
+	case "logical" :
+		fun = evalVector(LGLSXP)
+	default:
+		if name == "" {
+			return nil, evalError(x, "call is not constant")
+		}
+		return nil, evalError(x, "call of %s is not constant", name)
+	}
+
+	args := make([]Tagged, len(x.Args))
+	for i, a := range x.Args {
+		if a.Name != nil {
+			args[i].Tag = a.Name.stringvalue
+		}
+		if a.Value == nil {
+			return nil, evalError(x, "empty argument %d of %s", i+1, name)
+		}
+		v, err := EvalConstant(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		args[i].Value = v
+	}
+	return fun(x, args)
+}
+
+// matchConstantArgs matches the arguments to the formals by exact name, by unique partial name and then by
+// position, as R does for the formals before ... in a call.
+func matchConstantArgs(x *CallExpr, args []Tagged, formals ...string) (map[string]Value, error) {
+	matched := make(map[string]Value)
+	used := make([]bool, len(args))
+	for _, exact := range []bool{ true, false } {
+		for i, a := range args {
+			if a.Tag == "" || used[i] {
+				continue
+			}
+			found := ""
+			for _, f := range formals {
+				if _, ok := matched[f]; ok {
+					continue
+				}
+				if f == a.Tag || (!exact && strings.HasPrefix(f, a.Tag)) {
+					if found != "" {
+						return nil, evalError(x, "argument %s matches multiple formal arguments", a.Tag)
+					}
+					found = f
+				}
+			}
+			if found != "" {
+				matched[found], used[i] = a.Value, true
+			}
+		}
+	}
+	next := 0
+	for i, a := range args {
+		if used[i] {
+			continue
+		}
+		if a.Tag != "" {
+			return nil, evalError(x, "unused argument %s", a.Tag)
+		}
+		for next < len(formals) && matched[formals[next]] != nil {
+			next++
+		}
+		if next == len(formals) {
+			return nil, evalError(x, "unused argument %d", i+1)
+		}
+		matched[formals[next]] = a.Value
+	}
+	return matched, nil
+}
+
+// Ranks of the vector types in the coercion order of c().
+var vectorRanks = map[SexpType]int{ NILSXP: 0, LGLSXP: 1, INTSXP: 2, REALSXP: 3, CPLXSXP: 4, STRSXP: 5, VECSXP: 6 }
+
+// rankedType returns the type of highest rank of the values.
+func rankedType(x Node, values []Value) (typ SexpType, err error) {
+	typ = NILSXP
+	for _, v := range values {
+		t := v.Type()
+		if t == EXPRSXP {
+			t = VECSXP
+		}
+		r, ok := vectorRanks[t]
+		if !ok {
+			return 0, evalError(x, "%s can not be combined", v.Type())
+		}
+		if r > vectorRanks[typ] {
+			typ = t
+		}
+	}
+	return
+}
+
+// coerceVector converts a vector to the type as Coerce does, the vectors of the type are returned unchanged.
+func coerceVector(x Node, v Value, typ SexpType) (Value, error) {
+	switch {
+	case v.Type() == typ :
+		return v, nil
+	case typ == VECSXP :
+		if l, ok := v.(*List); ok {
+			return &List{ Data: l.Data }, nil
+		}
+		l := &List{ Data: make([]Value, Length(v)) }
+		for i := range l.Data {
+			l.Data[i] = selectElements(v, []int{ i })
+			l.Data[i].Attributes().List = nil
+		}
+		return l, nil
+	}
+	c, _, err := Coerce(v, typ)
+	if err != nil {
+		return nil, evalError(x, "%s", err)
+	}
+	return c, nil
+}
+
+// selectElements returns the elements of the vector at the indices and their names.
+func selectElements(v Value, idx []int) Value {
+	var r Value
+	switch x := v.(type) {
+	case *Logical :
+		l := &Logical{ Data: make([]int32, len(idx)) }
+		for i, j := range idx {
+			l.Data[i] = x.Data[j]
+		}
+		r = l
+	case *Integer :
+		n := &Integer{ Data: make([]int32, len(idx)) }
+		for i, j := range idx {
+			n.Data[i] = x.Data[j]
+		}
+		r = n
+	case *Real :
+		f := &Real{ Data: make([]float64, len(idx)) }
+		for i, j := range idx {
+			f.Data[i] = x.Data[j]
+		}
+		r = f
+	case *Complex :
+		c := &Complex{ Data: make([]complex128, len(idx)) }
+		for i, j := range idx {
+			c.Data[i] = x.Data[j]
+		}
+		r = c
+	case *Character :
+		s := &Character{ Data: make([]string, len(idx)) }
+		for i, j := range idx {
+			s.Data[i] = x.Data[j]
+			if x.IsNA(j) {
+				if s.NA == nil {
+					s.NA = make([]bool, len(idx))
+				}
+				s.NA[i] = true
+			}
+		}
+		r = s
+	case *List :
+		l := &List{ Data: make([]Value, len(idx)) }
+		for i, j := range idx {
+			l.Data[i] = x.Data[j]
+		}
+		r = l
+	default:
+		return NullValue
+	}
+	if names := Names(v); names != nil {
+		s := make([]string, len(idx))
+		for i, j := range idx {
+			s[i] = names[j]
+		}
+		r.Attributes().Set("names", NewCharacter(s...))
+	}
+	return r
+}
+
+// evalC combines the arguments as c() does: the result has the type of highest rank and the names are built
+// from the argument names and the element names.
+func evalC(x *CallExpr, args []Tagged) (Value, error) {
+	values := make([]Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	typ, err := rankedType(x, values)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	hasNames := false
+	var parts []Value
+	for _, a := range args {
+		n := Length(a.Value)
+		if a.Value.Type() == NILSXP {
+			continue
+		}
+		inner := Names(a.Value)
+		for i := 0; i < n; i++ {
+			name := ""
+			switch {
+			case a.Tag != "" && inner != nil && inner[i] != "" :
+				name = a.Tag + "." + inner[i]
+			case a.Tag != "" && n == 1 :
+				name = a.Tag
+			case a.Tag != "" :
+				name = a.Tag + strconv.Itoa(i+1)
+			case inner != nil :
+				name = inner[i]
+			}
+			hasNames = hasNames || name != ""
+			names = append(names, name)
+		}
+		p, err := coerceVector(x, a.Value, typ)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+
+	var r Value
+	switch typ {
+	case NILSXP :
+		return NullValue, nil
+	case LGLSXP, INTSXP :
+		var data []int32
+		for _, p := range parts {
+			data = append(data, asInts(p)...)
+		}
+		if typ == LGLSXP {
+			r = &Logical{ Data: data }
+		} else {
+			r = &Integer{ Data: data }
+		}
+	case REALSXP :
+		f := &Real{}
+		for _, p := range parts {
+			f.Data = append(f.Data, p.(*Real).Data...)
+		}
+		r = f
+	case CPLXSXP :
+		c := &Complex{}
+		for _, p := range parts {
+			c.Data = append(c.Data, p.(*Complex).Data...)
+		}
+		r = c
+	case STRSXP :
+		s := &Character{}
+		for _, p := range parts {
+			ps := p.(*Character)
+			if ps.NA != nil && s.NA == nil {
+				s.NA = make([]bool, len(s.Data))
+			}
+			for i := range ps.Data {
+				if s.NA != nil {
+					s.NA = append(s.NA, ps.IsNA(i))
+				}
+			}
+			s.Data = append(s.Data, ps.Data...)
+		}
+		r = s
+	default:
+		l := &List{}
+		for _, p := range parts {
+			l.Data = append(l.Data, p.(*List).Data...)
+		}
+		r = l
+	}
+	if hasNames {
+		r.Attributes().Set("names", NewCharacter(names...))
+	}
+	return r, nil
+}
+
+// evalList returns the list of the arguments, named by their tags.
+func evalList(x *CallExpr, args []Tagged) (Value, error) {
+	l := &List{ Data: make([]Value, len(args)) }
+	names := make([]string, len(args))
+	hasNames := false
+	for i, a := range args {
+		l.Data[i], names[i] = a.Value, a.Tag
+		hasNames = hasNames || a.Tag != ""
+	}
+	if hasNames {
+		l.Set("names", NewCharacter(names...))
+	}
+	return l, nil
+}
+
+// scalarReal returns the first element of a numeric argument as a double.
+func scalarReal(x *CallExpr, name string, v Value) (float64, error) {
+	f := asReals(v)
+	if len(f) == 0 || math.IsNaN(f[0]) {
+		return 0, evalError(x, "invalid '%s' argument", name)
+	}
+	return f[0], nil
+}
+
+// sequence returns the n numbers from + i * by, as integers if integer is true.
+func sequence(from, by float64, n int, integer bool) Value {
+	if integer {
+		s := &Integer{ Data: make([]int32, n) }
+		for i := range s.Data {
+			s.Data[i] = int32(from + float64(i) * by)
+		}
+		return s
+	}
+	s := &Real{ Data: make([]float64, n) }
+	for i := range s.Data {
+		s.Data[i] = from + float64(i) * by
+	}
+	return s
+}
+
+// evalColon returns from:to, the result is an integer vector when from is a whole number in the integer range.
+func evalColon(x Node, a, b Value) (Value, error) {
+	fa, fb := asReals(a), asReals(b)
+	if len(fa) == 0 || len(fb) == 0 {
+		return nil, evalError(x, "argument of length 0")
+	}
+	from, to := fa[0], fb[0]
+	if math.IsNaN(from) || math.IsNaN(to) {
+		return nil, evalError(x, "NA/NaN argument")
+	}
+	n := math.Floor(math.Abs(to - from) + 1e-10) + 1
+	if n > math.MaxInt32 {
+		return nil, evalError(x, "result would be too long a vector")
+	}
+	by := 1.0
+	if to < from {
+		by = -1
+	}
+	last := from + (n - 1) * by
+	integer := from == math.Floor(from) && math.Abs(from) <= math.MaxInt32 && math.Abs(last) <= math.MaxInt32
+	return sequence(from, by, int(n), integer), nil
+}
+
+// evalSeq evaluates seq(from, to, by, length.out, along.with) for numbers.
+func evalSeq(x *CallExpr, args []Tagged) (Value, error) {
+	m, err := matchConstantArgs(x, args, "from", "to", "by", "length.out", "along.with")
+	if err != nil {
+		return nil, err
+	}
+	if along := m["along.with"]; along != nil {
+		m["length.out"] = &Integer{ Data: []int32{ int32(Length(along)) } }
+	}
+	isInt := func(names ...string) bool {
+		for _, n := range names {
+			if v := m[n]; v != nil && v.Type() != INTSXP {
+				return false
+			}
+		}
+		return true
+	}
+	from, to, by, length := m["from"], m["to"], m["by"], m["length.out"]
+
+	switch {
+	case from != nil && to == nil && by == nil && length == nil && len(args) == 1 && args[0].Tag == "" :
+		// seq(n) is 1:n, seq(x) is seq_along(x)
+		if Length(from) == 1 {
+			return evalColon(x, &Integer{ Data: []int32{ 1 } }, from)
+		}
+		return sequence(1, 1, Length(from), true), nil
+	case length != nil :
+		f, err := scalarReal(x, "length.out", length)
+		if err != nil || f < 0 {
+			return nil, evalError(x, "invalid 'length.out' argument")
+		}
+		n := int(math.Ceil(f))
+		switch {
+		case from != nil && to != nil && by != nil :
+			return nil, evalError(x, "too many arguments")
+		case from != nil && to != nil :
+			a, err := scalarReal(x, "from", from)
+			if err != nil {
+				return nil, err
+			}
+			b, err := scalarReal(x, "to", to)
+			if err != nil {
+				return nil, err
+			}
+			if n == 1 {
+				return sequence(a, 0, 1, false), nil
+			}
+			return sequence(a, (b - a) / float64(n - 1), n, false), nil
+		case to != nil :
+			b, err := scalarReal(x, "to", to)
+			if err != nil {
+				return nil, err
+			}
+			step := 1.0
+			if by != nil {
+				if step, err = scalarReal(x, "by", by); err != nil {
+					return nil, err
+				}
+			}
+			return sequence(b - float64(n - 1) * step, step, n, isInt("to", "by") && by != nil), nil
+		default:
+			a, step := 1.0, 1.0
+			if from != nil {
+				if a, err = scalarReal(x, "from", from); err != nil {
+					return nil, err
+				}
+			}
+			if by != nil {
+				if step, err = scalarReal(x, "by", by); err != nil {
+					return nil, err
+				}
+			}
+			return sequence(a, step, n, (from == nil && by == nil) || isInt("from", "by")), nil
+		}
+	case by == nil :
+		a, b := Value(&Real{ Data: []float64{ 1 } }), Value(&Real{ Data: []float64{ 1 } })
+		if from != nil {
+			a = from
+		}
+		if to != nil {
+			b = to
+		}
+		return evalColon(x, a, b)
+	}
+
+	a, b := 1.0, 1.0
+	if from != nil {
+		if a, err = scalarReal(x, "from", from); err != nil {
+			return nil, err
+		}
+	}
+	if to != nil {
+		if b, err = scalarReal(x, "to", to); err != nil {
+			return nil, err
+		}
+	}
+	step, err := scalarReal(x, "by", by)
+	if err != nil {
+		return nil, err
+	}
+	if step == 0 && a == b {
+		return sequence(a, 0, 1, isInt("from", "to")), nil
+	}
+	n := (b - a) / step
+	if step == 0 || n < 0 {
+		return nil, evalError(x, "wrong sign in 'by' argument")
+	}
+	if n >= math.MaxInt32 {
+		return nil, evalError(x, "'by' argument is much too small")
+	}
+	return sequence(a, step, int(math.Floor(n + 1e-10)) + 1, from != nil && to != nil && isInt("from", "to", "by")), nil
+}
+
+// evalSeqAlong evaluates seq_along(along.with).
+func evalSeqAlong(x *CallExpr, args []Tagged) (Value, error) {
+	m, err := matchConstantArgs(x, args, "along.with")
+	if err != nil || m["along.with"] == nil {
+		return nil, evalError(x, "seq_along needs one argument")
+	}
+	return sequence(1, 1, Length(m["along.with"]), true), nil
+}
+
+// evalSeqLen evaluates seq_len(length.out).
+func evalSeqLen(x *CallExpr, args []Tagged) (Value, error) {
+	m, err := matchConstantArgs(x, args, "length.out")
+	if err != nil || m["length.out"] == nil {
+		return nil, evalError(x, "seq_len needs one argument")
+	}
+	f, err := scalarReal(x, "length.out", m["length.out"])
+	if err != nil || f < 0 || f >= math.MaxInt32 {
+		return nil, evalError(x, "argument of seq_len must be a non-negative number")
+	}
+	return sequence(1, 1, int(math.Ceil(f)), true), nil
+}
+
+// evalVector returns the evaluation of the constructor of the vectors of the type, as character(length = 0):
+// length elements of value "", 0 or FALSE.
+func evalVector(typ SexpType) func(*CallExpr, []Tagged) (Value, error) {
+	return func(x *CallExpr, args []Tagged) (Value, error) {
+		m, err := matchConstantArgs(x, args, "length")
+		if err != nil {
+			return nil, err
+		}
+		n := 0.0
+		if m["length"] != nil {
+			if n, err = scalarReal(x, "length", m["length"]); err != nil || n < 0 || n >= math.MaxInt32 {
+				return nil, evalError(x, "invalid 'length' argument")
+			}
+		}
+		switch typ {
+		case STRSXP :
+			return &Character{ Data: make([]string, int(n)) }, nil
+		case REALSXP :
+			return &Real{ Data: make([]float64, int(n)) }, nil
+		case INTSXP :
+			return &Integer{ Data: make([]int32, int(n)) }, nil
+		}
+		return &Logical{ Data: make([]int32, int(n)) }, nil
+	}
+}
+
+// evalRep evaluates rep(x, times, length.out, each).
+func evalRep(x *CallExpr, args []Tagged) (Value, error) {
+	m, err := matchConstantArgs(x, args, "x", "times", "length.out", "each")
+	if err != nil {
+		return nil, err
+	}
+	v := m["x"]
+	if v == nil {
+		return nil, evalError(x, "attempt to replicate an object of type 'symbol'")
+	}
+	if v.Type() == NILSXP {
+		return NullValue, nil
+	}
+	if _, ok := vectorRanks[v.Type()]; !ok {
+		return nil, evalError(x, "attempt to replicate an object of type '%s'", v.Type())
+	}
+
+	idx := make([]int, Length(v))
+	for i := range idx {
+		idx[i] = i
+	}
+	if each := m["each"]; each != nil {
+		f, err := scalarReal(x, "each", each)
+		if err != nil || f < 0 {
+			return nil, evalError(x, "invalid 'each' argument")
+		}
+		var e []int
+		for _, i := range idx {
+			for k := 0; k < int(f); k++ {
+				e = append(e, i)
+			}
+		}
+		idx = e
+	}
+
+	if length := m["length.out"]; length != nil && !IsNA(length, 0) {
+		f, err := scalarReal(x, "length.out", length)
+		if err != nil || f < 0 {
+			return nil, evalError(x, "invalid 'length.out' argument")
+		}
+		r := make([]int, int(f))
+		for i := range r {
+			if len(idx) == 0 {
+				return nil, evalError(x, "attempt to replicate an object of length 0")
+			}
+			r[i] = idx[i % len(idx)]
+		}
+		return selectElements(v, r), nil
+	}
+
+	if times := m["times"]; times != nil {
+		t := asReals(times)
+		var r []int
+		switch {
+		case len(t) == 1 :
+			if math.IsNaN(t[0]) || t[0] < 0 {
+				return nil, evalError(x, "invalid 'times' argument")
+			}
+			for k := 0; k < int(t[0]); k++ {
+				r = append(r, idx...)
+			}
+		case len(t) == len(idx) :
+			for i, j := range idx {
+				if math.IsNaN(t[i]) || t[i] < 0 {
+					return nil, evalError(x, "invalid 'times' argument")
+				}
+				for k := 0; k < int(t[i]); k++ {
+					r = append(r, j)
+				}
+			}
+		default:
+			return nil, evalError(x, "invalid 'times' argument")
+		}
+		idx = r
+	}
+	return selectElements(v, idx), nil
+}
+
+// evalPaste0 concatenates the arguments converted to strings element by element, NA becomes "NA".
+func evalPaste0(x *CallExpr, args []Tagged) (Value, error) {
+	var parts [][]string
+	var collapse *string
+	n := 0
+	for _, a := range args {
+		v := a.Value
+		if a.Tag == "collapse" {
+			if v.Type() == NILSXP {
+				continue
+			}
+			s, ok := v.(*Character)
+			if !ok || len(s.Data) != 1 || s.IsNA(0) {
+				return nil, evalError(x, "invalid 'collapse' argument")
+			}
+			collapse = &s.Data[0]
+			continue
+		}
+		if f, err := AsFactor(v); err == nil {
+			v = f.Values()
+		}
+		if _, ok := vectorRanks[v.Type()]; !ok || v.Type() == VECSXP {
+			return nil, evalError(x, "%s can not be converted to character", v.Type())
+		}
+		c, err := coerceVector(x, v, STRSXP)
+		if err != nil {
+			return nil, err
+		}
+		s := c.(*Character)
+		if len(s.Data) == 0 {
+			continue
+		}
+		p := make([]string, len(s.Data))
+		for i := range s.Data {
+			if s.IsNA(i) {
+				p[i] = "NA"
+			} else {
+				p[i] = s.Data[i]
+			}
+		}
+		parts = append(parts, p)
+		if len(p) > n {
+			n = len(p)
+		}
+	}
+
+	r := make([]string, n)
+	for i := range r {
+		var b strings.Builder
+		for _, p := range parts {
+			b.WriteString(p[i % len(p)])
+		}
+		r[i] = b.String()
+	}
+	if collapse != nil {
+		return NewCharacter(strings.Join(r, *collapse)), nil
+	}
+	return NewCharacter(r...), nil
+}
+
+// evalStructure returns .Data with the attributes given by the other arguments, .Names is the names attribute.
+func evalStructure(x *CallExpr, args []Tagged) (Value, error) {
+	if len(args) == 0 {
+		return nil, evalError(x, "argument .Data is missing")
+	}
+	data := args[0].Value
+	rest := args[1:]
+	for i, a := range args {
+		if a.Tag == ".Data" {
+			data = a.Value
+			rest = append(append([]Tagged{}, args[:i]...), args[i+1:]...)
+			break
+		}
+	}
+	if data.Type() == NILSXP {
+		return nil, evalError(x, "attempt to set an attribute on NULL")
+	}
+	v := copyVector(data)
+	if v == data || v.Attributes() == nil {
+		return nil, evalError(x, "can not set attributes on %s", data.Type())
+	}
+	v.Attributes().List = append([]Attribute(nil), data.Attributes().List...)
+
+	for _, a := range rest {
+		name := a.Tag
+		switch name {
+		case "" :
+			return nil, evalError(x, "attributes must be named")
+		case ".Names" :
+			name = "names"
+		}
+		switch name {
+		case "names", "class", "levels" :
+			if a.Value.Type() != STRSXP && a.Value.Type() != NILSXP {
+				return nil, evalError(x, "%s attribute must be a character vector", name)
+			}
+		}
+		if name == "names" && a.Value.Type() != NILSXP && Length(a.Value) != Length(v) {
+			return nil, evalError(x, "'names' attribute [%d] must be the same length as the vector [%d]", Length(a.Value), Length(v))
+		}
+		if name == "dim" && a.Value.Type() != NILSXP {
+			p := 1.0
+			for _, d := range asReals(a.Value) {
+				p *= d
+			}
+			if int(p) != Length(v) {
+				return nil, evalError(x, "dims [product %d] do not match the length of object [%d]", int(p), Length(v))
+			}
+		}
+		v.Attributes().Set(name, a.Value)
+	}
+	return v, nil
+}
+
+// evalUnary evaluates -x, +x and !x.
+func evalUnary(x *UnaryExpr, v Value) (Value, error) {
+	switch x.Op.Type {
+	case OP_ADD, OP_SUB, OP_NOT :
+	default:
+		return nil, evalError(x, "%s is not a constant operator", x.Op.stringvalue)
+	}
+	r, _, err := Unary(x.Op.stringvalue, v)
+	if err != nil {
+		return nil, evalError(x, "%s", err)
+	}
+	return r, nil
+}
+
+// evalArith evaluates the arithmetic operator.
+func evalArith(x Node, op string, a, b Value) (Value, error) {
+	r, _, err := Arith(op, a, b)
+	if err != nil {
+		return nil, evalError(x, "%s", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,154 @@
+package r
+
+import "testing"
+import "strings"
+
+// evalString returns the type, the elements and the names of a vector for the tests.
+func evalString(v Value) string {
+	s := &Character{}
+	if c, _, err := Coerce(v, STRSXP); err == nil {
+		s = c.(*Character)
+	}
+	elements := make([]string, len(s.Data))
+	for i := range s.Data {
+		elements[i] = s.Data[i]
+		if s.IsNA(i) {
+			elements[i] = "NA"
+		}
+	}
+	r := v.Type().String() + " " + strings.Join(elements, " ")
+	if names := Names(v); names != nil {
+		r += " | " + strings.Join(names, " ")
+	}
+	return r
+}
+
+func TestEvalConstant(e *testing.T) {
+	var tests = []struct {
+		src    string
+		result string
+	}{
+		{ "1L", "integer 1" },
+		{ "c(123456789012345678901L, 1e3L)", "double 1.23456789012346e+20 1000" },
+		{ "-0x80000000L", "double -2147483648" },
+		{ "-(2)", "double -2" },
+		{ "-TRUE", "integer -1" },
+		{ "!c(0, 2, NA)", "logical TRUE FALSE NA" },
+		{ "1L + 2L", "integer 3" },
+		{ "1L / 2L", "double 0.5" },
+		{ "2 ** 10", "double 1024" },
+		{ "c(1, 2, 3, 4) * c(10, 100)", "double 10 200 30 400" },
+		{ "2147483647L + 1L", "integer NA" },
+		{ "-7L %% 3L", "integer 2" },
+		{ "7 %/% -2", "double -4" },
+		{ "NA_integer_ * 0L", "integer NA" },
+		{ "NA ^ 0", "double 1" },
+		{ "0 / 0", "double NaN" },
+		{ "1i * 1i", "complex -1+0i" },
+		{ "c(a = 1, b = 2) + 1", "double 2 3 | a b" },
+		{ "1:3", "integer 1 2 3" },
+		{ "3:1", "integer 3 2 1" },
+		{ "1.5:3", "double 1.5 2.5" },
+		{ "c(1L, 2.5, TRUE)", "double 1 2.5 1" },
+		{ "c(1, \"a\", NA)", "character 1 a NA" },
+		{ "c(100000, 123456, 0.1, 1/3)", "double 1e+05 123456 0.1 0.333333333333333" },
+		{ "c(a = 1, b = c(x = 2, 3), c = 4:5)", "double 1 2 3 4 5 | a b.x b2 c1 c2" },
+		{ "c(NULL, NULL)", "NULL " },
+		{ "seq(5)", "integer 1 2 3 4 5" },
+		{ "seq(2, 11, by = 3)", "double 2 5 8 11" },
+		{ "seq(2L, 11L, 3L)", "integer 2 5 8 11" },
+		{ "seq(0, 1, length.out = 5)", "double 0 0.25 0.5 0.75 1" },
+		{ "seq(10, len = 3)", "double 10 11 12" },
+		{ "seq(to = 10, by = 2, length.out = 3)", "double 6 8 10" },
+		{ "seq(1, 2)", "integer 1 2" },
+		{ "seq(c(\"a\", \"b\"))", "integer 1 2" },
+		{ "seq_len(3)", "integer 1 2 3" },
+		{ "base::seq_along(c(\"a\", \"b\"))", "integer 1 2" },
+		{ "rep(1:2, 2)", "integer 1 2 1 2" },
+		{ "rep(1:2, each = 2)", "integer 1 1 2 2" },
+		{ "rep(c(\"a\", \"b\"), times = c(1, 3))", "character a b b b" },
+		{ "rep_len <- 1", "" },
+		{ "rep(c(x = TRUE), length.out = 3)", "logical TRUE TRUE TRUE | x x x" },
+		{ "paste0(\"x\", 1:3)", "character x1 x2 x3" },
+		{ "paste0(\"a\", NA, character(0))", "character aNA" },
+		{ "paste0(\"a\", NA, NULL, TRUE)", "character aNATRUE" },
+		{ "paste0(c(\"a\", \"b\"), collapse = \"+\")", "character a+b" },
+		{ "paste0()", "character " },
+		{ "structure(1:2, .Names = c(\"a\", \"b\"))", "integer 1 2 | a b" },
+		{ "structure(1:4, dim = c(2L, 2L))", "integer 1 2 3 4" },
+		{ "c(a = 1L)", "integer 1 | a" },
+		{ "list(a = 1L, b = c(\"x\", \"y\"), c = TRUE)", "list  | a b c" },
+		{ "character(0)", "character " },
+		{ "numeric(2)", "double 0 0" },
+		{ "logical(length = 1L)", "logical FALSE" },
+		{ "structure(list(), .Names = character(0))", "list  | " },
+		{ "c(a = 1L) + 0.5", "double 1.5 | a" },
+		{ "paste0(1e5, \"-\", 0.1 + 0.2)", "character 1e+05-0.3" },
+		{ "x + 1", "" },
+		{ "f(1)", "" },
+		{ "utils::head(1)", "" },
+		{ "\"a\" + 1", "" },
+		{ "c(1, function(x) x)", "" },
+		{ "seq(1, 10, by = -1)", "" },
+		{ "rep(1:2, times = 1:3)", "" },
+		{ "structure(1:2, names = \"a\")", "" },
+		{ "structure(1:4, dim = 3L)", "" },
+		{ "1 < 2", "" },
+		{ "integer(-1)", "" },
+		{ "list(1, )", "" },
+	}
+	for i, test := range tests {
+		x, err := ParseExpr(test.src)
+		if err != nil {
+			e.Error("Test EvalConstant[", i, "] Failed with parse error", err)
+			continue
+		}
+		v, err := EvalConstant(x)
+		if test.result == "" {
+			if _, ok := err.(*EvalError); !ok {
+				e.Error("Test EvalConstant[", i, "] Failed: no error on", test.src, err)
+			}
+			continue
+		}
+		if err != nil {
+			e.Error("Test EvalConstant[", i, "] Failed with error", err)
+			continue
+		}
+		if s := evalString(v); s != test.result {
+			e.Error("Test EvalConstant[", i, "] Failed: expected", test.result, "instead of", s)
+		}
+	}
+}
+
+func TestEvalConstantConfig(e *testing.T) {
+	src := `list(name = "model", sizes = c(small = 1L, large = 10L), tags = c("x", "y"), debug = FALSE,
+	             grid = seq(0, 1, by = 0.5), levels = structure(1:2, levels = c("lo", "hi"), class = "factor"))`
+	x, err := ParseExpr(src)
+	if err != nil {
+		e.Fatal(err)
+	}
+	v, err := EvalConstant(x)
+	if err != nil {
+		e.Fatal(err)
+	}
+	l, ok := v.(*List)
+	if !ok || strings.Join(Names(l), " ") != "name sizes tags debug grid levels" {
+		e.Fatal("Test EvalConstantConfig Failed:", v)
+	}
+	var results = []string{ "character model", "integer 1 10 | small large", "character x y", "logical FALSE", "double 0 0.5 1" }
+	for i, r := range results {
+		if s := evalString(l.Data[i]); s != r {
+			e.Error("Test EvalConstantConfig[", i, "] Failed: expected", r, "instead of", s)
+		}
+	}
+	f, err := AsFactor(l.Data[5])
+	if err != nil || strings.Join(f.Values().Data, " ") != "lo hi" {
+		e.Error("Test EvalConstantConfig Failed: factor", f, err)
+	}
+
+	// The error gives the position of the non-constant expression
+	x, _ = ParseExpr("list(a = 1,\n  b = Sys.time())")
+	if _, err = EvalConstant(x); err == nil || err.Error() != "2:7: call of Sys.time is not constant" {
+		e.Error("Test EvalConstantConfig Failed: error", err)
+	}
+}
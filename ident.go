@@ -0,0 +1,159 @@
+package r
+
+import "fmt"
+import "unicode"
+import "unicode/utf8"
+
+// Locale is the character classification of the symbols: R accepts the letters and digits of the current locale
+// in the symbols, isalnum() in a single byte locale and iswalnum() in a UTF-8 locale.
+type Locale int
+
+const (
+	// UTF-8 locale: the Unicode letters and decimal digits
+	LOCALE_UTF8 Locale = iota
+	// C locale: the ASCII letters and digits only
+	LOCALE_C
+)
+
+func (this Locale) String() (s string) {
+	switch this {
+	case LOCALE_UTF8 : s = "UTF-8"
+	case LOCALE_C : s = "C"
+	}
+	return
+}
+
+// isLetter reports whether r is a letter of the locale.
+func isLetter(r rune, locale Locale) bool {
+	if locale == LOCALE_C {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}
+	return unicode.IsLetter(r)
+}
+
+// isDigit reports whether r is a digit of the locale.
+func isDigit(r rune, locale Locale) bool {
+	if locale == LOCALE_C {
+		return r >= '0' && r <= '9'
+	}
+	return unicode.IsDigit(r)
+}
+
+// IsIdentStart reports whether a symbol can start with r: a letter of the locale or '.'. A '.' followed by a
+// digit starts a number.
+func IsIdentStart(r rune, locale Locale) bool {
+	return r == '.' || isLetter(r, locale)
+}
+
+// IsIdentRune reports whether r can follow the first character of a symbol: a letter or a digit of the locale,
+// '.' or '_'.
+func IsIdentRune(r rune, locale Locale) bool {
+	return r == '.' || r == '_' || isLetter(r, locale) || isDigit(r, locale)
+}
+
+// reserved are the words that can not be used as symbols without backquotes.
+var reserved = map[string]bool{
+	"if": true, "else": true, "repeat": true, "while": true, "function": true, "for": true, "next": true,
+	"break": true, "in": true, "TRUE": true, "FALSE": true, "NULL": true, "Inf": true, "NaN": true, "NA": true,
+	"NA_integer_": true, "NA_real_": true, "NA_character_": true, "NA_complex_": true,
+}
+
+// IsSyntacticName reports whether name is parsed as a symbol without backquotes in the locale: it starts with a
+// letter or with a '.' not followed by a digit, continues with letters, digits, '.' and '_', and is not a
+// reserved word. The ... and ..1, ..2 symbols are syntactic.
+func IsSyntacticName(name string, locale Locale) bool {
+	if name == "" || reserved[name] {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == utf8.RuneError :
+			return false
+		case i == 0 :
+			if !IsIdentStart(r, locale) {
+				return false
+			}
+		case i == 1 && name[0] == '.' :
+			if r >= '0' && r <= '9' {
+				return false
+			}
+			fallthrough
+		default:
+			if !IsIdentRune(r, locale) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// symbolType returns the type of the token of the symbol name: DOTS for ..., DOTDOT_N and N for ..N, SYMBOL
+// otherwise.
+func symbolType(name string) (TokenType, int64) {
+	if name == "..." {
+		return DOTS, 0
+	}
+	if len(name) > 2 && name[:2] == ".." {
+		var n int64
+		for _, c := range name[2:] {
+			if c < '0' || c > '9' {
+				return SYMBOL, 0
+			}
+			if n < 1 << 31 {
+				n = n*10 + int64(c-'0')
+			}
+		}
+		return DOTDOT_N, n
+	}
+	return SYMBOL, 0
+}
+
+// isSymbol reports whether t is a symbol: SYMBOL, DOTS or DOTDOT_N.
+func isSymbol(t *Token) bool {
+	return t.Type == SYMBOL || t.Type == DOTS || t.Type == DOTDOT_N
+}
+
+// IdentError is a symbol, or a character where a symbol is expected, that R rejects.
+type IdentError struct {
+	Pos  Position
+	Text string
+	Msg  string
+}
+
+func (this *IdentError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+// SetLocale sets the locale of the classification of the characters of the symbols, LOCALE_UTF8 by default.
+func (this *Scanner) SetLocale(locale Locale) {
+	this.locale = locale
+}
+
+// IdentErrors returns the symbols R rejects found so far: a symbol glued to a number (2x, .2x), a symbol
+// starting with '_', and the characters that are neither letters of the locale nor R operators.
+func (this *Scanner) IdentErrors() []*IdentError {
+	return this.identErrors
+}
+
+// checkIdent records a symbol t glued to the number last.
+func (this *Scanner) checkIdent(last, t *Token) {
+	if !isSymbol(t) || last == nil || last.End() != t.offset {
+		return
+	}
+	switch last.Type {
+	case CONST_INTEGER, CONST_REAL, CONST_COMPLEX :
+		this.identErrors = append(this.identErrors, &IdentError{ t.Pos(), t.stringvalue, "unexpected symbol " + t.stringvalue + " after the number " + last.stringvalue })
+	}
+}
+
+// unexpected records the character r that starts no token.
+func (this *Scanner) unexpected(r rune, t *Token) {
+	msg := fmt.Sprintf("unexpected input %q", r)
+	switch {
+	case r == '_' :
+		msg = "symbol starting with _"
+	case unicode.IsLetter(r) :
+		msg = fmt.Sprintf("%q is not a letter of the %s locale", r, this.locale)
+	}
+	this.identErrors = append(this.identErrors, &IdentError{ t.Pos(), string(r), msg })
+}
@@ -0,0 +1,80 @@
+package r
+
+import "strings"
+import "testing"
+
+func TestIsSyntacticName(e *testing.T) {
+	tests := []struct {
+		name      string
+		utf8, c   bool
+	}{
+		{ "x", true, true },
+		{ "x.y_z1", true, true },
+		{ ".", true, true },
+		{ ".x", true, true },
+		{ "._", true, true },
+		{ "...", true, true },
+		{ "..1", true, true },
+		{ "..10", true, true },
+		{ ".2x", false, false },
+		{ "2x", false, false },
+		{ "_x", false, false },
+		{ "x y", false, false },
+		{ "café", true, false },
+		{ "données", true, false },
+		{ "x٣", true, false },
+		{ "x→y", false, false },
+		{ "if", false, false },
+		{ "NA_real_", false, false },
+		{ "TRUE.x", true, true },
+		{ "", false, false },
+	}
+	for i, test := range tests {
+		if IsSyntacticName(test.name, LOCALE_UTF8) != test.utf8 || IsSyntacticName(test.name, LOCALE_C) != test.c {
+			e.Error("Test IsSyntacticName[", i, "] Failed:", test.name)
+		}
+	}
+}
+
+func TestIdentErrors(e *testing.T) {
+	tests := []struct {
+		src    string
+		locale Locale
+		tokens string
+		errors string
+	}{
+		{ "café <- ..1 + ...", LOCALE_UTF8, "SYMBOL:café LEFT_ASSIGN DOTDOT_N:..1 ADD DOTS:...", "" },
+		{ "café", LOCALE_C, "SYMBOL:caf ERROR", "1:4: 'é' is not a letter of the C locale;" },
+		{ "x <- _y", LOCALE_UTF8, "SYMBOL:x LEFT_ASSIGN ERROR SYMBOL:y", "1:6: symbol starting with _;" },
+		{ "2x + .2y + 1Lz + 2 x", LOCALE_UTF8, "CONST_REAL:2 SYMBOL:x ADD CONST_REAL:.2 SYMBOL:y ADD CONST_INTEGER:1L SYMBOL:z ADD CONST_REAL:2 SYMBOL:x",
+			"1:2: unexpected symbol x after the number 2;1:8: unexpected symbol y after the number .2;1:14: unexpected symbol z after the number 1L;" },
+		{ "a→b", LOCALE_UTF8, "SYMBOL:a ERROR SYMBOL:b", "1:2: unexpected input '→';" },
+		{ "f(\\x)", LOCALE_UTF8, "SYMBOL:f LEFT_ROUND ERROR SYMBOL:x RIGHT_ROUND", "1:3: unexpected input '\\\\';" },
+	}
+	for i, test := range tests {
+		s := NewScanner(strings.NewReader(test.src))
+		s.SetLocale(test.locale)
+		var tokens []string
+		for t := s.NextToken(); t.Type != END_OF_INPUT; t = s.NextToken() {
+			switch t.Type {
+			case SYMBOL, DOTS, DOTDOT_N, CONST_REAL, CONST_INTEGER :
+				tokens = append(tokens, t.Type.String() + ":" + t.stringvalue)
+			default:
+				tokens = append(tokens, t.Type.String())
+			}
+		}
+		var errors string
+		for _, err := range s.IdentErrors() {
+			errors += err.Error() + ";"
+		}
+		if strings.Join(tokens, " ") != test.tokens || errors != test.errors {
+			e.Error("Test IdentErrors[", i, "] Failed:", strings.Join(tokens, " "), errors)
+		}
+	}
+
+	p := NewParser(strings.NewReader("x <- 2y"))
+	p.SetLocale(LOCALE_C)
+	if _, err := p.Parse(); err == nil || len(p.IdentErrors()) != 1 {
+		e.Error("Test IdentErrors Failed: parser", err, p.IdentErrors())
+	}
+}
@@ -0,0 +1,690 @@
+package r
+
+import "fmt"
+import "math"
+import "sort"
+
+// Shape is the likely type and length of the value of an expression.
+type Shape struct {
+	// LGLSXP, INTSXP, REALSXP, CPLXSXP, STRSXP, VECSXP, CLOSXP, NILSXP, LANGSXP or ANYSXP when unknown
+	Type SexpType
+	// Number of elements, -1 when unknown
+	Length int
+}
+
+// AnyShape is the shape of an expression of unknown type and length.
+var AnyShape = Shape{ ANYSXP, -1 }
+
+// String returns the type and the length of the shape: double[1], character[?], any.
+func (this Shape) String() string {
+	if this.Type == ANYSXP {
+		return "any"
+	}
+	if this.Length < 0 {
+		return this.Type.String() + "[?]"
+	}
+	return fmt.Sprintf("%s[%d]", this.Type, this.Length)
+}
+
+// IsNumeric reports whether the shape is a logical, integer, double or complex vector.
+func (this Shape) IsNumeric() bool {
+	return this.Type == LGLSXP || this.Type == INTSXP || this.Type == REALSXP || this.Type == CPLXSXP
+}
+
+// join returns the shape of a value that is either a or b.
+func (this Shape) join(b Shape) Shape {
+	if this.Type != b.Type {
+		return AnyShape
+	}
+	if this.Length != b.Length {
+		return Shape{ this.Type, -1 }
+	}
+	return this
+}
+
+// variable is the shape of a variable and, for a function, the shape of its value and the variables it assigns
+// with <<-.
+type variable struct {
+	shape  Shape
+	result *Shape
+	writes []string
+}
+
+// typeScope is the variables of a function, or of the top level.
+type typeScope struct {
+	vars   map[string]variable
+	parent *typeScope
+}
+
+func (this *typeScope) lookup(name string) (variable, bool) {
+	for s := this; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return variable{}, false
+}
+
+// forget sets the variable to an unknown value in the scope that holds it.
+func (this *typeScope) forget(name string) {
+	for s := this; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			s.vars[name] = variable{ shape: AnyShape }
+			return
+		}
+	}
+}
+
+func (this *typeScope) copy() *typeScope {
+	c := &typeScope{ make(map[string]variable), this.parent }
+	for name, v := range this.vars {
+		c.vars[name] = v
+	}
+	return c
+}
+
+// merge joins the variables of the scope with the ones of other, a variable assigned in one of them only is
+// unknown.
+func (this *typeScope) merge(other *typeScope) {
+	for name, v := range this.vars {
+		if o, ok := other.vars[name]; ok {
+			v.shape = v.shape.join(o.shape)
+			if v.result != o.result {
+				v.result, v.writes = nil, append(append([]string{}, v.writes...), o.writes...)
+			}
+			this.vars[name] = v
+		} else {
+			this.vars[name] = variable{ shape: AnyShape }
+		}
+	}
+	for name := range other.vars {
+		if _, ok := this.vars[name]; !ok {
+			this.vars[name] = variable{ shape: AnyShape }
+		}
+	}
+}
+
+// TypeError is a likely mistake found by the type inference.
+type TypeError struct {
+	Pos Position
+	Msg string
+}
+
+func (this *TypeError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+// Types is the result of the type inference of a file: the shapes of its expressions.
+type Types struct {
+	shapes  map[Expr]Shape
+	// Shapes of the values of the functions
+	results map[*FunctionExpr]Shape
+	Errors  []*TypeError
+}
+
+// builtinShapes are the base variables of known shape.
+var builtinShapes = map[string]Shape{
+	"T": { LGLSXP, 1 }, "F": { LGLSXP, 1 }, "pi": { REALSXP, 1 }, "LETTERS": { STRSXP, 26 },
+	"letters": { STRSXP, 26 }, "month.name": { STRSXP, 12 }, "month.abb": { STRSXP, 12 },
+}
+
+// InferTypes annotates the expressions of the file with their likely shape, in evaluation order: the literals
+// have the type of their token, the operators and the calls of base functions follow the coercion rules of R,
+// and the variables have the shape of their last assignment. The branches of an if and the loops join the shapes
+// of their variables. The formals, the variables given to assign() and the ones assigned with <<- by a called
+// function are of unknown shape. Comparing a character to a number and the arithmetic on characters are reported
+// as errors.
+func InferTypes(f *File) *Types {
+	t := &Types{ shapes: make(map[Expr]Shape), results: make(map[*FunctionExpr]Shape) }
+	s := &typeScope{ vars: make(map[string]variable) }
+	for _, x := range f.Exprs {
+		t.infer(x, s)
+	}
+	sort.SliceStable(t.Errors, func(i, j int) bool { return t.Errors[i].Pos.Offset < t.Errors[j].Pos.Offset })
+	return t
+}
+
+// Shape returns the shape of the expression x, AnyShape for an expression that is not annotated.
+func (this *Types) Shape(x Expr) Shape {
+	if s, ok := this.shapes[x]; ok {
+		return s
+	}
+	return AnyShape
+}
+
+func (this *Types) errorf(x Node, format string, a ...interface{}) {
+	this.Errors = append(this.Errors, &TypeError{ x.Pos(), fmt.Sprintf(format, a...) })
+}
+
+// infer returns the shape of x and records it with the ones of its subexpressions.
+func (this *Types) infer(x Expr, s *typeScope) (shape Shape) {
+	shape = this.shape(x, s)
+	this.shapes[x] = shape
+	return
+}
+
+func (this *Types) shape(x Expr, s *typeScope) Shape {
+	switch v := x.(type) {
+	case *Constant :
+		c, err := constantValue(v.Token)
+		if err != nil {
+			return AnyShape
+		}
+		return Shape{ c.Type(), Length(c) }
+	case *Ident :
+		if variable, ok := s.lookup(v.Token.stringvalue); ok {
+			return variable.shape
+		}
+		if b, ok := builtinShapes[v.Token.stringvalue]; ok {
+			return b
+		}
+		if _, ok := LookupFunction("", v.Token.stringvalue); ok {
+			return Shape{ CLOSXP, 1 }
+		}
+		return AnyShape
+	case *ParenExpr :
+		return this.infer(v.X, s)
+	case *BlockExpr :
+		shape := Shape{ NILSXP, 0 }
+		for _, y := range v.List {
+			shape = this.infer(y, s)
+		}
+		return shape
+	case *UnaryExpr :
+		return this.unary(v, s)
+	case *BinaryExpr :
+		return this.binary(v, s)
+	case *CallExpr :
+		return this.call(v, s)
+	case *IndexExpr :
+		return this.index(v, s)
+	case *FunctionExpr :
+		this.results[v] = this.function(v, s)
+		return Shape{ CLOSXP, 1 }
+	case *IfExpr :
+		this.infer(v.Cond, s)
+		then := s.copy()
+		shape := this.infer(v.Then, then)
+		otherwise := s.copy()
+		if v.Else != nil {
+			shape = shape.join(this.infer(v.Else, otherwise))
+		} else {
+			shape = shape.join(Shape{ NILSXP, 0 })
+		}
+		then.merge(otherwise)
+		s.vars = then.vars
+		return shape
+	case *ForExpr :
+		seq := this.infer(v.Seq, s)
+		elem := AnyShape
+		if seq.IsNumeric() || seq.Type == STRSXP {
+			elem = Shape{ seq.Type, 1 }
+		}
+		s.vars[v.Var.stringvalue] = variable{ shape: elem }
+		this.loop(v.Body, s)
+		return Shape{ NILSXP, 0 }
+	case *WhileExpr :
+		this.infer(v.Cond, s)
+		this.loop(v.Body, s)
+		return Shape{ NILSXP, 0 }
+	case *RepeatExpr :
+		this.loop(v.Body, s)
+		return Shape{ NILSXP, 0 }
+	}
+	return AnyShape
+}
+
+// loop infers the body of a loop, run zero or more times.
+func (this *Types) loop(body Expr, s *typeScope) {
+	inner := s.copy()
+	this.infer(body, inner)
+	inner.merge(s)
+	s.vars = inner.vars
+}
+
+// function infers the body of fn in a new scope, and returns the shape of its value.
+func (this *Types) function(fn *FunctionExpr, s *typeScope) Shape {
+	inner := &typeScope{ make(map[string]variable), s }
+	for _, f := range fn.Formals {
+		// the default is a value among the ones the caller may give
+		if f.Default != nil {
+			this.infer(f.Default, inner)
+		}
+		inner.vars[f.Name.stringvalue] = variable{ shape: AnyShape }
+	}
+	return this.infer(fn.Body, inner)
+}
+
+// superAssigned returns the names of the variables assigned with <<- or ->> in the function fn.
+func superAssigned(fn *FunctionExpr) (names []string) {
+	Inspect(fn.Body, func(n Node) bool {
+		if b, ok := n.(*BinaryExpr); ok {
+			var target Expr
+			switch b.Op.Type {
+			case OP_LEFT_ASSIGN2 :
+				target = b.X
+			case OP_RIGHT_ASSIGN2 :
+				target = b.Y
+			}
+			switch t := target.(type) {
+			case *Ident :
+				names = append(names, t.Token.stringvalue)
+			case *Constant :
+				if t.Token.Type == CONST_CHARACTER {
+					names = append(names, t.Token.stringvalue)
+				}
+			}
+		}
+		return true
+	})
+	return
+}
+
+// assign records the shape of the variable assigned by target.
+func (this *Types) assign(target Expr, value Expr, shape Shape, s *typeScope, super bool) {
+	var name string
+	switch t := target.(type) {
+	case *Ident :
+		name = t.Token.stringvalue
+	case *Constant :
+		if t.Token.Type != CONST_CHARACTER {
+			return
+		}
+		name = t.Token.stringvalue
+	default:
+		// a replacement changes the shape of the variable
+		for {
+			switch t := target.(type) {
+			case *IndexExpr :
+				target = t.X
+				continue
+			case *CallExpr :
+				if len(t.Args) > 0 && t.Args[0].Value != nil {
+					target = t.Args[0].Value
+					continue
+				}
+			case *BinaryExpr :
+				target = t.X
+				continue
+			}
+			break
+		}
+		this.infer(target, s)
+		if id, ok := target.(*Ident); ok && !super {
+			if v, ok := s.vars[id.Token.stringvalue]; ok {
+				v.shape.Length = -1
+				s.vars[id.Token.stringvalue] = v
+			}
+		}
+		return
+	}
+	if super {
+		return
+	}
+	v := variable{ shape: shape }
+	if fn, ok := value.(*FunctionExpr); ok {
+		r := this.results[fn]
+		v.result, v.writes = &r, superAssigned(fn)
+	}
+	s.vars[name] = v
+}
+
+func (this *Types) unary(x *UnaryExpr, s *typeScope) Shape {
+	a := this.infer(x.X, s)
+	switch x.Op.Type {
+	case OP_NOT :
+		return Shape{ LGLSXP, a.Length }
+	case OP_ADD, OP_SUB :
+		if a.Type == STRSXP {
+			this.errorf(x, "invalid argument to unary operator")
+			return AnyShape
+		}
+		if a.Type == LGLSXP {
+			return Shape{ INTSXP, a.Length }
+		}
+		if a.IsNumeric() {
+			return a
+		}
+	case OP_TILDE :
+		return Shape{ LANGSXP, 2 }
+	}
+	return AnyShape
+}
+
+// recycled returns the length of the result of an operator on vectors of lengths a and b.
+func recycled(a, b int) int {
+	switch {
+	case a == 0 || b == 0 :
+		return 0
+	case a < 0 || b < 0 :
+		return -1
+	case a > b :
+		return a
+	}
+	return b
+}
+
+func (this *Types) binary(x *BinaryExpr, s *typeScope) Shape {
+	switch x.Op.Type {
+	case OP_LEFT_ASSIGN, OP_EQUAL_ASSIGN, OP_LEFT_ASSIGN2 :
+		shape := this.infer(x.Y, s)
+		this.assign(x.X, x.Y, shape, s, x.Op.Type == OP_LEFT_ASSIGN2)
+		return shape
+	case OP_RIGHT_ASSIGN, OP_RIGHT_ASSIGN2 :
+		shape := this.infer(x.X, s)
+		this.assign(x.Y, x.X, shape, s, x.Op.Type == OP_RIGHT_ASSIGN2)
+		return shape
+	case OP_DOLLAR, OP_AT :
+		this.infer(x.X, s)
+		return AnyShape
+	case OP_NAMESPACE, OP_NAMESPACE_INTERNAL :
+		return AnyShape
+	case OP_TILDE :
+		return Shape{ LANGSXP, 3 }
+	}
+	a, b := this.infer(x.X, s), this.infer(x.Y, s)
+	switch x.Op.Type {
+	case OP_AND2, OP_OR2 :
+		return Shape{ LGLSXP, 1 }
+	case OP_AND, OP_OR :
+		return Shape{ LGLSXP, recycled(a.Length, b.Length) }
+	case OP_GT, OP_GE, OP_LT, OP_LE, OP_EQ, OP_NE :
+		if (a.Type == STRSXP && b.IsNumeric() && b.Type != LGLSXP) || (b.Type == STRSXP && a.IsNumeric() && a.Type != LGLSXP) {
+			this.errorf(x, "comparison of character with %s: the number is compared as a string", a.numberType(b))
+		}
+		return Shape{ LGLSXP, recycled(a.Length, b.Length) }
+	case OP_COLON :
+		return colonShape(x, a, b)
+	case OP_ADD, OP_SUB, OP_MUL, OP_DIV, OP_POW, OP_MUL2, INFIX :
+		op := x.Op.stringvalue
+		if x.Op.Type == INFIX && op != "%%" && op != "%/%" {
+			return AnyShape
+		}
+		if a.Type == STRSXP || b.Type == STRSXP {
+			this.errorf(x, "non-numeric argument to binary operator")
+			return AnyShape
+		}
+		if !a.IsNumeric() || !b.IsNumeric() {
+			return AnyShape
+		}
+		typ := REALSXP
+		switch {
+		case a.Type == CPLXSXP || b.Type == CPLXSXP :
+			typ = CPLXSXP
+		case x.Op.Type == OP_DIV || x.Op.Type == OP_POW || x.Op.Type == OP_MUL2 :
+		case a.Type != REALSXP && b.Type != REALSXP :
+			typ = INTSXP
+		}
+		return Shape{ typ, recycled(a.Length, b.Length) }
+	}
+	return AnyShape
+}
+
+// numberType returns the type of the shape among this and b that is not a character vector.
+func (this Shape) numberType(b Shape) SexpType {
+	if this.Type == STRSXP {
+		return b.Type
+	}
+	return this.Type
+}
+
+// constNumber returns the value of a numeric literal, possibly negated or parenthesized.
+func constNumber(x Expr) (float64, bool) {
+	switch v := x.(type) {
+	case *Constant :
+		switch v.Token.Type {
+		case CONST_INTEGER :
+			return v.Token.realvalue, true
+		case CONST_REAL :
+			return v.Token.realvalue, true
+		case CONST_TRUE :
+			return 1, true
+		case CONST_FALSE :
+			return 0, true
+		}
+	case *ParenExpr :
+		return constNumber(v.X)
+	case *UnaryExpr :
+		if f, ok := constNumber(v.X); ok && (v.Op.Type == OP_SUB || v.Op.Type == OP_ADD) {
+			if v.Op.Type == OP_SUB {
+				f = -f
+			}
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// colonShape returns the shape of from:to: integer when from is a whole number, with the length of the sequence
+// when both are literals.
+func colonShape(x *BinaryExpr, a, b Shape) Shape {
+	from, ok1 := constNumber(x.X)
+	to, ok2 := constNumber(x.Y)
+	if !ok1 || !ok2 || math.IsInf(from, 0) || math.IsInf(to, 0) || math.IsNaN(from) || math.IsNaN(to) {
+		if a.Type == INTSXP || a.Type == LGLSXP {
+			return Shape{ INTSXP, -1 }
+		}
+		return AnyShape
+	}
+	n := int(math.Floor(math.Abs(to - from) + 1e-10)) + 1
+	if from == math.Trunc(from) && math.Abs(from) <= math.MaxInt32 && math.Abs(to) <= math.MaxInt32 {
+		return Shape{ INTSXP, n }
+	}
+	return Shape{ REALSXP, n }
+}
+
+func (this *Types) index(x *IndexExpr, s *typeScope) Shape {
+	a := this.infer(x.X, s)
+	for _, arg := range x.Args {
+		if arg.Value != nil {
+			this.infer(arg.Value, s)
+		}
+	}
+	switch {
+	case a.Type == ANYSXP || a.Type == NILSXP :
+		return AnyShape
+	case x.Lbrack.Type == OP_LEFT_SQUARE2 && a.Type == VECSXP :
+		return AnyShape
+	case x.Lbrack.Type == OP_LEFT_SQUARE2 :
+		return Shape{ a.Type, 1 }
+	}
+	return Shape{ a.Type, -1 }
+}
+
+// vectorTypes are the types of the vector constructors and of the modes of vector().
+var vectorTypes = map[string]SexpType{
+	"logical": LGLSXP, "integer": INTSXP, "numeric": REALSXP, "double": REALSXP, "complex": CPLXSXP,
+	"character": STRSXP, "list": VECSXP,
+}
+
+// coercions are the types returned by the as.* functions.
+var coercions = map[string]SexpType{
+	"as.logical": LGLSXP, "as.integer": INTSXP, "as.numeric": REALSXP, "as.double": REALSXP,
+	"as.complex": CPLXSXP, "as.character": STRSXP, "as.list": VECSXP,
+}
+
+// callArgs is the arguments of a call matched to the formals of the function.
+type callArgs struct {
+	types  *Types
+	shapes map[string]Shape
+	values map[string]Expr
+	all    []Shape
+}
+
+func (this callArgs) shape(formal string) Shape {
+	if s, ok := this.shapes[formal]; ok {
+		return s
+	}
+	return AnyShape
+}
+
+// length returns the value of the argument formal when it is a literal count, def when it is not given.
+func (this callArgs) length(formal string, def int) int {
+	x, ok := this.values[formal]
+	if !ok {
+		return def
+	}
+	if f, ok := constNumber(x); ok && f >= 0 && f < math.MaxInt32 {
+		return int(f)
+	}
+	return -1
+}
+
+func (this *Types) call(c *CallExpr, s *typeScope) Shape {
+	name := c.FunctionName()
+	this.infer(c.Fun, s)
+	args := callArgs{ types: this, shapes: make(map[string]Shape), values: make(map[string]Expr) }
+	for _, a := range c.Args {
+		if a.Value != nil {
+			args.all = append(args.all, this.infer(a.Value, s))
+		}
+	}
+	if id, ok := c.Fun.(*Ident); ok {
+		if v, ok := s.lookup(id.Token.stringvalue); ok {
+			// the variables assigned by the function with <<- may have any value
+			for _, name := range v.writes {
+				s.forget(name)
+			}
+			if v.result != nil {
+				return *v.result
+			}
+			return AnyShape
+		}
+	}
+	if sig, ok := LookupFunction("", name); ok {
+		for _, m := range MatchArgs(c, sig.Formals).Args {
+			if m.Kind != MATCH_UNUSED && m.Kind != MATCH_DOTS && m.Arg.Value != nil {
+				args.shapes[m.Formal], args.values[m.Formal] = this.shapes[m.Arg.Value], m.Arg.Value
+			}
+		}
+	}
+	x := args.shape("x")
+
+	switch name {
+	case "assign" :
+		if n, ok := args.values["x"].(*Constant); ok && n.Token.Type == CONST_CHARACTER {
+			s.vars[n.Token.stringvalue] = variable{ shape: AnyShape }
+		}
+	case "c" :
+		return combined(args.all)
+	case "list" :
+		return Shape{ VECSXP, len(c.Args) }
+	case "vapply" :
+		if v, ok := args.shapes["FUN.VALUE"]; ok && v.Type != ANYSXP {
+			if v.Length == 1 {
+				return Shape{ v.Type, args.shape("X").Length }
+			}
+			return Shape{ v.Type, -1 }
+		}
+	case "lapply" :
+		return Shape{ VECSXP, args.shape("X").Length }
+	case "Map", "strsplit", "data.frame" :
+		return Shape{ VECSXP, -1 }
+	case "vector" :
+		if mode, ok := args.values["mode"].(*Constant); ok && mode.Token.Type == CONST_CHARACTER {
+			if t, ok := vectorTypes[mode.Token.stringvalue]; ok {
+				return Shape{ t, args.length("length", 0) }
+			}
+		} else if args.values["mode"] == nil {
+			return Shape{ LGLSXP, args.length("length", 0) }
+		}
+	case "logical", "integer", "numeric", "double", "complex", "character" :
+		return Shape{ vectorTypes[name], args.length("length", 0) }
+	case "as.logical", "as.integer", "as.numeric", "as.double", "as.complex", "as.character" :
+		return Shape{ coercions[name], x.Length }
+	case "length", "nrow", "ncol", "nlevels" :
+		return Shape{ INTSXP, 1 }
+	case "is.na" :
+		return Shape{ LGLSXP, x.Length }
+	case "is.null", "is.function", "is.character", "is.numeric", "identical", "inherits", "missing", "exists" :
+		return Shape{ LGLSXP, 1 }
+	case "grepl" :
+		return Shape{ LGLSXP, x.Length }
+	case "nchar" :
+		return Shape{ INTSXP, x.Length }
+	case "paste", "paste0" :
+		if _, ok := args.values["collapse"]; ok {
+			return Shape{ STRSXP, 1 }
+		}
+		return Shape{ STRSXP, -1 }
+	case "sprintf" :
+		return Shape{ STRSXP, -1 }
+	case "format", "toupper", "tolower", "substr", "sub", "gsub" :
+		return Shape{ STRSXP, x.Length }
+	case "sum", "prod" :
+		t := combined(args.all).Type
+		switch t {
+		case LGLSXP, INTSXP :
+			return Shape{ INTSXP, 1 }
+		case REALSXP, CPLXSXP :
+			return Shape{ t, 1 }
+		}
+	case "max", "min", "range" :
+		t := combined(args.all).Type
+		if t == LGLSXP {
+			t = INTSXP
+		}
+		if t == INTSXP || t == REALSXP || t == STRSXP {
+			if name == "range" {
+				return Shape{ t, 2 }
+			}
+			return Shape{ t, 1 }
+		}
+	case "mean", "median", "sd", "var" :
+		if x.Type == LGLSXP || x.Type == INTSXP || x.Type == REALSXP {
+			return Shape{ REALSXP, 1 }
+		}
+	case "sqrt", "exp", "log" :
+		if x.IsNumeric() && x.Type != CPLXSXP {
+			return Shape{ REALSXP, x.Length }
+		}
+	case "abs", "round", "signif" :
+		switch x.Type {
+		case LGLSXP :
+			return Shape{ INTSXP, x.Length }
+		case INTSXP, REALSXP :
+			return x
+		}
+	case "seq_len" :
+		return Shape{ INTSXP, args.length("length.out", -1) }
+	case "seq_along" :
+		return Shape{ INTSXP, args.shape("along.with").Length }
+	case "which" :
+		return Shape{ INTSXP, -1 }
+	case "rev" :
+		return x
+	case "rep", "sort", "unique" :
+		if x.Type != ANYSXP {
+			return Shape{ x.Type, -1 }
+		}
+	case "matrix" :
+		if d := args.shape("data"); d.Type != ANYSXP {
+			return Shape{ d.Type, -1 }
+		}
+	case "invisible" :
+		return x
+	case "Sys.time" :
+		return Shape{ REALSXP, 1 }
+	}
+	return AnyShape
+}
+
+// combined returns the shape of c() of the shapes: the type of highest rank, the sum of the lengths.
+func combined(shapes []Shape) Shape {
+	c := Shape{ NILSXP, 0 }
+	for _, s := range shapes {
+		r, ok := vectorRanks[s.Type]
+		if !ok {
+			return AnyShape
+		}
+		if r > vectorRanks[c.Type] {
+			c.Type = s.Type
+		}
+		if c.Length >= 0 && s.Length >= 0 {
+			c.Length += s.Length
+		} else {
+			c.Length = -1
+		}
+	}
+	return c
+}
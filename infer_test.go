@@ -0,0 +1,141 @@
+package r
+
+import "strings"
+import "testing"
+
+func TestInferTypes(e *testing.T) {
+	tests := []struct {
+		src   string
+		shape string
+	}{
+		{ "1L", "integer[1]" },
+		{ "1", "double[1]" },
+		{ "2i", "complex[1]" },
+		{ "'a'", "character[1]" },
+		{ "NA", "logical[1]" },
+		{ "NA_integer_", "integer[1]" },
+		{ "NA_character_", "character[1]" },
+		{ "NULL", "NULL[0]" },
+		{ "Inf", "double[1]" },
+		{ "TRUE + 1L", "integer[1]" },
+		{ "1L + 2", "double[1]" },
+		{ "1L / 2L", "double[1]" },
+		{ "5L %/% 2L", "integer[1]" },
+		{ "1 + 2i", "complex[1]" },
+		{ "-TRUE", "integer[1]" },
+		{ "!c(1, 2)", "logical[2]" },
+		{ "1:10", "integer[10]" },
+		{ "1.5:3", "double[2]" },
+		{ "c(1:3, 2.5)", "double[4]" },
+		{ "c(1, 'a', TRUE)", "character[3]" },
+		{ "c(1, list(2))", "list[2]" },
+		{ "c()", "NULL[0]" },
+		{ "c(1:3, 4) == 2", "logical[4]" },
+		{ "1:3 && TRUE", "logical[1]" },
+		{ "vapply(1:5, f, numeric(1))", "double[5]" },
+		{ "vapply(x, f, FUN.VALUE = character(2))", "character[?]" },
+		{ "lapply(1:3, f)", "list[3]" },
+		{ "character(3)", "character[3]" },
+		{ "vector('list', 2)", "list[2]" },
+		{ "as.character(1:4)", "character[4]" },
+		{ "length(x)", "integer[1]" },
+		{ "paste(letters, collapse = '')", "character[1]" },
+		{ "nchar(LETTERS)", "integer[26]" },
+		{ "sum(1:3)", "integer[1]" },
+		{ "sum(1:3, 0.5)", "double[1]" },
+		{ "mean(1:3)", "double[1]" },
+		{ "sqrt(c(1, 4))", "double[2]" },
+		{ "seq_len(4)", "integer[4]" },
+		{ "seq_along(letters)", "integer[26]" },
+		{ "x[[1]]", "any" },
+		{ "letters[1:3]", "character[?]" },
+		{ "letters[[2]]", "character[1]" },
+		{ "function(x) x", "closure[1]" },
+		{ "f(1)", "any" },
+		{ "paste", "closure[1]" },
+		{ "if (a) 1 else 2", "double[1]" },
+		{ "if (a) 1L else 'b'", "any" },
+		{ "if (a) 1", "any" },
+		{ "{ }", "NULL[0]" },
+		{ "for (i in 1:3) i", "NULL[0]" },
+		{ "y ~ x", "language[3]" },
+	}
+	for i, test := range tests {
+		f, err := ParseFile(strings.NewReader(test.src))
+		if err != nil {
+			e.Fatal(err)
+		}
+		if s := InferTypes(f).Shape(f.Exprs[0]).String(); s != test.shape {
+			e.Error("Test InferTypes[", i, "] Failed:", s)
+		}
+	}
+}
+
+func TestInferTypesVariables(e *testing.T) {
+	tests := []struct {
+		src   string
+		shape string
+	}{
+		{ "x <- 1:3\nx", "integer[3]" },
+		{ "x <- 1:3\nx[2] <- 5L\nx", "integer[?]" },
+		{ "x <- 1L\nif (a) x <- 2L else x <- 3L\nx", "integer[1]" },
+		{ "x <- 1L\nif (a) x <- 'a'\nx", "any" },
+		{ "x <- 0L\nfor (i in 1:3) x <- x + i\nx", "integer[1]" },
+		{ "s <- ''\nfor (w in letters) s <- paste0(s, w)\nw", "character[1]" },
+		{ "f <- function(n) seq_len(n)\ng <- function() character(2)\ng()", "character[2]" },
+		{ "f <- function(x = 1L) x\nf", "closure[1]" },
+		{ "x <- 'a'\nf <- function() x\nf()", "character[1]" },
+		{ "'x' <- 2\nx", "double[1]" },
+		{ "x <- 2\nx <<- 'a'\nx", "double[1]" },
+		{ "x <- 'a'\nassign('x', 1)\nx", "any" },
+		{ "x <- 'a'\nf <- function() x <<- 1\nf()\nx", "any" },
+		{ "x <- 'a'\nf <- function() y <<- 1\nf()\nx", "character[1]" },
+	}
+	for i, test := range tests {
+		f, err := ParseFile(strings.NewReader(test.src))
+		if err != nil {
+			e.Fatal(err)
+		}
+		if s := InferTypes(f).Shape(f.Exprs[len(f.Exprs)-1]).String(); s != test.shape {
+			e.Error("Test InferTypesVariables[", i, "] Failed:", s)
+		}
+	}
+
+	f, _ := ParseFile(strings.NewReader("f <- function(n = 10L) { m <- n * 2L; m }"))
+	t := InferTypes(f)
+	body := f.Exprs[0].(*BinaryExpr).Y.(*FunctionExpr).Body.(*BlockExpr)
+	def := f.Exprs[0].(*BinaryExpr).Y.(*FunctionExpr).Formals[0].Default
+	if t.Shape(def).String() != "integer[1]" || t.Shape(body.List[1]).String() != "any" {
+		e.Error("Test InferTypesVariables Failed: formals", t.Shape(body.List[1]))
+	}
+}
+
+func TestTypeErrors(e *testing.T) {
+	tests := []struct {
+		src    string
+		errors string
+	}{
+		{ "x <- 'a'\nif (x == 1) 0", "2:5: comparison of character with double: the number is compared as a string;" },
+		{ "n <- nchar(s)\nn > '2'", "2:1: comparison of character with integer: the number is compared as a string;" },
+		{ "x <- 'a' + 1", "1:6: non-numeric argument to binary operator;" },
+		{ "-'a'", "1:1: invalid argument to unary operator;" },
+		{ "x == TRUE\n'a' == 'b'\n1 < 2", "" },
+		{ "f <- function(x) { y <- as.character(x); y > 10 }\nf(1)", "1:42: comparison of character with double: the number is compared as a string;" },
+		{ "f <- function(type = 'a') type + 1", "" },
+		{ "x <- 'a'\nassign('x', 1)\nx + 1", "" },
+		{ "x <- 'a'\nset <- function() x <<- 1\nset()\nx + 1", "" },
+	}
+	for i, test := range tests {
+		f, err := ParseFile(strings.NewReader(test.src))
+		if err != nil {
+			e.Fatal(err)
+		}
+		var errors string
+		for _, err := range InferTypes(f).Errors {
+			errors += err.Error() + ";"
+		}
+		if errors != test.errors {
+			e.Error("Test TypeErrors[", i, "] Failed:", errors)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package r
+
+import "bytes"
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+
+// Token types of the operators, indexed by the name of the function called in a language object.
+var operatorTokens = map[string]TokenType{
+	"<-": OP_LEFT_ASSIGN, "<<-": OP_LEFT_ASSIGN2, "=": OP_EQUAL_ASSIGN, ":=": OP_COLON_ASSIGN,
+	"->": OP_RIGHT_ASSIGN, "->>": OP_RIGHT_ASSIGN2,
+	"+": OP_ADD, "-": OP_SUB, "*": OP_MUL, "/": OP_DIV, "^": OP_POW,
+	">": OP_GT, ">=": OP_GE, "<": OP_LT, "<=": OP_LE, "==": OP_EQ, "!=": OP_NE, "!": OP_NOT,
+	"&": OP_AND, "&&": OP_AND2, "|": OP_OR, "||": OP_OR2, "~": OP_TILDE, "?": OP_QUESTION,
+	":": OP_COLON, "::": OP_NAMESPACE, ":::": OP_NAMESPACE_INTERNAL, "$": OP_DOLLAR, "@": OP_AT,
+}
+
+// newToken returns a token without position for the syntax trees built from values.
+func newToken(tt TokenType, s string) *Token {
+	return &Token{ Type: tt, stringvalue: s }
+}
+
+// newSymbol returns a SYMBOL, DOTS or DOTDOT_N token of the name.
+func newSymbol(name string) *Token {
+	t := &Token{ stringvalue: name }
+	t.Type, t.intvalue = symbolType(name)
+	return t
+}
+
+// ValueExpr converts a value to the syntax tree the parser would produce for it: a language object, a symbol or
+// a constant (a NULL or a vector of length 1 without attributes).
+func ValueExpr(v Value) (Expr, error) {
+	switch x := v.(type) {
+	case *Language :
+		return x.Expr()
+	case *Symbol :
+		if x == MissingArg {
+			return nil, nil
+		}
+		return &Ident{ newSymbol(x.Name) }, nil
+	case *Null :
+		return &Constant{ newToken(CONST_NULL, "NULL") }, nil
+	}
+
+	if a := v.Attributes(); a != nil && len(a.List) > 0 {
+		return nil, fmt.Errorf("%s with attributes has no syntax", v.Type())
+	}
+	var t *Token
+	switch x := v.(type) {
+	case *Logical :
+		if len(x.Data) == 1 {
+			switch x.Data[0] {
+			case NaLogical :
+				t = newToken(NA_LOGICAL, "NA")
+			case 0 :
+				t = newToken(CONST_FALSE, "FALSE")
+			default:
+				t = newToken(CONST_TRUE, "TRUE")
+			}
+		}
+	case *Integer :
+		if len(x.Data) == 1 {
+			if x.Data[0] == NaInteger {
+				t = newToken(NA_INTEGER, "NA_integer_")
+			} else if x.Data[0] < 0 {
+				return negate(&Integer{ Data: []int32{ -x.Data[0] } })
+			} else {
+				t = newToken(CONST_INTEGER, strconv.Itoa(int(x.Data[0])) + "L")
+				t.intvalue = int64(x.Data[0])
+				t.realvalue = float64(x.Data[0])
+			}
+		}
+	case *Real :
+		if len(x.Data) == 1 {
+			f := x.Data[0]
+			switch {
+			case IsNaReal(f) :
+				t = newToken(NA_REAL, "NA_real_")
+			case math.IsNaN(f) :
+				t = newToken(CONST_NAN, "NaN")
+			case f < 0 || (f == 0 && math.Signbit(f)) :
+				return negate(&Real{ Data: []float64{ -f } })
+			case math.IsInf(f, 1) :
+				t = newToken(CONST_INF, "Inf")
+			default:
+				t = newToken(CONST_REAL, FormatReal(f))
+				t.realvalue = f
+				t.intvalue = int64(f)
+			}
+		}
+	case *Complex :
+		// Only the pure imaginary numbers are literals
+		if len(x.Data) == 1 {
+			c := x.Data[0]
+			switch {
+			case IsNaReal(real(c)) :
+				t = newToken(NA_COMPLEX, "NA_complex_")
+			case real(c) == 0 && imag(c) >= 0 :
+				t = newToken(CONST_COMPLEX, FormatReal(imag(c)) + "i")
+				t.realvalue = imag(c)
+			}
+		}
+	case *Character :
+		if len(x.Data) == 1 {
+			if x.IsNA(0) {
+				t = newToken(NA_CHARACTER, "NA_character_")
+			} else {
+				t = newToken(CONST_CHARACTER, x.Data[0])
+			}
+		}
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%s of length %d has no syntax", v.Type(), Length(v))
+	}
+	return &Constant{ t }, nil
+}
+
+// negate returns -x for a positive constant.
+func negate(v Value) (Expr, error) {
+	x, err := ValueExpr(v)
+	if err != nil {
+		return nil, err
+	}
+	return &UnaryExpr{ newToken(OP_SUB, "-"), x }, nil
+}
+
+// args converts the tagged arguments of a call.
+func args(tagged []Tagged) (list []*Arg, err error) {
+	for _, t := range tagged {
+		a := &Arg{}
+		if t.Tag != "" {
+			a.Name = newSymbol(t.Tag)
+		}
+		if a.Value, err = ValueExpr(t.Value); err != nil {
+			return
+		}
+		list = append(list, a)
+	}
+	return
+}
+
+// Expr converts the call to the syntax tree the parser would produce for its deparsed code: the calls of the
+// operators, of the keywords, of ( and of { are converted to the corresponding nodes.
+func (this *Language) Expr() (x Expr, err error) {
+	// function(formals) body: the formals are a pairlist, the third argument is the srcref
+	if s, ok := this.Fun.(*Symbol); ok && s.Name == "function" && len(this.Args) >= 2 {
+		f := &FunctionExpr{ Function: newToken(KEYWORD_FUNCTION, "function") }
+		if p, ok := this.Args[0].Value.(*Pairlist); ok {
+			for _, t := range p.Elements {
+				formal := &Formal{ Name: newSymbol(t.Tag) }
+				if formal.Default, err = ValueExpr(t.Value); err != nil {
+					return
+				}
+				f.Formals = append(f.Formals, formal)
+			}
+		}
+		if f.Body, err = ValueExpr(this.Args[1].Value); err != nil {
+			return
+		}
+		return f, nil
+	}
+
+	var values []Expr
+	untagged := true
+	for _, a := range this.Args {
+		var e Expr
+		if e, err = ValueExpr(a.Value); err != nil {
+			return
+		}
+		if a.Tag != "" {
+			untagged = false
+		}
+		values = append(values, e)
+	}
+	missing := false
+	for _, v := range values {
+		missing = missing || v == nil
+	}
+
+	if s, ok := this.Fun.(*Symbol); ok && untagged && !missing {
+		name := s.Name
+		n := len(values)
+		switch {
+		case name == "(" && n == 1 :
+			return &ParenExpr{ newToken(OP_LEFT_ROUND, "("), values[0], newToken(OP_RIGHT_ROUND, ")") }, nil
+		case name == "{" :
+			return &BlockExpr{ newToken(OP_LEFT_CURLY, "{"), values, newToken(OP_RIGHT_CURLY, "}") }, nil
+		case name == "if" && (n == 2 || n == 3) :
+			i := &IfExpr{ If: newToken(KEYWORD_IF, "if"), Cond: values[0], Then: values[1] }
+			if n == 3 {
+				i.Else = values[2]
+			}
+			return i, nil
+		case name == "for" && n == 3 :
+			if v, ok := values[0].(*Ident); ok {
+				return &ForExpr{ newToken(KEYWORD_FOR, "for"), v.Token, values[1], values[2] }, nil
+			}
+		case name == "while" && n == 2 :
+			return &WhileExpr{ newToken(KEYWORD_WHILE, "while"), values[0], values[1] }, nil
+		case name == "repeat" && n == 1 :
+			return &RepeatExpr{ newToken(KEYWORD_REPEAT, "repeat"), values[0] }, nil
+		case name == "break" && n == 0 :
+			return &BreakExpr{ newToken(KEYWORD_BREAK, "break") }, nil
+		case name == "next" && n == 0 :
+			return &NextExpr{ newToken(KEYWORD_NEXT, "next") }, nil
+		case name == "[" || name == "[[" :
+			break
+		case n == 2 && strings.HasPrefix(name, "%") && strings.HasSuffix(name, "%") && len(name) >= 2 :
+			return &BinaryExpr{ newToken(INFIX, name), values[0], values[1] }, nil
+		case n == 2 :
+			if tt, ok := operatorTokens[name]; ok && tt != OP_NOT {
+				return &BinaryExpr{ newToken(tt, name), values[0], values[1] }, nil
+			}
+		case n == 1 :
+			switch name {
+			case "-", "+", "!", "~", "?" :
+				return &UnaryExpr{ newToken(operatorTokens[name], name), values[0] }, nil
+			}
+		}
+	}
+
+	var arguments []*Arg
+	if arguments, err = args(this.Args); err != nil {
+		return
+	}
+
+	// x[i] and x[[i]]
+	if s, ok := this.Fun.(*Symbol); ok && (s.Name == "[" || s.Name == "[[") && len(arguments) > 0 && arguments[0].Name == nil && arguments[0].Value != nil {
+		i := &IndexExpr{ X: arguments[0].Value, Args: arguments[1:] }
+		if s.Name == "[" {
+			i.Lbrack = newToken(OP_LEFT_SQUARE, "[")
+		} else {
+			i.Lbrack = newToken(OP_LEFT_SQUARE2, "[[")
+		}
+		i.Rbrack = newToken(OP_RIGHT_SQUARE, "]")
+		return i, nil
+	}
+
+	var fun Expr
+	if fun, err = ValueExpr(this.Fun); err != nil {
+		return
+	}
+	if fun == nil {
+		return nil, fmt.Errorf("call of a missing function")
+	}
+	return &CallExpr{ fun, newToken(OP_LEFT_ROUND, "("), arguments, newToken(OP_RIGHT_ROUND, ")") }, nil
+}
+
+// Names of the calls of the operators whose syntax differs from the call: a -> b is `<-`(b, a) and a ** b is a ^ b.
+var operatorCalls = map[TokenType]string{ OP_RIGHT_ASSIGN: "<-", OP_RIGHT_ASSIGN2: "<<-", OP_MUL2: "^" }
+
+// call returns the language object of a call of the function name.
+func call(name string, args ...Tagged) *Language {
+	return &Language{ Fun: &Symbol{ name }, Args: args }
+}
+
+// constantValue returns the value of a literal.
+func constantValue(t *Token) (Value, error) {
+	switch t.Type {
+	case CONST_NULL :
+		return NullValue, nil
+	case CONST_TRUE :
+		return &Logical{ Data: []int32{ 1 } }, nil
+	case CONST_FALSE :
+		return &Logical{ Data: []int32{ 0 } }, nil
+	case NA_LOGICAL :
+		return NA(LGLSXP), nil
+	case CONST_INTEGER :
+		// a literal out of the integer range or not whole is a double in R, as 3000000000L or 1.5L
+		if f := t.realvalue; f != math.Trunc(f) || f > math.MaxInt32 || f <= math.MinInt32 {
+			return &Real{ Data: []float64{ f } }, nil
+		}
+		return &Integer{ Data: []int32{ int32(t.realvalue) } }, nil
+	case NA_INTEGER :
+		return NA(INTSXP), nil
+	case CONST_REAL :
+		return &Real{ Data: []float64{ t.realvalue } }, nil
+	case CONST_NAN :
+		return &Real{ Data: []float64{ math.NaN() } }, nil
+	case CONST_INF :
+		return &Real{ Data: []float64{ math.Inf(1) } }, nil
+	case NA_REAL :
+		return NA(REALSXP), nil
+	case CONST_COMPLEX :
+		return &Complex{ Data: []complex128{ complex(0, t.realvalue) } }, nil
+	case NA_COMPLEX :
+		return NA(CPLXSXP), nil
+	case CONST_CHARACTER :
+		return &Character{ Data: []string{ t.stringvalue } }, nil
+	case NA_CHARACTER :
+		return NA(STRSXP), nil
+	}
+	return nil, fmt.Errorf("%d:%d: unexpected %s constant", t.nline, t.ncol, t.Type)
+}
+
+// argsValue converts the arguments of a call, the empty arguments are MissingArg.
+func argsValue(args []*Arg, srcref func(*FunctionExpr) Value) (tagged []Tagged, err error) {
+	for _, a := range args {
+		t := Tagged{ Value: MissingArg }
+		if a.Name != nil {
+			t.Tag = a.Name.stringvalue
+		}
+		if a.Value != nil {
+			if t.Value, err = exprValue(a.Value, srcref); err != nil {
+				return
+			}
+		}
+		tagged = append(tagged, t)
+	}
+	return
+}
+
+// ExprValue converts a syntax tree to the language object R builds when parsing the same code:
+// ExprValue is the inverse of ValueExpr. The srcref of the function definitions is NULL.
+func ExprValue(x Expr) (v Value, err error) {
+	return exprValue(x, nil)
+}
+
+// exprValue converts a syntax tree, srcref gives the srcref of the function definitions when it is not nil.
+func exprValue(x Expr, srcref func(*FunctionExpr) Value) (v Value, err error) {
+	values := func(xs ...Expr) (tagged []Tagged, err error) {
+		for _, x := range xs {
+			var v Value
+			if v, err = exprValue(x, srcref); err != nil {
+				return
+			}
+			tagged = append(tagged, Tagged{ Value: v })
+		}
+		return
+	}
+	var a []Tagged
+
+	switch n := x.(type) {
+	case *Constant :
+		return constantValue(n.Token)
+	case *Ident :
+		return &Symbol{ n.Name() }, nil
+	case *UnaryExpr :
+		if a, err = values(n.X); err != nil {
+			return
+		}
+		return call(n.Op.stringvalue, a...), nil
+	case *BinaryExpr :
+		switch n.Op.Type {
+		case OP_RIGHT_ASSIGN, OP_RIGHT_ASSIGN2 :
+			a, err = values(n.Y, n.X)
+		default:
+			a, err = values(n.X, n.Y)
+		}
+		if err != nil {
+			return
+		}
+		name := n.Op.stringvalue
+		if c, ok := operatorCalls[n.Op.Type]; ok {
+			name = c
+		}
+		return call(name, a...), nil
+	case *ParenExpr :
+		if a, err = values(n.X); err != nil {
+			return
+		}
+		return call("(", a...), nil
+	case *BlockExpr :
+		if a, err = values(n.List...); err != nil {
+			return
+		}
+		return call("{", a...), nil
+	case *CallExpr :
+		var fun Value
+		if fun, err = exprValue(n.Fun, srcref); err != nil {
+			return
+		}
+		if a, err = argsValue(n.Args, srcref); err != nil {
+			return
+		}
+		// A call of a string is a call of the symbol: "f"(x) is f(x)
+		if c, ok := fun.(*Character); ok {
+			fun = &Symbol{ c.Data[0] }
+		}
+		return &Language{ Fun: fun, Args: a }, nil
+	case *IndexExpr :
+		var object Value
+		if object, err = exprValue(n.X, srcref); err != nil {
+			return
+		}
+		if a, err = argsValue(n.Args, srcref); err != nil {
+			return
+		}
+		return call(n.Lbrack.stringvalue, append([]Tagged{ { "", object } }, a...)...), nil
+	case *FunctionExpr :
+		formals := &Pairlist{}
+		for _, f := range n.Formals {
+			t := Tagged{ Tag: f.Name.stringvalue, Value: MissingArg }
+			if f.Default != nil {
+				if t.Value, err = exprValue(f.Default, srcref); err != nil {
+					return
+				}
+			}
+			formals.Elements = append(formals.Elements, t)
+		}
+		var body Value
+		if body, err = exprValue(n.Body, srcref); err != nil {
+			return
+		}
+		var fv Value = formals
+		if len(formals.Elements) == 0 {
+			fv = NullValue
+		}
+		var ref Value = NullValue
+		if srcref != nil {
+			ref = srcref(n)
+		}
+		return call("function", Tagged{ Value: fv }, Tagged{ Value: body }, Tagged{ Value: ref }), nil
+	case *IfExpr :
+		if n.Else != nil {
+			a, err = values(n.Cond, n.Then, n.Else)
+		} else {
+			a, err = values(n.Cond, n.Then)
+		}
+		if err != nil {
+			return
+		}
+		return call("if", a...), nil
+	case *ForExpr :
+		if a, err = values(n.Seq, n.Body); err != nil {
+			return
+		}
+		return call("for", append([]Tagged{ { "", &Symbol{ n.Var.stringvalue } } }, a...)...), nil
+	case *WhileExpr :
+		if a, err = values(n.Cond, n.Body); err != nil {
+			return
+		}
+		return call("while", a...), nil
+	case *RepeatExpr :
+		if a, err = values(n.Body); err != nil {
+			return
+		}
+		return call("repeat", a...), nil
+	case *NextExpr :
+		return call("next"), nil
+	case *BreakExpr :
+		return call("break"), nil
+	}
+	return nil, fmt.Errorf("unsupported expression %T", x)
+}
+
+// lastToken returns the last token of the expression.
+func lastToken(x Expr) *Token {
+	switch n := x.(type) {
+	case *Constant :
+		return n.Token
+	case *Ident :
+		return n.Token
+	case *UnaryExpr :
+		return lastToken(n.X)
+	case *BinaryExpr :
+		return lastToken(n.Y)
+	case *ParenExpr :
+		return n.Rparen
+	case *BlockExpr :
+		return n.Rbrace
+	case *CallExpr :
+		return n.Rparen
+	case *IndexExpr :
+		return n.Rbrack
+	case *FunctionExpr :
+		return lastToken(n.Body)
+	case *IfExpr :
+		if n.Else != nil {
+			return lastToken(n.Else)
+		}
+		return lastToken(n.Then)
+	case *ForExpr :
+		return lastToken(n.Body)
+	case *WhileExpr :
+		return lastToken(n.Body)
+	case *RepeatExpr :
+		return lastToken(n.Body)
+	case *NextExpr :
+		return n.Token
+	case *BreakExpr :
+		return n.Token
+	}
+	return nil
+}
+
+// Srcref returns the srcref R attaches to the parsed expression: the integer vector c(first_line, first_byte,
+// last_line, last_byte, first_column, last_column, first_parsed, last_parsed) of class srcref, with a srcfile
+// environment holding the file name. src is the source the expression was parsed from, giving the bytes.
+func Srcref(x Expr, src []byte, file string) *Integer {
+	first, last := x.Pos(), lastToken(x)
+	end, from := last.End(), last.offset
+	if end > len(src) {
+		end = len(src)
+	}
+	if from > end {
+		from = end
+	}
+	// a string may end on a line after the one of its first character
+	start, lastStart := lineStart(src, first.Offset), lineStart(src, end - 1)
+	lastLine := last.nline + bytes.Count(src[from:end], []byte{ '\n' })
+	lastColumn := srcColumns(src[lastStart:end]) - 1
+	s := &Integer{ Data: []int32{ int32(first.Line), int32(first.Offset - start + 1), int32(lastLine), int32(end - lastStart),
+		int32(first.Column), int32(lastColumn), int32(first.Line), int32(lastLine) } }
+	srcfile := &Environment{ Enclos: EmptyEnv, Frame: []Tagged{ { "filename", NewCharacter(file) } } }
+	srcfile.Set("class", NewCharacter("srcfilecopy", "srcfile"))
+	s.Set("srcfile", srcfile)
+	s.Set("class", NewCharacter("srcref"))
+	return s
+}
+
+// lineStart returns the offset of the line holding the byte at offset.
+func lineStart(src []byte, offset int) int {
+	switch {
+	case offset > len(src) :
+		offset = len(src)
+	case offset < 0 :
+		offset = 0
+	}
+	return bytes.LastIndexByte(src[:offset], '\n') + 1
+}
+
+// srcColumns returns the column following the bytes of a line, the tabulations advancing to the next multiple of
+// 8 as in the scanner.
+func srcColumns(line []byte) int {
+	col := 1
+	for _, c := range string(line) {
+		if c == '\t' {
+			col = (col + 7) &^ 7 + 1
+		} else {
+			col++
+		}
+	}
+	return col
+}
@@ -0,0 +1,95 @@
+package r
+
+import "errors"
+import "fmt"
+
+
+// Logic applies the element-wise logical operator op (& or |) to x and y, in three-valued logic: NA & FALSE is
+// FALSE and NA | TRUE is TRUE. The numbers are TRUE if they are not zero, two raw vectors combine bitwise.
+func Logic(op string, x, y Value) (Value, []string, error) {
+	var w warnings
+	if op != "&" && op != "|" {
+		return nil, nil, fmt.Errorf("%s is not a logical operator", op)
+	}
+	raw := x.Type() == RAWSXP && y.Type() == RAWSXP
+	if !raw && (!isNumeric(x) || !isNumeric(y) || Inherits(x, "factor") || Inherits(y, "factor")) {
+		return nil, nil, errors.New("operations are possible only for numeric, logical or complex types")
+	}
+	dim, dimnames, err := binaryDims(x, y, &w)
+	if err != nil {
+		return nil, nil, err
+	}
+	nx, ny := Length(x), Length(y)
+	n := recycle(nx, ny, &w)
+
+	var ans Value
+	if raw {
+		a, b := x.(*Raw).Data, y.(*Raw).Data
+		v := &Raw{ Data: make([]byte, n) }
+		for i := range v.Data {
+			if op == "&" {
+				v.Data[i] = a[i % nx] & b[i % ny]
+			} else {
+				v.Data[i] = a[i % nx] | b[i % ny]
+			}
+		}
+		ans = v
+	} else {
+		v := &Logical{ Data: make([]int32, n) }
+		for i := range v.Data {
+			p, q := logicalAt(x, i % nx), logicalAt(y, i % ny)
+			switch {
+			case op == "&" && (p == 0 || q == 0) :
+				v.Data[i] = 0
+			case op == "|" && (p == 1 || q == 1) :
+				v.Data[i] = 1
+			case p == NaLogical || q == NaLogical :
+				v.Data[i] = NaLogical
+			default:
+				v.Data[i] = p
+			}
+		}
+		ans = v
+	}
+	setAttributes(ans, x, y, dim, dimnames, false)
+	return ans, w, nil
+}
+
+// Not applies ! to x: the logical negation of a logical or numeric vector, the bitwise negation of a raw vector.
+// A logical or raw vector keeps its attributes, a numeric one its names, dim and dimnames.
+func Not(x Value) (Value, error) {
+	n := Length(x)
+	switch x.Type() {
+	case LGLSXP, INTSXP, REALSXP, CPLXSXP :
+	case RAWSXP :
+		b := &Raw{ Data: make([]byte, n) }
+		for i, c := range x.(*Raw).Data {
+			b.Data[i] = ^c
+		}
+		copyAttributes(b, x)
+		return b, nil
+	default:
+		if n == 0 {
+			return &Logical{}, nil
+		}
+		return nil, errors.New("invalid argument type")
+	}
+	v := &Logical{ Data: make([]int32, n) }
+	for i := range v.Data {
+		if k := logicalAt(x, i); k == NaLogical {
+			v.Data[i] = k
+		} else {
+			v.Data[i] = 1 - k
+		}
+	}
+	if x.Type() == LGLSXP {
+		copyAttributes(v, x)
+		return v, nil
+	}
+	for _, name := range []string{ "names", "dim", "dimnames" } {
+		if a := attr(x, name); a != nil {
+			v.Attributes().Set(name, a)
+		}
+	}
+	return v, nil
+}
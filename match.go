@@ -0,0 +1,310 @@
+package r
+
+import "fmt"
+import "strings"
+
+// MatchKind tells how an actual argument of a call is bound to a formal argument.
+type MatchKind int
+
+const (
+	// Not bound: an unused argument
+	MATCH_UNUSED MatchKind = iota
+	MATCH_EXACT
+	MATCH_PARTIAL
+	MATCH_POSITIONAL
+	// Absorbed by the ... formal
+	MATCH_DOTS
+)
+
+func (this MatchKind) String() (s string) {
+	switch this {
+	case MATCH_UNUSED : s = "unused"
+	case MATCH_EXACT : s = "exact"
+	case MATCH_PARTIAL : s = "partial"
+	case MATCH_POSITIONAL : s = "positional"
+	case MATCH_DOTS : s = "dots"
+	}
+	return
+}
+
+// ArgMatch is the binding of an actual argument of a call.
+type ArgMatch struct {
+	Arg *Arg
+	// Index of the argument in the call
+	Index int
+	// Name of the formal, "..." for MATCH_DOTS, "" for MATCH_UNUSED
+	Formal string
+	Kind   MatchKind
+}
+
+// MatchError is a problem of the arguments of a call: an error R reports when the call is evaluated, or a warning
+// for a partial match of a name.
+type MatchError struct {
+	Pos     Position
+	Msg     string
+	Warning bool
+}
+
+func (this *MatchError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+// CallMatch is the result of the matching of the arguments of a call to the formals of a function.
+type CallMatch struct {
+	Call    *CallExpr
+	Formals []*Formal
+	// Bindings of the arguments, in the order of the call
+	Args []*ArgMatch
+	// Formals without argument nor default value
+	Missing []string
+	Errors  []*MatchError
+	// The call passes ... on: its content is unknown, the positional matching after it and the missing formals
+	// are not reliable
+	Dots bool
+}
+
+// argPos returns the position of an argument of the call.
+func argPos(call *CallExpr, a *Arg) Position {
+	switch {
+	case a.Name != nil :
+		return a.Name.Pos()
+	case a.Value != nil :
+		return a.Value.Pos()
+	}
+	return call.Lparen.Pos()
+}
+
+// argTag returns the name of an argument, "" for an unnamed one.
+func argTag(a *Arg) string {
+	if a.Name == nil {
+		return ""
+	}
+	if a.Name.Type == CONST_NULL {
+		return "NULL"
+	}
+	return a.Name.stringvalue
+}
+
+// argBinding is the binding of an argument by matchNames.
+type argBinding struct {
+	// Index of the formal, -1 for an unused argument
+	formal int
+	kind   MatchKind
+	// An error is reported for the argument, it stays unused
+	reported bool
+}
+
+// argProblem is an error or a warning of matchNames about the argument arg.
+type argProblem struct {
+	arg     int
+	msg     string
+	warning bool
+}
+
+// matchNames binds the arguments of a call, named tags ("" for an unnamed argument), to the formals named names
+// as R does, see MatchArgs. stop tells whether an unnamed argument is ... passed on, it stops the positional
+// matching. The unused arguments are not reported.
+func matchNames(names, tags []string, stop func(j int) bool) (args []argBinding, problems []argProblem, stopped bool) {
+	dots := len(names)
+	for i, name := range names {
+		if name == "..." {
+			dots = i
+			break
+		}
+	}
+	// argument bound to each formal, plus one
+	bound := make([]int, len(names))
+	args = make([]argBinding, len(tags))
+	for j := range args {
+		args[j].formal = -1
+	}
+	bind := func(j, i int, kind MatchKind) {
+		args[j].formal, args[j].kind, bound[i] = i, kind, j + 1
+	}
+	report := func(j int, format string, a ...interface{}) {
+		args[j].reported = true
+		problems = append(problems, argProblem{ j, fmt.Sprintf(format, a...), false })
+	}
+
+	// exact names
+	for i, name := range names {
+		if i == dots {
+			continue
+		}
+		for j, tag := range tags {
+			if args[j].kind == MATCH_UNUSED && tag == name {
+				if bound[i] != 0 {
+					report(j, "formal argument \"%s\" matched by multiple actual arguments", name)
+					continue
+				}
+				bind(j, i, MATCH_EXACT)
+			}
+		}
+	}
+
+	// partial names, before ... only: a formal matched partially by an argument can not be matched by another one
+	for j, tag := range tags {
+		if args[j].kind != MATCH_UNUSED || args[j].reported || tag == "" {
+			continue
+		}
+		found := -1
+		for i := 0; i < dots; i++ {
+			if strings.HasPrefix(names[i], tag) && (bound[i] == 0 || args[bound[i]-1].kind == MATCH_PARTIAL) {
+				if found >= 0 {
+					report(j, "argument %d matches multiple formal arguments", j + 1)
+					found = -2
+					break
+				}
+				found = i
+			}
+		}
+		switch {
+		case found >= 0 && bound[found] != 0 :
+			report(j, "formal argument \"%s\" matched by multiple actual arguments", names[found])
+		case found >= 0 :
+			bind(j, found, MATCH_PARTIAL)
+			problems = append(problems, argProblem{ j, fmt.Sprintf("partial argument match of '%s' to '%s'", tag, names[found]), true })
+		}
+	}
+
+	// positions, before ... only; the ... of the call stops the positional matching
+	next := 0
+	for j, tag := range tags {
+		if args[j].kind != MATCH_UNUSED || args[j].reported || tag != "" {
+			continue
+		}
+		if stop != nil && stop(j) {
+			stopped = true
+		}
+		if stopped {
+			continue
+		}
+		for next < dots && bound[next] != 0 {
+			next++
+		}
+		if next < dots {
+			bind(j, next, MATCH_POSITIONAL)
+		}
+	}
+
+	// ... absorbs the remaining arguments
+	for j := range args {
+		if args[j].kind == MATCH_UNUSED && !args[j].reported && dots < len(names) {
+			args[j].formal, args[j].kind = dots, MATCH_DOTS
+		}
+	}
+	return
+}
+
+// MatchArgs binds the arguments of the call to the formals of a function as R does when it calls a closure:
+// first the names matching exactly a formal, then the names matching partially a formal placed before ..., then
+// the unnamed arguments by position to the formals before ..., and ... absorbs the remaining arguments. A formal
+// matched by several arguments, an argument matching partially several formals and the arguments left without
+// ... are errors; the partial matches are warnings, as with options(warnPartialMatchArgs = TRUE).
+func MatchArgs(call *CallExpr, formals []*Formal) *CallMatch {
+	m := &CallMatch{ Call: call, Formals: formals }
+	names := make([]string, len(formals))
+	for i, f := range formals {
+		names[i] = f.Name.stringvalue
+		if f.Name.Type == DOTS {
+			names[i] = "..."
+		}
+	}
+	tags := make([]string, len(call.Args))
+	for j, a := range call.Args {
+		tags[j] = argTag(a)
+	}
+	bindings, problems, stopped := matchNames(names, tags, func(j int) bool {
+		id, ok := call.Args[j].Value.(*Ident)
+		return ok && id.Token.Type == DOTS
+	})
+	m.Dots = stopped
+	for j, a := range call.Args {
+		am := &ArgMatch{ Arg: a, Index: j, Kind: bindings[j].kind }
+		if bindings[j].formal >= 0 {
+			am.Formal = names[bindings[j].formal]
+		}
+		m.Args = append(m.Args, am)
+	}
+	for _, p := range problems {
+		m.errorf(argPos(call, call.Args[p.arg]), p.warning, "%s", p.msg)
+	}
+
+	// the unused arguments
+	for j, am := range m.Args {
+		if am.Kind != MATCH_UNUSED || bindings[j].reported {
+			continue
+		}
+		switch {
+		case m.Dots && am.Arg.Name == nil :
+			// may match a formal after the expansion of ...
+		case am.Arg.Name != nil :
+			m.errorf(argPos(call, am.Arg), false, "unused argument %s", argTag(am.Arg))
+		default:
+			m.errorf(argPos(call, am.Arg), false, "unused argument %d", am.Index+1)
+		}
+	}
+
+	for i, f := range formals {
+		bound := false
+		for _, b := range bindings {
+			bound = bound || (b.formal == i && b.kind != MATCH_DOTS)
+		}
+		if f.Name.Type != DOTS && !bound && f.Default == nil {
+			m.Missing = append(m.Missing, f.Name.stringvalue)
+		}
+	}
+	return m
+}
+
+func (this *CallMatch) errorf(pos Position, warning bool, format string, a ...interface{}) {
+	this.Errors = append(this.Errors, &MatchError{ pos, fmt.Sprintf(format, a...), warning })
+}
+
+// MatchedCall returns the call as match.call() does: the arguments in the order of the formals with their full
+// names, the arguments of ... in place of the ... formal with their own names. The unused arguments are dropped.
+func (this *CallMatch) MatchedCall() *CallExpr {
+	c := &CallExpr{ Fun: this.Call.Fun, Lparen: this.Call.Lparen, Rparen: this.Call.Rparen }
+	for _, f := range this.Formals {
+		for _, am := range this.Args {
+			switch {
+			case f.Name.Type == DOTS && am.Kind == MATCH_DOTS :
+				c.Args = append(c.Args, am.Arg)
+			case f.Name.Type != DOTS && am.Kind != MATCH_DOTS && am.Formal == f.Name.stringvalue :
+				c.Args = append(c.Args, &Arg{ Name: newSymbol(f.Name.stringvalue), Value: am.Arg.Value })
+			}
+		}
+	}
+	return c
+}
+
+// MatchArg returns the choice selected by arg as match.arg(arg, choices) does: the choice equal to arg, or the
+// only choice arg is a prefix of.
+func MatchArg(arg string, choices []string) (string, error) {
+	found := ""
+	for _, c := range choices {
+		if c == arg {
+			return c, nil
+		}
+		if arg != "" && strings.HasPrefix(c, arg) {
+			if found != "" {
+				found = "-"
+			} else {
+				found = c
+			}
+		}
+	}
+	if found == "" || found == "-" {
+		return "", fmt.Errorf("'arg' should be one of %s", strings.Join(quoteChoices(choices), ", "))
+	}
+	return found, nil
+}
+
+// quoteChoices returns the choices between curly quotes, as R writes them in its messages.
+func quoteChoices(choices []string) []string {
+	q := make([]string, len(choices))
+	for i, c := range choices {
+		q[i] = "“" + c + "”"
+	}
+	return q
+}
@@ -0,0 +1,110 @@
+package r
+
+import "strings"
+import "testing"
+
+// matchString returns the bindings, the missing formals and the errors of the match of call to the function def.
+func matchString(e *testing.T, def, call string) (m *CallMatch, s string) {
+	f, err := ParseExpr(def)
+	if err != nil {
+		e.Fatal(err)
+	}
+	c, err := ParseExpr(call)
+	if err != nil {
+		e.Fatal(err)
+	}
+	m = MatchArgs(c.(*CallExpr), f.(*FunctionExpr).Formals)
+	var b []string
+	for _, am := range m.Args {
+		b = append(b, am.Formal + ":" + am.Kind.String())
+	}
+	s = strings.Join(b, " ") + "|" + strings.Join(m.Missing, " ") + "|"
+	for _, err := range m.Errors {
+		s += err.Error() + ";"
+	}
+	return
+}
+
+func TestMatchArgs(e *testing.T) {
+	tests := []struct {
+		def, call string
+		result    string
+		dots      bool
+	}{
+		{ "function(x, y) 0", "f(1, 2)", "x:positional y:positional||", false },
+		{ "function(x, y) 0", "f(y = 1, 2)", "y:exact x:positional||", false },
+		{ "function(x, y = 0) 0", "f()", "|x|", false },
+		{ "function(value, verbose) 0", "f(val = 1, 2)", "value:partial verbose:positional||1:3: partial argument match of 'val' to 'value';", false },
+		{ "function(value, verbose) 0", "f(v = 1)", ":unused|value verbose|1:3: argument 1 matches multiple formal arguments;", false },
+		{ "function(x, y) 0", "f(x = 1, x = 2)", "x:exact :unused|y|1:10: formal argument \"x\" matched by multiple actual arguments;", false },
+		{ "function(abc, y) 0", "f(abc = 1, a = 2)", "abc:exact :unused|y|1:12: unused argument a;", false },
+		{ "function(x) 0", "f(1, 2, z = 3)", "x:positional :unused :unused||1:6: unused argument 2;1:9: unused argument z;", false },
+		{ "function(x, ...) 0", "f(1, 2, z = 3)", "x:positional ...:dots ...:dots||", false },
+		{ "function(..., na.rm = FALSE) 0", "f(1, na = 2)", "...:dots ...:dots||", false },
+		{ "function(..., na.rm = FALSE) 0", "f(1, na.rm = 2)", "...:dots na.rm:exact||", false },
+		{ "function(x, ..., y) 0", "f(1, 2, 3)", "x:positional ...:dots ...:dots|y|", false },
+		{ "function(x, y) 0", "f(..., 2)", ":unused :unused|x y|", true },
+		{ "function(x, y) 0", "f(y = 1, ...)", "y:exact :unused|x|", true },
+		{ "function(x, y) 0", "f(1, , 3)", "x:positional y:positional :unused||1:8: unused argument 3;", false },
+	}
+	for i, test := range tests {
+		m, s := matchString(e, test.def, test.call)
+		if s != test.result || m.Dots != test.dots {
+			e.Error("Test MatchArgs[", i, "] Failed:", s, m.Dots)
+		}
+	}
+
+	m, _ := matchString(e, "function(value, verbose) 0", "f(val = 1)")
+	if len(m.Errors) != 1 || !m.Errors[0].Warning {
+		e.Error("Test MatchArgs Failed: partial match is not a warning")
+	}
+}
+
+func TestMatchedCall(e *testing.T) {
+	tests := []struct {
+		def, call string
+		result    string
+	}{
+		{ "function(x, y) 0", "f(y = a, b)", "x=b y=a" },
+		{ "function(value, ...) 0", "f(c, val = a, z = b)", "value=a c z=b" },
+		{ "function(x, ..., y) 0", "f(a, b, y = c, d)", "x=a b d y=c" },
+		{ "function(x) 0", "f(a, b)", "x=a" },
+	}
+	for i, test := range tests {
+		m, _ := matchString(e, test.def, test.call)
+		var args []string
+		for _, a := range m.MatchedCall().Args {
+			s := a.Value.(*Ident).Token.Value()
+			if a.Name != nil {
+				s = a.Name.Value() + "=" + s
+			}
+			args = append(args, s)
+		}
+		if strings.Join(args, " ") != test.result {
+			e.Error("Test MatchedCall[", i, "] Failed:", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestMatchArg(e *testing.T) {
+	choices := []string{ "pearson", "kendall", "spearman" }
+	tests := []struct {
+		arg    string
+		result string
+	}{
+		{ "pearson", "pearson" },
+		{ "k", "kendall" },
+		{ "spear", "spearman" },
+		{ "p", "pearson" },
+		{ "", "" },
+		{ "x", "" },
+	}
+	for i, test := range tests {
+		if r, err := MatchArg(test.arg, choices); r != test.result || (err != nil) != (test.result == "") {
+			e.Error("Test MatchArg[", i, "] Failed:", r, err)
+		}
+	}
+	if _, err := MatchArg("a", []string{ "ab", "ac" }); err == nil || err.Error() != "'arg' should be one of “ab”, “ac”" {
+		e.Error("Test MatchArg Failed:", err)
+	}
+}
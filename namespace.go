@@ -0,0 +1,210 @@
+package r
+
+import "io"
+import "fmt"
+
+// Namespace is the content of the NAMESPACE file of an R package.
+type Namespace struct {
+	// export(f, g) exportClasses(A) exportMethods(show)
+	Exports       []string
+	ExportClasses []string
+	ExportMethods []string
+	// exportPattern("^[^\\.]") exportClassPattern("^A")
+	ExportPatterns      []string
+	ExportClassPatterns []string
+	// import(pkg) importFrom(pkg, f) importClassesFrom(pkg, A) importMethodsFrom(pkg, show)
+	Imports []NamespaceImport
+	// S3method(print, foo)
+	S3Methods []S3Method
+	// useDynLib(pkg, sym)
+	DynLibs []DynLib
+	// All the directives in file order
+	Directives []*NamespaceDirective
+}
+
+// NamespaceDirective is a call of the NAMESPACE file.
+type NamespaceDirective struct {
+	// Name of the directive: export, importFrom, S3method...
+	Name string
+	// Values of the arguments, symbols and strings are both given as text
+	Args []string
+	// Names of the arguments, "" for the unnamed ones
+	ArgNames []string
+	// The directive is inside an if () ... else ... branch
+	Conditional bool
+	// The parsed call
+	Call *CallExpr
+}
+
+// NamespaceImport is an import(), importFrom(), importClassesFrom() or importMethodsFrom() directive.
+type NamespaceImport struct {
+	Package string
+	// Name of the directive
+	Directive string
+	// Imported objects, empty for import(pkg)
+	Symbols []string
+	// Objects excluded by import(pkg, except = c(...))
+	Except []string
+}
+
+// S3Method is a S3method(generic, class, method) directive.
+type S3Method struct {
+	Generic string
+	Class   string
+	// Name of the implementation, "" for the default generic.class
+	Method string
+}
+
+// DynLib is a useDynLib(library, symbols..., .registration = TRUE, .fixes = "C_") directive.
+type DynLib struct {
+	Library      string
+	Symbols      []string
+	Registration bool
+	Fixes        string
+}
+
+// ParseNAMESPACE parses the directives of a NAMESPACE file.
+func ParseNAMESPACE(r io.Reader) (ns *Namespace, err error) {
+	var f *File
+
+	if f, err = ParseFile(r); err != nil {
+		return nil, fmt.Errorf("NAMESPACE: %s", err.Error())
+	}
+	ns = new(Namespace)
+	for _, x := range f.Exprs {
+		if err = ns.add(x, false); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// add records the directive x, the branches of an if are both recorded as conditional directives.
+func (this *Namespace) add(x Expr, conditional bool) (err error) {
+	switch n := x.(type) {
+	case *IfExpr :
+		if err = this.add(n.Then, true); err == nil && n.Else != nil {
+			err = this.add(n.Else, true)
+		}
+		return
+	case *BlockExpr :
+		for _, y := range n.List {
+			if err = this.add(y, conditional); err != nil {
+				return
+			}
+		}
+		return
+	case *CallExpr :
+		return this.addCall(n, conditional)
+	}
+	p := x.Pos()
+	return fmt.Errorf("NAMESPACE: %d:%d: directive expected", p.Line, p.Column)
+}
+
+// namespaceArgument returns the text of a directive argument: a symbol, a string, TRUE or FALSE.
+func namespaceArgument(x Expr) (s string, ok bool) {
+	switch n := x.(type) {
+	case *Ident :
+		return n.Name(), true
+	case *Constant :
+		switch n.Token.Type {
+		case CONST_CHARACTER, CONST_TRUE, CONST_FALSE :
+			return n.Token.stringvalue, true
+		}
+	}
+	return
+}
+
+func (this *Namespace) addCall(c *CallExpr, conditional bool) (err error) {
+	d := &NamespaceDirective{ Name: c.FunctionName(), Conditional: conditional, Call: c }
+	if d.Name == "" {
+		p := c.Pos()
+		return fmt.Errorf("NAMESPACE: %d:%d: directive expected", p.Line, p.Column)
+	}
+
+	var except []string
+	for _, a := range c.Args {
+		if a.Value == nil {
+			continue
+		}
+		name := ""
+		if a.Name != nil {
+			name = a.Name.stringvalue
+		}
+
+		// import(pkg, except = c(f, g))
+		if name == "except" {
+			if call, ok := a.Value.(*CallExpr); ok && call.FunctionName() == "c" {
+				for _, b := range call.Args {
+					if s, ok := namespaceArgument(b.Value); ok {
+						except = append(except, s)
+					}
+				}
+				continue
+			}
+		}
+
+		s, ok := namespaceArgument(a.Value)
+		if !ok {
+			p := a.Value.Pos()
+			return fmt.Errorf("NAMESPACE: %d:%d: symbol or string expected in %s()", p.Line, p.Column, d.Name)
+		}
+		d.Args = append(d.Args, s)
+		d.ArgNames = append(d.ArgNames, name)
+	}
+	this.Directives = append(this.Directives, d)
+
+	switch d.Name {
+	case "export" :
+		this.Exports = append(this.Exports, d.Args...)
+	case "exportClasses", "exportClass" :
+		this.ExportClasses = append(this.ExportClasses, d.Args...)
+	case "exportMethods" :
+		this.ExportMethods = append(this.ExportMethods, d.Args...)
+	case "exportPattern" :
+		this.ExportPatterns = append(this.ExportPatterns, d.Args...)
+	case "exportClassPattern" :
+		this.ExportClassPatterns = append(this.ExportClassPatterns, d.Args...)
+	case "import" :
+		for _, p := range d.Args {
+			this.Imports = append(this.Imports, NamespaceImport{ Package: p, Directive: d.Name, Except: except })
+		}
+	case "importFrom", "importClassesFrom", "importMethodsFrom" :
+		if len(d.Args) == 0 {
+			p := c.Pos()
+			return fmt.Errorf("NAMESPACE: %d:%d: package expected in %s()", p.Line, p.Column, d.Name)
+		}
+		this.Imports = append(this.Imports, NamespaceImport{ Package: d.Args[0], Directive: d.Name, Symbols: d.Args[1:] })
+	case "S3method" :
+		if len(d.Args) < 2 || len(d.Args) > 3 {
+			p := c.Pos()
+			return fmt.Errorf("NAMESPACE: %d:%d: S3method() needs a generic and a class", p.Line, p.Column)
+		}
+		m := S3Method{ Generic: d.Args[0], Class: d.Args[1] }
+		if len(d.Args) == 3 {
+			m.Method = d.Args[2]
+		}
+		this.S3Methods = append(this.S3Methods, m)
+	case "useDynLib" :
+		var l DynLib
+		for i, s := range d.Args {
+			switch d.ArgNames[i] {
+			case ".registration" :
+				l.Registration = s == "TRUE"
+			case ".fixes" :
+				l.Fixes = s
+			case "" :
+				if l.Library == "" {
+					l.Library = s
+				} else {
+					l.Symbols = append(l.Symbols, s)
+				}
+			default:
+				// useDynLib(pkg, alias = symbol)
+				l.Symbols = append(l.Symbols, s)
+			}
+		}
+		this.DynLibs = append(this.DynLibs, l)
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package r
+
+import "testing"
+import "strings"
+
+func TestParseNAMESPACE(e *testing.T) {
+	var str = `# Generated by roxygen2: do not edit by hand
+
+S3method(print, myclass)
+S3method("format", "myclass", format_my)
+export("%>%")
+export(f, g)
+exportPattern("^[^\\.]")
+exportClasses(Account)
+import(methods, except = c(show))
+importFrom(rlang, abort, .data)
+importFrom(magrittr,"%>%")
+useDynLib(mypkg, .registration = TRUE, .fixes = "C_")
+if (.Platform$OS.type == "windows") {
+  importFrom(utils, winDialog)
+} else importFrom(utils, head)
+`
+	ns, err := ParseNAMESPACE(strings.NewReader(str))
+	if err != nil { e.Fatal("Test NAMESPACE Failed", err) }
+
+	if strings.Join(ns.Exports, " ") != "%>% f g" { e.Error("Test NAMESPACE Exports Failed", ns.Exports) }
+	if len(ns.ExportPatterns) != 1 || ns.ExportPatterns[0] != `^[^\.]` { e.Error("Test NAMESPACE ExportPatterns Failed", ns.ExportPatterns) }
+	if strings.Join(ns.ExportClasses, " ") != "Account" { e.Error("Test NAMESPACE ExportClasses Failed", ns.ExportClasses) }
+
+	if len(ns.S3Methods) != 2 || ns.S3Methods[0] != (S3Method{ "print", "myclass", "" }) || ns.S3Methods[1] != (S3Method{ "format", "myclass", "format_my" }) { e.Error("Test NAMESPACE S3Methods Failed", ns.S3Methods) }
+
+	if len(ns.Imports) != 5 { e.Fatal("Test NAMESPACE Imports Failed", ns.Imports) }
+	if ns.Imports[0].Package != "methods" || ns.Imports[0].Directive != "import" || strings.Join(ns.Imports[0].Except, " ") != "show" { e.Error("Test NAMESPACE import Failed", ns.Imports[0]) }
+	if ns.Imports[1].Package != "rlang" || strings.Join(ns.Imports[1].Symbols, " ") != "abort .data" { e.Error("Test NAMESPACE importFrom Failed", ns.Imports[1]) }
+	if ns.Imports[4].Package != "utils" || strings.Join(ns.Imports[4].Symbols, " ") != "head" { e.Error("Test NAMESPACE conditional importFrom Failed", ns.Imports[4]) }
+
+	if len(ns.DynLibs) != 1 || ns.DynLibs[0].Library != "mypkg" || !ns.DynLibs[0].Registration || ns.DynLibs[0].Fixes != "C_" { e.Error("Test NAMESPACE useDynLib Failed", ns.DynLibs) }
+
+	if len(ns.Directives) != 12 { e.Error("Test NAMESPACE Directives Failed", len(ns.Directives)) }
+	if ns.Directives[0].Conditional || !ns.Directives[11].Conditional { e.Error("Test NAMESPACE Conditional Failed") }
+}
+
+func TestParseNAMESPACEErrors(e *testing.T) {
+	var tests = []string{
+		"export(f",
+		"x <- 1",
+		"export(f(x))",
+		"S3method(print)",
+		"importFrom()",
+	}
+	for i, str := range tests {
+		if _, err := ParseNAMESPACE(strings.NewReader(str)); err == nil {
+			e.Error("Test NAMESPACE Errors[", i, "] Failed")
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package r
+
+import "bytes"
+import "encoding/csv"
+import "fmt"
+import "io"
+import "sort"
+import "strconv"
+import "unicode/utf8"
+
+// ParseDataRow is a row of the table returned by R's getParseData(): a terminal token or an expr node.
+type ParseDataRow struct {
+	Line1, Col1 int
+	// Position of the last character
+	Line2, Col2 int
+	ID, Parent  int
+	// R token name: SYMBOL, NUM_CONST, LEFT_ASSIGN, '+', expr...
+	Token    string
+	Terminal bool
+	// Source text of a terminal, "" for the nodes
+	Text string
+}
+
+// Names of the tokens in R's parse data, indexed by token type, for the tokens whose name does not depend on
+// their context.
+var parseDataTokens = map[TokenType]string{
+	CONST_CHARACTER: "STR_CONST", CONST_NULL: "NULL_CONST",
+	KEYWORD_IF: "IF", KEYWORD_ELSE: "ELSE", KEYWORD_FOR: "FOR", KEYWORD_IN: "IN", KEYWORD_REPEAT: "REPEAT",
+	KEYWORD_WHILE: "WHILE", KEYWORD_NEXT: "NEXT", KEYWORD_BREAK: "BREAK", KEYWORD_FUNCTION: "FUNCTION",
+	SYMBOL: "SYMBOL", DOTS: "SYMBOL", DOTDOT_N: "SYMBOL", INFIX: "SPECIAL", COMMENT: "COMMENT",
+	OP_RIGHT_ASSIGN: "RIGHT_ASSIGN", OP_RIGHT_ASSIGN2: "RIGHT_ASSIGN", OP_LEFT_ASSIGN: "LEFT_ASSIGN",
+	OP_LEFT_ASSIGN2: "LEFT_ASSIGN", OP_COLON_ASSIGN: "LEFT_ASSIGN", OP_EQUAL_ASSIGN: "EQ_ASSIGN",
+	OP_LEFT_SQUARE: "'['", OP_LEFT_SQUARE2: "LBB", OP_RIGHT_SQUARE: "']'", OP_LEFT_ROUND: "'('",
+	OP_RIGHT_ROUND: "')'", OP_LEFT_CURLY: "'{'", OP_RIGHT_CURLY: "'}'", OP_COLON: "':'", OP_NAMESPACE: "NS_GET",
+	OP_NAMESPACE_INTERNAL: "NS_GET_INT", OP_DOLLAR: "'$'", OP_AT: "'@'", OP_COMMA: "','", OP_SEMICOLON: "';'",
+	OP_ADD: "'+'", OP_SUB: "'-'", OP_MUL: "'*'", OP_MUL2: "'^'", OP_DIV: "'/'", OP_POW: "'^'",
+	OP_GT: "GT", OP_GE: "GE", OP_LT: "LT", OP_LE: "LE", OP_EQ: "EQ", OP_NE: "NE", OP_NOT: "'!'",
+	OP_AND: "AND", OP_AND2: "AND2", OP_OR: "OR", OP_OR2: "OR2", OP_TILDE: "'~'", OP_QUESTION: "'?'",
+}
+
+// endPosition returns the line and the column of the last character of the token.
+func endPosition(t *Token, src []byte) (line, col int) {
+	line, col = t.nline, t.ncol
+	text := src[t.offset:t.End()]
+	for len(text) > 0 {
+		r, n := utf8.DecodeRune(text)
+		text = text[n:]
+		if len(text) == 0 {
+			break
+		}
+		if r == '\n' {
+			line, col = line + 1, 1
+		} else {
+			col++
+		}
+	}
+	return
+}
+
+// ParseData returns the parse data of the R source as getParseData() does for R 3.x: the terminal tokens, the
+// comments and one expr row per expression node, the symbols after ::, $ and @ not being nodes. The ids are
+// assigned in source order and the parent of a row is the innermost node containing it, 0 at the top level.
+func ParseData(src []byte) (rows []*ParseDataRow, err error) {
+	f, err := ParseFile(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	// Names of the tokens given by their context and the expr nodes
+	roles := make(map[int]string)
+	// Offsets of the names followed by = in the arguments and the formals
+	equals := make(map[int]string)
+	skip := make(map[Node]bool)
+	var nodes []Expr
+	for _, x := range f.Exprs {
+		Inspect(x, func(n Node) bool {
+			switch v := n.(type) {
+			case *BinaryExpr :
+				switch v.Op.Type {
+				case OP_NAMESPACE, OP_NAMESPACE_INTERNAL :
+					skip[v.X], skip[v.Y] = true, true
+					roles[v.X.Pos().Offset] = "SYMBOL_PACKAGE"
+				case OP_DOLLAR :
+					skip[v.Y] = true
+				case OP_AT :
+					skip[v.Y] = true
+					roles[v.Y.Pos().Offset] = "SLOT"
+				}
+			case *CallExpr :
+				fun := v.Fun
+				if b, ok := fun.(*BinaryExpr); ok && (b.Op.Type == OP_NAMESPACE || b.Op.Type == OP_NAMESPACE_INTERNAL) {
+					fun = b.Y
+				}
+				if id, ok := fun.(*Ident); ok {
+					roles[id.Token.offset] = "SYMBOL_FUNCTION_CALL"
+				}
+				for _, a := range v.Args {
+					if a.Name != nil {
+						equals[a.Name.offset] = "EQ_SUB"
+						if isSymbol(a.Name) {
+							roles[a.Name.offset] = "SYMBOL_SUB"
+						}
+					}
+				}
+			case *IndexExpr :
+				for _, a := range v.Args {
+					if a.Name != nil {
+						equals[a.Name.offset] = "EQ_SUB"
+						if isSymbol(a.Name) {
+							roles[a.Name.offset] = "SYMBOL_SUB"
+						}
+					}
+				}
+			case *FunctionExpr :
+				for _, p := range v.Formals {
+					roles[p.Name.offset] = "SYMBOL_FORMALS"
+					if p.Default != nil {
+						equals[p.Name.offset] = "EQ_FORMALS"
+					}
+				}
+			}
+			if !skip[n] {
+				nodes = append(nodes, n.(Expr))
+			}
+			return true
+		})
+	}
+
+	// The expr rows in source order, the enclosing nodes first
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Pos().Offset != b.Pos().Offset {
+			return a.Pos().Offset < b.Pos().Offset
+		}
+		return a.End() > b.End()
+	})
+	type span struct {
+		row        *ParseDataRow
+		start, end int
+	}
+	var spans []span
+	for _, x := range nodes {
+		p := x.Pos()
+		line2, col2 := endPosition(lastToken(x), src)
+		r := &ParseDataRow{ Line1: p.Line, Col1: p.Column, Line2: line2, Col2: col2, Token: "expr" }
+		rows = append(rows, r)
+		spans = append(spans, span{ r, p.Offset, x.End() })
+	}
+
+	s := NewScanner(bytes.NewReader(src))
+	pending := ""
+	for {
+		t := s.NextToken()
+		if t.Type == END_OF_INPUT {
+			break
+		}
+		if t.Type == ERROR {
+			return nil, fmt.Errorf("%d:%d: invalid token", t.nline, t.ncol)
+		}
+		if t.Type == END_OF_LINE || t.Type == LINE_DIRECTIVE {
+			continue
+		}
+		name, ok := roles[t.offset]
+		if !ok {
+			name, ok = parseDataTokens[t.Type]
+		}
+		if !ok {
+			// The other constants: numbers, logicals, NA
+			name = "NUM_CONST"
+		}
+		if t.Type == OP_EQUAL_ASSIGN && pending != "" {
+			name = pending
+		}
+		if t.Type != COMMENT {
+			pending = equals[t.offset]
+		}
+		line2, col2 := endPosition(t, src)
+		r := &ParseDataRow{ Line1: t.nline, Col1: t.ncol, Line2: line2, Col2: col2, Token: name, Terminal: true, Text: string(src[t.offset:t.End()]) }
+		// R saves ** as ^
+		if t.Type == OP_MUL2 {
+			r.Text = "^"
+		}
+		rows = append(rows, r)
+		spans = append(spans, span{ r, t.offset, t.End() })
+	}
+
+	// Ids in source order and parents by containment
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end || (spans[i].end == spans[j].end && !spans[i].row.Terminal && spans[j].row.Terminal)
+	})
+	var stack []span
+	rows = rows[:0]
+	for i, sp := range spans {
+		sp.row.ID = i + 1
+		for len(stack) > 0 && stack[len(stack)-1].end <= sp.start {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			sp.row.Parent = stack[len(stack)-1].row.ID
+		}
+		if !sp.row.Terminal {
+			stack = append(stack, sp)
+		}
+		rows = append(rows, sp.row)
+	}
+	return rows, nil
+}
+
+// ReadParseData reads parse data written by write.csv(getParseData(x), row.names = FALSE): the columns line1,
+// col1, line2, col2, id, parent, token, terminal and text are found by their header.
+func ReadParseData(r io.Reader) (rows []*ParseDataRow, err error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("parse data without header")
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+	for _, name := range []string{ "line1", "col1", "line2", "col2", "id", "parent", "token", "terminal", "text" } {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("parse data without %s column", name)
+		}
+	}
+	for n, rec := range records[1:] {
+		row := new(ParseDataRow)
+		ints := []*int{ &row.Line1, &row.Col1, &row.Line2, &row.Col2, &row.ID, &row.Parent }
+		for i, name := range []string{ "line1", "col1", "line2", "col2", "id", "parent" } {
+			if *ints[i], err = strconv.Atoi(rec[columns[name]]); err != nil {
+				return nil, fmt.Errorf("parse data row %d: invalid %s", n+1, name)
+			}
+		}
+		row.Token, row.Text = rec[columns["token"]], rec[columns["text"]]
+		row.Terminal = rec[columns["terminal"]] == "TRUE"
+		rows = append(rows, row)
+	}
+	return
+}
+
+// WriteParseData writes the rows as write.csv(getParseData(x), row.names = FALSE) does.
+func WriteParseData(w io.Writer, rows []*ParseDataRow) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{ "line1", "col1", "line2", "col2", "id", "parent", "token", "terminal", "text" })
+	for _, r := range rows {
+		terminal := "FALSE"
+		if r.Terminal {
+			terminal = "TRUE"
+		}
+		c.Write([]string{ strconv.Itoa(r.Line1), strconv.Itoa(r.Col1), strconv.Itoa(r.Line2), strconv.Itoa(r.Col2),
+			strconv.Itoa(r.ID), strconv.Itoa(r.Parent), r.Token, terminal, r.Text })
+	}
+	c.Flush()
+	return c.Error()
+}
+
+// ParseDataDiff compares parse data to the expected parse data of R. The terminals are compared one by one in
+// source order by position, token name and text; the expr nodes (and the equal_assign and
+// expr_or_assign_or_help nodes of R) are compared by position only. It returns the number of matching terminals
+// and nodes and a description of each difference.
+func ParseDataDiff(expected, actual []*ParseDataRow) (tokens, nodes int, diffs []string) {
+	split := func(rows []*ParseDataRow) (terminals []*ParseDataRow, exprs map[[4]int]int) {
+		exprs = make(map[[4]int]int)
+		for _, r := range rows {
+			switch {
+			case r.Terminal :
+				terminals = append(terminals, r)
+			case r.Token == "expr" || r.Token == "equal_assign" || r.Token == "expr_or_assign_or_help" :
+				exprs[[4]int{ r.Line1, r.Col1, r.Line2, r.Col2 }]++
+			}
+		}
+		sort.SliceStable(terminals, func(i, j int) bool {
+			if terminals[i].Line1 != terminals[j].Line1 {
+				return terminals[i].Line1 < terminals[j].Line1
+			}
+			return terminals[i].Col1 < terminals[j].Col1
+		})
+		return
+	}
+	et, ee := split(expected)
+	at, ae := split(actual)
+
+	format := func(r *ParseDataRow) string {
+		return fmt.Sprintf("%d:%d-%d:%d %s %q", r.Line1, r.Col1, r.Line2, r.Col2, r.Token, r.Text)
+	}
+	for i := 0; i < len(et) || i < len(at); i++ {
+		switch {
+		case i >= len(at) :
+			diffs = append(diffs, "missing token " + format(et[i]))
+		case i >= len(et) :
+			diffs = append(diffs, "extra token " + format(at[i]))
+		case format(et[i]) == format(at[i]) :
+			tokens++
+		default:
+			diffs = append(diffs, "token " + format(at[i]) + ", expected " + format(et[i]))
+		}
+	}
+
+	var keys [][4]int
+	for k := range ee {
+		keys = append(keys, k)
+	}
+	for k := range ae {
+		if _, ok := ee[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		for n := 0; n < 4; n++ {
+			if keys[i][n] != keys[j][n] {
+				return keys[i][n] < keys[j][n]
+			}
+		}
+		return false
+	})
+	for _, k := range keys {
+		e, a := ee[k], ae[k]
+		m := e
+		if a < m {
+			m = a
+		}
+		nodes += m
+		for ; e > a; e-- {
+			diffs = append(diffs, fmt.Sprintf("missing expr %d:%d-%d:%d", k[0], k[1], k[2], k[3]))
+		}
+		for ; a > e; a-- {
+			diffs = append(diffs, fmt.Sprintf("extra expr %d:%d-%d:%d", k[0], k[1], k[2], k[3]))
+		}
+	}
+	return
+}
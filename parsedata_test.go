@@ -0,0 +1,112 @@
+package r
+
+import "bytes"
+import "strings"
+import "testing"
+
+func TestParseData(e *testing.T) {
+	tests := []struct {
+		src    string
+		tokens string
+	}{
+		{ "x <- 1", "SYMBOL LEFT_ASSIGN NUM_CONST" },
+		{ "f(a = 1, b)", "SYMBOL_FUNCTION_CALL '(' SYMBOL_SUB EQ_SUB NUM_CONST ',' SYMBOL ')'" },
+		{ "function(x, y = 2) x", "FUNCTION '(' SYMBOL_FORMALS ',' SYMBOL_FORMALS EQ_FORMALS NUM_CONST ')' SYMBOL" },
+		{ "stats::sd(x$a@b)", "SYMBOL_PACKAGE NS_GET SYMBOL_FUNCTION_CALL '(' SYMBOL '$' SYMBOL '@' SLOT ')'" },
+		{ "x[[1]] ** 2 # c", "SYMBOL LBB NUM_CONST ']' ']' '^' NUM_CONST COMMENT" },
+		{ "if (a %in% b) NULL else \"s\"", "IF '(' SYMBOL SPECIAL SYMBOL ')' NULL_CONST ELSE STR_CONST" },
+	}
+	for i, test := range tests {
+		rows, err := ParseData([]byte(test.src))
+		if err != nil {
+			e.Error("Test ParseData[", i, "] Failed with error", err)
+			continue
+		}
+		var tokens []string
+		for _, r := range rows {
+			if r.Terminal {
+				tokens = append(tokens, r.Token)
+			}
+		}
+		if strings.Join(tokens, " ") != test.tokens {
+			e.Error("Test ParseData[", i, "] Failed:", strings.Join(tokens, " "))
+		}
+	}
+
+	rows, _ := ParseData([]byte("x <- y ** 2\n"))
+	if len(rows) != 10 || rows[0].Token != "expr" || rows[0].Parent != 0 || rows[0].Col2 != 11 {
+		e.Fatal("Test ParseData Failed: rows", len(rows))
+	}
+	for _, r := range rows[1:] {
+		if r.Parent == 0 || r.Parent >= r.ID {
+			e.Error("Test ParseData Failed: parent of", *r)
+		}
+	}
+	if rows[7].Text != "^" || rows[7].Col1 != 8 || rows[7].Col2 != 9 {
+		e.Error("Test ParseData Failed: **", *rows[7])
+	}
+
+	if _, err := ParseData([]byte("f(")); err == nil {
+		e.Error("Test ParseData Failed: no error")
+	}
+}
+
+func TestReadWriteParseData(e *testing.T) {
+	rows, _ := ParseData([]byte("s <- \"a\nb\" # c"))
+	var b bytes.Buffer
+	if err := WriteParseData(&b, rows); err != nil {
+		e.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "line1,col1,line2,col2,id,parent,token,terminal,text\n") {
+		e.Error("Test WriteParseData Failed:", b.String())
+	}
+	read, err := ReadParseData(&b)
+	if err != nil || len(read) != len(rows) {
+		e.Fatal("Test ReadParseData Failed:", len(read), err)
+	}
+	for i := range rows {
+		if *read[i] != *rows[i] {
+			e.Error("Test ReadParseData[", i, "] Failed:", *read[i])
+		}
+	}
+
+	read, err = ReadParseData(strings.NewReader("\"token\",\"text\",\"line1\",\"col1\",\"line2\",\"col2\",\"id\",\"parent\",\"terminal\"\n" +
+		"\"SYMBOL\",\"x\",1,1,1,1,1,0,TRUE\n"))
+	if err != nil || len(read) != 1 || read[0].Token != "SYMBOL" || read[0].Text != "x" || !read[0].Terminal {
+		e.Error("Test ReadParseData Failed: columns", err)
+	}
+	for i, src := range []string{ "", "line1,col1\n", "line1,col1,line2,col2,id,parent,token,terminal,text\n1,x,1,1,1,0,expr,FALSE,\n" } {
+		if _, err := ReadParseData(strings.NewReader(src)); err == nil {
+			e.Error("Test ReadParseData[", i, "] Failed: no error")
+		}
+	}
+}
+
+func TestParseDataDiff(e *testing.T) {
+	expected, _ := ParseData([]byte("x <- f(1)"))
+	actual, _ := ParseData([]byte("x <- f(1)"))
+	tokens, nodes, diffs := ParseDataDiff(expected, actual)
+	if tokens != 6 || nodes != 5 || len(diffs) != 0 {
+		e.Error("Test ParseDataDiff Failed:", tokens, nodes, diffs)
+	}
+
+	actual, _ = ParseData([]byte("x <- g(1)"))
+	expected[0].Token = "equal_assign"
+	tokens, nodes, diffs = ParseDataDiff(expected, actual)
+	if tokens != 5 || nodes != 5 || len(diffs) != 1 || diffs[0] != "token 1:6-1:6 SYMBOL_FUNCTION_CALL \"g\", expected 1:6-1:6 SYMBOL_FUNCTION_CALL \"f\"" {
+		e.Error("Test ParseDataDiff Failed:", tokens, nodes, diffs)
+	}
+
+	actual, _ = ParseData([]byte("x <- f(1) "))
+	actual = actual[:len(actual)-1]
+	tokens, nodes, diffs = ParseDataDiff(expected, actual)
+	if tokens != 5 || nodes != 5 || len(diffs) != 1 || diffs[0] != "missing token 1:9-1:9 ')' \")\"" {
+		e.Error("Test ParseDataDiff Failed:", tokens, nodes, diffs)
+	}
+
+	actual, _ = ParseData([]byte("x <- (f)(1)"))
+	_, nodes, diffs = ParseDataDiff(expected, actual)
+	if nodes != 1 || !strings.Contains(strings.Join(diffs, "\n"), "missing expr 1:1-1:9\n") {
+		e.Error("Test ParseDataDiff Failed:", nodes, diffs)
+	}
+}
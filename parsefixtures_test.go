@@ -0,0 +1,132 @@
+package r
+
+import "bytes"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+// parseFixtures compares the parse of the file name.R with the expected results next to it: name.parsedata.csv
+// (getParseData), name.deparse (deparse of each expression) and name.sexp (expressions in the form of Sexp). A
+// missing fixture is skipped. It returns the matching and expected numbers of terminals and nodes of the parse
+// data and the differences.
+func parseFixtures(name string) (tokens, ntokens, nodes, nnodes int, diffs []string, err error) {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return
+	}
+	f, err := ParseFile(bytes.NewReader(src))
+	if err != nil {
+		return
+	}
+	base := strings.TrimSuffix(name, ".R")
+
+	if fixture, e := os.Open(base + ".parsedata.csv"); e == nil {
+		expected, e := ReadParseData(fixture)
+		fixture.Close()
+		if e != nil {
+			return 0, 0, 0, 0, nil, e
+		}
+		actual, e := ParseData(src)
+		if e != nil {
+			return 0, 0, 0, 0, nil, e
+		}
+		for _, r := range expected {
+			switch {
+			case r.Terminal :
+				ntokens++
+			case r.Token == "expr" || r.Token == "equal_assign" || r.Token == "expr_or_assign_or_help" :
+				nnodes++
+			}
+		}
+		tokens, nodes, diffs = ParseDataDiff(expected, actual)
+	}
+
+	var exprs []string
+	for _, x := range f.Exprs {
+		s, e := Sexp(x)
+		if e != nil {
+			return 0, 0, 0, 0, nil, e
+		}
+		exprs = append(exprs, s)
+	}
+
+	if deparsed, e := ioutil.ReadFile(base + ".deparse"); e == nil {
+		g, e := ParseFile(bytes.NewReader(deparsed))
+		if e != nil {
+			return 0, 0, 0, 0, nil, fmt.Errorf("%s.deparse: %v", base, e)
+		}
+		for i := 0; i < len(g.Exprs) || i < len(exprs); i++ {
+			switch {
+			case i >= len(g.Exprs) :
+				diffs = append(diffs, fmt.Sprintf("deparse %d: missing, expected %s", i+1, exprs[i]))
+			case i >= len(exprs) :
+				diffs = append(diffs, fmt.Sprintf("deparse %d: extra", i+1))
+			default:
+				if s, _ := Sexp(g.Exprs[i]); s != exprs[i] {
+					diffs = append(diffs, fmt.Sprintf("deparse %d: %s, expected %s", i+1, s, exprs[i]))
+				}
+			}
+		}
+	}
+
+	if sexp, e := ioutil.ReadFile(base + ".sexp"); e == nil {
+		lines := strings.Split(strings.TrimSuffix(string(sexp), "\n"), "\n")
+		for i := 0; i < len(lines) || i < len(exprs); i++ {
+			switch {
+			case i >= len(exprs) :
+				diffs = append(diffs, fmt.Sprintf("sexp %d: missing %s", i+1, lines[i]))
+			case i >= len(lines) :
+				diffs = append(diffs, fmt.Sprintf("sexp %d: extra %s", i+1, exprs[i]))
+			case lines[i] != exprs[i] :
+				diffs = append(diffs, fmt.Sprintf("sexp %d: %s, expected %s", i+1, exprs[i], lines[i]))
+			}
+		}
+	}
+	return
+}
+
+// TestParseFixtures compares the parser with the hand-written fixtures of each grammar of testdata/parsefixtures.
+// The differences listed in the name.known file of a source file are expected and logged, the others fail.
+func TestParseFixtures(e *testing.T) {
+	dirs, _ := filepath.Glob(filepath.Join("testdata", "parsefixtures", "R-*"))
+	if len(dirs) == 0 {
+		e.Fatal("Test ParseFixtures Failed: no fixtures")
+	}
+	for _, dir := range dirs {
+		version := filepath.Base(dir)
+		files, _ := filepath.Glob(filepath.Join(dir, "*.R"))
+		var tokens, ntokens, nodes, nnodes int
+		for _, name := range files {
+			t, nt, n, nn, diffs, err := parseFixtures(name)
+			if err != nil {
+				e.Error("Test ParseFixtures[", name, "] Failed with error", err)
+				continue
+			}
+			tokens, ntokens, nodes, nnodes = tokens+t, ntokens+nt, nodes+n, nnodes+nn
+
+			known := make(map[string]bool)
+			if b, err := ioutil.ReadFile(strings.TrimSuffix(name, ".R") + ".known"); err == nil {
+				for _, l := range strings.Split(string(b), "\n") {
+					if l != "" && !strings.HasPrefix(l, "#") {
+						known[l] = true
+					}
+				}
+			}
+			for _, d := range diffs {
+				if known[d] {
+					e.Log(name, ": known difference", d)
+					delete(known, d)
+				} else {
+					e.Error("Test ParseFixtures[", name, "] Failed:", d)
+				}
+			}
+			for d := range known {
+				e.Error("Test ParseFixtures[", name, "] Failed: known difference not found", d)
+			}
+		}
+		e.Logf("%s: %d files, tokens %d/%d, nodes %d/%d", version, len(files), tokens, ntokens, nodes, nnodes)
+	}
+}
@@ -0,0 +1,657 @@
+package r
+
+import "io"
+import "fmt"
+import "strings"
+
+// Operator precedences, from the lowest to the highest (see the precedence table of grammar/R-3.3.1.y).
+const (
+	precLowest = iota
+	precQuestion     // ?
+	precLow          // bodies of function, while, for, repeat and if
+	precLeftAssign   // <- <<- := (right)
+	precRightAssign  // -> ->>
+	precTilde        // ~
+	precOr           // | ||
+	precAnd          // & &&
+	precNot          // unary !
+	precCompare      // > >= < <= == != (non associative)
+	precAdd          // + -
+	precMul          // * /
+	precSpecial      // %xxx%
+	precColon        // :
+	precUnary        // unary - +
+	precPow          // ^ ** (right)
+	precDollar       // $ @
+	precNamespace    // :: :::
+	precPostfix      // ( [ [[
+)
+
+// ParseError is a syntax error found by the Parser.
+type ParseError struct {
+	// Position of the unexpected token
+	Pos Position
+	Msg string
+}
+
+func (this *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", this.Pos.Line, this.Pos.Column, this.Msg)
+}
+
+// Parser builds the abstract syntax tree of R source code from the tokens of a Scanner.
+type Parser struct {
+	scanner *Scanner
+	// Significant tokens read so far and index of the next one
+	tokens []*Token
+	pos    int
+	// COMMENT and LINE_DIRECTIVE tokens read so far
+	comments []*Token
+	// Stack of the opened '(', '[' and '{' contexts: newlines are not significant inside '(' and '['
+	contexts []TokenType
+}
+
+// NewParser returns a Parser reading the R source code from r, in UTF-8 or in the encoding given as option.
+func NewParser(r io.Reader, encoding ...Encoding) (p *Parser) {
+	p = new(Parser)
+	p.scanner = NewScanner(r, encoding...)
+	return
+}
+
+// EncodingErrors returns the invalid sequences of bytes read so far.
+func (this *Parser) EncodingErrors() []*EncodingError {
+	return this.scanner.EncodingErrors()
+}
+
+// SetLocale sets the locale of the classification of the characters of the symbols, LOCALE_UTF8 by default.
+func (this *Parser) SetLocale(locale Locale) {
+	this.scanner.SetLocale(locale)
+}
+
+// IdentErrors returns the symbols R rejects found so far.
+func (this *Parser) IdentErrors() []*IdentError {
+	return this.scanner.IdentErrors()
+}
+
+// newParserAt returns a Parser whose token positions start at pos, used for R code embedded in another document.
+func newParserAt(r io.Reader, pos Position) (p *Parser) {
+	p = NewParser(r)
+	p.scanner.currentOffset = pos.Offset
+	p.scanner.nline = pos.Line
+	p.scanner.ncol = pos.Column
+	return
+}
+
+// ParseFile parses all the R source code read from r.
+func ParseFile(r io.Reader) (*File, error) {
+	return NewParser(r).Parse()
+}
+
+// ParseExpr parses a single R expression.
+func ParseExpr(src string) (x Expr, err error) {
+	var f *File
+
+	if f, err = ParseFile(strings.NewReader(src)); err != nil {
+		return
+	}
+	if len(f.Exprs) != 1 {
+		err = &ParseError{ Position{ 0, 1, 1 }, fmt.Sprintf("expected one expression, found %d", len(f.Exprs)) }
+		return
+	}
+	x = f.Exprs[0]
+	return
+}
+
+// Parse parses the whole input and returns the list of the top level expressions.
+func (this *Parser) Parse() (f *File, err error) {
+	var x Expr
+
+	f = new(File)
+	for {
+		// Skip the empty statements
+		t := this.peek()
+		for t.Type == END_OF_LINE || t.Type == OP_SEMICOLON {
+			this.next()
+			t = this.peek()
+		}
+		if t.Type == END_OF_INPUT {
+			break
+		}
+		if x, err = this.parseExprOrAssign(precLowest); err != nil {
+			break
+		}
+		f.Exprs = append(f.Exprs, x)
+
+		// An expression must be followed by a newline, a ';' or the end of input
+		t = this.peek()
+		if t.Type != END_OF_LINE && t.Type != OP_SEMICOLON && t.Type != END_OF_INPUT {
+			err = this.unexpected(t)
+			break
+		}
+	}
+	f.Comments = this.comments
+	return
+}
+
+// token returns the i-th significant token of the input, reading it from the scanner if needed.
+func (this *Parser) token(i int) *Token {
+	for len(this.tokens) <= i {
+		if n := len(this.tokens); n > 0 && this.tokens[n-1].Type == END_OF_INPUT {
+			return this.tokens[n-1]
+		}
+		t := this.scanner.NextToken()
+		if t.Type == COMMENT || t.Type == LINE_DIRECTIVE {
+			this.comments = append(this.comments, t)
+			continue
+		}
+		this.tokens = append(this.tokens, t)
+	}
+	return this.tokens[i]
+}
+
+// skipsNewlines reports whether the newlines are insignificant in the current context.
+func (this *Parser) skipsNewlines() bool {
+	n := len(this.contexts)
+	return n > 0 && (this.contexts[n-1] == OP_LEFT_ROUND || this.contexts[n-1] == OP_LEFT_SQUARE)
+}
+
+// peekAt returns the k-th next token without consuming it (k = 0 is the next token).
+func (this *Parser) peekAt(k int) *Token {
+	i := this.pos
+	for {
+		for this.skipsNewlines() && this.token(i).Type == END_OF_LINE {
+			i++
+		}
+		if k == 0 {
+			return this.token(i)
+		}
+		k--
+		i++
+	}
+}
+
+func (this *Parser) peek() *Token {
+	return this.peekAt(0)
+}
+
+// next consumes and returns the next token.
+func (this *Parser) next() (t *Token) {
+	for this.skipsNewlines() && this.token(this.pos).Type == END_OF_LINE {
+		this.pos++
+	}
+	t = this.token(this.pos)
+	if t.Type != END_OF_INPUT {
+		this.pos++
+	}
+	return
+}
+
+// skipNewlines consumes the newlines where an expression is still expected.
+func (this *Parser) skipNewlines() {
+	for this.token(this.pos).Type == END_OF_LINE {
+		this.pos++
+	}
+}
+
+// expect consumes the next token if it has the type tt and returns a syntax error otherwise.
+func (this *Parser) expect(tt TokenType) (t *Token, err error) {
+	t = this.peek()
+	if t.Type != tt {
+		err = this.unexpected(t)
+		return
+	}
+	this.next()
+	return
+}
+
+// expectSymbol reads a SYMBOL, DOTS or DOTDOT_N token.
+func (this *Parser) expectSymbol() (t *Token, err error) {
+	t = this.peek()
+	if !isSymbol(t) {
+		err = this.unexpected(t)
+		return
+	}
+	this.next()
+	return
+}
+
+func (this *Parser) push(tt TokenType) {
+	this.contexts = append(this.contexts, tt)
+}
+
+func (this *Parser) pop() {
+	this.contexts = this.contexts[:len(this.contexts)-1]
+}
+
+// unexpected returns the syntax error reported by R for an unexpected token.
+func (this *Parser) unexpected(t *Token) error {
+	var what string
+
+	switch t.Type {
+	case END_OF_INPUT : what = "end of input"
+	case END_OF_LINE : what = "end of line"
+	case ERROR : what = "input"
+	case SYMBOL, DOTS, DOTDOT_N : what = "symbol"
+	case CONST_CHARACTER : what = "string constant"
+	case CONST_INTEGER, CONST_REAL, CONST_COMPLEX, CONST_NAN, CONST_INF, CONST_TRUE, CONST_FALSE, CONST_NULL,
+		NA_CHARACTER, NA_INTEGER, NA_REAL, NA_COMPLEX, NA_LOGICAL : what = "numeric constant"
+	case INFIX : what = "SPECIAL"
+	case OP_LEFT_ASSIGN, OP_LEFT_ASSIGN2, OP_COLON_ASSIGN : what = "assignment"
+	case OP_RIGHT_ASSIGN, OP_RIGHT_ASSIGN2 : what = "assignment"
+	default:
+		what = "'" + t.stringvalue + "'"
+	}
+	return &ParseError{ t.Pos(), "unexpected " + what }
+}
+
+// binaryPrecedence returns the precedence of a binary operator token, or precLowest if t is not a binary operator.
+func binaryPrecedence(t *Token) (prec int, right bool) {
+	switch t.Type {
+	case OP_QUESTION : return precQuestion, false
+	case OP_LEFT_ASSIGN, OP_LEFT_ASSIGN2, OP_COLON_ASSIGN : return precLeftAssign, true
+	case OP_RIGHT_ASSIGN, OP_RIGHT_ASSIGN2 : return precRightAssign, false
+	case OP_TILDE : return precTilde, false
+	case OP_OR, OP_OR2 : return precOr, false
+	case OP_AND, OP_AND2 : return precAnd, false
+	case OP_GT, OP_GE, OP_LT, OP_LE, OP_EQ, OP_NE : return precCompare, false
+	case OP_ADD, OP_SUB : return precAdd, false
+	case OP_MUL, OP_DIV : return precMul, false
+	case INFIX : return precSpecial, false
+	case OP_COLON : return precColon, false
+	case OP_POW, OP_MUL2 : return precPow, true
+	case OP_DOLLAR, OP_AT : return precDollar, false
+	case OP_NAMESPACE, OP_NAMESPACE_INTERNAL : return precNamespace, false
+	case OP_LEFT_ROUND, OP_LEFT_SQUARE, OP_LEFT_SQUARE2 : return precPostfix, false
+	}
+	return precLowest, false
+}
+
+// parseExprOrAssign parses an expression optionally followed by '=' and another expression (expr_or_assign rule).
+func (this *Parser) parseExprOrAssign(prec int) (x Expr, err error) {
+	var y Expr
+
+	if x, err = this.parseExpr(prec); err != nil {
+		return
+	}
+	if t := this.peek(); t.Type == OP_EQUAL_ASSIGN {
+		this.next()
+		this.skipNewlines()
+		if y, err = this.parseExprOrAssign(prec); err != nil {
+			return
+		}
+		x = &BinaryExpr{ t, x, y }
+	}
+	return
+}
+
+// parseExpr parses an expression made of operators with a precedence of at least prec.
+func (this *Parser) parseExpr(prec int) (x Expr, err error) {
+	var y Expr
+
+	if x, err = this.parseOperand(); err != nil {
+		return
+	}
+	for {
+		t := this.peek()
+		p, right := binaryPrecedence(t)
+		if p == precLowest || p < prec {
+			return
+		}
+		this.next()
+
+		switch t.Type {
+		case OP_LEFT_ROUND :
+			x, err = this.parseCall(x, t)
+		case OP_LEFT_SQUARE, OP_LEFT_SQUARE2 :
+			x, err = this.parseIndex(x, t)
+		case OP_DOLLAR, OP_AT :
+			// the right operand must be a symbol or a string
+			this.skipNewlines()
+			n := this.next()
+			if isSymbol(n) {
+				x = &BinaryExpr{ t, x, &Ident{ n } }
+			} else if n.Type == CONST_CHARACTER {
+				x = &BinaryExpr{ t, x, &Constant{ n } }
+			} else {
+				err = this.unexpected(n)
+			}
+		case OP_NAMESPACE, OP_NAMESPACE_INTERNAL :
+			// both operands must be a symbol or a string
+			if !isNamespaceOperand(x) {
+				err = this.unexpected(t)
+				return
+			}
+			n := this.next()
+			if isSymbol(n) {
+				x = &BinaryExpr{ t, x, &Ident{ n } }
+			} else if n.Type == CONST_CHARACTER {
+				x = &BinaryExpr{ t, x, &Constant{ n } }
+			} else {
+				err = this.unexpected(n)
+			}
+		default:
+			this.skipNewlines()
+			if right {
+				y, err = this.parseExpr(p)
+			} else {
+				y, err = this.parseExpr(p + 1)
+			}
+			if err != nil {
+				return
+			}
+			x = &BinaryExpr{ t, x, y }
+
+			// comparison operators are not associative: a < b < c is a syntax error
+			if p == precCompare {
+				if q, _ := binaryPrecedence(this.peek()); q == precCompare {
+					err = this.unexpected(this.peek())
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func isNamespaceOperand(x Expr) bool {
+	switch o := x.(type) {
+	case *Ident :
+		return true
+	case *Constant :
+		return o.Token.Type == CONST_CHARACTER
+	}
+	return false
+}
+
+// parseOperand parses a constant, a symbol, a prefix operator or a language construct.
+func (this *Parser) parseOperand() (x Expr, err error) {
+	var y Expr
+
+	t := this.next()
+	switch t.Type {
+	case CONST_CHARACTER, CONST_INTEGER, CONST_REAL, CONST_COMPLEX, CONST_NAN, CONST_INF, CONST_TRUE, CONST_FALSE, CONST_NULL,
+		NA_CHARACTER, NA_INTEGER, NA_REAL, NA_COMPLEX, NA_LOGICAL :
+		x = &Constant{ t }
+
+	case SYMBOL, DOTS, DOTDOT_N :
+		x = &Ident{ t }
+
+	case OP_SUB, OP_ADD :
+		this.skipNewlines()
+		if y, err = this.parseExpr(precUnary + 1); err == nil {
+			x = &UnaryExpr{ t, y }
+		}
+
+	case OP_NOT :
+		this.skipNewlines()
+		if y, err = this.parseExpr(precNot + 1); err == nil {
+			x = &UnaryExpr{ t, y }
+		}
+
+	case OP_TILDE :
+		this.skipNewlines()
+		if y, err = this.parseExpr(precTilde + 1); err == nil {
+			x = &UnaryExpr{ t, y }
+		}
+
+	case OP_QUESTION :
+		this.skipNewlines()
+		if y, err = this.parseExpr(precQuestion + 1); err == nil {
+			x = &UnaryExpr{ t, y }
+		}
+
+	case OP_LEFT_ROUND :
+		p := &ParenExpr{ Lparen: t }
+		this.push(OP_LEFT_ROUND)
+		p.X, err = this.parseExprOrAssign(precLowest)
+		if err == nil {
+			p.Rparen, err = this.expect(OP_RIGHT_ROUND)
+		}
+		this.pop()
+		x = p
+
+	case OP_LEFT_CURLY :
+		x, err = this.parseBlock(t)
+
+	case KEYWORD_FUNCTION :
+		x, err = this.parseFunction(t)
+
+	case KEYWORD_IF :
+		x, err = this.parseIf(t)
+
+	case KEYWORD_FOR :
+		x, err = this.parseFor(t)
+
+	case KEYWORD_WHILE :
+		w := &WhileExpr{ While: t }
+		if w.Cond, err = this.parseCondition(); err == nil {
+			w.Body, err = this.parseBody()
+		}
+		x = w
+
+	case KEYWORD_REPEAT :
+		r := &RepeatExpr{ Repeat: t }
+		r.Body, err = this.parseBody()
+		x = r
+
+	case KEYWORD_NEXT :
+		x = &NextExpr{ t }
+
+	case KEYWORD_BREAK :
+		x = &BreakExpr{ t }
+
+	default:
+		err = this.unexpected(t)
+	}
+	return
+}
+
+// parseBody parses the body of a function, a loop or a branch of an if (expr_or_assign %prec LOW).
+func (this *Parser) parseBody() (Expr, error) {
+	this.skipNewlines()
+	return this.parseExprOrAssign(precLow)
+}
+
+// parseCondition parses '(' expr ')' after if and while.
+func (this *Parser) parseCondition() (x Expr, err error) {
+	if _, err = this.expect(OP_LEFT_ROUND); err != nil {
+		return
+	}
+	this.push(OP_LEFT_ROUND)
+	defer this.pop()
+	if x, err = this.parseExpr(precLowest); err != nil {
+		return
+	}
+	_, err = this.expect(OP_RIGHT_ROUND)
+	return
+}
+
+// parseBlock parses the list of expressions of a '{' ... '}' block.
+func (this *Parser) parseBlock(lbrace *Token) (b *BlockExpr, err error) {
+	var x Expr
+
+	b = &BlockExpr{ Lbrace: lbrace }
+	this.push(OP_LEFT_CURLY)
+	defer this.pop()
+	for {
+		// Skip the empty statements
+		t := this.peek()
+		for t.Type == END_OF_LINE || t.Type == OP_SEMICOLON {
+			this.next()
+			t = this.peek()
+		}
+		if t.Type == OP_RIGHT_CURLY {
+			b.Rbrace = this.next()
+			return
+		}
+		if x, err = this.parseExprOrAssign(precLowest); err != nil {
+			return
+		}
+		b.List = append(b.List, x)
+
+		// An expression must be followed by a newline, a ';' or the closing '}'
+		t = this.peek()
+		if t.Type != END_OF_LINE && t.Type != OP_SEMICOLON && t.Type != OP_RIGHT_CURLY {
+			err = this.unexpected(t)
+			return
+		}
+	}
+}
+
+// parseFunction parses the formals and the body of a function definition.
+func (this *Parser) parseFunction(function *Token) (f *FunctionExpr, err error) {
+	f = &FunctionExpr{ Function: function }
+	if _, err = this.expect(OP_LEFT_ROUND); err != nil {
+		return
+	}
+	this.push(OP_LEFT_ROUND)
+	if this.peek().Type != OP_RIGHT_ROUND {
+		for {
+			var name *Token
+			if name, err = this.expectSymbol(); err != nil {
+				this.pop()
+				return
+			}
+			formal := &Formal{ Name: name }
+			if this.peek().Type == OP_EQUAL_ASSIGN {
+				this.next()
+				if formal.Default, err = this.parseExpr(precLowest); err != nil {
+					this.pop()
+					return
+				}
+			}
+			f.Formals = append(f.Formals, formal)
+			if this.peek().Type != OP_COMMA {
+				break
+			}
+			this.next()
+		}
+	}
+	_, err = this.expect(OP_RIGHT_ROUND)
+	this.pop()
+	if err != nil {
+		return
+	}
+	f.Body, err = this.parseBody()
+	return
+}
+
+// parseIf parses the condition and the branches of an if.
+func (this *Parser) parseIf(ift *Token) (i *IfExpr, err error) {
+	i = &IfExpr{ If: ift }
+	if i.Cond, err = this.parseCondition(); err != nil {
+		return
+	}
+	if i.Then, err = this.parseBody(); err != nil {
+		return
+	}
+
+	// Inside braces, the else keyword can be on one of the following lines
+	k := 0
+	n := len(this.contexts)
+	if n > 0 && this.contexts[n-1] == OP_LEFT_CURLY {
+		for this.peekAt(k).Type == END_OF_LINE {
+			k++
+		}
+	}
+	if this.peekAt(k).Type == KEYWORD_ELSE {
+		for ; k >= 0; k-- {
+			this.next()
+		}
+		i.Else, err = this.parseBody()
+	}
+	return
+}
+
+// parseFor parses '(' SYMBOL in expr ')' and the body of a for loop.
+func (this *Parser) parseFor(fort *Token) (f *ForExpr, err error) {
+	f = &ForExpr{ For: fort }
+	if _, err = this.expect(OP_LEFT_ROUND); err != nil {
+		return
+	}
+	this.push(OP_LEFT_ROUND)
+	if f.Var, err = this.expectSymbol(); err == nil {
+		if _, err = this.expect(KEYWORD_IN); err == nil {
+			if f.Seq, err = this.parseExpr(precLowest); err == nil {
+				_, err = this.expect(OP_RIGHT_ROUND)
+			}
+		}
+	}
+	this.pop()
+	if err != nil {
+		return
+	}
+	f.Body, err = this.parseBody()
+	return
+}
+
+// parseArgs parses the arguments of a call or of an index until the closing token (sublist rule).
+func (this *Parser) parseArgs(closing TokenType) (args []*Arg, err error) {
+	// f() has no argument
+	if this.peek().Type == closing {
+		return
+	}
+	for {
+		a := new(Arg)
+		t := this.peek()
+		switch {
+		case t.Type == OP_COMMA || t.Type == closing :
+			// empty argument
+
+		case (isSymbol(t) || t.Type == CONST_CHARACTER || t.Type == CONST_NULL) && this.peekAt(1).Type == OP_EQUAL_ASSIGN :
+			// named argument
+			a.Name = this.next()
+			this.next()
+			if n := this.peek(); n.Type != OP_COMMA && n.Type != closing {
+				if a.Value, err = this.parseExpr(precLowest); err != nil {
+					return
+				}
+			}
+
+		default:
+			if a.Value, err = this.parseExpr(precLowest); err != nil {
+				return
+			}
+		}
+		args = append(args, a)
+
+		t = this.peek()
+		if t.Type == closing {
+			return
+		}
+		if t.Type != OP_COMMA {
+			err = this.unexpected(t)
+			return
+		}
+		this.next()
+	}
+}
+
+// parseCall parses the arguments of the call of fun.
+func (this *Parser) parseCall(fun Expr, lparen *Token) (c *CallExpr, err error) {
+	c = &CallExpr{ Fun: fun, Lparen: lparen }
+	this.push(OP_LEFT_ROUND)
+	defer this.pop()
+	if c.Args, err = this.parseArgs(OP_RIGHT_ROUND); err != nil {
+		return
+	}
+	c.Rparen, err = this.expect(OP_RIGHT_ROUND)
+	return
+}
+
+// parseIndex parses the arguments of x[...] or x[[...]].
+func (this *Parser) parseIndex(x Expr, lbrack *Token) (i *IndexExpr, err error) {
+	i = &IndexExpr{ X: x, Lbrack: lbrack }
+	this.push(OP_LEFT_SQUARE)
+	defer this.pop()
+	if i.Args, err = this.parseArgs(OP_RIGHT_SQUARE); err != nil {
+		return
+	}
+	if i.Rbrack, err = this.expect(OP_RIGHT_SQUARE); err != nil {
+		return
+	}
+	if lbrack.Type == OP_LEFT_SQUARE2 {
+		i.Rbrack, err = this.expect(OP_RIGHT_SQUARE)
+	}
+	return
+}
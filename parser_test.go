@@ -0,0 +1,195 @@
+package r
+
+import "bytes"
+import "testing"
+import "strings"
+
+// lisp returns a compact prefix notation of the expression to check the shape of the tree.
+func lisp(x Expr) string {
+	args := func(args []*Arg) (s string) {
+		for _, a := range args {
+			s += " "
+			if a.Name != nil {
+				s += a.Name.stringvalue + "="
+			}
+			if a.Value != nil {
+				s += lisp(a.Value)
+			} else {
+				s += "_"
+			}
+		}
+		return
+	}
+
+	switch n := x.(type) {
+	case *Constant : return n.Token.stringvalue
+	case *Ident : return n.Name()
+	case *UnaryExpr : return "(" + n.Op.stringvalue + " " + lisp(n.X) + ")"
+	case *BinaryExpr : return "(" + n.Op.stringvalue + " " + lisp(n.X) + " " + lisp(n.Y) + ")"
+	case *ParenExpr : return "(( " + lisp(n.X) + ")"
+	case *BlockExpr :
+		s := "({"
+		for _, y := range n.List {
+			s += " " + lisp(y)
+		}
+		return s + ")"
+	case *CallExpr : return "(call " + lisp(n.Fun) + args(n.Args) + ")"
+	case *IndexExpr : return "(" + n.Lbrack.stringvalue + " " + lisp(n.X) + args(n.Args) + ")"
+	case *FunctionExpr :
+		s := "(function ("
+		for i, f := range n.Formals {
+			if i > 0 {
+				s += " "
+			}
+			s += f.Name.stringvalue
+			if f.Default != nil {
+				s += "=" + lisp(f.Default)
+			}
+		}
+		return s + ") " + lisp(n.Body) + ")"
+	case *IfExpr :
+		if n.Else != nil {
+			return "(if " + lisp(n.Cond) + " " + lisp(n.Then) + " " + lisp(n.Else) + ")"
+		}
+		return "(if " + lisp(n.Cond) + " " + lisp(n.Then) + ")"
+	case *ForExpr : return "(for " + n.Var.stringvalue + " " + lisp(n.Seq) + " " + lisp(n.Body) + ")"
+	case *WhileExpr : return "(while " + lisp(n.Cond) + " " + lisp(n.Body) + ")"
+	case *RepeatExpr : return "(repeat " + lisp(n.Body) + ")"
+	case *NextExpr : return "next"
+	case *BreakExpr : return "break"
+	}
+	return "?"
+}
+
+func TestParser(e *testing.T) {
+	var tests = []struct{ src, tree string }{
+		{ "1 + 2 * 3", "(+ 1 (* 2 3))" },
+		{ "1 - 2 - 3", "(- (- 1 2) 3)" },
+		{ "2 ^ 3 ^ 4", "(^ 2 (^ 3 4))" },
+		{ "-2^2", "(- (^ 2 2))" },
+		{ "-1:2", "(: (- 1) 2)" },
+		{ "a^-b + c", "(+ (^ a (- b)) c)" },
+		{ "!a == b && c", "(&& (! (== a b)) c)" },
+		{ "x <- y <- 1", "(<- x (<- y 1))" },
+		{ "1 -> x", "(-> 1 x)" },
+		{ "x = y <- 2", "(= x (<- y 2))" },
+		{ "x <- y = 2", "(= (<- x y) 2)" },
+		{ "y ~ a + b", "(~ y (+ a b))" },
+		{ "~ a | b", "(~ (| a b))" },
+		{ "a %in% b:c", "(%in% a (: b c))" },
+		{ "x$a$b@c", "(@ ($ ($ x a) b) c)" },
+		{ "-x$'a'", "(- ($ x a))" },
+		{ "stats::sd(x)", "(call (:: stats sd) x)" },
+		{ "'pkg':::`f`", "(::: pkg f)" },
+		{ "f(a, b = 2, , 'c' = , NULL = 3)", "(call f a b=2 _ c=_ NULL=3)" },
+		{ "f()", "(call f)" },
+		{ "f(x)(y)", "(call (call f x) y)" },
+		{ "x[1, ]", "([ x 1 _)" },
+		{ "x[[i]][j]", "([ ([[ x i) j)" },
+		{ "x[a[1]]", "([ x ([ a 1))" },
+		{ "function(x, y = 2, ...) x + y", "(function (x y=2 ...) (+ x y))" },
+		{ "function(x) y = x", "(function (x) (= y x))" },
+		{ "function(x) x ? y", "(? (function (x) x) y)" },
+		{ "if (a) b else c + 1", "(if a b (+ c 1))" },
+		{ "y <- if (a) b", "(<- y (if a b))" },
+		{ "for (i in 1:10) print(i)", "(for i (: 1 10) (call print i))" },
+		{ "while (TRUE) break", "(while TRUE break)" },
+		{ "repeat { next }", "(repeat ({ next))" },
+		{ "{ a; b\n c }", "({ a b c)" },
+		{ "{\n if (a) b\n else c\n}", "({ (if a b c))" },
+		{ "(a = 1)", "(( (= a 1))" },
+		{ "f(a,\n b\n)", "(call f a b)" },
+		{ "x <-\n 1", "(<- x 1)" },
+		{ "function(x)\n{\n x\n}", "(function (x) ({ x))" },
+		{ "-a ~ b", "(~ (- a) b)" },
+		{ "?help", "(? help)" },
+		{ "a ** 2", "(** a 2)" },
+	}
+
+	for i, test := range tests {
+		x, err := ParseExpr(test.src)
+		if err != nil {
+			e.Error("Test Parser[", i, "]", test.src, "Failed", err)
+			continue
+		}
+		if s := lisp(x); s != test.tree {
+			e.Error("Test Parser[", i, "]", test.src, "Failed", s)
+		}
+	}
+}
+
+func TestParserErrors(e *testing.T) {
+	var tests = []struct{ src, msg string }{
+		{ "a < b < c", "1:7: unexpected '<'" },
+		{ "if (a) b\nelse c", "2:1: unexpected 'else'" },
+		{ "f(x", "1:4: unexpected end of input" },
+		{ "x y", "1:3: unexpected symbol" },
+		{ "f(a = b = 1)", "1:9: unexpected '='" },
+		{ "x$1", "1:3: unexpected numeric constant" },
+		{ "f(x)::g", "1:5: unexpected '::'" },
+		{ "{ 1 2 }", "1:5: unexpected numeric constant" },
+	}
+
+	for i, test := range tests {
+		_, err := ParseFile(strings.NewReader(test.src))
+		if err == nil || err.Error() != test.msg {
+			e.Error("Test Parser Errors[", i, "]", test.src, "Failed", err)
+		}
+	}
+}
+
+func TestParseFile(e *testing.T) {
+	var src = "# header\nx <- 1; y <- 2\n\nf <- function(a) {\n  a + x # add\n}\n"
+
+	f, err := ParseFile(strings.NewReader(src))
+	if err != nil { e.Fatal("Test ParseFile Failed", err) }
+	if len(f.Exprs) != 3 { e.Error("Test ParseFile Exprs Failed", len(f.Exprs)) }
+	if len(f.Comments) != 2 || f.Comments[1].stringvalue != "# add" { e.Error("Test ParseFile Comments Failed", f.Comments) }
+
+	fn := f.Exprs[2].(*BinaryExpr).Y.(*FunctionExpr)
+	if fn.Pos() != (Position{ 30, 4, 6 }) { e.Error("Test ParseFile Pos Failed", fn.Pos()) }
+	if fn.End() != len(src) - 1 { e.Error("Test ParseFile End Failed", fn.End()) }
+
+	var calls []string
+	Inspect(f.Exprs[2], func(n Node) bool {
+		if b, ok := n.(*BinaryExpr); ok {
+			calls = append(calls, b.Op.stringvalue)
+		}
+		return true
+	})
+	if strings.Join(calls, " ") != "<- +" { e.Error("Test ParseFile Inspect Failed", calls) }
+}
+
+func FuzzParseFile(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(e *testing.T, src []byte) {
+		file, err := ParseFile(bytes.NewReader(src))
+		if err != nil {
+			if p, ok := err.(*ParseError); !ok || p.Pos.Offset < 0 || p.Pos.Offset > len(src) {
+				e.Fatalf("error %v for %q", err, src)
+			}
+			return
+		}
+		for _, x := range file.Exprs {
+			// the nodes are inside the source and their children inside them
+			Inspect(x, func(n Node) bool {
+				if n.Pos().Offset < 0 || n.Pos().Offset > n.End() || n.End() > len(src) {
+					e.Fatalf("%T at %d-%d in %q", n, n.Pos().Offset, n.End(), src)
+				}
+				Inspect(n, func(c Node) bool {
+					if c == n {
+						return true
+					}
+					if c.Pos().Offset < n.Pos().Offset || c.End() > n.End() {
+						e.Fatalf("%T at %d-%d outside of %T at %d-%d in %q", c, c.Pos().Offset, c.End(), n, n.Pos().Offset, n.End(), src)
+					}
+					return false
+				})
+				return true
+			})
+			if _, err := Sexp(x); err != nil {
+				e.Fatalf("Sexp %v for %q", err, src)
+			}
+		}
+	})
+}
@@ -0,0 +1,929 @@
+package r
+
+import "io"
+import "io/ioutil"
+import "fmt"
+import "html"
+import "strings"
+
+// Tags of the nodes of an Rd document, they match the Rd_tag attributes of tools::parse_Rd().
+const (
+	RD_TEXT    = "TEXT"    // LaTeX-like text
+	RD_RCODE   = "RCODE"   // R-like text of \usage, \examples, \code...
+	RD_VERB    = "VERB"    // Verbatim text of \alias, \preformatted, \dontrun...
+	RD_COMMENT = "COMMENT" // % comment
+	RD_LIST    = "LIST"    // {} group of LaTeX-like text
+)
+
+// The three modes of the content of an Rd macro argument.
+const (
+	rdLatex = iota
+	rdRLike
+	rdVerbatim
+)
+
+// rdMacro describes the arguments of an Rd macro.
+type rdMacro struct {
+	// Takes an optional [option] before the arguments
+	option bool
+	// Mode of each argument, the number of arguments is len(args)
+	args []int
+	// The last arguments are optional (\item, \eqn...)
+	optional int
+}
+
+var rdMacros = map[string]rdMacro{
+	// Sections with LaTeX-like text
+	"title": { false, []int{ rdLatex }, 0 },
+	"description": { false, []int{ rdLatex }, 0 },
+	"details": { false, []int{ rdLatex }, 0 },
+	"value": { false, []int{ rdLatex }, 0 },
+	"format": { false, []int{ rdLatex }, 0 },
+	"note": { false, []int{ rdLatex }, 0 },
+	"author": { false, []int{ rdLatex }, 0 },
+	"references": { false, []int{ rdLatex }, 0 },
+	"seealso": { false, []int{ rdLatex }, 0 },
+	"source": { false, []int{ rdLatex }, 0 },
+	"concept": { false, []int{ rdLatex }, 0 },
+	"keyword": { false, []int{ rdLatex }, 0 },
+	"docType": { false, []int{ rdLatex }, 0 },
+	"arguments": { false, []int{ rdLatex }, 0 },
+	"section": { false, []int{ rdLatex, rdLatex }, 0 },
+	"subsection": { false, []int{ rdLatex, rdLatex }, 0 },
+	// Sections with R-like text
+	"usage": { false, []int{ rdRLike }, 0 },
+	"examples": { false, []int{ rdRLike }, 0 },
+	"synopsis": { false, []int{ rdRLike }, 0 },
+	// Sections with verbatim text
+	"name": { false, []int{ rdVerbatim }, 0 },
+	"alias": { false, []int{ rdVerbatim }, 0 },
+	"encoding": { false, []int{ rdVerbatim }, 0 },
+	"Rdversion": { false, []int{ rdVerbatim }, 0 },
+	// Macros without argument
+	"cr": { false, nil, 0 },
+	"dots": { false, nil, 0 },
+	"ldots": { false, nil, 0 },
+	"R": { false, nil, 0 },
+	"tab": { false, nil, 0 },
+	// Macros with LaTeX-like arguments
+	"emph": { false, []int{ rdLatex }, 0 },
+	"bold": { false, []int{ rdLatex }, 0 },
+	"strong": { false, []int{ rdLatex }, 0 },
+	"acronym": { false, []int{ rdLatex }, 0 },
+	"abbr": { false, []int{ rdLatex }, 0 },
+	"cite": { false, []int{ rdLatex }, 0 },
+	"dfn": { false, []int{ rdLatex }, 0 },
+	"dQuote": { false, []int{ rdLatex }, 0 },
+	"sQuote": { false, []int{ rdLatex }, 0 },
+	"email": { false, []int{ rdLatex }, 0 },
+	"file": { false, []int{ rdLatex }, 0 },
+	"pkg": { false, []int{ rdLatex }, 0 },
+	"var": { false, []int{ rdLatex }, 0 },
+	"linkS4class": { false, []int{ rdLatex }, 0 },
+	"itemize": { false, []int{ rdLatex }, 0 },
+	"enumerate": { false, []int{ rdLatex }, 0 },
+	"describe": { false, []int{ rdLatex }, 0 },
+	"item": { false, []int{ rdLatex, rdLatex }, 2 },
+	"link": { true, []int{ rdLatex }, 0 },
+	"method": { false, []int{ rdLatex, rdLatex }, 0 },
+	"S3method": { false, []int{ rdLatex, rdLatex }, 0 },
+	"S4method": { false, []int{ rdLatex, rdLatex }, 0 },
+	// Macros with R-like arguments
+	"code": { false, []int{ rdRLike }, 0 },
+	"Sexpr": { true, []int{ rdRLike }, 0 },
+	"donttest": { false, []int{ rdRLike }, 0 },
+	"dontshow": { false, []int{ rdRLike }, 0 },
+	"testonly": { false, []int{ rdRLike }, 0 },
+	"dontdiff": { false, []int{ rdRLike }, 0 },
+	// Macros with verbatim arguments
+	"dontrun": { false, []int{ rdVerbatim }, 0 },
+	"preformatted": { false, []int{ rdVerbatim }, 0 },
+	"verb": { false, []int{ rdVerbatim }, 0 },
+	"url": { false, []int{ rdVerbatim }, 0 },
+	"kbd": { false, []int{ rdVerbatim }, 0 },
+	"env": { false, []int{ rdVerbatim }, 0 },
+	"option": { false, []int{ rdVerbatim }, 0 },
+	"command": { false, []int{ rdVerbatim }, 0 },
+	"samp": { false, []int{ rdVerbatim }, 0 },
+	"out": { false, []int{ rdVerbatim }, 0 },
+	"special": { false, []int{ rdVerbatim }, 0 },
+	"eqn": { false, []int{ rdVerbatim, rdVerbatim }, 1 },
+	"deqn": { false, []int{ rdVerbatim, rdVerbatim }, 1 },
+	"enc": { false, []int{ rdVerbatim, rdVerbatim }, 0 },
+	"figure": { false, []int{ rdVerbatim, rdVerbatim }, 1 },
+	"newcommand": { false, []int{ rdVerbatim, rdVerbatim }, 0 },
+	"renewcommand": { false, []int{ rdVerbatim, rdVerbatim }, 0 },
+	// Macros with mixed arguments
+	"href": { false, []int{ rdVerbatim, rdLatex }, 0 },
+	"tabular": { false, []int{ rdVerbatim, rdLatex }, 0 },
+	"if": { false, []int{ rdVerbatim, rdLatex }, 0 },
+	"ifelse": { false, []int{ rdVerbatim, rdLatex, rdLatex }, 0 },
+}
+
+// RdNode is a node of the tree of an Rd document: a text, a comment or a macro with its arguments.
+type RdNode struct {
+	// RD_TEXT, RD_RCODE, RD_VERB, RD_COMMENT, RD_LIST or the name of the macro with its backslash: \name \item \link
+	Tag string
+	// Text of TEXT, RCODE, VERB and COMMENT nodes
+	Text string
+	// Content of the optional [option] of a macro
+	Option []*RdNode
+	// Content of the {arguments} of a macro
+	Args [][]*RdNode
+	// Position of the first character of the node
+	Pos Position
+}
+
+// RdDocument is a parsed Rd file.
+type RdDocument struct {
+	Nodes []*RdNode
+}
+
+// RdArgument is an \item{name}{description} of the \arguments section.
+type RdArgument struct {
+	Name        string
+	Description []*RdNode
+}
+
+// rdParser reads the characters of an Rd file.
+type rdParser struct {
+	src    []rune
+	i      int
+	nline  int
+	ncol   int
+	offset int
+}
+
+// ParseRd parses an Rd documentation file.
+func ParseRd(r io.Reader) (d *RdDocument, err error) {
+	var b []byte
+
+	if b, err = ioutil.ReadAll(r); err != nil {
+		return
+	}
+	p := &rdParser{ src: []rune(string(b)), nline: 1, ncol: 1 }
+	d = new(RdDocument)
+	if d.Nodes, err = p.parseContent(rdLatex, false); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (this *rdParser) pos() Position {
+	return Position{ this.offset, this.nline, this.ncol }
+}
+
+func (this *rdParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Rd %d:%d: %s", this.nline, this.ncol, fmt.Sprintf(format, args...))
+}
+
+func (this *rdParser) eof() bool {
+	return this.i >= len(this.src)
+}
+
+func (this *rdParser) peek(k int) rune {
+	if this.i+k >= len(this.src) {
+		return endOfInput
+	}
+	return this.src[this.i+k]
+}
+
+func (this *rdParser) advance() (r rune) {
+	r = this.src[this.i]
+	this.i++
+	this.offset += len(string(r))
+	if r == '\n' {
+		this.nline++
+		this.ncol = 1
+	} else {
+		this.ncol++
+	}
+	return
+}
+
+func isRdMacroLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// parseContent parses the nodes until the end of input or, if braced, until the closing brace (which is consumed).
+func (this *rdParser) parseContent(mode int, braced bool) (nodes []*RdNode, err error) {
+	var text []rune
+	var quote rune
+	var start Position
+	var depth int
+
+	tag := RD_TEXT
+	if mode == rdRLike {
+		tag = RD_RCODE
+	} else if mode == rdVerbatim {
+		tag = RD_VERB
+	}
+	flush := func() {
+		if len(text) > 0 {
+			nodes = append(nodes, &RdNode{ Tag: tag, Text: string(text), Pos: start })
+			text = nil
+		}
+	}
+	add := func(r rune, p Position) {
+		if len(text) == 0 {
+			start = p
+		}
+		text = append(text, r)
+	}
+
+	for !this.eof() {
+		p := this.pos()
+		r := this.peek(0)
+
+		// Quoted strings of R code do not count the braces, but % must still be escaped
+		if mode == rdRLike && quote != 0 && r != '%' {
+			this.advance()
+			if r == '\\' && !this.eof() {
+				n := this.advance()
+				if n == '%' {
+					add('%', p)
+				} else {
+					add(r, p)
+					add(n, p)
+				}
+				continue
+			}
+			if r == quote {
+				quote = 0
+			}
+			add(r, p)
+			continue
+		}
+
+		switch {
+		case r == '%' :
+			// Comment until the end of line
+			flush()
+			var c []rune
+			for !this.eof() && this.peek(0) != '\n' {
+				c = append(c, this.advance())
+			}
+			nodes = append(nodes, &RdNode{ Tag: RD_COMMENT, Text: string(c), Pos: p })
+
+		case r == '\\' && (this.peek(1) == '\\' || this.peek(1) == '%' || this.peek(1) == '{' || this.peek(1) == '}') :
+			// Escaped character
+			this.advance()
+			add(this.advance(), p)
+
+		case r == '\\' && isRdMacroLetter(this.peek(1)) && mode != rdVerbatim :
+			flush()
+			var m *RdNode
+			if m, err = this.parseMacro(mode); err != nil {
+				return
+			}
+			nodes = append(nodes, m)
+
+		case r == '{' :
+			this.advance()
+			if mode == rdLatex {
+				// Group of LaTeX-like text
+				flush()
+				var g []*RdNode
+				if g, err = this.parseContent(mode, true); err != nil {
+					return
+				}
+				nodes = append(nodes, &RdNode{ Tag: RD_LIST, Args: [][]*RdNode{ g }, Pos: p })
+			} else {
+				depth++
+				add(r, p)
+			}
+
+		case r == '}' :
+			if depth == 0 {
+				if !braced {
+					return nil, this.errorf("unexpected '}'")
+				}
+				this.advance()
+				flush()
+				return
+			}
+			depth--
+			this.advance()
+			add(r, p)
+
+		case (r == '"' || r == '\'') && mode == rdRLike :
+			quote = r
+			this.advance()
+			add(r, p)
+
+		default:
+			this.advance()
+			add(r, p)
+		}
+	}
+	if braced {
+		return nil, this.errorf("unexpected end of input, missing '}'")
+	}
+	flush()
+	return
+}
+
+// parseMacro parses a \macro with its optional [option] and its {arguments}.
+func (this *rdParser) parseMacro(mode int) (m *RdNode, err error) {
+	m = &RdNode{ Pos: this.pos() }
+	this.advance()
+	var name []rune
+	for !this.eof() && isRdMacroLetter(this.peek(0)) {
+		name = append(name, this.advance())
+	}
+	m.Tag = "\\" + string(name)
+
+	desc, known := rdMacros[string(name)]
+	if !known {
+		// Unknown (user) macro: take all the following arguments in the current mode
+		for this.peek(0) == '{' {
+			this.advance()
+			var a []*RdNode
+			if a, err = this.parseContent(mode, true); err != nil {
+				return
+			}
+			m.Args = append(m.Args, a)
+		}
+		return
+	}
+
+	if desc.option && this.peek(0) == '[' {
+		this.advance()
+		var o []rune
+		p := this.pos()
+		for !this.eof() && this.peek(0) != ']' {
+			o = append(o, this.advance())
+		}
+		if this.eof() {
+			return nil, this.errorf("unexpected end of input, missing ']'")
+		}
+		this.advance()
+		m.Option = []*RdNode{ &RdNode{ Tag: RD_TEXT, Text: string(o), Pos: p } }
+	}
+
+	for i, amode := range desc.args {
+		// Whitespace is allowed between the arguments of a section
+		if i > 0 {
+			for j := 0; this.peek(j) == ' ' || this.peek(j) == '\t' || this.peek(j) == '\n'; j++ {
+				if this.peek(j+1) == '{' {
+					for ; j >= 0; j-- {
+						this.advance()
+					}
+					break
+				}
+			}
+		}
+		if this.peek(0) != '{' {
+			if i >= len(desc.args) - desc.optional {
+				break
+			}
+			return nil, this.errorf("missing argument of %s", m.Tag)
+		}
+		this.advance()
+		var a []*RdNode
+		if a, err = this.parseContent(amode, true); err != nil {
+			return
+		}
+		m.Args = append(m.Args, a)
+	}
+
+	// \dots{} and \R{} are often written with empty braces
+	if len(desc.args) == 0 && this.peek(0) == '{' && this.peek(1) == '}' {
+		this.advance()
+		this.advance()
+	}
+	return
+}
+
+// Sections returns the top level macros with the given tag (\alias, \section...).
+func (this *RdDocument) Sections(tag string) (sections []*RdNode) {
+	for _, n := range this.Nodes {
+		if n.Tag == tag {
+			sections = append(sections, n)
+		}
+	}
+	return
+}
+
+// Section returns the first top level macro with the given tag, or nil.
+func (this *RdDocument) Section(tag string) *RdNode {
+	if s := this.Sections(tag); len(s) > 0 {
+		return s[0]
+	}
+	return nil
+}
+
+// sectionText returns the plain text of the first argument of a section.
+func (this *RdDocument) sectionText(tag string) string {
+	if s := this.Section(tag); s != nil && len(s.Args) > 0 {
+		return strings.TrimSpace(RdText(s.Args[0]))
+	}
+	return ""
+}
+
+// Name returns the content of \name.
+func (this *RdDocument) Name() string {
+	return this.sectionText("\\name")
+}
+
+// Title returns the content of \title.
+func (this *RdDocument) Title() string {
+	return this.sectionText("\\title")
+}
+
+// Aliases returns the content of all the \alias.
+func (this *RdDocument) Aliases() (aliases []string) {
+	for _, s := range this.Sections("\\alias") {
+		if len(s.Args) > 0 {
+			aliases = append(aliases, strings.TrimSpace(RdText(s.Args[0])))
+		}
+	}
+	return
+}
+
+// Arguments returns the \item{name}{description} of the \arguments section.
+func (this *RdDocument) Arguments() (args []RdArgument) {
+	s := this.Section("\\arguments")
+	if s == nil || len(s.Args) == 0 {
+		return
+	}
+	for _, n := range s.Args[0] {
+		if n.Tag == "\\item" && len(n.Args) == 2 {
+			args = append(args, RdArgument{ strings.TrimSpace(RdText(n.Args[0])), n.Args[1] })
+		}
+	}
+	return
+}
+
+// RdText returns the plain text of a list of nodes, without comments and macros markup.
+func RdText(nodes []*RdNode) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.Tag {
+		case RD_TEXT, RD_RCODE, RD_VERB :
+			b.WriteString(n.Text)
+		case RD_COMMENT :
+		case "\\dots", "\\ldots" :
+			b.WriteString("...")
+		case "\\R" :
+			b.WriteString("R")
+		case "\\cr" :
+			b.WriteString("\n")
+		case "\\tab" :
+			b.WriteString("\t")
+		case "\\sQuote" :
+			b.WriteString("‘" + rdArgText(n, 0) + "’")
+		case "\\dQuote" :
+			b.WriteString("“" + rdArgText(n, 0) + "”")
+		case "\\href" :
+			b.WriteString(rdArgText(n, 1))
+		case "\\method", "\\S3method", "\\S4method" :
+			b.WriteString(rdArgText(n, 0))
+		case "\\item" :
+			for _, a := range n.Args {
+				b.WriteString(RdText(a))
+			}
+		default:
+			if len(n.Args) > 0 {
+				b.WriteString(RdText(n.Args[len(n.Args)-1]))
+			}
+		}
+	}
+	return b.String()
+}
+
+func rdArgText(n *RdNode, i int) string {
+	if i < len(n.Args) {
+		return RdText(n.Args[i])
+	}
+	return ""
+}
+
+// RCode returns the R code of an R-like section: the text of \usage or \examples, \dontrun blocks included.
+func (this *RdNode) RCode() string {
+	var b strings.Builder
+	for _, a := range this.Args {
+		b.WriteString(rdCode(a, false))
+	}
+	return b.String()
+}
+
+// rdCode returns the R code of nodes, \method{generic}{class} becomes generic and,
+// if commented is true, \dontrun blocks are commented and \dontshow blocks removed as in the output of example().
+func rdCode(nodes []*RdNode, commented bool) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.Tag {
+		case RD_TEXT, RD_RCODE, RD_VERB :
+			b.WriteString(n.Text)
+		case "\\dots", "\\ldots" :
+			b.WriteString("...")
+		case "\\R" :
+			b.WriteString("R")
+		case "\\method", "\\S3method", "\\S4method" :
+			b.WriteString(rdArgText(n, 0))
+		case "\\dontrun" :
+			if commented {
+				b.WriteString("## Not run: ")
+			}
+			b.WriteString(rdCode(n.Args[0], commented))
+			if commented {
+				b.WriteString("## End(Not run)")
+			}
+		case "\\dontshow", "\\testonly" :
+			if !commented {
+				b.WriteString(rdCode(n.Args[0], commented))
+			}
+		case RD_COMMENT :
+		default:
+			for _, a := range n.Args {
+				b.WriteString(rdCode(a, commented))
+			}
+		}
+	}
+	return b.String()
+}
+
+// Tokens returns the R tokens of an R-like section (see RCode), scanned by the R Scanner.
+func (this *RdNode) Tokens() (tokens []*Token) {
+	s := NewScanner(strings.NewReader(this.RCode()))
+	for {
+		t := s.NextToken()
+		if t.Type == END_OF_INPUT {
+			return
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// rdSectionOrder is the order of the sections in the rendered documents.
+var rdSectionOrder = []string{ "\\description", "\\usage", "\\arguments", "\\details", "\\value", "\\section", "\\note", "\\author", "\\references", "\\seealso", "\\examples" }
+
+var rdSectionTitles = map[string]string{
+	"\\description": "Description",
+	"\\usage": "Usage",
+	"\\arguments": "Arguments",
+	"\\details": "Details",
+	"\\value": "Value",
+	"\\note": "Note",
+	"\\author": "Author(s)",
+	"\\references": "References",
+	"\\seealso": "See Also",
+	"\\examples": "Examples",
+}
+
+// rdRenderer renders the Rd nodes in Markdown or HTML.
+type rdRenderer struct {
+	html bool
+}
+
+func (this *rdRenderer) escape(s string) string {
+	if this.html {
+		return html.EscapeString(s)
+	}
+	return s
+}
+
+// inline renders LaTeX-like nodes.
+func (this *rdRenderer) inline(nodes []*RdNode) string {
+	var b strings.Builder
+	md := !this.html
+	for _, n := range nodes {
+		switch n.Tag {
+		case RD_TEXT, RD_VERB :
+			b.WriteString(this.escape(n.Text))
+		case RD_RCODE :
+			b.WriteString(this.escape(n.Text))
+		case RD_COMMENT :
+		case RD_LIST :
+			b.WriteString(this.inline(n.Args[0]))
+		case "\\dots", "\\ldots" :
+			b.WriteString("...")
+		case "\\R" :
+			b.WriteString("R")
+		case "\\cr" :
+			if md {
+				b.WriteString("  \n")
+			} else {
+				b.WriteString("<br>\n")
+			}
+		case "\\code", "\\verb", "\\kbd", "\\samp", "\\env", "\\option", "\\command", "\\file", "\\eqn", "\\Sexpr" :
+			if len(n.Args[0]) == 1 && n.Args[0][0].Tag == "\\link" {
+				// \code{\link{topic}} is a link with a code text
+				b.WriteString(this.link(n.Args[0][0], true))
+			} else if md {
+				b.WriteString("`" + rdCode(n.Args[0], false) + "`")
+			} else {
+				b.WriteString("<code>" + html.EscapeString(rdCode(n.Args[0], false)) + "</code>")
+			}
+		case "\\emph", "\\var", "\\dfn", "\\cite" :
+			if md {
+				b.WriteString("*" + this.inline(n.Args[0]) + "*")
+			} else {
+				b.WriteString("<em>" + this.inline(n.Args[0]) + "</em>")
+			}
+		case "\\bold", "\\strong", "\\pkg" :
+			if md {
+				b.WriteString("**" + this.inline(n.Args[0]) + "**")
+			} else {
+				b.WriteString("<strong>" + this.inline(n.Args[0]) + "</strong>")
+			}
+		case "\\sQuote" :
+			b.WriteString("‘" + this.inline(n.Args[0]) + "’")
+		case "\\dQuote" :
+			b.WriteString("“" + this.inline(n.Args[0]) + "”")
+		case "\\link", "\\linkS4class" :
+			b.WriteString(this.link(n, false))
+		case "\\url" :
+			u := strings.TrimSpace(RdText(n.Args[0]))
+			if md {
+				b.WriteString("<" + u + ">")
+			} else {
+				b.WriteString("<a href=\"" + html.EscapeString(u) + "\">" + html.EscapeString(u) + "</a>")
+			}
+		case "\\href" :
+			u := strings.TrimSpace(RdText(n.Args[0]))
+			if md {
+				b.WriteString("[" + this.inline(n.Args[1]) + "](" + u + ")")
+			} else {
+				b.WriteString("<a href=\"" + html.EscapeString(u) + "\">" + this.inline(n.Args[1]) + "</a>")
+			}
+		case "\\email" :
+			u := strings.TrimSpace(RdText(n.Args[0]))
+			if md {
+				b.WriteString("<" + u + ">")
+			} else {
+				b.WriteString("<a href=\"mailto:" + html.EscapeString(u) + "\">" + html.EscapeString(u) + "</a>")
+			}
+		case "\\deqn", "\\preformatted" :
+			b.WriteString(this.codeBlock(RdText(n.Args[0]), ""))
+		case "\\itemize", "\\enumerate", "\\describe" :
+			b.WriteString(this.list(n))
+		case "\\tabular" :
+			b.WriteString(this.tabular(n))
+		case "\\if", "\\ifelse", "\\out", "\\newcommand", "\\renewcommand", "\\figure", "\\alias", "\\keyword", "\\concept" :
+			// Output format specific content and metadata are not rendered
+		default:
+			if len(n.Args) > 0 {
+				b.WriteString(this.inline(n.Args[len(n.Args)-1]))
+			}
+		}
+	}
+	return b.String()
+}
+
+// link renders \link{topic}, \link[pkg]{topic}, \link[pkg:topic]{text} and \link[=topic]{text}.
+func (this *rdRenderer) link(n *RdNode, code bool) string {
+	text := this.inline(n.Args[0])
+	if code && this.html {
+		text = "<code>" + text + "</code>"
+	} else if code {
+		text = "`" + text + "`"
+	}
+	topic := strings.TrimSpace(RdText(n.Args[0]))
+	if n.Option != nil {
+		o := RdText(n.Option)
+		if strings.HasPrefix(o, "=") {
+			topic = o[1:]
+		} else if i := strings.IndexByte(o, ':'); i >= 0 {
+			topic = o[:i] + "/" + o[i+1:]
+		} else {
+			topic = o + "/" + topic
+		}
+	}
+	if this.html {
+		return "<a href=\"" + html.EscapeString(topic) + ".html\">" + text + "</a>"
+	}
+	return "[" + text + "](" + topic + ".md)"
+}
+
+func (this *rdRenderer) codeBlock(code, lang string) string {
+	code = strings.Trim(code, "\n")
+	if this.html {
+		class := ""
+		if lang != "" {
+			class = " class=\"language-" + lang + "\""
+		}
+		return "\n<pre><code" + class + ">" + html.EscapeString(code) + "</code></pre>\n"
+	}
+	return "\n```" + lang + "\n" + code + "\n```\n"
+}
+
+// list renders \itemize, \enumerate and \describe.
+func (this *rdRenderer) list(n *RdNode) string {
+	var items []string
+	var current []*RdNode
+	var term string
+	started := false
+
+	flush := func() {
+		if started {
+			items = append(items, term + strings.TrimSpace(this.inline(current)))
+		}
+		current = nil
+		term = ""
+	}
+	for _, c := range n.Args[0] {
+		if c.Tag != "\\item" {
+			current = append(current, c)
+			continue
+		}
+		flush()
+		started = true
+		if len(c.Args) == 2 {
+			// \describe{\item{term}{description}}
+			if this.html {
+				term = "<strong>" + this.inline(c.Args[0]) + "</strong>: "
+			} else {
+				term = "**" + this.inline(c.Args[0]) + "**: "
+			}
+			current = c.Args[1]
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	if this.html {
+		tag := "ul"
+		if n.Tag == "\\enumerate" {
+			tag = "ol"
+		}
+		b.WriteString("\n<" + tag + ">\n")
+		for _, i := range items {
+			b.WriteString("<li>" + i + "</li>\n")
+		}
+		b.WriteString("</" + tag + ">\n")
+		return b.String()
+	}
+	b.WriteString("\n")
+	for k, i := range items {
+		if n.Tag == "\\enumerate" {
+			b.WriteString(fmt.Sprintf("%d. %s\n", k+1, i))
+		} else {
+			b.WriteString("- " + i + "\n")
+		}
+	}
+	return b.String()
+}
+
+// tabular renders \tabular{format}{cells} where the cells are separated by \tab and the rows by \cr.
+func (this *rdRenderer) tabular(n *RdNode) string {
+	var rows [][]string
+	var row []string
+	var cell []*RdNode
+
+	for _, c := range n.Args[1] {
+		switch c.Tag {
+		case "\\tab" :
+			row = append(row, strings.TrimSpace(this.inline(cell)))
+			cell = nil
+		case "\\cr" :
+			row = append(row, strings.TrimSpace(this.inline(cell)))
+			rows = append(rows, row)
+			row, cell = nil, nil
+		default:
+			cell = append(cell, c)
+		}
+	}
+	if s := strings.TrimSpace(this.inline(cell)); s != "" || len(row) > 0 {
+		rows = append(rows, append(row, s))
+	}
+
+	var b strings.Builder
+	if this.html {
+		b.WriteString("\n<table>\n")
+		for _, r := range rows {
+			b.WriteString("<tr><td>" + strings.Join(r, "</td><td>") + "</td></tr>\n")
+		}
+		b.WriteString("</table>\n")
+		return b.String()
+	}
+	b.WriteString("\n")
+	for i, r := range rows {
+		b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString(strings.Repeat("| --- ", len(r)) + "|\n")
+		}
+	}
+	return b.String()
+}
+
+func (this *rdRenderer) heading(level int, s string) string {
+	if this.html {
+		return fmt.Sprintf("<h%d>%s</h%d>\n", level, s, level)
+	}
+	return strings.Repeat("#", level) + " " + s + "\n"
+}
+
+func (this *rdRenderer) paragraph(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	if this.html {
+		var b strings.Builder
+		for _, p := range strings.Split(s, "\n\n") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			if strings.HasPrefix(p, "<") {
+				b.WriteString(p + "\n")
+			} else {
+				b.WriteString("<p>" + p + "</p>\n")
+			}
+		}
+		return b.String()
+	}
+	return s + "\n"
+}
+
+// section renders one top level section.
+func (this *rdRenderer) section(n *RdNode) string {
+	var b strings.Builder
+
+	switch n.Tag {
+	case "\\usage" :
+		b.WriteString(this.heading(2, rdSectionTitles[n.Tag]))
+		b.WriteString(this.codeBlock(rdUsage(n.Args[0]), "r"))
+	case "\\examples" :
+		b.WriteString(this.heading(2, rdSectionTitles[n.Tag]))
+		b.WriteString(this.codeBlock(rdCode(n.Args[0], true), "r"))
+	case "\\arguments" :
+		b.WriteString(this.heading(2, rdSectionTitles[n.Tag]))
+		if this.html {
+			b.WriteString("<dl>\n")
+		}
+		for _, c := range n.Args[0] {
+			if c.Tag != "\\item" || len(c.Args) != 2 {
+				continue
+			}
+			name := this.escape(strings.TrimSpace(RdText(c.Args[0])))
+			desc := strings.TrimSpace(this.inline(c.Args[1]))
+			if this.html {
+				b.WriteString("<dt><code>" + name + "</code></dt><dd>" + desc + "</dd>\n")
+			} else {
+				b.WriteString("- `" + name + "`: " + desc + "\n")
+			}
+		}
+		if this.html {
+			b.WriteString("</dl>\n")
+		}
+	case "\\section" :
+		b.WriteString(this.heading(2, strings.TrimSpace(this.inline(n.Args[0]))))
+		b.WriteString(this.paragraph(this.inline(n.Args[1])))
+	default:
+		b.WriteString(this.heading(2, rdSectionTitles[n.Tag]))
+		b.WriteString(this.paragraph(this.inline(n.Args[0])))
+	}
+	return b.String()
+}
+
+// rdUsage returns the R code of \usage with the S3 methods annotated as in R help pages.
+func rdUsage(nodes []*RdNode) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.Tag {
+		case "\\method", "\\S3method" :
+			b.WriteString("## S3 method for class '" + strings.TrimSpace(rdArgText(n, 1)) + "'\n")
+			b.WriteString(strings.TrimSpace(rdArgText(n, 0)))
+		case "\\S4method" :
+			b.WriteString("## S4 method for signature '" + strings.TrimSpace(rdArgText(n, 1)) + "'\n")
+			b.WriteString(strings.TrimSpace(rdArgText(n, 0)))
+		default:
+			b.WriteString(rdCode([]*RdNode{ n }, false))
+		}
+	}
+	return b.String()
+}
+
+func (this *rdRenderer) document(d *RdDocument) string {
+	var b strings.Builder
+
+	if this.html {
+		b.WriteString("<h1>" + html.EscapeString(d.Title()) + "</h1>\n")
+	} else {
+		b.WriteString("# " + d.Title() + "\n")
+	}
+	for _, tag := range rdSectionOrder {
+		for _, s := range d.Sections(tag) {
+			if len(s.Args) == 0 {
+				continue
+			}
+			b.WriteString("\n")
+			b.WriteString(this.section(s))
+		}
+	}
+	return b.String()
+}
+
+// Markdown renders the document as a Markdown help page.
+func (this *RdDocument) Markdown() string {
+	r := rdRenderer{ html: false }
+	return r.document(this)
+}
+
+// HTML renders the document as an HTML fragment.
+func (this *RdDocument) HTML() string {
+	r := rdRenderer{ html: true }
+	return r.document(this)
+}
@@ -0,0 +1,117 @@
+package r
+
+import "testing"
+import "strings"
+
+var rdSample = `% Generated by roxygen2: do not edit by hand
+\name{clamp}
+\alias{clamp}
+\alias{clamp.default}
+\title{Clamp values between bounds}
+\usage{
+clamp(x, lo = 0, hi = 1, ...)
+
+\method{clamp}{default}(x, lo = 0, hi = 1, ...)
+}
+\arguments{
+\item{x}{A numeric vector, see \code{\link[base]{pmin}}.}
+
+\item{lo, hi}{Lower and upper \emph{bounds}.}
+
+\item{\dots}{Unused.}
+}
+\value{
+The clamped vector, \strong{same length} as \code{x}.
+}
+\description{
+Clamp the values of \code{x} in \verb{[lo, hi]} (50\% of the time).
+}
+\section{Warning}{
+\itemize{
+\item NA values are kept.
+\item Use \code{"{"} with care.
+}
+}
+\examples{
+clamp(c(-1, 0.5, 2)) # a "\%" comment
+\dontrun{
+clamp(x, hi = "\}")
+}
+}
+\seealso{
+\link{pmax}, \link[=Comparison]{comparison operators}
+}
+`
+
+func TestParseRd(e *testing.T) {
+	d, err := ParseRd(strings.NewReader(rdSample))
+	if err != nil { e.Fatal("Test Rd Failed", err) }
+
+	if d.Name() != "clamp" { e.Error("Test Rd Name Failed", d.Name()) }
+	if d.Title() != "Clamp values between bounds" { e.Error("Test Rd Title Failed", d.Title()) }
+	if strings.Join(d.Aliases(), ",") != "clamp,clamp.default" { e.Error("Test Rd Aliases Failed", d.Aliases()) }
+	if d.Nodes[0].Tag != RD_COMMENT || d.Nodes[0].Text != "% Generated by roxygen2: do not edit by hand" { e.Error("Test Rd Comment Failed", d.Nodes[0]) }
+
+	args := d.Arguments()
+	if len(args) != 3 || args[0].Name != "x" || args[1].Name != "lo, hi" || args[2].Name != "..." { e.Fatal("Test Rd Arguments Failed", args) }
+	if RdText(args[1].Description) != "Lower and upper bounds." { e.Error("Test Rd Argument text Failed", RdText(args[1].Description)) }
+	if RdText(d.Section("\\description").Args[0]) != "\nClamp the values of x in [lo, hi] (50% of the time).\n" { e.Error("Test Rd escapes Failed", RdText(d.Section("\\description").Args[0])) }
+
+	link := args[0].Description[1].Args[0][0]
+	if link.Tag != "\\link" || RdText(link.Option) != "base" || RdText(link.Args[0]) != "pmin" { e.Error("Test Rd link Failed", link) }
+
+	// R-like sections keep the braces of the strings and are scanned by the R scanner
+	ex := d.Section("\\examples")
+	if ex.RCode() != "\nclamp(c(-1, 0.5, 2)) # a \"%\" comment\n\nclamp(x, hi = \"}\")\n\n" { e.Error("Test Rd RCode Failed", ex.RCode()) }
+	var types []string
+	for _, t := range d.Section("\\usage").Tokens() {
+		if t.Type != END_OF_LINE {
+			types = append(types, t.Value())
+		}
+	}
+	if strings.Join(types, " ") != "clamp ( x , lo = 0 , hi = 1 , ... ) clamp ( x , lo = 0 , hi = 1 , ... )" { e.Error("Test Rd Tokens Failed", types) }
+}
+
+func TestRdMarkdown(e *testing.T) {
+	d, err := ParseRd(strings.NewReader(rdSample))
+	if err != nil { e.Fatal("Test Rd Failed", err) }
+
+	md := d.Markdown()
+	for _, s := range []string{
+		"# Clamp values between bounds\n",
+		"## Usage\n\n```r\nclamp(x, lo = 0, hi = 1, ...)\n\n## S3 method for class 'default'\nclamp(x, lo = 0, hi = 1, ...)\n```\n",
+		"- `x`: A numeric vector, see [`pmin`](base/pmin.md).\n",
+		"- `lo, hi`: Lower and upper *bounds*.\n",
+		"The clamped vector, **same length** as `x`.\n",
+		"## Warning\n- NA values are kept.\n- Use `\"{\"` with care.\n",
+		"## Not run: \nclamp(x, hi = \"}\")\n## End(Not run)",
+		"[pmax](pmax.md), [comparison operators](Comparison.md)",
+	} {
+		if !strings.Contains(md, s) { e.Error("Test Rd Markdown Failed", s, "\n", md) }
+	}
+
+	h := d.HTML()
+	for _, s := range []string{
+		"<h1>Clamp values between bounds</h1>",
+		"<dt><code>x</code></dt><dd>A numeric vector, see <a href=\"base/pmin.html\"><code>pmin</code></a>.</dd>",
+		"<p>The clamped vector, <strong>same length</strong> as <code>x</code>.</p>",
+		"<li>Use <code>&#34;{&#34;</code> with care.</li>",
+		"<a href=\"Comparison.html\">comparison operators</a>",
+	} {
+		if !strings.Contains(h, s) { e.Error("Test Rd HTML Failed", s, "\n", h) }
+	}
+}
+
+func TestParseRdErrors(e *testing.T) {
+	var tests = []string{
+		"\\name{x",
+		"\\title{x}}",
+		"\\href{x}",
+		"\\link[x{y}",
+	}
+	for i, str := range tests {
+		if _, err := ParseRd(strings.NewReader(str)); err == nil {
+			e.Error("Test Rd Errors[", i, "] Failed")
+		}
+	}
+}
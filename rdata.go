@@ -0,0 +1,81 @@
+package r
+
+import "io"
+import "bufio"
+import "compress/gzip"
+import "errors"
+import "fmt"
+
+// Magic numbers of the save() formats, followed by the serialized pairlist of the objects.
+var rdataMagics = map[string]byte{
+	"RDX2\n": SERIALIZE_XDR, "RDX3\n": SERIALIZE_XDR,
+	"RDB2\n": SERIALIZE_BINARY, "RDB3\n": SERIALIZE_BINARY,
+	"RDA2\n": SERIALIZE_ASCII, "RDA3\n": SERIALIZE_ASCII,
+}
+
+// LoadRData reads the objects of a file written by save() or save.image(), compressed or not.
+func LoadRData(r io.Reader) (objects map[string]Value, err error) {
+	var list []Tagged
+
+	if list, err = LoadRDataList(r); err != nil {
+		return
+	}
+	objects = make(map[string]Value, len(list))
+	for _, t := range list {
+		objects[t.Tag] = t.Value
+	}
+	return
+}
+
+// LoadRDataList reads the objects of a file written by save() in the order they were saved.
+func LoadRDataList(r io.Reader) (objects []Tagged, err error) {
+	if r, err = Decompress(r); err != nil {
+		return
+	}
+	b := bufio.NewReader(r)
+	magic, err := b.Peek(5)
+	if err != nil {
+		return nil, errors.New("RData: file too short")
+	}
+	format, ok := rdataMagics[string(magic)]
+	if !ok {
+		if magic[0] == SERIALIZE_XDR && magic[1] == '\n' {
+			return nil, errors.New("RData: serialized object without save() header, use ReadRDS")
+		}
+		return nil, fmt.Errorf("RData: unknown header %q", magic)
+	}
+	b.Discard(5)
+
+	var v Value
+	var info SerializeInfo
+	if v, info, err = Unserialize(b); err != nil {
+		return
+	}
+	if info.Format != format {
+		return nil, fmt.Errorf("RData: %q header for a %q stream", magic, info.Format)
+	}
+	switch p := v.(type) {
+	case *Pairlist :
+		for _, t := range p.Elements {
+			if t.Tag == "" {
+				return nil, errors.New("RData: unnamed object")
+			}
+		}
+		return p.Elements, nil
+	case *Null :
+		return nil, nil
+	}
+	return nil, fmt.Errorf("RData: pairlist expected, got %s", v.Type())
+}
+
+// SaveRData writes the objects as save() does: a gzip compressed RDX3 file.
+func SaveRData(w io.Writer, objects []Tagged) (err error) {
+	z := gzip.NewWriter(w)
+	if _, err = io.WriteString(z, "RDX3\n"); err != nil {
+		return
+	}
+	if err = Serialize(z, &Pairlist{ Elements: objects }, nil); err != nil {
+		return
+	}
+	return z.Close()
+}
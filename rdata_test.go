@@ -0,0 +1,70 @@
+package r
+
+import "testing"
+import "bytes"
+import "compress/gzip"
+import "strings"
+
+func TestLoadRData(e *testing.T) {
+	// save(m, f) with m <- matrix(1:4, 2) and f <- factor("a") in the version 2 XDR format
+	s := &rdsStream{}
+	s.WriteString("RDX2\n")
+	s.Write(newXDRStream(2).
+		ints(0x402).symbol("m").ints(0x20d, 4, 1, 2, 3, 4, 0x402).symbol("dim").ints(0xd, 2, 2, 2, 0xfe).
+		ints(0x402).symbol("f").ints(0x30d, 1, 1, 0x402).symbol("levels").ints(0x10, 1).charsxp("a").
+		ints(0x402).symbol("class").ints(0x10, 1).charsxp("factor").ints(0xfe).
+		ints(0xfe).Bytes())
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(s.Bytes())
+	w.Close()
+
+	for i, data := range [][]byte{ s.Bytes(), gz.Bytes() } {
+		objects, err := LoadRData(bytes.NewReader(data))
+		if err != nil {
+			e.Error("Test LoadRData[", i, "] Failed with error", err)
+			continue
+		}
+		if len(objects) != 2 {
+			e.Error("Test LoadRData[", i, "] Failed:", len(objects), "objects")
+			continue
+		}
+		m, f := objects["m"], objects["f"]
+		if strings.Join(Class(m), " ") != "matrix array" || len(Dim(m)) != 2 || Dim(m)[1] != 2 {
+			e.Error("Test LoadRData[", i, "] Failed: matrix", Class(m), Dim(m))
+		}
+		if strings.Join(Class(f), " ") != "factor" || Dim(f) != nil {
+			e.Error("Test LoadRData[", i, "] Failed: factor", Class(f))
+		}
+	}
+
+	// Errors
+	var errors = []string{ "", "RDX", "X\n\x00\x00\x00\x02", "RDZ2\nX\n", "RDA2\nX\n\x00\x00\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xfe" }
+	for i, data := range errors {
+		if _, err := LoadRData(strings.NewReader(data)); err == nil {
+			e.Error("Test LoadRData error[", i, "] Failed: no error")
+		}
+	}
+}
+
+func TestSaveRData(e *testing.T) {
+	df, _ := NewDataFrame([]string{ "x" }, []Value{ &Real{ Data: []float64{ 1, 2, 3 } } })
+	objects := []Tagged{ { "df", df }, { "s", NewCharacter("a") }, { "f", &Closure{ Body: NullValue, Env: GlobalEnv } } }
+
+	var buf bytes.Buffer
+	if err := SaveRData(&buf, objects); err != nil {
+		e.Fatal(err)
+	}
+	list, err := LoadRDataList(&buf)
+	if err != nil {
+		e.Fatal("Test SaveRData Failed with error", err)
+	}
+	var classes []string
+	for _, t := range list {
+		classes = append(classes, t.Tag + ":" + strings.Join(Class(t.Value), ","))
+	}
+	if strings.Join(classes, " ") != "df:data.frame s:character f:function" {
+		e.Error("Test SaveRData Failed:", classes)
+	}
+}
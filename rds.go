@@ -0,0 +1,953 @@
+package r
+
+import "io"
+import "io/ioutil"
+import "bufio"
+import "bytes"
+import "compress/bzip2"
+import "compress/gzip"
+import "encoding/binary"
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+
+// Serialization formats.
+const (
+	SERIALIZE_XDR    = 'X' // big endian binary
+	SERIALIZE_BINARY = 'B' // native binary, little endian
+	SERIALIZE_ASCII  = 'A' // text
+)
+
+// Pseudo types of the serialization format.
+const (
+	sxpRef            = 255
+	sxpNilValue       = 254
+	sxpGlobalEnv      = 253
+	sxpUnboundValue   = 252
+	sxpMissingArg     = 251
+	sxpBaseNamespace  = 250
+	sxpNamespace      = 249
+	sxpPackage        = 248
+	sxpPersist        = 247
+	sxpClassRef       = 246
+	sxpGenericRef     = 245
+	sxpBCRepDef       = 244
+	sxpBCRepRef       = 243
+	sxpEmptyEnv       = 242
+	sxpBaseEnv        = 241
+	sxpAttrLang       = 240
+	sxpAttrList       = 239
+	sxpAltrep         = 238
+)
+
+// Bits of the flags of an item.
+const (
+	flagIsObject = 1 << 8
+	flagHasAttr  = 1 << 9
+	flagHasTag   = 1 << 10
+)
+
+// Encoding bits of the levels of a CHARSXP.
+const (
+	charsxpBytes  = 1 << 1
+	charsxpLatin1 = 1 << 2
+	charsxpUTF8   = 1 << 3
+	charsxpASCII  = 1 << 6
+)
+
+// Magic numbers of the compressed streams.
+var gzipMagic = []byte{ 0x1f, 0x8b }
+var bzip2Magic = []byte("BZh")
+var xzMagic = []byte{ 0xfd, '7', 'z', 'X', 'Z', 0x00 }
+
+// SerializeInfo is the header of a serialized stream.
+type SerializeInfo struct {
+	// SERIALIZE_XDR, SERIALIZE_BINARY or SERIALIZE_ASCII
+	Format byte
+	// 2 or 3
+	Version int
+	// Version of R that wrote the stream and minimal version of R able to read it: 3.5.0 is 0x030500
+	WriterVersion int
+	MinReaderVersion int
+	// Native encoding of the writer, only for version 3
+	NativeEncoding string
+}
+
+// unserializer reads the items of a serialized stream.
+type unserializer struct {
+	reader *bufio.Reader
+	info   SerializeInfo
+	// Reference table of the symbols and environments, the references are 1-based
+	refs []Value
+	buf  [8]byte
+	// Nesting level of the item being read
+	depth int
+}
+
+// maxDepth is the maximal nesting level of the items: a deeper stream is an error rather than a stack overflow.
+const maxDepth = 1 << 14
+
+// maxCompactLength is the maximal length of the compact ALTREP sequences expanded by the reader: their length is
+// not backed by the bytes of the stream.
+const maxCompactLength = 1 << 24
+
+// Decompress returns a reader of the decompressed content of r: gzip, bzip2 and xz streams are detected by their
+// magic number, the other streams are returned unchanged. An xz stream is decompressed in memory.
+func Decompress(r io.Reader) (io.Reader, error) {
+	b := bufio.NewReader(r)
+	magic, err := b.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic) :
+		return gzip.NewReader(b)
+	case bytes.HasPrefix(magic, bzip2Magic) :
+		return bzip2.NewReader(b), nil
+	case bytes.HasPrefix(magic, xzMagic) :
+		data, err := ioutil.ReadAll(b)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = decodeXZ(data); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+	return b, nil
+}
+
+// ReadRDS reads an object written by saveRDS(), compressed or not.
+func ReadRDS(r io.Reader) (v Value, err error) {
+	if r, err = Decompress(r); err != nil {
+		return
+	}
+	v, _, err = Unserialize(r)
+	return
+}
+
+// Unserialize reads an object written by serialize(), the stream must not be compressed. A malformed stream is
+// an error, the vectors are allocated while their elements are read so that a corrupted length fails at the end of
+// the stream.
+func Unserialize(r io.Reader) (v Value, info SerializeInfo, err error) {
+	u := &unserializer{ reader: bufio.NewReader(r) }
+	defer func() {
+		if e := recover(); e != nil {
+			v = nil
+			if re, ok := e.(unserializeError); ok {
+				err = re.err
+				return
+			}
+			err = fmt.Errorf("unserialize: malformed stream: %v", e)
+		}
+	}()
+	u.readHeader()
+	v = u.readItem()
+	info = u.info
+	return
+}
+
+// unserializeError wraps the errors raised with panic while reading the nested items.
+type unserializeError struct {
+	err error
+}
+
+func (this *unserializer) fail(format string, args ...interface{}) {
+	panic(unserializeError{ fmt.Errorf("unserialize: " + format, args...) })
+}
+
+func (this *unserializer) check(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		panic(unserializeError{ fmt.Errorf("unserialize: %s", err.Error()) })
+	}
+}
+
+func (this *unserializer) readHeader() {
+	format := make([]byte, 2)
+	_, err := io.ReadFull(this.reader, format)
+	this.check(err)
+	if format[1] != '\n' {
+		this.fail("unknown format %q", format)
+	}
+	switch format[0] {
+	case SERIALIZE_XDR, SERIALIZE_BINARY, SERIALIZE_ASCII :
+		this.info.Format = format[0]
+	default:
+		this.fail("unknown format %q", format)
+	}
+
+	this.info.Version = this.readInt()
+	this.info.WriterVersion = this.readInt()
+	this.info.MinReaderVersion = this.readInt()
+	switch this.info.Version {
+	case 2 :
+	case 3 :
+		n := this.readInt()
+		this.info.NativeEncoding = this.readString(n)
+	default:
+		this.fail("unsupported version %d", this.info.Version)
+	}
+}
+
+// word reads the next blank separated word of an ASCII stream.
+func (this *unserializer) word() string {
+	var w []byte
+	for {
+		c, err := this.reader.ReadByte()
+		if err == io.EOF && len(w) > 0 {
+			break
+		}
+		this.check(err)
+		if c == ' ' || c == '\n' || c == '\t' || c == '\r' {
+			if len(w) > 0 {
+				break
+			}
+			continue
+		}
+		w = append(w, c)
+	}
+	return string(w)
+}
+
+func (this *unserializer) readInt() int {
+	return int(this.readInt32())
+}
+
+func (this *unserializer) readInt32() int32 {
+	switch this.info.Format {
+	case SERIALIZE_ASCII :
+		w := this.word()
+		if w == "NA" {
+			return NaInteger
+		}
+		i, err := strconv.ParseInt(w, 10, 32)
+		if err != nil {
+			this.fail("integer expected, got %q", w)
+		}
+		return int32(i)
+	case SERIALIZE_BINARY :
+		_, err := io.ReadFull(this.reader, this.buf[:4])
+		this.check(err)
+		return int32(binary.LittleEndian.Uint32(this.buf[:4]))
+	}
+	_, err := io.ReadFull(this.reader, this.buf[:4])
+	this.check(err)
+	return int32(binary.BigEndian.Uint32(this.buf[:4]))
+}
+
+func (this *unserializer) readReal() float64 {
+	switch this.info.Format {
+	case SERIALIZE_ASCII :
+		w := this.word()
+		switch w {
+		case "NA" :
+			return NaReal
+		case "NaN" :
+			return math.NaN()
+		case "Inf" :
+			return math.Inf(1)
+		case "-Inf" :
+			return math.Inf(-1)
+		}
+		f, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			this.fail("real expected, got %q", w)
+		}
+		return f
+	case SERIALIZE_BINARY :
+		_, err := io.ReadFull(this.reader, this.buf[:8])
+		this.check(err)
+		return math.Float64frombits(binary.LittleEndian.Uint64(this.buf[:8]))
+	}
+	_, err := io.ReadFull(this.reader, this.buf[:8])
+	this.check(err)
+	return math.Float64frombits(binary.BigEndian.Uint64(this.buf[:8]))
+}
+
+// readLength reads the length of a vector, the long vectors have a -1 length followed by two words.
+func (this *unserializer) readLength() int {
+	n := this.readInt()
+	if n == -1 {
+		upper := this.readInt()
+		lower := this.readInt()
+		if upper < 0 || upper >= 1 << 20 {
+			this.fail("invalid long length")
+		}
+		return upper << 32 + int(uint32(lower))
+	}
+	if n < 0 {
+		this.fail("negative length %d", n)
+	}
+	return n
+}
+
+// capacity returns the initial capacity of a vector of length n: the vectors grow while their elements are read.
+func capacity(n int) int {
+	if n > 1 << 12 {
+		return 1 << 12
+	}
+	return n
+}
+
+// readBytes reads n bytes, the buffer grows while reading.
+func (this *unserializer) readBytes(n int) []byte {
+	if n < 0 {
+		this.fail("negative length %d", n)
+	}
+	var b bytes.Buffer
+	if m, err := io.CopyN(&b, this.reader, int64(n)); m < int64(n) {
+		this.check(err)
+	}
+	return b.Bytes()
+}
+
+// readString reads the n bytes of a string, the ASCII strings are escaped like C strings.
+func (this *unserializer) readString(n int) string {
+	if this.info.Format != SERIALIZE_ASCII {
+		return string(this.readBytes(n))
+	}
+
+	// Skip the blanks then read the n escaped characters
+	var b []byte
+	c, err := this.reader.ReadByte()
+	for ; err == nil && (c == ' ' || c == '\n' || c == '\t' || c == '\r'); c, err = this.reader.ReadByte() {
+	}
+	for len(b) < n {
+		this.check(err)
+		if c == '\\' {
+			c, err = this.reader.ReadByte()
+			this.check(err)
+			switch c {
+			case 'n' : c = '\n'
+			case 't' : c = '\t'
+			case 'v' : c = '\v'
+			case 'b' : c = '\b'
+			case 'r' : c = '\r'
+			case 'f' : c = '\f'
+			case 'a' : c = '\a'
+			case '0', '1', '2', '3', '4', '5', '6', '7' :
+				// up to 3 octal digits
+				v := c - '0'
+				for i := 0; i < 2; i++ {
+					d, err := this.reader.ReadByte()
+					this.check(err)
+					if d < '0' || d > '7' {
+						this.check(this.reader.UnreadByte())
+						break
+					}
+					v = v * 8 + d - '0'
+				}
+				c = v
+			}
+		}
+		b = append(b, c)
+		if len(b) < n {
+			c, err = this.reader.ReadByte()
+		}
+	}
+	return string(b)
+}
+
+func (this *unserializer) addRef(v Value) {
+	this.refs = append(this.refs, v)
+}
+
+func (this *unserializer) readItem() Value {
+	flags := this.readInt()
+	return this.readItemFlags(flags)
+}
+
+// readAttributes reads the attributes pairlist of an item.
+func (this *unserializer) readAttributes(a *Attrs) {
+	v := this.readItem()
+	switch p := v.(type) {
+	case *Pairlist :
+		for _, t := range p.Elements {
+			a.List = append(a.List, Attribute{ t.Tag, t.Value })
+		}
+	case *Null :
+	default:
+		this.fail("attributes must be a pairlist, got %s", v.Type())
+	}
+}
+
+// tagName returns the name of a tag: a symbol or NULL.
+func (this *unserializer) tagName(v Value) string {
+	switch s := v.(type) {
+	case *Symbol :
+		return s.Name
+	case *Null :
+		return ""
+	}
+	this.fail("tag must be a symbol, got %s", v.Type())
+	return ""
+}
+
+// elements flattens the pairlist v.
+func (this *unserializer) elements(v Value) []Tagged {
+	switch p := v.(type) {
+	case *Pairlist :
+		return p.Elements
+	case *Null :
+		return nil
+	}
+	this.fail("pairlist expected, got %s", v.Type())
+	return nil
+}
+
+func (this *unserializer) readItemFlags(flags int) Value {
+	if this.depth++; this.depth > maxDepth {
+		this.fail("items nested too deeply")
+	}
+	defer func() { this.depth-- }()
+	typ := flags & 0xff
+	levels := flags >> 12
+	hasattr := flags & flagHasAttr != 0
+	hastag := flags & flagHasTag != 0
+
+	switch typ {
+	case sxpNilValue :
+		return NullValue
+	case sxpEmptyEnv :
+		return EmptyEnv
+	case sxpBaseEnv :
+		return BaseEnv
+	case sxpGlobalEnv :
+		return GlobalEnv
+	case sxpUnboundValue :
+		return UnboundValue
+	case sxpMissingArg :
+		return MissingArg
+	case sxpBaseNamespace :
+		return BaseNamespace
+	case sxpRef :
+		i := flags >> 8
+		if i == 0 {
+			i = this.readInt()
+		}
+		if i < 1 || i > len(this.refs) {
+			this.fail("reference %d out of range", i)
+		}
+		return this.refs[i-1]
+	case sxpPersist :
+		this.fail("persistent references are not supported")
+	case sxpClassRef, sxpGenericRef :
+		this.fail("this version of R can not read class references")
+	case int(SYMSXP) :
+		name := this.readItem()
+		c, ok := name.(*Character)
+		if !ok {
+			this.fail("symbol name expected")
+		}
+		s := &Symbol{ c.Data[0] }
+		this.addRef(s)
+		return s
+	case sxpPackage, sxpNamespace :
+		spec := this.readStringVec()
+		e := &Environment{ Spec: spec }
+		if typ == sxpPackage {
+			e.Name = strings.Join(spec, " ")
+		} else if len(spec) > 0 {
+			e.Name = "namespace:" + spec[0]
+		}
+		this.addRef(e)
+		return e
+	case int(ENVSXP) :
+		e := &Environment{ Locked: this.readInt() != 0 }
+		this.addRef(e)
+		e.Enclos = this.readItem()
+		e.Frame = this.elements(this.readItem())
+		// The hash table is a list of the pairlists of the buckets
+		switch h := this.readItem().(type) {
+		case *List :
+			for _, bucket := range h.Data {
+				e.Frame = append(e.Frame, this.elements(bucket)...)
+			}
+		case *Null :
+		default:
+			this.fail("environment hash table must be a list")
+		}
+		this.readAttributes(&e.Attrs)
+		return e
+	case int(LISTSXP), int(LANGSXP), int(CLOSXP), int(PROMSXP), int(DOTSXP) :
+		return this.readCons(SexpType(typ), hasattr, hastag)
+	case sxpAltrep :
+		return this.readAltrep()
+	}
+
+	var v Value
+	var attrs *Attrs
+	switch SexpType(typ) {
+	case EXTPTRSXP :
+		p := &ExternalPointer{}
+		this.addRef(p)
+		p.Prot = this.readItem()
+		p.Tag = this.readItem()
+		v, attrs = p, &p.Attrs
+	case WEAKREFSXP :
+		w := &WeakRef{}
+		this.addRef(w)
+		v, attrs = w, &w.Attrs
+	case SPECIALSXP, BUILTINSXP :
+		n := this.readInt()
+		b := &Builtin{ Name: this.readString(n), Special: SexpType(typ) == SPECIALSXP }
+		v, attrs = b, &b.Attrs
+	case CHARSXP :
+		// A CHARSXP is returned as a character vector of length 1
+		n := this.readInt()
+		if n == -1 {
+			v = &Character{ Data: []string{ "" }, NA: []bool{ true } }
+		} else {
+			s := this.readString(n)
+			if levels & charsxpLatin1 != 0 {
+				s = latin1ToUTF8(s)
+			}
+			v = &Character{ Data: []string{ s } }
+		}
+		if hasattr {
+			this.readItem()
+		}
+		return v
+	case LGLSXP :
+		l := &Logical{ Data: this.readInt32s() }
+		v, attrs = l, &l.Attrs
+	case INTSXP :
+		i := &Integer{ Data: this.readInt32s() }
+		v, attrs = i, &i.Attrs
+	case REALSXP :
+		n := this.readLength()
+		r := &Real{ Data: make([]float64, 0, capacity(n)) }
+		for i := 0; i < n; i++ {
+			r.Data = append(r.Data, this.readReal())
+		}
+		v, attrs = r, &r.Attrs
+	case CPLXSXP :
+		n := this.readLength()
+		c := &Complex{ Data: make([]complex128, 0, capacity(n)) }
+		for i := 0; i < n; i++ {
+			re := this.readReal()
+			c.Data = append(c.Data, complex(re, this.readReal()))
+		}
+		v, attrs = c, &c.Attrs
+	case STRSXP :
+		n := this.readLength()
+		s := &Character{ Data: make([]string, 0, capacity(n)) }
+		var na []int
+		for i := 0; i < n; i++ {
+			c, ok := this.readItem().(*Character)
+			if !ok {
+				this.fail("CHARSXP expected in a character vector")
+			}
+			s.Data = append(s.Data, c.Data[0])
+			if c.IsNA(0) {
+				na = append(na, i)
+			}
+		}
+		if len(na) > 0 {
+			s.NA = make([]bool, n)
+			for _, i := range na {
+				s.NA[i] = true
+			}
+		}
+		v, attrs = s, &s.Attrs
+	case VECSXP, EXPRSXP :
+		n := this.readLength()
+		l := &List{ Data: make([]Value, 0, capacity(n)), Expression: SexpType(typ) == EXPRSXP }
+		for i := 0; i < n; i++ {
+			l.Data = append(l.Data, this.readItem())
+		}
+		v, attrs = l, &l.Attrs
+	case BCODESXP :
+		n := this.readInt()
+		if n < 0 {
+			this.fail("negative byte code reference table length %d", n)
+		}
+		v = this.readBC(&bcReps{ n: n, cells: map[int]*bcCons{} })
+	case RAWSXP :
+		n := this.readLength()
+		r := &Raw{}
+		if this.info.Format == SERIALIZE_ASCII {
+			r.Data = make([]byte, 0, capacity(n))
+			for i := 0; i < n; i++ {
+				b, err := strconv.ParseUint(this.word(), 16, 8)
+				this.check(err)
+				r.Data = append(r.Data, byte(b))
+			}
+		} else {
+			r.Data = this.readBytes(n)
+		}
+		v, attrs = r, &r.Attrs
+	case S4SXP :
+		s := &S4Object{}
+		v, attrs = s, &s.Attrs
+	default:
+		this.fail("unknown type %d", typ)
+	}
+	if hasattr {
+		if attrs == nil {
+			this.fail("unexpected attributes on %s", v.Type())
+		}
+		this.readAttributes(attrs)
+	}
+	return v
+}
+
+// readInt32s reads the length and the elements of a logical or integer vector.
+func (this *unserializer) readInt32s() []int32 {
+	n := this.readLength()
+	if this.info.Format == SERIALIZE_ASCII {
+		data := make([]int32, 0, capacity(n))
+		for i := 0; i < n; i++ {
+			data = append(data, this.readInt32())
+		}
+		return data
+	}
+	b := this.readBytes(4 * n)
+	data := make([]int32, n)
+	for i := range data {
+		if this.info.Format == SERIALIZE_BINARY {
+			data[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+		} else {
+			data[i] = int32(binary.BigEndian.Uint32(b[4*i:]))
+		}
+	}
+	return data
+}
+
+// readStringVec reads the names of a package or namespace environment.
+func (this *unserializer) readStringVec() (s []string) {
+	if this.readInt() != 0 {
+		this.fail("names in persistent strings are not supported")
+	}
+	n := this.readInt()
+	for i := 0; i < n; i++ {
+		c, ok := this.readItem().(*Character)
+		if !ok {
+			this.fail("CHARSXP expected")
+		}
+		s = append(s, c.Data[0])
+	}
+	return
+}
+
+// readCons reads a pairlist, a call, a closure or a promise: attributes, tag, car and cdr.
+// The pairlists are flattened by reading the cdr chain iteratively.
+func (this *unserializer) readCons(typ SexpType, hasattr, hastag bool) Value {
+	var attrs Attrs
+	if hasattr {
+		this.readAttributes(&attrs)
+	}
+	var tag Value = NullValue
+	if hastag {
+		tag = this.readItem()
+	}
+
+	switch typ {
+	case CLOSXP :
+		c := &Closure{ Attrs: attrs, Env: tag }
+		c.Formals = this.elements(this.readItem())
+		c.Body = this.readItem()
+		return c
+	case PROMSXP :
+		p := &Promise{ Env: tag }
+		p.Value = this.readItem()
+		p.Expr = this.readItem()
+		return p
+	}
+
+	first := Tagged{ this.tagName(tag), this.readItem() }
+	elements := []Tagged{ first }
+	for {
+		flags := this.readInt()
+		if flags & 0xff != int(LISTSXP) || flags & flagHasAttr != 0 {
+			rest := this.elements(this.readItemFlags(flags))
+			elements = append(elements, rest...)
+			break
+		}
+		t := Tagged{}
+		if flags & flagHasTag != 0 {
+			t.Tag = this.tagName(this.readItem())
+		}
+		t.Value = this.readItem()
+		elements = append(elements, t)
+	}
+
+	if typ == LANGSXP {
+		return &Language{ Attrs: attrs, Fun: elements[0].Value, Args: elements[1:] }
+	}
+	return &Pairlist{ Attrs: attrs, Elements: elements, Dots: typ == DOTSXP }
+}
+
+// readAltrep reads an ALTREP object: the class information, the state and the attributes.
+// The compact sequences and the wrappers are expanded in ordinary vectors.
+func (this *unserializer) readAltrep() Value {
+	info := this.elements(this.readItem())
+	state := this.readItem()
+	attr := this.readItem()
+	if len(info) < 1 {
+		this.fail("malformed ALTREP class information")
+	}
+	class, _ := info[0].Value.(*Symbol)
+	if class == nil {
+		this.fail("malformed ALTREP class information")
+	}
+
+	var v Value
+	switch class.Name {
+	case "compact_intseq", "compact_realseq" :
+		// state is c(length, first, increment)
+		s, ok := state.(*Real)
+		if !ok || len(s.Data) != 3 || !(s.Data[0] >= 0 && s.Data[0] == math.Trunc(s.Data[0])) {
+			this.fail("malformed %s state", class.Name)
+		}
+		if s.Data[0] > maxCompactLength {
+			this.fail("%s of length %g is too long to be expanded", class.Name, s.Data[0])
+		}
+		n, first, inc := int(s.Data[0]), s.Data[1], s.Data[2]
+		if class.Name == "compact_intseq" {
+			data := make([]int32, n)
+			for i := range data {
+				data[i] = int32(first + float64(i) * inc)
+			}
+			v = &Integer{ Data: data }
+		} else {
+			data := make([]float64, n)
+			for i := range data {
+				data[i] = first + float64(i) * inc
+			}
+			v = &Real{ Data: data }
+		}
+	case "deferred_string" :
+		// state is a pairlist of the vector to convert and of the scipen option
+		args := this.elements(state)
+		if len(args) < 1 {
+			this.fail("malformed deferred_string state")
+		}
+		v = deferredString(args[0].Value)
+		if v == nil {
+			this.fail("unsupported deferred_string of %s", args[0].Value.Type())
+		}
+	case "wrap_integer", "wrap_real", "wrap_logical", "wrap_complex", "wrap_string", "wrap_raw", "wrap_list" :
+		// state is a list of the wrapped vector and of its metadata
+		switch s := state.(type) {
+		case *List :
+			if len(s.Data) > 0 {
+				v = s.Data[0]
+			}
+		case *Pairlist :
+			if len(s.Elements) > 0 {
+				v = s.Elements[0].Value
+			}
+		}
+		if v == nil {
+			this.fail("malformed %s state", class.Name)
+		}
+		v = copyVector(v)
+	default:
+		this.fail("unsupported ALTREP class %s", class.Name)
+	}
+
+	if attrs := v.Attributes(); attrs != nil {
+		attrs.List = nil
+		for _, t := range this.elements(attr) {
+			attrs.List = append(attrs.List, Attribute{ t.Tag, t.Value })
+		}
+	}
+	return v
+}
+
+// copyVector returns a shallow copy of a vector without its attributes.
+func copyVector(v Value) Value {
+	switch x := v.(type) {
+	case *Logical :
+		return &Logical{ Data: x.Data }
+	case *Integer :
+		return &Integer{ Data: x.Data }
+	case *Real :
+		return &Real{ Data: x.Data }
+	case *Complex :
+		return &Complex{ Data: x.Data }
+	case *Character :
+		return &Character{ Data: x.Data, NA: x.NA }
+	case *Raw :
+		return &Raw{ Data: x.Data }
+	case *List :
+		return &List{ Data: x.Data }
+	}
+	return v
+}
+
+// deferredString converts an integer or a real vector to a character vector as as.character() does.
+func deferredString(v Value) Value {
+	switch x := v.(type) {
+	case *Integer :
+		s := &Character{ Data: make([]string, len(x.Data)) }
+		for i, n := range x.Data {
+			if n == NaInteger {
+				if s.NA == nil {
+					s.NA = make([]bool, len(x.Data))
+				}
+				s.NA[i] = true
+				continue
+			}
+			s.Data[i] = strconv.Itoa(int(n))
+		}
+		return s
+	case *Real :
+		s := &Character{ Data: make([]string, len(x.Data)) }
+		for i, f := range x.Data {
+			if IsNaReal(f) {
+				if s.NA == nil {
+					s.NA = make([]bool, len(x.Data))
+				}
+				s.NA[i] = true
+				continue
+			}
+			s.Data[i] = FormatReal(f)
+		}
+		return s
+	}
+	return nil
+}
+
+// latin1ToUTF8 converts a latin1 string to UTF-8.
+func latin1ToUTF8(s string) string {
+	r := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		r[i] = rune(s[i])
+	}
+	return string(r)
+}
+
+// bcReps is the table of the shared cons cells of a byte code object: n cells.
+type bcReps struct {
+	n     int
+	cells map[int]*bcCons
+}
+
+// bcCons is a cons cell of the byte code constants, they may be shared through the reps table.
+type bcCons struct {
+	typ  SexpType
+	attr Attrs
+	tag  Value
+	car  Value
+	cdr  Value
+	// Converted value
+	value Value
+}
+
+func (this *bcCons) Type() SexpType { return this.typ }
+func (this *bcCons) Attributes() *Attrs { return &this.attr }
+
+// readBC reads a byte code object: the code vector and the constants.
+func (this *unserializer) readBC(reps *bcReps) *Bytecode {
+	if this.depth++; this.depth > maxDepth {
+		this.fail("items nested too deeply")
+	}
+	defer func() { this.depth-- }()
+	b := &Bytecode{ Code: this.readItem() }
+	n := this.readInt()
+	for i := 0; i < n; i++ {
+		typ := this.readInt()
+		switch typ {
+		case int(BCODESXP) :
+			b.Consts = append(b.Consts, this.readBC(reps))
+		case int(LANGSXP), int(LISTSXP), sxpBCRepDef, sxpBCRepRef, sxpAttrLang, sxpAttrList :
+			b.Consts = append(b.Consts, bcValue(this.readBCLang(typ, reps)))
+		default:
+			b.Consts = append(b.Consts, this.readItem())
+		}
+	}
+	return b
+}
+
+func (this *unserializer) readBCLang(typ int, reps *bcReps) Value {
+	if this.depth++; this.depth > maxDepth {
+		this.fail("items nested too deeply")
+	}
+	defer func() { this.depth-- }()
+	switch typ {
+	case sxpBCRepRef :
+		i := this.readInt()
+		if reps.cells[i] == nil {
+			this.fail("byte code reference %d out of range", i)
+		}
+		return reps.cells[i]
+	case sxpBCRepDef, int(LANGSXP), int(LISTSXP), sxpAttrLang, sxpAttrList :
+		pos := -1
+		if typ == sxpBCRepDef {
+			pos = this.readInt()
+			typ = this.readInt()
+		}
+		hasattr := false
+		switch typ {
+		case sxpAttrLang :
+			typ, hasattr = int(LANGSXP), true
+		case sxpAttrList :
+			typ, hasattr = int(LISTSXP), true
+		}
+		c := &bcCons{ typ: SexpType(typ) }
+		if pos != -1 {
+			if pos < 0 || pos >= reps.n {
+				this.fail("byte code reference %d out of range", pos)
+			}
+			reps.cells[pos] = c
+		}
+		if hasattr {
+			this.readAttributes(&c.attr)
+		}
+		c.tag = this.readItem()
+		c.car = this.readBCLang(this.readInt(), reps)
+		c.cdr = this.readBCLang(this.readInt(), reps)
+		return c
+	}
+	return this.readItem()
+}
+
+// bcValue converts the cons cells of the byte code constants to pairlists and calls.
+func bcValue(v Value) Value {
+	c, ok := v.(*bcCons)
+	if !ok {
+		return v
+	}
+	if c.value != nil {
+		return c.value
+	}
+	var elements []Tagged
+	var result Value
+	var tail *[]Tagged
+	if c.typ == LANGSXP {
+		l := &Language{ Attrs: c.attr }
+		c.value, result = l, l
+		tail = &l.Args
+	} else {
+		p := &Pairlist{ Attrs: c.attr }
+		c.value, result = p, p
+		tail = &p.Elements
+	}
+	for cell := c; ; {
+		tag := ""
+		if s, ok := cell.tag.(*Symbol); ok {
+			tag = s.Name
+		}
+		elements = append(elements, Tagged{ tag, bcValue(cell.car) })
+		next, ok := cell.cdr.(*bcCons)
+		if !ok || next.typ != LISTSXP || next.value != nil {
+			if p, ok := bcValue(cell.cdr).(*Pairlist); ok {
+				elements = append(elements, p.Elements...)
+			}
+			break
+		}
+		cell = next
+	}
+	if l, ok := result.(*Language); ok {
+		l.Fun = elements[0].Value
+		elements = elements[1:]
+	}
+	*tail = elements
+	return result
+}
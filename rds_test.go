@@ -0,0 +1,274 @@
+package r
+
+import "testing"
+import "bytes"
+import "compress/gzip"
+import "encoding/binary"
+import "io/ioutil"
+import "math"
+import "path/filepath"
+import "strings"
+
+// rdsStream builds a serialized stream item by item.
+type rdsStream struct {
+	bytes.Buffer
+	order binary.ByteOrder
+}
+
+func newXDRStream(version int) *rdsStream {
+	s := &rdsStream{ order: binary.BigEndian }
+	s.WriteString("X\n")
+	s.ints(int32(version), 0x030602, 0x020300)
+	if version == 3 {
+		s.ints(5)
+		s.WriteString("UTF-8")
+	}
+	return s
+}
+
+func (this *rdsStream) ints(values ...int32) *rdsStream {
+	for _, v := range values {
+		binary.Write(this, this.order, v)
+	}
+	return this
+}
+
+func (this *rdsStream) reals(values ...float64) *rdsStream {
+	for _, v := range values {
+		binary.Write(this, this.order, math.Float64bits(v))
+	}
+	return this
+}
+
+// charsxp writes an ASCII CHARSXP.
+func (this *rdsStream) charsxp(s string) *rdsStream {
+	this.ints(0x40009, int32(len(s)))
+	this.WriteString(s)
+	return this
+}
+
+// symbol writes a new symbol.
+func (this *rdsStream) symbol(s string) *rdsStream {
+	this.ints(int32(SYMSXP))
+	return this.charsxp(s)
+}
+
+func TestUnserialize(e *testing.T) {
+	var tests = []struct {
+		stream *rdsStream
+		check  func(v Value) bool
+	}{
+		// c(a = 1L, b = NA)
+		{ newXDRStream(2).ints(0x20d, 2, 1, NaInteger, 0x402).symbol("names").ints(0x10, 2).charsxp("a").charsxp("b").ints(0xfe),
+			func(v Value) bool {
+				i, ok := v.(*Integer)
+				names, _ := i.Attributes().Get("names").(*Character)
+				return ok && len(i.Data) == 2 && i.Data[1] == NaInteger && names != nil && names.Data[1] == "b"
+			} },
+		// as.character(c(1e5, 1e-4, 123456, 0.1, -0, NA)), an ALTREP deferred string
+		{ newXDRStream(3).ints(0xee, 2).symbol("deferred_string").ints(2).symbol("base").ints(2, 0xd, 1, 16, 0xfe).
+			ints(2, 0xe, 6).reals(1e5, 1e-4, 123456, 0.1, math.Copysign(0, -1), NaReal).ints(2, 0xd, 1, 0, 0xfe).ints(0xfe),
+			func(v Value) bool {
+				c, ok := v.(*Character)
+				return ok && strings.Join(c.Data[:5], " ") == "1e+05 1e-04 123456 0.1 0" && c.IsNA(5)
+			} },
+		// c(1.5, NA, -Inf)
+		{ newXDRStream(3).ints(0xe, 3).reals(1.5, NaReal, math.Inf(-1)),
+			func(v Value) bool {
+				r, ok := v.(*Real)
+				return ok && r.Data[0] == 1.5 && IsNaReal(r.Data[1]) && math.IsInf(r.Data[2], -1)
+			} },
+		// c("x", NA)
+		{ newXDRStream(3).ints(0x10, 2).charsxp("x").ints(9, -1),
+			func(v Value) bool {
+				c, ok := v.(*Character)
+				return ok && c.Data[0] == "x" && !c.IsNA(0) && c.IsNA(1)
+			} },
+		// 1:3 as a compact ALTREP sequence
+		{ newXDRStream(3).ints(0xee, 2).symbol("compact_intseq").ints(2).symbol("base").ints(2, 0xd, 1, 13, 0xfe).
+			ints(0xe, 3).reals(3, 1, 1).ints(0xfe),
+			func(v Value) bool {
+				i, ok := v.(*Integer)
+				return ok && len(i.Data) == 3 && i.Data[0] == 1 && i.Data[2] == 3
+			} },
+		// list(TRUE, as.raw(255), 2i)
+		{ newXDRStream(2).ints(0x13, 3, 0xa, 1, 1, 0x18, 1).WriteByteAndReturn(0xff).ints(0xf, 1).reals(0, 2),
+			func(v Value) bool {
+				l, ok := v.(*List)
+				if !ok || len(l.Data) != 3 {
+					return false
+				}
+				b, _ := l.Data[0].(*Logical)
+				r, _ := l.Data[1].(*Raw)
+				c, _ := l.Data[2].(*Complex)
+				return b != nil && b.Data[0] == 1 && r != nil && r.Data[0] == 255 && c != nil && c.Data[0] == 2i
+			} },
+		// function(x) x + a in an environment with a = 1, the symbol x is given by reference
+		{ newXDRStream(2).ints(0x403, 4, 0, 0xfd, 0x402).symbol("a").ints(0xe, 1).reals(1).ints(0xfe, 0xfe, 0xfe).
+			ints(0x402).symbol("x").ints(0xfb, 0xfe).
+			ints(6).symbol("+").ints(2, 0x3ff, 2).ints(0x2ff).ints(0xfe),
+			func(v Value) bool {
+				c, ok := v.(*Closure)
+				if !ok || len(c.Formals) != 1 || c.Formals[0].Tag != "x" || c.Formals[0].Value != MissingArg {
+					return false
+				}
+				env, _ := c.Env.(*Environment)
+				body, _ := c.Body.(*Language)
+				return env != nil && env.Enclos == GlobalEnv && env.Get("a") != nil && body != nil &&
+					body.Args[0].Value.(*Symbol).Name == "x" && body.Args[1].Value.(*Symbol).Name == "a"
+			} },
+	}
+
+	for i, test := range tests {
+		v, _, err := Unserialize(bytes.NewReader(test.stream.Bytes()))
+		if err != nil {
+			e.Error("Test Unserialize[", i, "] Failed with error", err)
+			continue
+		}
+		if !test.check(v) {
+			e.Errorf("Test Unserialize[%d] Failed: %#v", i, v)
+		}
+	}
+}
+
+func (this *rdsStream) WriteByteAndReturn(b byte) *rdsStream {
+	this.WriteByte(b)
+	return this
+}
+
+func TestUnserializeFormats(e *testing.T) {
+	// c(TRUE, NA) in the native binary format
+	b := &rdsStream{ order: binary.LittleEndian }
+	b.WriteString("B\n")
+	b.ints(2, 0x030602, 0x020300, 0xa, 2, 1, NaLogical)
+	v, info, err := Unserialize(bytes.NewReader(b.Bytes()))
+	if l, ok := v.(*Logical); err != nil || !ok || info.Format != SERIALIZE_BINARY || l.Data[1] != NaLogical {
+		e.Error("Test UnserializeFormats binary Failed:", v, err)
+	}
+
+	// c(a = 1.5, b = NA) in the ASCII format
+	ascii := "A\n3\n263682\n197888\n5\nUTF-8\n526\n2\n1.5\nNA\n1026\n1\n262153\n5\nnames\n16\n2\n262153\n1\na\n262153\n2\nb\\n\n254\n"
+	v, info, err = Unserialize(bytes.NewReader([]byte(ascii)))
+	if err != nil {
+		e.Fatal("Test UnserializeFormats ASCII Failed with error", err)
+	}
+	r, ok := v.(*Real)
+	names, _ := r.Attributes().Get("names").(*Character)
+	if !ok || info.NativeEncoding != "UTF-8" || r.Data[0] != 1.5 || !IsNaReal(r.Data[1]) || names == nil || names.Data[1] != "b\n" {
+		e.Error("Test UnserializeFormats ASCII Failed:", v)
+	}
+
+	// gzip compressed rds
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(newXDRStream(3).ints(0xfe).Bytes())
+	w.Close()
+	if v, err = ReadRDS(&gz); err != nil || v != NullValue {
+		e.Error("Test UnserializeFormats gzip Failed:", v, err)
+	}
+
+	// xz compressed rds, as saveRDS(compress = "xz") writes it
+	xz, err := ioutil.ReadFile(filepath.Join("testdata", "serialize", "dataframe-xz.rds"))
+	if err != nil {
+		e.Fatal(err)
+	}
+	if v, err = ReadRDS(bytes.NewReader(xz)); err != nil || Names(v) == nil || Names(v)[1] != "y" {
+		e.Error("Test UnserializeFormats xz Failed:", v, err)
+	}
+	if _, err = ReadRDS(bytes.NewReader([]byte{ 0xfd, '7', 'z', 'X', 'Z', 0x00, 0, 0 })); err == nil {
+		e.Error("Test UnserializeFormats xz Failed: no error on a truncated stream")
+	}
+
+	// Truncated and malformed streams
+	for i, s := range malformedStreams() {
+		if _, _, err = Unserialize(bytes.NewReader(s)); err == nil {
+			e.Error("Test UnserializeFormats error[", i, "] Failed: no error")
+		}
+	}
+}
+
+// malformedStreams returns truncated and corrupted streams.
+func malformedStreams() [][]byte {
+	return [][]byte{
+		[]byte("X\n"),
+		[]byte("Z\n\x00\x00\x00\x02"),
+		newXDRStream(2).ints(0xe, 3).reals(1).Bytes(),
+		newXDRStream(2).ints(0x1ff).Bytes(),
+		// lengths larger than the stream
+		newXDRStream(2).ints(0xe, 0x7fffffff).Bytes(),
+		newXDRStream(2).ints(0xd, -1, 0x7fffffff, -1).Bytes(),
+		newXDRStream(2).ints(0x10, -1, 0xfffff, 0).Bytes(),
+		newXDRStream(2).ints(0x18, 0x7fffffff, 0).Bytes(),
+		newXDRStream(2).ints(0x9, 0x7fffffff).Bytes(),
+		newXDRStream(2).ints(0x15, 0x7fffffff).Bytes(),
+		newXDRStream(2).ints(0x15, -5).Bytes(),
+		// ALTREP objects with a short state
+		newXDRStream(3).ints(0xee, 2).symbol("wrap_real").ints(2).symbol("base").ints(2, 0xd, 1, 14, 0xfe).ints(0x13, 0, 0xfe).Bytes(),
+		newXDRStream(3).ints(0xee, 2).symbol("wrap_integer").ints(2).symbol("base").ints(2, 0xd, 1, 13, 0xfe).ints(0xfe, 0xfe).Bytes(),
+		newXDRStream(3).ints(0xee, 2).symbol("compact_intseq").ints(2).symbol("base").ints(2, 0xd, 1, 13, 0xfe).
+			ints(0xe, 2).reals(3, 1).ints(0xfe).Bytes(),
+		newXDRStream(3).ints(0xee, 2).symbol("compact_realseq").ints(2).symbol("base").ints(2, 0xd, 1, 14, 0xfe).
+			ints(0xe, 3).reals(1e15, 1, 1).ints(0xfe).Bytes(),
+		newXDRStream(3).ints(0xee, 2).symbol("compact_intseq").ints(2).symbol("base").ints(2, 0xd, 1, 13, 0xfe).
+			ints(0xe, 3).reals(-1, 1, 1).ints(0xfe).Bytes(),
+	}
+}
+
+func FuzzUnserialize(f *testing.F) {
+	for _, s := range malformedStreams() {
+		f.Add(s)
+	}
+	f.Add(newXDRStream(3).ints(0xee, 2).symbol("compact_intseq").ints(2).symbol("base").ints(2, 0xd, 1, 13, 0xfe).
+		ints(0xe, 3).reals(3, 1, 1).ints(0xfe).Bytes())
+	f.Add(newXDRStream(2).ints(0x20d, 2, 1, NaInteger, 0x402).symbol("names").ints(0x10, 2).charsxp("a").charsxp("b").ints(0xfe).Bytes())
+	f.Add(newXDRStream(2).ints(0x13, 3, 0xa, 1, 1, 0x18, 1).WriteByteAndReturn(0xff).ints(0xf, 1).reals(0, 2).Bytes())
+	f.Add(newXDRStream(2).ints(0x403, 4, 0, 0xfd, 0x402).symbol("a").ints(0xe, 1).reals(1).ints(0xfe, 0xfe, 0xfe).
+		ints(0x402).symbol("x").ints(0xfb, 0xfe).ints(6).symbol("+").ints(2, 0x3ff, 2).ints(0x2ff).ints(0xfe).Bytes())
+	f.Add([]byte("A\n3\n263682\n197888\n5\nUTF-8\n526\n2\n1.5\nNA\n1026\n1\n262153\n5\nnames\n16\n2\n262153\n1\na\n262153\n2\nb\\n\n254\n"))
+	f.Fuzz(func(e *testing.T, stream []byte) {
+		v, _, err := Unserialize(bytes.NewReader(stream))
+		if (err == nil) == (v == nil) {
+			e.Fatalf("value %v and error %v for %q", v, err, stream)
+		}
+	})
+}
+
+func TestLanguageExpr(e *testing.T) {
+	var tests = []struct {
+		stream *rdsStream
+		src    string
+	}{
+		// f(x, y = 1)
+		{ newXDRStream(2).ints(6).symbol("f").ints(2).symbol("x").ints(0x402).symbol("y").ints(0xe, 1).reals(1).ints(0xfe),
+			"f(x, y = 1)" },
+		// x[[1L]] <- -2
+		{ newXDRStream(2).ints(6).symbol("<-").ints(2, 6).symbol("[[").ints(2).symbol("x").ints(2, 0xd, 1, 1, 0xfe).
+			ints(2, 0xe, 1).reals(-2).ints(0xfe),
+			"x[[1L]] <- -2" },
+		// if (a) { b } else (c)
+		{ newXDRStream(2).ints(6).symbol("if").ints(2).symbol("a").ints(2, 6).symbol("{").ints(2).symbol("b").ints(0xfe).
+			ints(2, 6).symbol("(").ints(2).symbol("c").ints(0xfe, 0xfe),
+			"if (a) { b } else (c)" },
+		// function(x, n = 2L) x %in% n
+		{ newXDRStream(2).ints(6).symbol("function").ints(2, 0x402).symbol("x").ints(0xfb, 0x402).symbol("n").ints(0xd, 1, 2, 0xfe).
+			ints(2, 6).symbol("%in%").ints(2, 0x2ff, 2).ints(0x3ff, 0xfe).ints(2, 0xfe, 0xfe),
+			"function(x, n = 2L) x %in% n" },
+	}
+
+	for i, test := range tests {
+		v, _, err := Unserialize(bytes.NewReader(test.stream.Bytes()))
+		if err != nil {
+			e.Error("Test LanguageExpr[", i, "] Failed with error", err)
+			continue
+		}
+		x, err := ValueExpr(v)
+		if err != nil {
+			e.Error("Test LanguageExpr[", i, "] Failed with error", err)
+			continue
+		}
+		expected, _ := ParseExpr(test.src)
+		if lisp(x) != lisp(expected) {
+			e.Error("Test LanguageExpr[", i, "] Failed: expected", lisp(expected), "but got", lisp(x))
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package r
+
+import "io"
+import "io/ioutil"
+import "regexp"
+import "strings"
+import "unicode/utf8"
+
+// RmdDocument is an R Markdown (.Rmd) or Quarto (.qmd) document split in Markdown text and code chunks.
+type RmdDocument struct {
+	// YAML front matter without the --- lines, "" if there is none
+	FrontMatter string
+	// Markdown text between the chunks
+	Text []*RmdText
+	// Code chunks in document order
+	Chunks []*RmdChunk
+	// Inline `r expr` spans of all the text parts
+	Inlines []*RmdInline
+}
+
+// RmdText is a run of Markdown lines.
+type RmdText struct {
+	Text string
+	Pos  Position
+	// Inline `r expr` spans of the text
+	Inlines []*RmdInline
+}
+
+// RmdChunk is a ```{engine label, option = value} code chunk.
+type RmdChunk struct {
+	// Engine in lower case: r, python, sql...
+	Engine string
+	// Label of the chunk, "" if the chunk has none
+	Label string
+	// Options of the chunk header followed by the Quarto #| options
+	Options []*RmdOption
+	// Position of the opening fence
+	Pos Position
+	// Code of the chunk without the fences and its position in the document
+	Code    string
+	CodePos Position
+	// Syntax tree of the code of an R chunk, with positions in the document
+	File *File
+	// Parse error of the code of an R chunk
+	Err error
+}
+
+// RmdOption is a chunk option: echo = FALSE in the header or #| echo: false in a Quarto chunk.
+type RmdOption struct {
+	Name string
+	// Source text of the value
+	Value string
+	// Parsed value of a header option, nil for a #| option
+	Expr Expr
+	// The option comes from a #| comment
+	Quarto bool
+	Pos    Position
+}
+
+// RmdInline is an inline `r expr` (or Quarto `{r} expr`) code span.
+type RmdInline struct {
+	Code string
+	// Position of the code in the document
+	Pos  Position
+	Expr Expr
+	Err  error
+}
+
+var rmdChunkStart = regexp.MustCompile("^([ \t]*)(`{3,})[ \t]*\\{([A-Za-z0-9_]+)(.*)\\}[ \t]*$")
+var rmdInline = regexp.MustCompile("`(r|\\{r\\})[ \t]+([^`]+)`")
+var rmdQuartoOption = regexp.MustCompile(`^[ \t]*#\|[ \t]?(.*)$`)
+
+// rmdLine is a line of a document with the position of its first byte.
+type rmdLine struct {
+	text string
+	pos  Position
+}
+
+// Option returns the value of the option name, a #| option overrides the header.
+func (this *RmdChunk) Option(name string) (o *RmdOption) {
+	for _, opt := range this.Options {
+		if opt.Name == name {
+			o = opt
+		}
+	}
+	return
+}
+
+// rmdLines splits src in lines without the newline characters.
+func rmdLines(src string) (lines []rmdLine) {
+	offset := 0
+	for n := 1; offset < len(src); n++ {
+		end := strings.IndexByte(src[offset:], '\n')
+		if end < 0 {
+			end = len(src) - offset
+		}
+		lines = append(lines, rmdLine{ src[offset:offset+end], Position{ offset, n, 1 } })
+		offset += end + 1
+	}
+	return
+}
+
+// ParseRmd reads an R Markdown or a Quarto document, the R chunks and the inline R code are parsed with the
+// positions of the document: a parse error in a chunk is given by RmdChunk.Err and does not stop the reading.
+func ParseRmd(r io.Reader) (doc *RmdDocument, err error) {
+	var b []byte
+
+	if b, err = ioutil.ReadAll(r); err != nil {
+		return
+	}
+	src := string(b)
+	lines := rmdLines(src)
+	doc = new(RmdDocument)
+
+	i := 0
+	// YAML front matter
+	if len(lines) > 0 && strings.TrimRight(lines[0].text, " \t") == "---" {
+		for j := 1; j < len(lines); j++ {
+			if t := strings.TrimRight(lines[j].text, " \t"); t == "---" || t == "..." {
+				doc.FrontMatter = src[lines[1].pos.Offset:lines[j].pos.Offset]
+				i = j + 1
+				break
+			}
+		}
+	}
+
+	var text *RmdText
+	textEnd := 0
+	flushText := func() {
+		if text != nil {
+			text.Text = src[text.Pos.Offset:textEnd]
+			doc.addInlines(text)
+			doc.Text = append(doc.Text, text)
+			text = nil
+		}
+	}
+
+	for ; i < len(lines); i++ {
+		l := lines[i]
+		m := rmdChunkStart.FindStringSubmatch(l.text)
+		if m == nil {
+			if text == nil {
+				text = &RmdText{ Pos: l.pos }
+			}
+			textEnd = len(src)
+			if i+1 < len(lines) {
+				textEnd = lines[i+1].pos.Offset
+			}
+			continue
+		}
+		flushText()
+
+		c := &RmdChunk{ Engine: strings.ToLower(m[3]), Pos: l.pos }
+		header := m[4]
+		at := len(l.text) - len(header) - len(l.text[strings.LastIndexByte(l.text, '}'):])
+		c.parseHeader(header, Position{ l.pos.Offset + at, l.pos.Line, utf8.RuneCountInString(l.text[:at]) + 1 })
+
+		// The chunk ends with a fence of at least the same length, or at the end of the document
+		fence := m[2]
+		j := i + 1
+		for ; j < len(lines); j++ {
+			t := strings.TrimSpace(lines[j].text)
+			if strings.HasPrefix(t, fence) && strings.Trim(t, "`") == "" {
+				break
+			}
+		}
+		if i+1 < len(lines) {
+			c.CodePos = lines[i+1].pos
+			end := len(src)
+			if j < len(lines) {
+				end = lines[j].pos.Offset
+			}
+			c.Code = src[c.CodePos.Offset:end]
+			c.parseQuartoOptions(lines[i+1:j])
+		}
+		if c.Engine == "r" && c.CodePos.Line > 0 {
+			c.File, c.Err = newParserAt(strings.NewReader(c.Code), c.CodePos).Parse()
+		}
+		doc.Chunks = append(doc.Chunks, c)
+		i = j
+	}
+	flushText()
+	return
+}
+
+// parseHeader reads the label and the options of the chunk header: label, name = value, ...
+// The label may be a bare word like my-label that is not an R expression.
+func (this *RmdChunk) parseHeader(header string, pos Position) {
+	var tokens []*Token
+
+	s := newParserAt(strings.NewReader(header), pos).scanner
+	for t := s.NextToken(); t.Type != END_OF_INPUT; t = s.NextToken() {
+		if t.Type != COMMENT {
+			tokens = append(tokens, t)
+		}
+	}
+
+	// Split at the commas outside of parentheses
+	var segments [][]*Token
+	var current []*Token
+	depth := 0
+	for _, t := range tokens {
+		switch t.Type {
+		case OP_LEFT_ROUND, OP_LEFT_SQUARE, OP_LEFT_SQUARE2, OP_LEFT_CURLY :
+			depth++
+		case OP_RIGHT_ROUND, OP_RIGHT_SQUARE, OP_RIGHT_CURLY :
+			depth--
+		case OP_COMMA :
+			if depth == 0 {
+				segments = append(segments, current)
+				current = nil
+				continue
+			}
+		}
+		current = append(current, t)
+	}
+	segments = append(segments, current)
+
+	text := func(tokens []*Token) string {
+		return header[tokens[0].offset-pos.Offset:tokens[len(tokens)-1].End()-pos.Offset]
+	}
+	for n, seg := range segments {
+		if len(seg) == 0 {
+			continue
+		}
+		if len(seg) >= 2 && (seg[0].Type == SYMBOL || seg[0].Type == CONST_CHARACTER) && seg[1].Type == OP_EQUAL_ASSIGN {
+			o := &RmdOption{ Name: seg[0].stringvalue, Pos: seg[0].Pos() }
+			if len(seg) > 2 {
+				o.Value = text(seg[2:])
+				valuePos := seg[2].Pos()
+				if f, err := newParserAt(strings.NewReader(o.Value), valuePos).Parse(); err == nil && len(f.Exprs) == 1 {
+					o.Expr = f.Exprs[0]
+				}
+			}
+			if o.Name == "label" {
+				if c, ok := o.Expr.(*Constant); ok && c.Token.Type == CONST_CHARACTER {
+					this.Label = c.Token.stringvalue
+				}
+			}
+			this.Options = append(this.Options, o)
+		} else if n == 0 {
+			// Unquoted label
+			this.Label = text(seg)
+			if seg[0].Type == CONST_CHARACTER && len(seg) == 1 {
+				this.Label = seg[0].stringvalue
+			}
+		}
+	}
+}
+
+// parseQuartoOptions reads the #| name: value lines at the beginning of the chunk code.
+func (this *RmdChunk) parseQuartoOptions(lines []rmdLine) {
+	var last *RmdOption
+	for _, l := range lines {
+		m := rmdQuartoOption.FindStringSubmatch(l.text)
+		if m == nil {
+			return
+		}
+		at := len(l.text) - len(m[1])
+		pos := Position{ l.pos.Offset + at, l.pos.Line, utf8.RuneCountInString(l.text[:at]) + 1 }
+		if i := strings.IndexByte(m[1], ':'); i > 0 && !strings.HasPrefix(m[1], " ") {
+			last = &RmdOption{ Name: strings.TrimSpace(m[1][:i]), Value: strings.TrimSpace(m[1][i+1:]), Quarto: true, Pos: pos }
+			if this.Label == "" && last.Name == "label" {
+				this.Label = last.Value
+			}
+			this.Options = append(this.Options, last)
+		} else if last != nil {
+			// YAML continuation line
+			last.Value = strings.TrimSpace(last.Value + "\n" + strings.TrimSpace(m[1]))
+		}
+	}
+}
+
+// addInlines parses the inline R code spans of text.
+func (this *RmdDocument) addInlines(text *RmdText) {
+	for _, m := range rmdInline.FindAllStringSubmatchIndex(text.Text, -1) {
+		in := &RmdInline{ Code: text.Text[m[4]:m[5]] }
+		// Line and column of the code
+		before := text.Text[:m[4]]
+		line := text.Pos.Line + strings.Count(before, "\n")
+		column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+		in.Pos = Position{ text.Pos.Offset + m[4], line, column }
+		var f *File
+		if f, in.Err = newParserAt(strings.NewReader(in.Code), in.Pos).Parse(); in.Err == nil && len(f.Exprs) > 0 {
+			in.Expr = f.Exprs[0]
+		}
+		text.Inlines = append(text.Inlines, in)
+		this.Inlines = append(this.Inlines, in)
+	}
+}
@@ -0,0 +1,80 @@
+package r
+
+import "testing"
+import "strings"
+
+var rmdSample = "---\ntitle: \"Report\"\n---\n\nWe have `r nrow(df)` rows, é `r mean(x)`.\n\n```{r setup, include=FALSE, fig.cap = \"A, b\"}\nlibrary(dplyr)\n```\n\nText\n\n```{r my-chunk}\n#| echo: false\n#| fig-cap:\n#|   Long caption\nx <- 1 +\n  2\n```\n\n```{python}\nprint(1)\n```\n\n```{r bad}\nx <- (\n```\n"
+
+func TestParseRmd(e *testing.T) {
+	doc, err := ParseRmd(strings.NewReader(rmdSample))
+	if err != nil {
+		e.Fatal(err)
+	}
+	if doc.FrontMatter != "title: \"Report\"\n" {
+		e.Errorf("Test ParseRmd front matter Failed: %q", doc.FrontMatter)
+	}
+	if len(doc.Chunks) != 4 || len(doc.Text) != 4 || len(doc.Inlines) != 2 {
+		e.Fatal("Test ParseRmd Failed:", len(doc.Chunks), "chunks", len(doc.Text), "texts", len(doc.Inlines), "inlines")
+	}
+
+	// Inline code
+	var inlines = []struct {
+		code string
+		line, column, offset int
+		fun string
+	}{
+		{ "nrow(df)", 5, 12, 36, "nrow" },
+		{ "mean(x)", 5, 33, 58, "mean" },
+	}
+	for i, test := range inlines {
+		in := doc.Inlines[i]
+		if in.Code != test.code || in.Pos != (Position{ test.offset, test.line, test.column }) || in.Err != nil {
+			e.Error("Test ParseRmd inline[", i, "] Failed:", in.Code, in.Pos, in.Err)
+			continue
+		}
+		if c, ok := in.Expr.(*CallExpr); !ok || c.FunctionName() != test.fun || c.Pos() != in.Pos {
+			e.Error("Test ParseRmd inline[", i, "] Failed: expression", in.Expr)
+		}
+	}
+
+	// Chunk headers and options
+	var chunks = []struct {
+		engine, label string
+		options []string
+		line int
+		err bool
+	}{
+		{ "r", "setup", []string{ "include=FALSE", "fig.cap=\"A, b\"" }, 8, false },
+		{ "r", "my-chunk", []string{ "echo=false", "fig-cap=Long caption" }, 14, false },
+		{ "python", "", nil, 22, false },
+		{ "r", "bad", nil, 26, true },
+	}
+	for i, test := range chunks {
+		c := doc.Chunks[i]
+		var options []string
+		for _, o := range c.Options {
+			options = append(options, o.Name + "=" + o.Value)
+		}
+		if c.Engine != test.engine || c.Label != test.label || strings.Join(options, "|") != strings.Join(test.options, "|") {
+			e.Error("Test ParseRmd chunk[", i, "] Failed:", c.Engine, c.Label, options)
+		}
+		if c.CodePos.Line != test.line || (c.Err != nil) != test.err {
+			e.Error("Test ParseRmd chunk[", i, "] Failed: line", c.CodePos.Line, "error", c.Err)
+		}
+	}
+	if o := doc.Chunks[0].Option("include"); o == nil || o.Expr == nil || o.Pos != (Position{ 82, 7, 14 }) {
+		e.Error("Test ParseRmd option Failed:", o)
+	}
+
+	// Positions of the R code are positions in the document
+	x := doc.Chunks[1].File.Exprs[0].(*BinaryExpr)
+	if x.Pos() != (Position{ 202, 17, 1 }) || x.Y.(*BinaryExpr).Y.Pos() != (Position{ 213, 18, 3 }) {
+		e.Error("Test ParseRmd chunk positions Failed:", x.Pos(), x.Y.(*BinaryExpr).Y.Pos())
+	}
+	if p := doc.Chunks[3].Err.(*ParseError).Pos; p.Line != 27 {
+		e.Error("Test ParseRmd chunk error Failed:", doc.Chunks[3].Err)
+	}
+	if doc.Text[1].Text != "\nText\n\n" || doc.Text[1].Pos.Line != 10 {
+		e.Errorf("Test ParseRmd text Failed: %q %v", doc.Text[1].Text, doc.Text[1].Pos)
+	}
+}
@@ -0,0 +1,241 @@
+package r
+
+import "io"
+import "io/ioutil"
+import "bytes"
+import "fmt"
+import "regexp"
+import "strings"
+import "unicode/utf8"
+
+// RnwDocument is a Sweave or knitr .Rnw document: LaTeX text with <<label, options>>= ... @ code chunks.
+type RnwDocument struct {
+	// LaTeX text between the chunks
+	Text []*RmdText
+	// Code chunks in document order, the engine is always r
+	Chunks []*RmdChunk
+	// \Sexpr{expr} inline code of all the text parts
+	Inlines []*RmdInline
+}
+
+var rnwChunkStart = regexp.MustCompile(`^[ \t]*<<(.*)>>=[ \t]*$`)
+var rnwChunkEnd = regexp.MustCompile(`^@([ \t].*)?$`)
+var rnwReference = regexp.MustCompile(`^[ \t]*<<(.*)>>[ \t]*$`)
+
+// ParseRnw reads an .Rnw document, the code of the chunks is parsed with the positions of the document:
+// a parse error in a chunk is given by RmdChunk.Err and does not stop the reading.
+func ParseRnw(r io.Reader) (doc *RnwDocument, err error) {
+	var b []byte
+
+	if b, err = ioutil.ReadAll(r); err != nil {
+		return
+	}
+	src := string(b)
+	lines := rmdLines(src)
+	doc = new(RnwDocument)
+
+	var text *RmdText
+	textEnd := 0
+	flushText := func() {
+		if text != nil {
+			text.Text = src[text.Pos.Offset:textEnd]
+			doc.addSexprs(text)
+			doc.Text = append(doc.Text, text)
+			text = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		m := rnwChunkStart.FindStringSubmatch(l.text)
+		if m == nil {
+			// A @ outside of a chunk is part of the text
+			if text == nil {
+				text = &RmdText{ Pos: l.pos }
+			}
+			textEnd = len(src)
+			if i+1 < len(lines) {
+				textEnd = lines[i+1].pos.Offset
+			}
+			continue
+		}
+		flushText()
+
+		c := &RmdChunk{ Engine: "r", Pos: l.pos }
+		at := strings.Index(l.text, "<<") + 2
+		c.parseHeader(m[1], Position{ l.pos.Offset + at, l.pos.Line, utf8.RuneCountInString(l.text[:at]) + 1 })
+
+		// The chunk ends with a @ line, at the start of the next chunk or at the end of the document
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if rnwChunkEnd.MatchString(lines[j].text) || rnwChunkStart.MatchString(lines[j].text) {
+				break
+			}
+		}
+		if i+1 < len(lines) {
+			c.CodePos = lines[i+1].pos
+			end := len(src)
+			if j < len(lines) {
+				end = lines[j].pos.Offset
+			}
+			c.Code = src[c.CodePos.Offset:end]
+			c.File, c.Err = newParserAt(strings.NewReader(rnwStripReferences(c.Code)), c.CodePos).Parse()
+		}
+		doc.Chunks = append(doc.Chunks, c)
+		i = j
+		if j < len(lines) && !rnwChunkEnd.MatchString(lines[j].text) {
+			i--
+		}
+	}
+	flushText()
+	return
+}
+
+// rnwStripReferences turns the <<label>> chunk references into comments, keeping the positions of the code.
+func rnwStripReferences(code string) string {
+	lines := strings.SplitAfter(code, "\n")
+	for i, l := range lines {
+		if rnwReference.MatchString(strings.TrimRight(l, "\n")) {
+			lines[i] = "#" + l[1:]
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// addSexprs parses the \Sexpr{expr} of text, the braces of expr must be balanced.
+func (this *RnwDocument) addSexprs(text *RmdText) {
+	s := text.Text
+	for from := 0; ; {
+		i := strings.Index(s[from:], "\\Sexpr{")
+		if i < 0 {
+			return
+		}
+		start := from + i + len("\\Sexpr{")
+		end, depth := start, 1
+		for ; end < len(s) && depth > 0; end++ {
+			switch s[end] {
+			case '{' :
+				depth++
+			case '}' :
+				depth--
+			}
+		}
+		if depth > 0 {
+			return
+		}
+		in := &RmdInline{ Code: s[start:end-1] }
+		before := s[:start]
+		line := text.Pos.Line + strings.Count(before, "\n")
+		column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+		in.Pos = Position{ text.Pos.Offset + start, line, column }
+		var f *File
+		if f, in.Err = newParserAt(strings.NewReader(in.Code), in.Pos).Parse(); in.Err == nil && len(f.Exprs) > 0 {
+			in.Expr = f.Exprs[0]
+		}
+		text.Inlines = append(text.Inlines, in)
+		this.Inlines = append(this.Inlines, in)
+		from = end
+	}
+}
+
+// rnwFalse reports whether the option of the chunk is FALSE, false or F.
+func rnwFalse(c *RmdChunk, name string) bool {
+	o := c.Option(name)
+	if o == nil {
+		return false
+	}
+	switch v := strings.TrimSpace(o.Value); v {
+	case "FALSE", "false", "F" :
+		return true
+	}
+	return false
+}
+
+// Source returns the R code of the chunks, each chunk is preceded by a #line directive giving its position in
+// the file, so that the tokens of the returned code have the line numbers of the .Rnw document. The <<label>>
+// chunk references are commented out.
+func (this *RnwDocument) Source(file string) string {
+	var buf bytes.Buffer
+
+	for _, c := range this.Chunks {
+		if c.CodePos.Line == 0 || c.Code == "" {
+			continue
+		}
+		fmt.Fprintf(&buf, "#line %d \"%s\"\n", c.CodePos.Line, file)
+		buf.WriteString(rnwStripReferences(c.Code))
+		if !strings.HasSuffix(c.Code, "\n") {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+// Tangle returns the R code of the document as Stangle writes it: a header per chunk, the <<label>> references
+// are replaced by the code of the referenced chunks and the code of the eval = FALSE chunks is commented out.
+func (this *RnwDocument) Tangle(file string) string {
+	var buf bytes.Buffer
+
+	labels := make(map[string]*RmdChunk)
+	for _, c := range this.Chunks {
+		if c.Label != "" {
+			labels[c.Label] = c
+		}
+	}
+
+	fmt.Fprintf(&buf, "### R code from vignette source '%s'\n", file)
+	for n, c := range this.Chunks {
+		label := c.Label
+		if label == "" {
+			last := c.CodePos.Line + strings.Count(strings.TrimSuffix(c.Code, "\n"), "\n")
+			label = fmt.Sprintf("%s:%d-%d", file, c.CodePos.Line, last)
+		}
+		buf.WriteString("\n###################################################\n")
+		fmt.Fprintf(&buf, "### code chunk number %d: %s\n", n+1, label)
+		buf.WriteString("###################################################\n")
+
+		code := rnwExpand(c.Code, labels, map[string]bool{ c.Label: true })
+		if rnwFalse(c, "eval") {
+			lines := strings.SplitAfter(code, "\n")
+			for i, l := range lines {
+				if l != "" {
+					lines[i] = "## " + l
+				}
+			}
+			code = strings.Join(lines, "")
+		}
+		buf.WriteString(code)
+		if code != "" && !strings.HasSuffix(code, "\n") {
+			buf.WriteByte('\n')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// rnwExpand replaces the <<label>> lines of code by the code of the chunks, the chunks being expanded are
+// given by active to stop on recursive references.
+func rnwExpand(code string, labels map[string]*RmdChunk, active map[string]bool) string {
+	var buf bytes.Buffer
+
+	for _, l := range strings.SplitAfter(code, "\n") {
+		m := rnwReference.FindStringSubmatch(strings.TrimRight(l, "\n"))
+		if m == nil {
+			buf.WriteString(l)
+			continue
+		}
+		label := strings.TrimSpace(m[1])
+		c := labels[label]
+		if c == nil || active[label] {
+			buf.WriteString(l)
+			continue
+		}
+		active[label] = true
+		expanded := rnwExpand(c.Code, labels, active)
+		delete(active, label)
+		buf.WriteString(expanded)
+		if expanded != "" && !strings.HasSuffix(expanded, "\n") {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,127 @@
+package r
+
+import "testing"
+import "strings"
+import "fmt"
+
+var rnwSample = `\documentclass{article}
+\begin{document}
+The mean is \Sexpr{round(mean(x), 2)}.
+
+<<setup, echo=FALSE>>=
+x <- c(1, 2, 3)
+@
+
+<<>>=
+y <- x +
+  1
+<<setup>>
+@ some text
+
+<<skip, eval=FALSE>>=
+stop("not run")
+@
+\end{document}
+`
+
+func TestParseRnw(e *testing.T) {
+	doc, err := ParseRnw(strings.NewReader(rnwSample))
+	if err != nil {
+		e.Fatal(err)
+	}
+	if len(doc.Chunks) != 3 || len(doc.Inlines) != 1 || len(doc.Text) != 4 {
+		e.Fatal("Test ParseRnw Failed:", len(doc.Chunks), "chunks", len(doc.Inlines), "inlines", len(doc.Text), "texts")
+	}
+
+	var chunks = []struct {
+		label string
+		line  int
+		exprs int
+	}{
+		{ "setup", 6, 1 },
+		{ "", 10, 1 },
+		{ "skip", 16, 1 },
+	}
+	for i, test := range chunks {
+		c := doc.Chunks[i]
+		if c.Label != test.label || c.CodePos.Line != test.line || c.Err != nil || len(c.File.Exprs) != test.exprs {
+			e.Error("Test ParseRnw chunk[", i, "] Failed:", c.Label, c.CodePos, c.Err)
+		}
+	}
+	if o := doc.Chunks[0].Option("echo"); o == nil || o.Value != "FALSE" || o.Pos != (Position{ 90, 5, 10 }) {
+		e.Error("Test ParseRnw option Failed:", o)
+	}
+	if in := doc.Inlines[0]; in.Code != "round(mean(x), 2)" || in.Pos != (Position{ 60, 3, 20 }) || in.Expr == nil {
+		e.Error("Test ParseRnw Sexpr Failed:", in.Code, in.Pos)
+	}
+	if y := doc.Chunks[1].File.Exprs[0].(*BinaryExpr).Y.(*BinaryExpr).Y; y.Pos().Line != 11 || y.Pos().Column != 3 {
+		e.Error("Test ParseRnw positions Failed:", y.Pos())
+	}
+}
+
+func TestRnwSource(e *testing.T) {
+	doc, _ := ParseRnw(strings.NewReader(rnwSample))
+	src := doc.Source("doc.Rnw")
+	if !strings.HasPrefix(src, "#line 6 \"doc.Rnw\"\nx <- c(1, 2, 3)\n#line 10 \"doc.Rnw\"\n") {
+		e.Errorf("Test RnwSource Failed: %q", src)
+	}
+
+	// The #line directives map the positions of the tokens back to the .Rnw file
+	s := NewScanner(strings.NewReader(src))
+	var lines []int
+	for t := s.NextToken(); t.Type != END_OF_INPUT; t = s.NextToken() {
+		if t.Type == SYMBOL {
+			lines = append(lines, t.Pos().Line)
+		}
+	}
+	if fmt.Sprint(lines) != "[6 6 10 10 16]" {
+		e.Error("Test RnwSource Failed: symbol lines", lines)
+	}
+	if s.Filename() != "doc.Rnw" {
+		e.Error("Test RnwSource Failed: file name", s.Filename())
+	}
+
+	// The code is R code, the <<setup>> reference is a comment
+	f, err := ParseFile(strings.NewReader(src))
+	if err != nil || len(f.Exprs) != 3 {
+		e.Fatal("Test RnwSource Failed: parse", err)
+	}
+	var comments []int
+	for _, c := range f.Comments {
+		if c.Type == COMMENT {
+			comments = append(comments, c.Pos().Line)
+		}
+	}
+	if fmt.Sprint(comments) != "[12]" {
+		e.Error("Test RnwSource Failed: reference", comments)
+	}
+}
+
+func TestRnwTangle(e *testing.T) {
+	doc, _ := ParseRnw(strings.NewReader(rnwSample))
+	expected := `### R code from vignette source 'doc.Rnw'
+
+###################################################
+### code chunk number 1: setup
+###################################################
+x <- c(1, 2, 3)
+
+
+###################################################
+### code chunk number 2: doc.Rnw:10-12
+###################################################
+y <- x +
+  1
+x <- c(1, 2, 3)
+
+
+###################################################
+### code chunk number 3: skip
+###################################################
+## stop("not run")
+
+`
+	if tangled := doc.Tangle("doc.Rnw"); tangled != expected {
+		e.Errorf("Test RnwTangle Failed:\n%s", tangled)
+	}
+}
@@ -0,0 +1,701 @@
+package r
+
+import "io"
+import "io/ioutil"
+import "bytes"
+import "fmt"
+import "regexp"
+import "sort"
+import "strings"
+
+// Kinds of the objects documented by a roxygen block.
+const (
+	ROXYGEN_FUNCTION = "function" // name <- function(...)
+	ROXYGEN_S3METHOD = "s3method" // generic.class <- function(...) of a known generic
+	ROXYGEN_DATA     = "data"     // name <- value or "name"
+	ROXYGEN_PACKAGE  = "package"  // "_PACKAGE"
+	ROXYGEN_NULL     = "null"     // NULL, the topic is given by @name
+)
+
+// RoxygenTag is a @tag of a roxygen block, the introduction paragraphs are given as title, description and details tags.
+type RoxygenTag struct {
+	Name  string
+	Value string
+	// Position of the #' line of the tag
+	Pos Position
+}
+
+// RoxygenObject is the R object documented by a roxygen block.
+type RoxygenObject struct {
+	Name string
+	// ROXYGEN_FUNCTION, ROXYGEN_S3METHOD, ROXYGEN_DATA, ROXYGEN_PACKAGE or ROXYGEN_NULL
+	Kind string
+	// Generic and class of a S3 method
+	Generic string
+	Class   string
+	// Usage of a function: name(x, y = 2)
+	Usage string
+	// Names of the formals of a function
+	Formals []string
+	// The documented expression
+	Expr Expr
+}
+
+// RoxygenBlock is a block of #' comments and the object that follows it.
+type RoxygenBlock struct {
+	File   string
+	Tags   []RoxygenTag
+	Object *RoxygenObject
+	// Position of the first #' line
+	Pos Position
+}
+
+// roxygenGenerics are the generics of the base packages used to recognize the S3 methods.
+var roxygenGenerics = map[string]bool{
+	"print": true, "format": true, "summary": true, "plot": true, "as.character": true, "as.list": true,
+	"as.data.frame": true, "as.vector": true, "as.numeric": true, "as.double": true, "as.integer": true,
+	"as.logical": true, "toString": true, "length": true, "names": true, "levels": true, "unique": true,
+	"rev": true, "sort": true, "mean": true, "median": true, "quantile": true, "head": true, "tail": true,
+	"c": true, "t": true, "dim": true, "merge": true, "split": true, "subset": true, "transform": true,
+	"with": true, "within": true, "update": true, "predict": true, "fitted": true, "residuals": true,
+	"coef": true, "anova": true, "logLik": true, "AIC": true, "all.equal": true, "str": true, "seq": true,
+	"rep": true, "Ops": true, "Math": true, "Summary": true, "$": true, "[": true, "[[": true, "$<-": true,
+	"[<-": true, "[[<-": true, "==": true, "+": true, "-": true, "*": true, "/": true,
+}
+
+// Tags whose value is kept verbatim, including the newlines.
+var roxygenVerbatimTags = map[string]bool{ "examples": true, "usage": true, "format": true }
+
+// ParseRoxygen returns the roxygen blocks of the R source file read from r with the objects they document.
+func ParseRoxygen(file string, r io.Reader) (blocks []*RoxygenBlock, err error) {
+	var src []byte
+	var f *File
+
+	if src, err = ioutil.ReadAll(r); err != nil {
+		return
+	}
+	if f, err = ParseFile(bytes.NewReader(src)); err != nil {
+		return
+	}
+
+	// Generics defined in the file with UseMethod()
+	generics := make(map[string]bool)
+	for _, x := range f.Exprs {
+		if name, fn := assignedFunction(x); fn != nil {
+			Inspect(fn.Body, func(n Node) bool {
+				if c, ok := n.(*CallExpr); ok && c.FunctionName() == "UseMethod" {
+					generics[name] = true
+				}
+				return true
+			})
+		}
+	}
+
+	// Group the consecutive #' lines
+	var current *RoxygenBlock
+	var lines []*Token
+	last := 0
+	flush := func() {
+		if current != nil {
+			current.Tags = parseRoxygenLines(lines)
+			blocks = append(blocks, current)
+		}
+		current = nil
+		lines = nil
+	}
+	for _, c := range f.Comments {
+		if !strings.HasPrefix(c.stringvalue, "#'") {
+			continue
+		}
+		if current == nil || c.nline != last+1 {
+			flush()
+			current = &RoxygenBlock{ File: file, Pos: c.Pos() }
+		}
+		lines = append(lines, c)
+		last = c.nline
+	}
+	flush()
+
+	// Associate each block with the first expression after it, if there is no other block in between
+	for i, b := range blocks {
+		limit := -1
+		if i+1 < len(blocks) {
+			limit = blocks[i+1].Pos.Line
+		}
+		for _, x := range f.Exprs {
+			if x.Pos().Line > b.Pos.Line && (limit < 0 || x.Pos().Line < limit) {
+				b.Object = roxygenObject(x, src, generics)
+				break
+			}
+		}
+	}
+	return
+}
+
+// assignedFunction returns the name and the function of name <- function(...) or name = function(...).
+func assignedFunction(x Expr) (string, *FunctionExpr) {
+	if b, ok := x.(*BinaryExpr); ok {
+		switch b.Op.Type {
+		case OP_LEFT_ASSIGN, OP_LEFT_ASSIGN2, OP_EQUAL_ASSIGN :
+			if f, ok := b.Y.(*FunctionExpr); ok {
+				if name, ok := assignedName(b.X); ok {
+					return name, f
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// assignedName returns the name of the target of an assignment: name, `name` or "name".
+func assignedName(x Expr) (string, bool) {
+	switch n := x.(type) {
+	case *Ident :
+		return n.Name(), true
+	case *Constant :
+		if n.Token.Type == CONST_CHARACTER {
+			return n.Token.stringvalue, true
+		}
+	}
+	return "", false
+}
+
+// roxygenObject returns the object documented by the top level expression x.
+func roxygenObject(x Expr, src []byte, generics map[string]bool) (o *RoxygenObject) {
+	o = &RoxygenObject{ Expr: x }
+
+	if name, fn := assignedFunction(x); fn != nil {
+		o.Name = name
+		o.Kind = ROXYGEN_FUNCTION
+		var args []string
+		for _, f := range fn.Formals {
+			o.Formals = append(o.Formals, f.Name.stringvalue)
+			a := f.Name.stringvalue
+			if f.Default != nil {
+				a += " = " + string(src[f.Default.Pos().Offset:f.Default.End()])
+			}
+			args = append(args, a)
+		}
+		// The shortest known generic wins: as.data.frame.foo is a method of as.data.frame, print.foo.bar of print
+		for i := 1; i < len(name)-1; i++ {
+			if name[i] == '.' && (roxygenGenerics[name[:i]] || generics[name[:i]]) {
+				o.Kind = ROXYGEN_S3METHOD
+				o.Generic = name[:i]
+				o.Class = name[i+1:]
+				break
+			}
+		}
+		if o.Kind == ROXYGEN_S3METHOD {
+			o.Usage = "\\method{" + o.Generic + "}{" + o.Class + "}(" + strings.Join(args, ", ") + ")"
+		} else {
+			o.Usage = rdName(name) + "(" + strings.Join(args, ", ") + ")"
+		}
+		return
+	}
+
+	switch n := x.(type) {
+	case *BinaryExpr :
+		if name, ok := assignedName(n.X); ok && (n.Op.Type == OP_LEFT_ASSIGN || n.Op.Type == OP_EQUAL_ASSIGN) {
+			o.Name = name
+			o.Kind = ROXYGEN_DATA
+			o.Usage = rdName(name)
+			return
+		}
+	case *Constant :
+		if n.Token.Type == CONST_CHARACTER {
+			if n.Token.stringvalue == "_PACKAGE" {
+				o.Kind = ROXYGEN_PACKAGE
+			} else {
+				o.Name = n.Token.stringvalue
+				o.Kind = ROXYGEN_DATA
+				o.Usage = rdName(o.Name)
+			}
+			return
+		}
+		if n.Token.Type == CONST_NULL {
+			o.Kind = ROXYGEN_NULL
+			return
+		}
+	}
+	return nil
+}
+
+// isSyntacticName reports whether name can be used without backquotes.
+func isSyntacticName(name string) bool {
+	return IsSyntacticName(name, LOCALE_UTF8)
+}
+
+// rdName returns the name of a function as written in an Rd usage.
+func rdName(name string) string {
+	if isSyntacticName(name) {
+		return name
+	}
+	return "`" + name + "`"
+}
+
+// parseRoxygenLines splits the #' lines of a block in tags.
+func parseRoxygenLines(lines []*Token) (tags []RoxygenTag) {
+	var intro []string
+	var introPos Position
+	var current *RoxygenTag
+
+	for _, l := range lines {
+		text := strings.TrimPrefix(l.stringvalue, "#'")
+		text = strings.TrimPrefix(text, " ")
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(trimmed, "@") && len(trimmed) > 1 && trimmed[1] != '@' {
+			// New tag
+			if current != nil {
+				tags = append(tags, *current)
+			}
+			name := trimmed[1:]
+			value := ""
+			if i := strings.IndexAny(name, " \t"); i >= 0 {
+				name, value = name[:i], strings.TrimSpace(name[i+1:])
+			}
+			current = &RoxygenTag{ Name: name, Value: value, Pos: l.Pos() }
+			continue
+		}
+		if current == nil {
+			if len(intro) == 0 {
+				introPos = l.Pos()
+			}
+			intro = append(intro, strings.TrimRight(text, " \t"))
+			continue
+		}
+		if roxygenVerbatimTags[current.Name] {
+			current.Value += "\n" + strings.TrimRight(text, " \t")
+		} else {
+			current.Value += "\n" + trimmed
+		}
+	}
+	if current != nil {
+		tags = append(tags, *current)
+	}
+	for i := range tags {
+		if roxygenVerbatimTags[tags[i].Name] {
+			tags[i].Value = strings.Trim(tags[i].Value, "\n")
+		} else {
+			tags[i].Value = strings.TrimSpace(tags[i].Value)
+		}
+	}
+
+	// The introduction is made of the title, the description and the details paragraphs
+	var paragraphs []string
+	for _, p := range strings.Split(strings.Join(intro, "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	var introTags []RoxygenTag
+	if len(paragraphs) > 0 {
+		introTags = append(introTags, RoxygenTag{ "title", paragraphs[0], introPos })
+	}
+	if len(paragraphs) > 1 {
+		introTags = append(introTags, RoxygenTag{ "description", paragraphs[1], introPos })
+	}
+	if len(paragraphs) > 2 {
+		introTags = append(introTags, RoxygenTag{ "details", strings.Join(paragraphs[2:], "\n\n"), introPos })
+	}
+	return append(introTags, tags...)
+}
+
+// Tag returns the value of the first tag with the given name.
+func (this *RoxygenBlock) Tag(name string) (string, bool) {
+	for _, t := range this.Tags {
+		if t.Name == name {
+			return t.Value, true
+		}
+	}
+	return "", false
+}
+
+// TagValues returns the values of all the tags with the given name.
+func (this *RoxygenBlock) TagValues(name string) (values []string) {
+	for _, t := range this.Tags {
+		if t.Name == name {
+			values = append(values, t.Value)
+		}
+	}
+	return
+}
+
+// Name returns the name of the topic documented by the block: @name or the name of the object.
+func (this *RoxygenBlock) Name() string {
+	if n, ok := this.Tag("name"); ok {
+		return n
+	}
+	if this.Object != nil {
+		return this.Object.Name
+	}
+	return ""
+}
+
+// namespaceName quotes a non syntactic name for the NAMESPACE file.
+func namespaceName(name string) string {
+	if isSyntacticName(name) {
+		return name
+	}
+	return "\"" + name + "\""
+}
+
+// RoxygenNamespace returns the content of the NAMESPACE file generated from the @export, @import, @importFrom,
+// @useDynLib and @rawNamespace tags of the blocks, sorted and without duplicates as roxygen2 writes it.
+func RoxygenNamespace(blocks []*RoxygenBlock) string {
+	seen := make(map[string]bool)
+	var directives []string
+	add := func(d string) {
+		if !seen[d] {
+			seen[d] = true
+			directives = append(directives, d)
+		}
+	}
+
+	for _, b := range blocks {
+		for _, t := range b.Tags {
+			fields := strings.Fields(t.Value)
+			switch t.Name {
+			case "export" :
+				if len(fields) > 0 {
+					for _, f := range fields {
+						add("export(" + namespaceName(f) + ")")
+					}
+				} else if b.Object != nil && b.Object.Kind == ROXYGEN_S3METHOD {
+					add("S3method(" + namespaceName(b.Object.Generic) + "," + namespaceName(b.Object.Class) + ")")
+				} else if name := b.Name(); name != "" {
+					add("export(" + namespaceName(name) + ")")
+				}
+			case "exportS3Method" :
+				if b.Object == nil || b.Object.Kind == ROXYGEN_DATA {
+					break
+				}
+				if len(fields) == 1 && strings.Contains(fields[0], "::") {
+					// @exportS3Method pkg::generic delays the registration
+					generic := fields[0][strings.LastIndex(fields[0], ":")+1:]
+					add("S3method(" + fields[0] + "," + namespaceName(strings.TrimPrefix(b.Object.Name, generic + ".")) + ")")
+				} else if b.Object.Kind == ROXYGEN_S3METHOD {
+					add("S3method(" + namespaceName(b.Object.Generic) + "," + namespaceName(b.Object.Class) + ")")
+				}
+			case "method" :
+				if len(fields) == 2 {
+					add("S3method(" + namespaceName(fields[0]) + "," + namespaceName(fields[1]) + ")")
+				}
+			case "import" :
+				for _, f := range fields {
+					add("import(" + f + ")")
+				}
+			case "importFrom" :
+				for i := 1; i < len(fields); i++ {
+					f := fields[i]
+					add("importFrom(" + fields[0] + "," + namespaceName(f) + ")")
+				}
+			case "useDynLib" :
+				if len(fields) > 0 {
+					add("useDynLib(" + t.Value + ")")
+				}
+			case "rawNamespace" :
+				add(t.Value)
+			}
+		}
+	}
+
+	// roxygen2 sorts the directives in the C locale
+	sort.Strings(directives)
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by roxygen2: do not edit by hand\n\n")
+	for _, d := range directives {
+		buf.WriteString(d + "\n")
+	}
+	return buf.String()
+}
+
+// roxygenTopic is an Rd file made of one or several blocks (see @rdname).
+type roxygenTopic struct {
+	name     string
+	files    []string
+	blocks   []*RoxygenBlock
+	aliases  []string
+	usages   []string
+	formals  []string
+	params   [][2]string
+	inherits []string
+}
+
+// rdFileNames are the names given to the special characters of the topic names in the Rd file names.
+var rdFileNames = strings.NewReplacer(
+	"%", "-grapes-", "<", "-less-than-", ">", "-greater-than-", "[", "-sub-", "$", "-cash-", "=", "-equals-",
+	"!", "-bang-", "&", "-and-", "|", "-or-", "*", "-times-", "+", "-plus-", "^", "-hat-", "/", "-slash-",
+)
+
+var rdFileInvalid = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+var rdFileDashes = regexp.MustCompile(`--+`)
+
+// nicename returns the Rd file name of a topic as roxygen2 does: %>% gives grapes-greater-than-grapes.
+func nicename(name string) string {
+	name = rdFileNames.Replace(name)
+	name = rdFileInvalid.ReplaceAllString(name, "_")
+	name = rdFileDashes.ReplaceAllString(name, "-")
+	return strings.Trim(name, "-_")
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if l == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// RoxygenRd returns the Rd files generated from the blocks, indexed by file name (man/<name>.Rd without the man/ directory).
+func RoxygenRd(blocks []*RoxygenBlock) map[string]string {
+	var topics []*roxygenTopic
+	byName := make(map[string]*roxygenTopic)
+
+	for _, b := range blocks {
+		if _, ok := b.Tag("noRd"); ok {
+			continue
+		}
+		name := b.Name()
+		rdname, merged := b.Tag("rdname")
+		if !merged {
+			rdname = name
+		}
+		if rdname == "" {
+			continue
+		}
+		t := byName[rdname]
+		if t == nil {
+			t = &roxygenTopic{ name: rdname }
+			byName[rdname] = t
+			topics = append(topics, t)
+		}
+		t.blocks = append(t.blocks, b)
+		t.files = appendUnique(t.files, b.File)
+		if name != "" {
+			t.aliases = appendUnique(t.aliases, name)
+		}
+		for _, a := range b.TagValues("aliases") {
+			t.aliases = appendUnique(t.aliases, strings.Fields(a)...)
+		}
+		if b.Object != nil && b.Object.Usage != "" {
+			t.usages = append(t.usages, b.Object.Usage)
+			t.formals = appendUnique(t.formals, b.Object.Formals...)
+		}
+		for _, p := range b.TagValues("param") {
+			name, desc := p, ""
+			if i := strings.IndexAny(p, " \t\n"); i >= 0 {
+				name, desc = p[:i], strings.TrimSpace(p[i+1:])
+			}
+			t.params = append(t.params, [2]string{ name, desc })
+		}
+		t.inherits = appendUnique(t.inherits, b.TagValues("inheritParams")...)
+	}
+
+	// @inheritParams copies the documentation of the formals that are not documented
+	for _, t := range topics {
+		for _, from := range t.inherits {
+			source := byName[from]
+			if source == nil {
+				for _, s := range topics {
+					for _, a := range s.aliases {
+						if a == from {
+							source = s
+						}
+					}
+				}
+			}
+			if source == nil {
+				continue
+			}
+			for _, p := range source.params {
+				if t.documents(p[0]) || !t.hasFormal(p[0]) {
+					continue
+				}
+				t.params = append(t.params, p)
+			}
+		}
+	}
+
+	files := make(map[string]string)
+	for _, t := range topics {
+		if rd, ok := t.rd(); ok {
+			files[nicename(t.name) + ".Rd"] = rd
+		}
+	}
+	return files
+}
+
+// documents reports whether the topic has a @param for the formal.
+func (this *roxygenTopic) documents(formal string) bool {
+	for _, p := range this.params {
+		for _, n := range strings.Split(p[0], ",") {
+			if strings.TrimSpace(n) == formal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (this *roxygenTopic) hasFormal(formal string) bool {
+	for _, f := range this.formals {
+		if f == formal {
+			return true
+		}
+	}
+	return false
+}
+
+// tag returns the value of the first block of the topic with the tag.
+func (this *roxygenTopic) tag(name string) (string, bool) {
+	for _, b := range this.blocks {
+		if v, ok := b.Tag(name); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// rdEscape escapes the % of the R code of usage and examples.
+func rdEscape(s string) string {
+	return strings.Replace(s, "%", "\\%", -1)
+}
+
+// rd returns the content of the Rd file of the topic, in the section order of roxygen2.
+func (this *roxygenTopic) rd() (string, bool) {
+	var buf bytes.Buffer
+
+	title, ok := this.tag("title")
+	if !ok {
+		return "", false
+	}
+	section := func(name, value string) {
+		buf.WriteString("\\" + name + "{\n" + value + "\n}\n")
+	}
+
+	buf.WriteString("% Generated by roxygen2: do not edit by hand\n")
+	buf.WriteString("% Please edit documentation in " + strings.Join(this.files, ", ") + "\n")
+	for _, b := range this.blocks {
+		if b.Object != nil && b.Object.Kind == ROXYGEN_DATA {
+			if _, ok := this.tag("docType"); !ok {
+				buf.WriteString("\\docType{data}\n")
+			}
+			break
+		}
+	}
+	if d, ok := this.tag("docType"); ok {
+		buf.WriteString("\\docType{" + d + "}\n")
+	}
+	buf.WriteString("\\name{" + rdEscape(this.name) + "}\n")
+	for _, a := range this.aliases {
+		buf.WriteString("\\alias{" + rdEscape(a) + "}\n")
+	}
+	buf.WriteString("\\title{" + title + "}\n")
+	if f, ok := this.tag("format"); ok {
+		section("format", f)
+	}
+	if s, ok := this.tag("source"); ok {
+		section("source", s)
+	}
+	if u, ok := this.tag("usage"); ok {
+		section("usage", u)
+	} else if len(this.usages) > 0 {
+		section("usage", rdEscape(strings.Join(this.usages, "\n\n")))
+	}
+
+	// The arguments are sorted in the order of the formals
+	if len(this.params) > 0 {
+		params := make([][2]string, len(this.params))
+		copy(params, this.params)
+		rank := func(p [2]string) int {
+			first := strings.TrimSpace(strings.Split(p[0], ",")[0])
+			for i, f := range this.formals {
+				if f == first {
+					return i
+				}
+			}
+			return len(this.formals)
+		}
+		sort.SliceStable(params, func(i, j int) bool { return rank(params[i]) < rank(params[j]) })
+		var items []string
+		for _, p := range params {
+			name := p[0]
+			if name == "..." {
+				name = "\\dots"
+			}
+			items = append(items, "\\item{" + name + "}{" + p[1] + "}")
+		}
+		section("arguments", strings.Join(items, "\n\n"))
+	}
+
+	if v, ok := this.tag("return"); ok {
+		section("value", v)
+	} else if v, ok := this.tag("returns"); ok {
+		section("value", v)
+	}
+	if d, ok := this.tag("description"); ok {
+		section("description", d)
+	} else {
+		// roxygen2 uses the title when there is no description
+		section("description", title)
+	}
+	if d, ok := this.tag("details"); ok {
+		section("details", d)
+	}
+	if n, ok := this.tag("note"); ok {
+		section("note", n)
+	}
+	for _, b := range this.blocks {
+		for _, s := range b.TagValues("section") {
+			if i := strings.IndexByte(s, ':'); i >= 0 {
+				buf.WriteString("\\section{" + strings.TrimSpace(s[:i]) + "}{\n" + strings.TrimSpace(s[i+1:]) + "\n}\n\n")
+			}
+		}
+	}
+	var examples []string
+	for _, b := range this.blocks {
+		examples = append(examples, b.TagValues("examples")...)
+	}
+	if len(examples) > 0 {
+		section("examples", rdEscape(strings.Join(examples, "\n")))
+	}
+	if r, ok := this.tag("references"); ok {
+		section("references", r)
+	}
+	if s, ok := this.tag("seealso"); ok {
+		section("seealso", s)
+	}
+	if a, ok := this.tag("author"); ok {
+		section("author", a)
+	}
+	for _, b := range this.blocks {
+		for _, c := range b.TagValues("concept") {
+			buf.WriteString("\\concept{" + c + "}\n")
+		}
+	}
+	for _, b := range this.blocks {
+		for _, k := range b.TagValues("keywords") {
+			for _, w := range strings.Fields(k) {
+				buf.WriteString("\\keyword{" + w + "}\n")
+			}
+		}
+	}
+	return buf.String(), true
+}
+
+// String returns a short description of the block for debugging.
+func (this *RoxygenBlock) String() string {
+	name := "?"
+	if this.Object != nil {
+		name = this.Object.Kind + " " + this.Object.Name
+	}
+	return fmt.Sprintf("%s:%d: %s (%d tags)", this.File, this.Pos.Line, name, len(this.Tags))
+}
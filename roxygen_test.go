@@ -0,0 +1,104 @@
+package r
+
+import "testing"
+import "os"
+import "io/ioutil"
+import "path/filepath"
+import "strings"
+
+func TestParseRoxygen(e *testing.T) {
+	var tests = []struct {
+		source  string
+		kinds   []string
+		names   []string
+		tags    [][]string
+	}{
+		{ "#' Title\n#'\n#' Description\n#' @param x A value\n#'   on two lines\n#' @export\nf <- function(x) x\n",
+			[]string{ ROXYGEN_FUNCTION }, []string{ "f" },
+			[][]string{ { "title=Title", "description=Description", "param=x A value\non two lines", "export=" } } },
+		{ "#' @examples\n#' f(1)\n#'   g(2)\n`f` = function(x, y = 1) NULL\n",
+			[]string{ ROXYGEN_FUNCTION }, []string{ "f" },
+			[][]string{ { "examples=f(1)\n  g(2)" } } },
+		{ "#' Data\n\"mydata\"\n\n#' A\nNULL\n#' Method\nprint.foo <- function(x, ...) NULL\n",
+			[]string{ ROXYGEN_DATA, ROXYGEN_NULL, ROXYGEN_S3METHOD }, []string{ "mydata", "", "print.foo" },
+			[][]string{ { "title=Data" }, { "title=A" }, { "title=Method" } } },
+		{ "# comment\nx <- 1\n#' Orphan\n",
+			[]string{ "" }, []string{ "" },
+			[][]string{ { "title=Orphan" } } },
+		{ "gen <- function(x) UseMethod(\"gen\")\n#' @export\ngen.my.class <- function(x) x\n",
+			[]string{ ROXYGEN_S3METHOD }, []string{ "gen.my.class" },
+			[][]string{ { "export=" } } },
+	}
+
+	for i, test := range tests {
+		blocks, err := ParseRoxygen("test.R", strings.NewReader(test.source))
+		if err != nil {
+			e.Error("Test ParseRoxygen[", i, "] Failed with error", err)
+			continue
+		}
+		if len(blocks) != len(test.kinds) {
+			e.Error("Test ParseRoxygen[", i, "] Failed: expected", len(test.kinds), "blocks but got", len(blocks))
+			continue
+		}
+		for j, b := range blocks {
+			kind, name := "", ""
+			if b.Object != nil {
+				kind, name = b.Object.Kind, b.Object.Name
+			}
+			if kind != test.kinds[j] || name != test.names[j] {
+				e.Error("Test ParseRoxygen[", i, "] block", j, "Failed: expected", test.kinds[j], test.names[j], "but got", kind, name)
+			}
+			var tags []string
+			for _, t := range b.Tags {
+				tags = append(tags, t.Name + "=" + t.Value)
+			}
+			if strings.Join(tags, "|") != strings.Join(test.tags[j], "|") {
+				e.Errorf("Test ParseRoxygen[%d] block %d Failed: expected tags %q but got %q", i, j, test.tags[j], tags)
+			}
+		}
+	}
+
+	blocks, _ := ParseRoxygen("test.R", strings.NewReader("gen <- function(x) UseMethod(\"gen\")\n#' T\ngen.cls <- function(x, n = 10L) x\n"))
+	if o := blocks[0].Object; o.Generic != "gen" || o.Class != "cls" || o.Usage != "\\method{gen}{cls}(x, n = 10L)" {
+		e.Error("Test ParseRoxygen S3 method Failed:", o.Generic, o.Class, o.Usage)
+	}
+}
+
+// The expected files of testdata/roxygen follow the output format of roxygen2 7.x.
+func TestRoxygenCorpus(e *testing.T) {
+	f, err := os.Open("testdata/roxygen/R/clamp.R")
+	if err != nil {
+		e.Fatal(err)
+	}
+	defer f.Close()
+	blocks, err := ParseRoxygen("R/clamp.R", f)
+	if err != nil {
+		e.Fatal(err)
+	}
+
+	expected, _ := ioutil.ReadFile("testdata/roxygen/NAMESPACE")
+	if ns := RoxygenNamespace(blocks); ns != string(expected) {
+		e.Errorf("Test RoxygenCorpus NAMESPACE Failed:\n%s\nexpected:\n%s", ns, expected)
+	}
+
+	files := RoxygenRd(blocks)
+	names, _ := filepath.Glob("testdata/roxygen/man/*.Rd")
+	if len(files) != len(names) {
+		e.Error("Test RoxygenCorpus Failed: expected", len(names), "Rd files but got", len(files))
+	}
+	for _, name := range names {
+		expected, _ := ioutil.ReadFile(name)
+		rd, ok := files[filepath.Base(name)]
+		if !ok {
+			e.Error("Test RoxygenCorpus Failed: missing", filepath.Base(name))
+			continue
+		}
+		if rd != string(expected) {
+			e.Errorf("Test RoxygenCorpus %s Failed:\n%s\nexpected:\n%s", filepath.Base(name), rd, expected)
+		}
+		// The generated files are valid Rd
+		if _, err := ParseRd(strings.NewReader(rd)); err != nil {
+			e.Error("Test RoxygenCorpus", filepath.Base(name), "Failed with error", err)
+		}
+	}
+}
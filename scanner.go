@@ -33,6 +33,15 @@ type Scanner struct {
 	// Pushback buffer to handle rune look ahead
 	npush			int
 	pushback		[16]character
+	// File name of the last #line directive
+	filename		string
+	// Locale of the symbols, last token and rejected symbols
+	locale			Locale
+	last			*Token
+	identErrors		[]*IdentError
+	// Encoding of the source and invalid sequences found
+	encoding		Encoding
+	encodingErrors	[]*EncodingError
 }
 
 // Token is the set of lexical tokens of the R programming language.
@@ -95,7 +104,9 @@ const (
 	KEYWORD_FUNCTION // function
 
 	// Symbols & Identifiers
-	SYMBOL // xxx | `xxx` symbol name
+	SYMBOL   // xxx | `xxx` symbol name
+	DOTS     // ... the arguments matched by the ... formal
+	DOTDOT_N // ..1 ..2 the N-th argument matched by ..., N is the integer value of the token
 
 	// Infix operator
 	INFIX 	// %xxxxxxx%
@@ -120,6 +131,8 @@ const (
 	OP_NAMESPACE_INTERNAL // :::
 	OP_DOLLAR             // $
 	OP_AT                 // @
+	OP_COMMA              // ,
+	OP_SEMICOLON          // ;
 
 	// Arithmetic operators
 	OP_ADD  // +
@@ -198,6 +211,8 @@ func (this TokenType) String() (s string) {
 	// Symbols & Identifiers
 
 	case SYMBOL : s = "SYMBOL"
+	case DOTS : s = "DOTS"
+	case DOTDOT_N : s = "DOTDOT_N"
 
 	// Infix operator
 
@@ -224,6 +239,8 @@ func (this TokenType) String() (s string) {
 	case OP_NAMESPACE_INTERNAL : s = "NAMESPACE_INTERNAL"
 	case OP_DOLLAR : s = "DOLLAR"
 	case OP_AT : s = "AT"
+	case OP_COMMA : s = "COMMA"
+	case OP_SEMICOLON : s = "SEMICOLON"
 
 	// Arithmetic operators
 
@@ -254,14 +271,50 @@ func (this TokenType) String() (s string) {
 	return
 }
 
+// Position is a location in the source text.
+type Position struct {
+	// File offset (in bytes)
+	Offset	int
+	// Text editor position
+	Line	int
+	Column	int
+}
+
+// Pos returns the position of the first character of the token.
+func (this *Token) Pos() Position {
+	return Position{ Offset: this.offset, Line: this.nline, Column: this.ncol }
+}
+
+// End returns the file offset of the character immediately after the token.
+func (this *Token) End() int {
+	return this.offset + this.nbyte
+}
+
+// Value returns the text of the token: the name of a symbol, the unquoted content of a string, the literal of a numeric or operator.
+func (this *Token) Value() string {
+	return this.stringvalue
+}
+
+// Int returns the integer value of a numeric token.
+func (this *Token) Int() int64 {
+	return this.intvalue
+}
+
+// Real returns the floating point value of a numeric token.
+func (this *Token) Real() float64 {
+	return this.realvalue
+}
+
 func (this *Scanner) getCharacter() (c *character, err error) {
 	var ru rune
 	var nb int
 
 	if this.npush > 0 {
-		c = &this.pushback[this.npush-1]
+		// a copy: the slot is reused by the next ungetCharacter
+		pushed := this.pushback[this.npush-1]
+		c = &pushed
 		this.npush--
-	} else if ru, nb, err = this.reader.ReadRune(); err == nil {
+	} else if ru, nb, err = this.readRune(); err == nil {
 		c = new(character)
 
 		c.r = ru
@@ -272,17 +325,42 @@ func (this *Scanner) getCharacter() (c *character, err error) {
 
 		this.currentOffset += c.nbyte
 		this.nrune++
-		if ru == '\n' {
+		switch ru {
+		case '\n' :
 			this.ncol = 1
 			this.nline++
-		} else {
+		case '\t' :
+			// as R, a tab advances the column to the next multiple of 8
+			this.ncol = (this.ncol + 7) &^ 7 + 1
+		default:
 			this.ncol++
 		}
 	}
 	return
 }
 
+// endOfInput is the rune returned by getCharacterOrEOF when the reader is exhausted
+const endOfInput rune = -1
+
+// getCharacterOrEOF is like getCharacter but returns an endOfInput character instead of io.EOF,
+// so that a token placed at the very end of the input is terminated like any other token.
+func (this *Scanner) getCharacterOrEOF() (c *character, err error) {
+	if c, err = this.getCharacter(); err == io.EOF {
+		c = new(character)
+		c.r = endOfInput
+		c.offset = this.currentOffset
+		c.ncol = this.ncol
+		c.nline = this.nline
+		err = nil
+	}
+	return
+}
+
 func (this *Scanner) ungetCharacter(c *character) (err error) {
+	if c.r == endOfInput {
+		// nothing to pushback, the reader will return io.EOF again
+		return
+	}
 	if this.npush < len(this.pushback) {
 		this.pushback[this.npush] = *c
 		this.npush++
@@ -295,7 +373,7 @@ func (this *Scanner) ungetCharacter(c *character) (err error) {
 func (this *Scanner) isNextCharacter(r rune) (b bool, err error) {
 	var c *character
 
-	if c, err = this.getCharacter(); err != nil {
+	if c, err = this.getCharacterOrEOF(); err != nil {
 		return false, err
 	}
 	if c.r == r {
@@ -310,7 +388,7 @@ func (this *Scanner) isNextCharacter(r rune) (b bool, err error) {
 func (this *Scanner) isNextCharacterLetter(r rune) (b bool, err error) {
 	var c *character
 
-	if c, err = this.getCharacter(); err != nil {
+	if c, err = this.getCharacterOrEOF(); err != nil {
 		return false, err
 	}
 	if c.r == unicode.ToLower(r) || c.r == unicode.ToUpper(r) {
@@ -325,7 +403,7 @@ func (this *Scanner) isNextCharacterLetter(r rune) (b bool, err error) {
 func (this *Scanner) isNextCharacterDigit() (b bool, err error) {
 	var c *character
 
-	if c, err = this.getCharacter(); err != nil {
+	if c, err = this.getCharacterOrEOF(); err != nil {
 		return false, err
 	}
 	if unicode.IsDigit(c.r) {
@@ -350,11 +428,31 @@ func (this *Scanner) getCharacterAfterSpaces() (c *character, err error) {
 	return
 }
 
+// processLineDirective handles #line N "file": the line following the directive is the line N of file.
+// The newline ending the directive has already been read, so the next line number is set directly.
 func (this *Scanner) processLineDirective(t *Token) {
 	t.Type = LINE_DIRECTIVE
+	fields := strings.Fields(t.stringvalue[len("#line"):])
+	if len(fields) == 0 {
+		return
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n <= 0 {
+		return
+	}
+	t.intvalue = int64(n)
+	this.nline = n
+	if len(fields) > 1 {
+		this.filename = strings.Trim(strings.Join(fields[1:], " "), "\"")
+	}
 	return
 }
 
+// Filename returns the file name given by the last #line directive, "" if there was none.
+func (this *Scanner) Filename() string {
+	return this.filename
+}
+
 func (this *Scanner) processComment(t *Token) {
 	var c *character
 	var err error
@@ -410,6 +508,7 @@ func (this *Scanner) processString(r rune, t *Token) {
 					return
 				} 
 				t.Type = CONST_CHARACTER
+				t.intvalue = this.stringMark(t.stringvalue, hasunicode, hasoctal || hashexa)
 				return
 			} else {
 				t.stringvalue += string(c.r)
@@ -417,7 +516,7 @@ func (this *Scanner) processString(r rune, t *Token) {
 
 		case '`' :
 			if c.r == r {
-				t.Type = SYMBOL
+				t.Type, t.intvalue = symbolType(t.stringvalue)
 				return
 			} else {
 				t.stringvalue += string(c.r)
@@ -474,11 +573,12 @@ func (this *Scanner) processString(r rune, t *Token) {
 						break
 					}
 				}
-				if v == 0 { // nul character not allowed
+				if byte(v) == 0 { // nul character not allowed
 						t.Type = ERROR
 						return
 				}
-				t.stringvalue += string(v)
+				// the byte itself, as R keeps it
+				t.stringvalue += string([]byte{ byte(v) })
 
 			case 'x' : // \xnn	character with given hex code (1 or 2 hex digits)
 				hashexa = true
@@ -503,7 +603,8 @@ func (this *Scanner) processString(r rune, t *Token) {
 					t.Type = ERROR
 					return
 				}
-				t.stringvalue += string(v)
+				// the byte itself, as R keeps it
+				t.stringvalue += string([]byte{ byte(v) })
 
 			case 'u' : // \unnnn	Unicode character with given code (1--4 hex digits)
 				if r == '`' { // \\uxxxx sequences not supported inside backticks
@@ -634,7 +735,7 @@ func (this *Scanner) processDecimal(c *character, t *Token) {
 		t.stringvalue = string(c.r)
 		for {
 			// Trying to read the next rune
-			if c, err = this.getCharacter(); err != nil {
+			if c, err = this.getCharacterOrEOF(); err != nil {
 				t.Type = ERROR
 				return
 			}
@@ -651,7 +752,7 @@ func (this *Scanner) processDecimal(c *character, t *Token) {
 		t.stringvalue = t.stringvalue + "."
 		for {
 			// Trying to read the next rune
-			if c, err = this.getCharacter(); err != nil {
+			if c, err = this.getCharacterOrEOF(); err != nil {
 				t.Type = ERROR
 				return
 			}
@@ -666,31 +767,30 @@ func (this *Scanner) processDecimal(c *character, t *Token) {
 	// process exponential part of the decimal (at the right of 'e' or 'E')
 	if c.r == 'e' || c.r == 'E' {
 		t.stringvalue = t.stringvalue + string(c.r)
-		if c, err = this.getCharacter(); err != nil {
+		if c, err = this.getCharacterOrEOF(); err != nil {
 			t.Type = ERROR
 			return
 		}
-		switch c.r {
-		case '+' :
-			t.stringvalue = t.stringvalue + "+"
-		case '-' :
-			t.stringvalue = t.stringvalue + "-"
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if c.r == '+' || c.r == '-' {
 			t.stringvalue = t.stringvalue + string(c.r)
-		default:
-			break
+			if c, err = this.getCharacterOrEOF(); err != nil {
+				t.Type = ERROR
+				return
+			}
 		}
-		for {
+		if c.r < '0' || c.r > '9' {
+			// an exponent without digits is an error as in the lexer of R: 1e and 1e+ are not numbers
+			t.Type = ERROR
+			this.ungetCharacter(c)
+			return
+		}
+		for c.r >= '0' && c.r <= '9' {
+			t.stringvalue = t.stringvalue + string(c.r)
 			// Trying to read the next rune
-			if c, err = this.getCharacter(); err != nil {
+			if c, err = this.getCharacterOrEOF(); err != nil {
 				t.Type = ERROR
 				return
 			}
-			if c.r >= '0' && c.r <= '9' {
-				t.stringvalue = t.stringvalue + string(c.r)
-			} else {
-				break
-			}
 		}
 	}
 
@@ -736,6 +836,7 @@ func (this *Scanner) processHexadecimal(c *character, t *Token) {
 	var err 					error
 	var expn, exph, sign, n		int64
 	var v						float64
+	var ndigit					int
 
 	exph = -1
 	t.Type = CONST_REAL
@@ -747,7 +848,7 @@ func (this *Scanner) processHexadecimal(c *character, t *Token) {
 	}
 
 	// remove the 'x' or 'X'
-	if c, err = this.getCharacter(); err != nil {
+	if c, err = this.getCharacterOrEOF(); err != nil {
 		t.Type = ERROR
 		return
 	}
@@ -758,18 +859,19 @@ func (this *Scanner) processHexadecimal(c *character, t *Token) {
 	}
 
 	// Get the firs character after "0x"
-	if c, err = this.getCharacter(); err != nil {
+	if c, err = this.getCharacterOrEOF(); err != nil {
 		t.Type = ERROR
 		return
 	}
 
 	// process integer part of the decimal (at the left of '.')
-	if c.r != '.' {
+	if this.isCharacterHexa(c.r) {
+		ndigit++
 		v = float64(this.hexaValue(c.r))
 		t.stringvalue = t.stringvalue + string(c.r)
 		for {
 			// Trying to read the next rune
-			if c, err = this.getCharacter(); err != nil {
+			if c, err = this.getCharacterOrEOF(); err != nil {
 				t.Type = ERROR
 				return
 			}
@@ -788,11 +890,12 @@ func (this *Scanner) processHexadecimal(c *character, t *Token) {
 		t.stringvalue = t.stringvalue + "."
 		for {
 			// Trying to read the next rune
-			if c, err = this.getCharacter(); err != nil {
+			if c, err = this.getCharacterOrEOF(); err != nil {
 				t.Type = ERROR
 				return
 			}
 			if this.isCharacterHexa(c.r) {
+				ndigit++
 				exph += 4
 				v = v*16 + float64(this.hexaValue(c.r))
 				t.stringvalue = t.stringvalue + string(c.r)
@@ -802,11 +905,18 @@ func (this *Scanner) processHexadecimal(c *character, t *Token) {
 		}
 	}
 
+	// "0x" and "0x." without any hexadecimal digit
+	if ndigit == 0 {
+		t.Type = ERROR
+		this.ungetCharacter(c)
+		return
+	}
+
 	// process exponential part of the decimal (at the right of 'e' or 'E')
 	if c.r == 'p' || c.r == 'P' {
 		t.stringvalue = t.stringvalue + string(c.r)
 		sign = 1
-		if c, err = this.getCharacter(); err != nil {
+		if c, err = this.getCharacterOrEOF(); err != nil {
 			t.Type = ERROR
 			return
 		}
@@ -825,7 +935,7 @@ func (this *Scanner) processHexadecimal(c *character, t *Token) {
 		}
 		for {
 			// Trying to read the next rune
-			if c, err = this.getCharacter(); err != nil {
+			if c, err = this.getCharacterOrEOF(); err != nil {
 				t.Type = ERROR
 				return
 			}
@@ -883,16 +993,16 @@ func (this *Scanner) processSymbol(c *character, t *Token) {
 
 	for {
 		// Trying to read the next rune
-		if c, err = this.getCharacter(); err != nil {
+		if c, err = this.getCharacterOrEOF(); err != nil {
 			t.Type = ERROR
 			return
 		}
-		if c.r == '.' || c.r == '_' || unicode.IsLetter(c.r) || unicode.IsDigit(c.r) {
+		if IsIdentRune(c.r, this.locale) {
 			// Continue to read the symbol
 			t.stringvalue += string(c.r)
 		} else {
 			// End of symbol
-			t.Type = SYMBOL
+			t.Type, t.intvalue = symbolType(t.stringvalue)
 			if err = this.ungetCharacter(c); err != nil {
 				t.Type = ERROR
 			} else {
@@ -952,7 +1062,25 @@ func (this *Scanner) processInfix(t *Token) {
 	return
 }
 
+// closeToken computes the data quantity of the token t that started at the rune index start.
+func (this *Scanner) closeToken(t *Token, start int) {
+	end := this.currentOffset
+	if this.npush > 0 {
+		end = this.pushback[this.npush-1].offset
+	}
+	t.nbyte = end - t.offset
+	t.nrune = this.nrune - this.npush - start
+}
+
+// NextToken returns the next token of the source, END_OF_INPUT at the end.
 func (this *Scanner) NextToken() (t *Token) {
+	t = this.nextToken()
+	this.checkIdent(this.last, t)
+	this.last = t
+	return
+}
+
+func (this *Scanner) nextToken() (t *Token) {
 	var b	bool
 	var c 	*character
 	var err error
@@ -969,15 +1097,25 @@ func (this *Scanner) NextToken() (t *Token) {
 	// Skip all the space ' ', tabulation '\t' and form feed '\f' and return the next character
 	if c, err = this.getCharacterAfterSpaces(); err != nil {
 		if err == io.EOF {
+			// the end of input is after the trailing spaces
 			t.Type = END_OF_INPUT
+			t.offset = this.currentOffset
+			t.ncol = this.ncol
+			t.nline = this.nline
 		}
 		return
 	}
 
+	// The token starts at the first character after the spaces
+	t.offset = c.offset
+	t.ncol = c.ncol
+	t.nline = c.nline
+	defer this.closeToken(t, this.nrune - this.npush - 1)
+
 	// Now we have the next rune after skipping all the consecutive spaces, tabulation and form feed runes,
 
 	// Is it a symbol ?
-	if unicode.IsLetter(c.r) {
+	if isLetter(c.r, this.locale) {
 		this.processSymbol(c,t)
 	} else {
 		switch	c.r {
@@ -1019,7 +1157,7 @@ func (this *Scanner) NextToken() (t *Token) {
 			}
 
 		// Single rune tokens
-		case '\n' :	t.Type = END_OF_LINE; this.nline++
+		case '\n' :	t.Type = END_OF_LINE
 		case '+' :	t.Type = OP_ADD; t.stringvalue = "+"
     	case '/' :	t.Type = OP_DIV; t.stringvalue = "/"
     	case '^' :	t.Type = OP_POW; t.stringvalue = "^"
@@ -1027,6 +1165,8 @@ func (this *Scanner) NextToken() (t *Token) {
 		case '?' :	t.Type = OP_QUESTION; t.stringvalue = "?"
     	case '$' :	t.Type = OP_DOLLAR; t.stringvalue = "$"
     	case '@' :	t.Type = OP_AT; t.stringvalue = "@"
+		case ',' :	t.Type = OP_COMMA; t.stringvalue = ","
+		case ';' :	t.Type = OP_SEMICOLON; t.stringvalue = ";"
 		case '(' :	t.Type = OP_LEFT_ROUND; t.stringvalue = "("
 		case ')' :	t.Type = OP_RIGHT_ROUND; t.stringvalue = ")"
 		case '{' :	t.Type = OP_LEFT_CURLY; t.stringvalue = "{"
@@ -1210,12 +1350,17 @@ func (this *Scanner) NextToken() (t *Token) {
 				t.Type = OP_LEFT_SQUARE
 				t.stringvalue = "["
 			}
+
+		default:
+			this.unexpected(c.r, t)
 		}
 	}
 	return
 }
 
-func NewScanner(r io.Reader) (s *Scanner) {
+// NewScanner returns a Scanner reading the R source code from r, in UTF-8 or in the encoding given as option. A
+// byte order mark at the beginning of the source is skipped.
+func NewScanner(r io.Reader, encoding ...Encoding) (s *Scanner) {
 	s = new(Scanner)
 
 	// properties init
@@ -1225,6 +1370,11 @@ func NewScanner(r io.Reader) (s *Scanner) {
 	s.nline = 1
 	s.nrune = 0
 
+	if len(encoding) > 0 {
+		s.encoding = encoding[0]
+	}
+	s.encoding, s.currentOffset = detectBOM(s.reader, s.encoding)
+
 	return
 }
 
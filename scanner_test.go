@@ -1,8 +1,13 @@
 package r
 
-import "testing"
-import "strings"
+import "bytes"
+import "fmt"
+import "io/ioutil"
 import "math"
+import "path/filepath"
+import "strconv"
+import "strings"
+import "testing"
 
 // .romain if else break function ... jacotin
 // NA NA_character_ NA_integer_ NA_complex_ NA_real_
@@ -153,7 +158,7 @@ func TestProcessSymbol(e *testing.T) {
 		{ KEYWORD_NEXT, 0, 0, "next", 0, 0, 0, 0, 0 },
 		{ KEYWORD_BREAK, 0, 0, "break", 0, 0, 0, 0, 0 },
 		{ KEYWORD_FUNCTION, 0, 0, "function", 0, 0, 0, 0, 0 },
-		{ SYMBOL, 0, 0, "...", 0, 0, 0, 0, 0 },
+		{ DOTS, 0, 0, "...", 0, 0, 0, 0, 0 },
 		{ SYMBOL, 0, 0, "ja_co.tin", 0, 0, 0, 0, 0 },
 		{ END_OF_LINE, 0, 0, "", 0, 0, 0, 0, 0 },
 		// Tests 13 to 18
@@ -285,3 +290,225 @@ func TestProcessString(e *testing.T) {
 	}
 }
 
+
+func TestEndOfInput(e *testing.T) {
+	var t *Token
+	var str = `f(x, 1L); y <- pkg::fn`
+	var tests []Token = []Token{
+		{ SYMBOL, 0, 0, "f", 0, 1, 1, 1, 1 },
+		{ OP_LEFT_ROUND, 0, 0, "(", 1, 2, 1, 1, 1 },
+		{ SYMBOL, 0, 0, "x", 2, 3, 1, 1, 1 },
+		{ OP_COMMA, 0, 0, ",", 3, 4, 1, 1, 1 },
+		{ CONST_INTEGER, 1, 1, "1L", 5, 6, 1, 2, 2 },
+		{ OP_RIGHT_ROUND, 0, 0, ")", 7, 8, 1, 1, 1 },
+		{ OP_SEMICOLON, 0, 0, ";", 8, 9, 1, 1, 1 },
+		{ SYMBOL, 0, 0, "y", 10, 11, 1, 1, 1 },
+		{ OP_LEFT_ASSIGN, 0, 0, "<-", 12, 13, 1, 2, 2 },
+		{ SYMBOL, 0, 0, "pkg", 15, 16, 1, 3, 3 },
+		{ OP_NAMESPACE, 0, 0, "::", 18, 19, 1, 2, 2 },
+		{ SYMBOL, 0, 0, "fn", 20, 21, 1, 2, 2 },
+		{ END_OF_INPUT, 0, 0, "", 22, 0, 0, 0, 0 },
+	}
+
+	r := strings.NewReader(str)
+	s := NewScanner(r)
+	for i := 0; i <len(tests);i++ {
+		t = s.NextToken()
+		if t.Type != tests[i].Type { e.Error("Test EOF[", i, "]", t.stringvalue, "Type Failed", t.Type) }
+		if t.stringvalue != tests[i].stringvalue { e.Error("Test EOF[", i, "]", t.stringvalue, "stringvalue Failed") }
+		if t.offset != tests[i].offset { e.Error("Test EOF[", i, "]", t.stringvalue, "offset Failed", t.offset) }
+		if t.Type == END_OF_INPUT { continue }
+		if t.ncol != tests[i].ncol || t.nline != tests[i].nline { e.Error("Test EOF[", i, "]", t.stringvalue, "position Failed", t.nline, t.ncol) }
+		if t.nbyte != tests[i].nbyte || t.nrune != tests[i].nrune { e.Error("Test EOF[", i, "]", t.stringvalue, "quantity Failed", t.nbyte, t.nrune) }
+	}
+}
+
+func TestScannerEdgeCases(e *testing.T) {
+	tests := []struct {
+		src    string
+		types  []TokenType
+		offset int
+	}{
+		{ "0x", []TokenType{ ERROR }, 2 },
+		{ "0x;", []TokenType{ ERROR, OP_SEMICOLON }, 3 },
+		{ "0x.p1", []TokenType{ ERROR, SYMBOL }, 5 },
+		{ "0x.8", []TokenType{ CONST_REAL }, 4 },
+		{ "x  \t", []TokenType{ SYMBOL }, 4 },
+		{ "..", []TokenType{ SYMBOL }, 2 },
+		{ "\"\\U{", []TokenType{ ERROR }, 4 },
+		// the character pushed back after a leading 0 is not overwritten by the look ahead of 0x
+		{ "x-0.5", []TokenType{ SYMBOL, OP_SUB, CONST_REAL }, 5 },
+		{ "2*0.25", []TokenType{ CONST_REAL, OP_MUL, CONST_REAL }, 6 },
+		{ "!0.5", []TokenType{ OP_NOT, CONST_REAL }, 4 },
+		{ "a[0.5]", []TokenType{ SYMBOL, OP_LEFT_SQUARE, CONST_REAL, OP_RIGHT_SQUARE }, 6 },
+		{ "-0.5", []TokenType{ OP_SUB, CONST_REAL }, 4 },
+		{ "1e", []TokenType{ ERROR }, 2 },
+		{ "1e+;", []TokenType{ ERROR, OP_SEMICOLON }, 4 },
+		{ "0eA", []TokenType{ ERROR, SYMBOL }, 3 },
+		{ "1e-5L", []TokenType{ CONST_INTEGER }, 5 },
+	}
+	for i, test := range tests {
+		tokens := scanAll([]byte(test.src))
+		if len(tokens) != len(test.types) + 1 {
+			e.Error("Test ScannerEdgeCases[", i, "] Failed:", len(tokens), "tokens")
+			continue
+		}
+		for j, t := range test.types {
+			if tokens[j].Type != t {
+				e.Error("Test ScannerEdgeCases[", i, "] Failed: token", j, tokens[j].Type)
+			}
+			if err := checkNumber(tokens[j], []byte(test.src[tokens[j].offset:tokens[j].End()])); err != "" {
+				e.Error("Test ScannerEdgeCases[", i, "] Failed:", err)
+			}
+		}
+		if eof := tokens[len(tokens)-1]; eof.Type != END_OF_INPUT || eof.offset != test.offset {
+			e.Error("Test ScannerEdgeCases[", i, "] Failed: end of input", eof.Type, eof.offset)
+		}
+	}
+}
+
+func TestPosition(e *testing.T) {
+	var str = "a\n  é <- 'ü'\n# x\nb"
+	var tests []Position = []Position{
+		{ 0, 1, 1 },
+		{ 1, 1, 2 },
+		{ 4, 2, 3 },
+		{ 7, 2, 5 },
+		{ 10, 2, 8 },
+		{ 14, 2, 11 },
+		{ 15, 3, 1 },
+		{ 18, 3, 4 },
+		{ 19, 4, 1 },
+	}
+
+	s := NewScanner(strings.NewReader(str))
+	for i := 0; i <len(tests);i++ {
+		t := s.NextToken()
+		if t.Pos() != tests[i] { e.Error("Test Position[", i, "]", t.stringvalue, "Failed", t.Pos()) }
+	}
+	if t := s.NextToken(); t.Type != END_OF_INPUT { e.Error("Test Position END_OF_INPUT Failed", t.Type) }
+}
+
+func TestPositionTab(e *testing.T) {
+	// as R, a tab advances the column to the next multiple of 8
+	var str = "\tx\n1234567\ty <- \t\tz"
+	var tests []Position = []Position{
+		{ 1, 1, 9 },
+		{ 2, 1, 10 },
+		{ 3, 2, 1 },
+		{ 11, 2, 9 },
+		{ 13, 2, 11 },
+		{ 18, 2, 25 },
+	}
+
+	s := NewScanner(strings.NewReader(str))
+	for i := 0; i <len(tests);i++ {
+		t := s.NextToken()
+		if t.Pos() != tests[i] { e.Error("Test PositionTab[", i, "]", t.stringvalue, "Failed", t.Pos()) }
+	}
+}
+
+// addSeeds adds the R files of the test data and the edge cases of the scanner to the seed corpus of f.
+func addSeeds(f *testing.F) {
+	for _, pattern := range []string{ "testdata/corpus/*.R", "testdata/sexp/*.R", "testdata/parsefixtures/*/*.R", "testdata/roxygen/R/*.R" } {
+		files, _ := filepath.Glob(pattern)
+		for _, name := range files {
+			if b, err := ioutil.ReadFile(name); err == nil {
+				f.Add(b)
+			}
+		}
+	}
+	for _, s := range []string{ "", "\"\\U{", "'\\u{12", "\"\\x", "`a\\", "0x", "0x;", "0X.p", "0x1p", "1e", "1e+", ".",
+		"..", "x..", "...1", "%%", "%in", "#line 0\n", "<<", "\r\n", "a\f\t b", "\"\\0\"", "\xff\xfe", "\xef\xbb\xbfx", "5.L", "1i",
+		"x-0.5", "2*0.25", "!0.5", "a[0.5]", "(0.1)", "-0.5e3L", "1+0.5i" } {
+		f.Add([]byte(s))
+	}
+}
+
+// scanAll returns the tokens of src up to END_OF_INPUT, nil if the scanner does not stop.
+func scanAll(src []byte) []*Token {
+	var tokens []*Token
+	s := NewScanner(bytes.NewReader(src), ENCODING_UTF8)
+	for i := 0; i <= len(src); i++ {
+		t := s.NextToken()
+		tokens = append(tokens, t)
+		if t.Type == END_OF_INPUT {
+			return tokens
+		}
+	}
+	return nil
+}
+
+// checkNumber returns an error if the value of the decimal numeric token is not the one of its text.
+func checkNumber(t *Token, text []byte) string {
+	switch t.Type {
+	case CONST_REAL, CONST_INTEGER, CONST_COMPLEX :
+	default:
+		return ""
+	}
+	s := strings.TrimRight(string(text), "Li")
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return ""
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !strings.Contains(err.Error(), "range") {
+		return fmt.Sprintf("token %s %q: %s", t.Type, text, err)
+	}
+	if f != t.realvalue {
+		return fmt.Sprintf("token %s %q has the value %g", t.Type, text, t.realvalue)
+	}
+	return ""
+}
+
+func FuzzNextToken(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(e *testing.T, src []byte) {
+		tokens := scanAll(src)
+		if tokens == nil {
+			e.Fatalf("no END_OF_INPUT for %q", src)
+		}
+		end, valid := 0, true
+		if bytes.HasPrefix(src, []byte("\xEF\xBB\xBF")) {
+			// byte order mark
+			end = 3
+		}
+		var text bytes.Buffer
+		for _, t := range tokens {
+			if t.offset < end || t.End() > len(src) || (t.nbyte == 0 && t.Type != END_OF_INPUT) {
+				e.Fatalf("token %s at %d-%d after %d in %q", t.Type, t.offset, t.End(), end, src)
+			}
+			if strings.Trim(string(src[end:t.offset]), " \t\f") != "" {
+				e.Fatalf("token %s at %d skips %q in %q", t.Type, t.offset, src[end:t.offset], src)
+			}
+			if t.offset > end {
+				text.WriteByte(' ')
+			}
+			text.Write(src[t.offset:t.End()])
+			end = t.End()
+			if t.Type == ERROR {
+				valid = false
+			}
+		}
+		if end != len(src) {
+			e.Fatalf("tokens end at %d of %q", end, src)
+		}
+		if !valid {
+			return
+		}
+		for _, t := range tokens {
+			if err := checkNumber(t, src[t.offset:t.End()]); err != "" {
+				e.Fatalf("%s in %q", err, src)
+			}
+		}
+
+		again := scanAll(text.Bytes())
+		if len(again) != len(tokens) {
+			e.Fatalf("%d tokens, %d after re-lexing %q", len(tokens), len(again), src)
+		}
+		for i, t := range again {
+			if t.Type != tokens[i].Type || t.stringvalue != tokens[i].stringvalue {
+				e.Fatalf("token %d %s %q, %s %q after re-lexing %q", i, tokens[i].Type, tokens[i].stringvalue, t.Type, t.stringvalue, src)
+			}
+		}
+	})
+}
@@ -0,0 +1,475 @@
+package r
+
+import "io"
+import "bytes"
+import "compress/gzip"
+import "encoding/binary"
+import "errors"
+import "fmt"
+import "math"
+import "strconv"
+
+// Version of R written in the headers and minimal versions of R able to read the streams.
+const (
+	serializeWriterVersion = 0x040300 // R 4.3.0
+	serializeMinReader2    = 0x020300 // R 2.3.0
+	serializeMinReader3    = 0x030500 // R 3.5.0
+)
+
+// SerializeOptions are the options of Serialize, the zero value writes an uncompressed XDR stream of version 3.
+type SerializeOptions struct {
+	// SERIALIZE_XDR (default), SERIALIZE_BINARY or SERIALIZE_ASCII
+	Format byte
+	// 2 or 3 (default)
+	Version int
+	// The stream is gzip compressed, as saveRDS() does by default
+	Compress bool
+}
+
+// serializer writes the items of a serialized stream in a buffer.
+type serializer struct {
+	buf    bytes.Buffer
+	format byte
+	// Reference table: symbols by name and environments, the references are 1-based
+	symbols map[string]int
+	envs    map[Value]int
+	nrefs   int
+}
+
+// Serialize writes v in the R serialization format, opts may be nil.
+func Serialize(w io.Writer, v Value, opts *SerializeOptions) (err error) {
+	var o SerializeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Format == 0 {
+		o.Format = SERIALIZE_XDR
+	}
+	if o.Version == 0 {
+		o.Version = 3
+	}
+	switch o.Format {
+	case SERIALIZE_XDR, SERIALIZE_BINARY, SERIALIZE_ASCII :
+	default:
+		return fmt.Errorf("serialize: unknown format %q", o.Format)
+	}
+	if o.Version != 2 && o.Version != 3 {
+		return fmt.Errorf("serialize: unsupported version %d", o.Version)
+	}
+
+	s := &serializer{ format: o.Format, symbols: make(map[string]int), envs: make(map[Value]int) }
+	s.buf.WriteByte(o.Format)
+	s.buf.WriteByte('\n')
+	s.writeInt(int32(o.Version))
+	s.writeInt(serializeWriterVersion)
+	if o.Version == 2 {
+		s.writeInt(serializeMinReader2)
+	} else {
+		s.writeInt(serializeMinReader3)
+		s.writeInt(5)
+		s.writeString("UTF-8")
+	}
+	if err = s.writeItem(v); err != nil {
+		return
+	}
+
+	if !o.Compress {
+		_, err = w.Write(s.buf.Bytes())
+		return
+	}
+	z := gzip.NewWriter(w)
+	if _, err = z.Write(s.buf.Bytes()); err != nil {
+		return
+	}
+	return z.Close()
+}
+
+// WriteRDS writes v as saveRDS() does: a gzip compressed XDR stream of version 3.
+func WriteRDS(w io.Writer, v Value) error {
+	return Serialize(w, v, &SerializeOptions{ Compress: true })
+}
+
+func (this *serializer) writeInt(i int32) {
+	switch this.format {
+	case SERIALIZE_ASCII :
+		if i == NaInteger {
+			this.buf.WriteString("NA\n")
+		} else {
+			this.buf.WriteString(strconv.Itoa(int(i)) + "\n")
+		}
+	case SERIALIZE_BINARY :
+		binary.Write(&this.buf, binary.LittleEndian, i)
+	default:
+		binary.Write(&this.buf, binary.BigEndian, i)
+	}
+}
+
+func (this *serializer) writeReal(f float64) {
+	switch this.format {
+	case SERIALIZE_ASCII :
+		switch {
+		case IsNaReal(f) :
+			this.buf.WriteString("NA\n")
+		case math.IsNaN(f) :
+			this.buf.WriteString("NaN\n")
+		case math.IsInf(f, 1) :
+			this.buf.WriteString("Inf\n")
+		case math.IsInf(f, -1) :
+			this.buf.WriteString("-Inf\n")
+		default:
+			this.buf.WriteString(strconv.FormatFloat(f, 'g', 16, 64) + "\n")
+		}
+	case SERIALIZE_BINARY :
+		binary.Write(&this.buf, binary.LittleEndian, math.Float64bits(f))
+	default:
+		binary.Write(&this.buf, binary.BigEndian, math.Float64bits(f))
+	}
+}
+
+// writeLength writes the length of a vector, with the -1 escape of the long vectors.
+func (this *serializer) writeLength(n int) {
+	if n > math.MaxInt32 {
+		this.writeInt(-1)
+		this.writeInt(int32(n >> 32))
+		this.writeInt(int32(uint32(n)))
+		return
+	}
+	this.writeInt(int32(n))
+}
+
+// writeString writes the bytes of a string, escaped like a C string in the ASCII format.
+func (this *serializer) writeString(s string) {
+	if this.format != SERIALIZE_ASCII {
+		this.buf.WriteString(s)
+		return
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n' : this.buf.WriteString(`\n`)
+		case '\t' : this.buf.WriteString(`\t`)
+		case '\v' : this.buf.WriteString(`\v`)
+		case '\b' : this.buf.WriteString(`\b`)
+		case '\r' : this.buf.WriteString(`\r`)
+		case '\f' : this.buf.WriteString(`\f`)
+		case '\a' : this.buf.WriteString(`\a`)
+		case '\\' : this.buf.WriteString(`\\`)
+		case '?' : this.buf.WriteString(`\?`)
+		case '\'' : this.buf.WriteString(`\'`)
+		case '"' : this.buf.WriteString(`\"`)
+		default:
+			if c <= 32 || c > 126 {
+				fmt.Fprintf(&this.buf, "\\%03o", c)
+			} else {
+				this.buf.WriteByte(c)
+			}
+		}
+	}
+	this.buf.WriteByte('\n')
+}
+
+// writeCharsxp writes an element of a character vector with its encoding.
+func (this *serializer) writeCharsxp(s string, na bool) {
+	if na {
+		this.writeInt(int32(CHARSXP))
+		this.writeInt(-1)
+		return
+	}
+	levels := charsxpASCII
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			levels = charsxpUTF8
+			break
+		}
+	}
+	this.writeInt(int32(CHARSXP) | int32(levels) << 12)
+	this.writeInt(int32(len(s)))
+	this.writeString(s)
+}
+
+// flags returns the flags of an item with the object and attribute bits.
+func flags(typ SexpType, attrs *Attrs, hastag bool) int32 {
+	f := int32(typ)
+	if attrs != nil && len(attrs.List) > 0 {
+		f |= flagHasAttr
+		if attrs.Get("class") != nil {
+			f |= flagIsObject
+		}
+	}
+	if hastag {
+		f |= flagHasTag
+	}
+	return f
+}
+
+// writeAttributes writes the attributes pairlist if there are attributes.
+func (this *serializer) writeAttributes(attrs *Attrs) error {
+	if attrs == nil || len(attrs.List) == 0 {
+		return nil
+	}
+	elements := make([]Tagged, len(attrs.List))
+	for i, a := range attrs.List {
+		elements[i] = Tagged{ a.Name, a.Value }
+	}
+	return this.writePairlist(LISTSXP, nil, elements)
+}
+
+// writeRef writes a reference to an object already written.
+func (this *serializer) writeRef(i int) {
+	if i <= math.MaxInt32 >> 8 {
+		this.writeInt(int32(i << 8 | sxpRef))
+		return
+	}
+	this.writeInt(sxpRef)
+	this.writeInt(int32(i))
+}
+
+func (this *serializer) writeSymbol(name string) {
+	if i, ok := this.symbols[name]; ok {
+		this.writeRef(i)
+		return
+	}
+	this.nrefs++
+	this.symbols[name] = this.nrefs
+	this.writeInt(int32(SYMSXP))
+	this.writeCharsxp(name, false)
+}
+
+// writePairlist writes a chain of cons cells: the attributes on the first cell, a tag per named element.
+func (this *serializer) writePairlist(typ SexpType, attrs *Attrs, elements []Tagged) (err error) {
+	if len(elements) == 0 {
+		this.writeInt(sxpNilValue)
+		return
+	}
+	for i, t := range elements {
+		if i == 0 {
+			this.writeInt(flags(typ, attrs, t.Tag != ""))
+			if err = this.writeAttributes(attrs); err != nil {
+				return
+			}
+		} else {
+			this.writeInt(flags(LISTSXP, nil, t.Tag != ""))
+		}
+		if t.Tag != "" {
+			this.writeSymbol(t.Tag)
+		}
+		if err = this.writeItem(t.Value); err != nil {
+			return
+		}
+	}
+	this.writeInt(sxpNilValue)
+	return
+}
+
+func (this *serializer) writeItem(v Value) (err error) {
+	switch x := v.(type) {
+	case nil :
+		return errors.New("serialize: nil value")
+	case *Null :
+		this.writeInt(sxpNilValue)
+		return
+	case *Symbol :
+		switch x {
+		case MissingArg :
+			this.writeInt(sxpMissingArg)
+		case UnboundValue :
+			this.writeInt(sxpUnboundValue)
+		default:
+			this.writeSymbol(x.Name)
+		}
+		return
+	case *Environment :
+		return this.writeEnvironment(x)
+	case *Pairlist :
+		return this.writePairlist(x.Type(), &x.Attrs, x.Elements)
+	case *Language :
+		elements := append([]Tagged{ { "", x.Fun } }, x.Args...)
+		return this.writePairlist(LANGSXP, &x.Attrs, elements)
+	case *Closure :
+		this.writeInt(flags(CLOSXP, &x.Attrs, true))
+		if err = this.writeAttributes(&x.Attrs); err != nil {
+			return
+		}
+		env := x.Env
+		if env == nil {
+			env = GlobalEnv
+		}
+		if err = this.writeItem(env); err != nil {
+			return
+		}
+		if err = this.writePairlist(LISTSXP, nil, x.Formals); err != nil {
+			return
+		}
+		return this.writeItem(x.Body)
+	case *Promise :
+		hasenv := x.Env != nil && x.Env.Type() != NILSXP
+		this.writeInt(flags(PROMSXP, nil, hasenv))
+		if hasenv {
+			if err = this.writeItem(x.Env); err != nil {
+				return
+			}
+		}
+		value := x.Value
+		if value == nil {
+			value = UnboundValue
+		}
+		if err = this.writeItem(value); err != nil {
+			return
+		}
+		return this.writeItem(x.Expr)
+	case *Bytecode :
+		// The original expression is written instead of the byte code
+		if len(x.Consts) == 0 {
+			return errors.New("serialize: byte code without constants")
+		}
+		return this.writeItem(x.Consts[0])
+	case *Builtin :
+		this.writeInt(flags(x.Type(), &x.Attrs, false))
+		this.writeInt(int32(len(x.Name)))
+		this.writeString(x.Name)
+		return this.writeAttributes(&x.Attrs)
+	case *ExternalPointer :
+		this.nrefs++
+		this.envs[x] = this.nrefs
+		this.writeInt(flags(EXTPTRSXP, &x.Attrs, false))
+		if err = this.writeItem(orNull(x.Prot)); err != nil {
+			return
+		}
+		if err = this.writeItem(orNull(x.Tag)); err != nil {
+			return
+		}
+		return this.writeAttributes(&x.Attrs)
+	case *WeakRef :
+		this.nrefs++
+		this.envs[x] = this.nrefs
+		this.writeInt(flags(WEAKREFSXP, &x.Attrs, false))
+		return this.writeAttributes(&x.Attrs)
+	case *S4Object :
+		this.writeInt(flags(S4SXP, &x.Attrs, false))
+		return this.writeAttributes(&x.Attrs)
+	}
+	return this.writeVector(v)
+}
+
+// orNull returns NULL for a nil value.
+func orNull(v Value) Value {
+	if v == nil {
+		return NullValue
+	}
+	return v
+}
+
+func (this *serializer) writeEnvironment(e *Environment) (err error) {
+	switch specialOf(e) {
+	case GlobalEnv :
+		this.writeInt(sxpGlobalEnv)
+		return
+	case BaseEnv :
+		this.writeInt(sxpBaseEnv)
+		return
+	case EmptyEnv :
+		this.writeInt(sxpEmptyEnv)
+		return
+	case BaseNamespace :
+		this.writeInt(sxpBaseNamespace)
+		return
+	}
+	if i, ok := this.envs[e]; ok {
+		this.writeRef(i)
+		return
+	}
+	this.nrefs++
+	this.envs[e] = this.nrefs
+
+	if e.Spec != nil {
+		// Package and namespace environments are written by name
+		if len(e.Name) >= len("namespace:") && e.Name[:len("namespace:")] == "namespace:" {
+			this.writeInt(sxpNamespace)
+		} else {
+			this.writeInt(sxpPackage)
+		}
+		this.writeInt(0)
+		this.writeInt(int32(len(e.Spec)))
+		for _, s := range e.Spec {
+			this.writeCharsxp(s, false)
+		}
+		return
+	}
+
+	this.writeInt(int32(ENVSXP))
+	if e.Locked {
+		this.writeInt(1)
+	} else {
+		this.writeInt(0)
+	}
+	enclos := e.Enclos
+	if enclos == nil {
+		enclos = GlobalEnv
+	}
+	if err = this.writeItem(enclos); err != nil {
+		return
+	}
+	if err = this.writePairlist(LISTSXP, nil, e.Frame); err != nil {
+		return
+	}
+	// No hash table
+	this.writeInt(sxpNilValue)
+	if len(e.List) == 0 {
+		this.writeInt(sxpNilValue)
+		return
+	}
+	return this.writeAttributes(&e.Attrs)
+}
+
+// writeVector writes the atomic vectors and the lists.
+func (this *serializer) writeVector(v Value) (err error) {
+	attrs := v.Attributes()
+	this.writeInt(flags(v.Type(), attrs, false))
+
+	switch x := v.(type) {
+	case *Logical :
+		this.writeLength(len(x.Data))
+		for _, i := range x.Data {
+			this.writeInt(i)
+		}
+	case *Integer :
+		this.writeLength(len(x.Data))
+		for _, i := range x.Data {
+			this.writeInt(i)
+		}
+	case *Real :
+		this.writeLength(len(x.Data))
+		for _, f := range x.Data {
+			this.writeReal(f)
+		}
+	case *Complex :
+		this.writeLength(len(x.Data))
+		for _, c := range x.Data {
+			this.writeReal(real(c))
+			this.writeReal(imag(c))
+		}
+	case *Character :
+		this.writeLength(len(x.Data))
+		for i, s := range x.Data {
+			this.writeCharsxp(s, x.IsNA(i))
+		}
+	case *Raw :
+		this.writeLength(len(x.Data))
+		if this.format == SERIALIZE_ASCII {
+			for _, b := range x.Data {
+				fmt.Fprintf(&this.buf, "%02x\n", b)
+			}
+		} else {
+			this.buf.Write(x.Data)
+		}
+	case *List :
+		this.writeLength(len(x.Data))
+		for _, e := range x.Data {
+			if err = this.writeItem(e); err != nil {
+				return
+			}
+		}
+	default:
+		return fmt.Errorf("serialize: unsupported value %T", v)
+	}
+	return this.writeAttributes(attrs)
+}
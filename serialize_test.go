@@ -0,0 +1,164 @@
+package r
+
+import "testing"
+import "bytes"
+import "io/ioutil"
+import "math"
+import "path/filepath"
+
+func TestSerializeFixtures(e *testing.T) {
+	named := &Integer{ Data: []int32{ 1, NaInteger } }
+	named.Set("names", NewCharacter("a", "b"))
+	df, _ := NewDataFrame([]string{ "x", "y" }, []Value{ &Integer{ Data: []int32{ 1, 2 } }, NewCharacter("a", "é") })
+	f := NewFactor([]string{ "lo", "hi", "lo", "?" }, []string{ "lo", "hi" })
+	lang, _ := ParseExpr("f(x, y = 1)")
+	call, _ := ExprValue(lang)
+
+	// the values of testdata/serialize/generate.R
+	var tests = []struct {
+		name  string
+		value Value
+	}{
+		{ "null", NullValue },
+		{ "named", named },
+		{ "dataframe", df },
+		{ "factor", f },
+		{ "call", call },
+	}
+
+	for i, test := range tests {
+		expected, err := ioutil.ReadFile(filepath.Join("testdata", "serialize", test.name + ".rds"))
+		if err != nil {
+			e.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Serialize(&buf, test.value, nil); err != nil {
+			e.Error("Test SerializeFixtures[", i, "] Failed with error", err)
+			continue
+		}
+		b := buf.Bytes()
+		// the version of R writing the stream
+		if len(b) >= 10 && len(expected) >= 10 {
+			copy(b[6:10], expected[6:10])
+		}
+		if !bytes.Equal(b, expected) {
+			e.Errorf("Test SerializeFixtures[%s] Failed:\n% x\nexpected:\n% x", test.name, b, expected)
+		}
+		// the stream read and written again
+		v, err := ReadRDS(bytes.NewReader(expected))
+		buf.Reset()
+		if err == nil {
+			err = Serialize(&buf, v, nil)
+		}
+		if b = buf.Bytes(); err != nil || !bytes.Equal(b[10:], expected[10:]) {
+			e.Error("Test SerializeFixtures[", test.name, "] Failed: read and written again", err)
+		}
+	}
+}
+
+func TestSerializeRoundTrip(e *testing.T) {
+	var sources = []string{
+		"function(x, y = 2L) { if (x > y) x[[1]] else -y }",
+		"for (i in 1:10) while (TRUE) { repeat break; next }",
+		"a -> b; x$y <- z %in% c(NA, NA_integer_, NA_real_, NA_character_, Inf, NaN, 2i, NULL)",
+		"f(, 'quote\"s\\n and spaces', ` odd name` = x[, 1])",
+		"~ (a + b) ** 2",
+	}
+	var options = []*SerializeOptions{
+		nil,
+		{ Version: 2 },
+		{ Format: SERIALIZE_BINARY },
+		{ Format: SERIALIZE_ASCII },
+		{ Compress: true },
+	}
+
+	for i, src := range sources {
+		f, err := ParseFile(bytes.NewReader([]byte(src)))
+		if err != nil {
+			e.Fatal(err)
+		}
+		for j, opts := range options {
+			for _, x := range f.Exprs {
+				v, err := ExprValue(x)
+				if err != nil {
+					e.Error("Test SerializeRoundTrip[", i, j, "] Failed with error", err)
+					continue
+				}
+				var buf bytes.Buffer
+				if err = Serialize(&buf, v, opts); err != nil {
+					e.Error("Test SerializeRoundTrip[", i, j, "] Failed with error", err)
+					continue
+				}
+				if v, err = ReadRDS(&buf); err != nil {
+					e.Error("Test SerializeRoundTrip[", i, j, "] Failed with error", err)
+					continue
+				}
+				y, err := ValueExpr(v)
+				if err != nil {
+					e.Error("Test SerializeRoundTrip[", i, j, "] Failed with error", err)
+					continue
+				}
+				// a -> b is read back as b <- a and ** as ^
+				expected := lisp(x)
+				if b, ok := x.(*BinaryExpr); ok && b.Op.Type == OP_RIGHT_ASSIGN {
+					expected = "(<- " + lisp(b.Y) + " " + lisp(b.X) + ")"
+				}
+				if src == sources[4] {
+					expected = "(~ (^ (( (+ a b)) 2))"
+				}
+				if lisp(y) != expected {
+					e.Error("Test SerializeRoundTrip[", i, j, "] Failed: expected", expected, "but got", lisp(y))
+				}
+			}
+		}
+	}
+}
+
+func TestSerializeValues(e *testing.T) {
+	env := &Environment{ Enclos: GlobalEnv, Frame: []Tagged{ { "a", &Real{ Data: []float64{ 1 } } } } }
+	body, _ := ParseExpr("x + a")
+	bodyValue, _ := ExprValue(body)
+	f := &Closure{ Formals: []Tagged{ { "x", MissingArg } }, Body: bodyValue, Env: env }
+	g := &Closure{ Formals: []Tagged{ { "y", &Integer{ Data: []int32{ 2 } } } }, Body: bodyValue, Env: env }
+	values := &List{ Data: []Value{
+		f, g,
+		&Real{ Data: []float64{ 1.5, NaReal, math.NaN(), math.Inf(-1), 1e-300 } },
+		&Character{ Data: []string{ "a b\n\"é?", "" }, NA: []bool{ false, true } },
+		&Logical{ Data: []int32{ 1, 0, NaLogical } },
+		&Complex{ Data: []complex128{ complex(1, -2) } },
+		&Raw{ Data: []byte{ 0, 255 } },
+	} }
+
+	for _, opts := range []*SerializeOptions{ nil, { Format: SERIALIZE_ASCII }, { Format: SERIALIZE_BINARY, Version: 2 } } {
+		var buf bytes.Buffer
+		if err := Serialize(&buf, values, opts); err != nil {
+			e.Fatal(err)
+		}
+		v, _, err := Unserialize(&buf)
+		if err != nil {
+			e.Fatal("Test SerializeValues Failed with error", err)
+		}
+		l := v.(*List)
+		f2, g2 := l.Data[0].(*Closure), l.Data[1].(*Closure)
+		if f2.Env != g2.Env || f2.Env.(*Environment).Get("a") == nil || f2.Formals[0].Value != MissingArg {
+			e.Error("Test SerializeValues Failed: closures", f2, g2)
+		}
+		r := l.Data[2].(*Real)
+		if r.Data[0] != 1.5 || !IsNaReal(r.Data[1]) || !math.IsNaN(r.Data[2]) || IsNaReal(r.Data[2]) || !math.IsInf(r.Data[3], -1) || r.Data[4] != 1e-300 {
+			e.Error("Test SerializeValues Failed: reals", r.Data)
+		}
+		s := l.Data[3].(*Character)
+		if s.Data[0] != "a b\n\"é?" || s.IsNA(0) || !s.IsNA(1) {
+			e.Errorf("Test SerializeValues Failed: strings %q", s.Data)
+		}
+		if b := l.Data[4].(*Logical); b.Data[2] != NaLogical {
+			e.Error("Test SerializeValues Failed: logicals", b.Data)
+		}
+		if c := l.Data[5].(*Complex); c.Data[0] != complex(1, -2) {
+			e.Error("Test SerializeValues Failed: complex", c.Data)
+		}
+		if b := l.Data[6].(*Raw); !bytes.Equal(b.Data, []byte{ 0, 255 }) {
+			e.Error("Test SerializeValues Failed: raw", b.Data)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package r
+
+import "fmt"
+import "strings"
+import "unicode"
+import "unicode/utf8"
+
+// SexpOptions are the options of FormatSexp.
+type SexpOptions struct {
+	// Tree writes the lobstr::ast() tree of the expression instead of the call form
+	Tree bool
+	// Srcref gives the functions the srcref R keeps with options(keep.source = TRUE) instead of NULL
+	Srcref bool
+	// File name of the srcrefs
+	File string
+	// Source the expression was parsed from, giving the bytes of the srcrefs
+	Source []byte
+}
+
+// Sexp returns the expression as R sees it, every operator written as a call: x <- 1 + 2 is `<-`(x, `+`(1, 2)).
+func Sexp(x Expr) (string, error) {
+	return FormatSexp(x, SexpOptions{})
+}
+
+// FormatSexp returns the expression as R sees it: the language object of the expression written in the call
+// form of Sexp, or as the tree lobstr::ast() prints. The ( and { are calls, x[[i]] is `[[`(x, i) and a function
+// definition is a call of function with the pairlist of the formals, the body and the srcref.
+func FormatSexp(x Expr, opts SexpOptions) (string, error) {
+	var srcref func(*FunctionExpr) Value
+	if opts.Srcref {
+		srcref = func(f *FunctionExpr) Value {
+			return Srcref(f, opts.Source, opts.File)
+		}
+	}
+	v, err := exprValue(x, srcref)
+	if err != nil {
+		return "", err
+	}
+	if opts.Tree {
+		return strings.Join(sexpTree(v), "\n") + "\n", nil
+	}
+	var b strings.Builder
+	writeSexp(&b, v)
+	return b.String(), nil
+}
+
+// rQuote returns the string as a double quoted R literal.
+func rQuote(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+	for i, c := range s {
+		if c == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				// a byte in no encoding, as from "\xe9"
+				fmt.Fprintf(&b, "\\x%02x", s[i])
+				continue
+			}
+		}
+		switch c {
+		case '"' :
+			b.WriteString(`\"`)
+		case '\\' :
+			b.WriteString(`\\`)
+		case '\n' :
+			b.WriteString(`\n`)
+		case '\t' :
+			b.WriteString(`\t`)
+		case '\r' :
+			b.WriteString(`\r`)
+		case '\a' :
+			b.WriteString(`\a`)
+		case '\b' :
+			b.WriteString(`\b`)
+		case '\f' :
+			b.WriteString(`\f`)
+		case '\v' :
+			b.WriteString(`\v`)
+		default:
+			if c < 0x80 && !unicode.IsPrint(c) {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// sexpName returns the symbol, backquoted if it is not syntactic.
+func sexpName(name string) string {
+	if isSyntacticName(name) {
+		return name
+	}
+	return "`" + strings.Replace(strings.Replace(name, "\\", "\\\\", -1), "`", "\\`", -1) + "`"
+}
+
+func writeSexp(b *strings.Builder, v Value) {
+	switch x := v.(type) {
+	case *Language :
+		writeSexp(b, x.Fun)
+		b.WriteByte('(')
+		writeSexpArgs(b, x.Args)
+		b.WriteByte(')')
+	case *Pairlist :
+		b.WriteString("pairlist(")
+		writeSexpArgs(b, x.Elements)
+		b.WriteByte(')')
+	case *Symbol :
+		if x != MissingArg {
+			b.WriteString(sexpName(x.Name))
+		}
+	case *Null :
+		b.WriteString("NULL")
+	case *Logical :
+		switch x.Data[0] {
+		case NaLogical :
+			b.WriteString("NA")
+		case 0 :
+			b.WriteString("FALSE")
+		default:
+			b.WriteString("TRUE")
+		}
+	case *Integer :
+		if s := srcrefString(v); s != "" {
+			b.WriteString(s)
+		} else if x.Data[0] == NaInteger {
+			b.WriteString("NA_integer_")
+		} else {
+			fmt.Fprintf(b, "%dL", x.Data[0])
+		}
+	case *Real :
+		if IsNaReal(x.Data[0]) {
+			b.WriteString("NA_real_")
+		} else {
+			b.WriteString(FormatReal(x.Data[0]))
+		}
+	case *Complex :
+		if IsNaReal(real(x.Data[0])) {
+			b.WriteString("NA_complex_")
+		} else {
+			b.WriteString(FormatReal(imag(x.Data[0])) + "i")
+		}
+	case *Character :
+		if x.IsNA(0) {
+			b.WriteString("NA_character_")
+		} else {
+			b.WriteString(rQuote(x.Data[0]))
+		}
+	default:
+		if s := srcrefString(v); s != "" {
+			b.WriteString(s)
+			return
+		}
+		fmt.Fprintf(b, "<%s>", v.Type())
+	}
+}
+
+func writeSexpArgs(b *strings.Builder, args []Tagged) {
+	for i, a := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if a.Tag != "" {
+			b.WriteString(sexpName(a.Tag))
+			if a.Value == MissingArg {
+				b.WriteString(" =")
+				continue
+			}
+			b.WriteString(" = ")
+		}
+		writeSexp(b, a.Value)
+	}
+}
+
+// srcrefString returns a srcref as R prints it without the source: <srcref: file "a.R" chars 1:1 to 1:13>,
+// "" if v is not a srcref.
+func srcrefString(v Value) string {
+	s, ok := v.(*Integer)
+	if !ok || !Inherits(v, "srcref") || len(s.Data) < 6 {
+		return ""
+	}
+	file := ""
+	if env, ok := s.Get("srcfile").(*Environment); ok {
+		if name, ok := env.Get("filename").(*Character); ok && len(name.Data) == 1 {
+			file = name.Data[0]
+		}
+	}
+	return fmt.Sprintf("<srcref: file %s chars %d:%d to %d:%d>", rQuote(file), s.Data[0], s.Data[4], s.Data[2], s.Data[5])
+}
+
+// sexpTree returns the lines of the lobstr::ast() tree of v: a call or a pairlist is a node whose first child is
+// the function or the first element, the constants and the symbols are leaves.
+func sexpTree(v Value) []string {
+	var elements []Tagged
+	switch x := v.(type) {
+	case *Language :
+		elements = append([]Tagged{ { "", x.Fun } }, x.Args...)
+	case *Pairlist :
+		elements = x.Elements
+	case *Symbol :
+		if isSyntacticName(x.Name) {
+			return []string{ x.Name }
+		}
+		return []string{ "`" + strings.Replace(x.Name, "`", "\\`", -1) + "`" }
+	case *Null, *Logical, *Integer, *Real, *Complex, *Character :
+		if Inherits(v, "srcref") {
+			return []string{ "<inline srcref>" }
+		}
+		var b strings.Builder
+		writeSexp(&b, v)
+		return []string{ b.String() }
+	default:
+		return []string{ "<inline " + strings.Join(Class(v), "/") + ">" }
+	}
+
+	var lines []string
+	for i, e := range elements {
+		sub := sexpTree(e.Value)
+		if e.Tag != "" {
+			sub[0] = sexpTree(&Symbol{ e.Tag })[0] + " = " + sub[0]
+		}
+		first, rest := "├─", "│ "
+		switch {
+		case i == 0 :
+			first = "█─"
+			if len(elements) == 1 {
+				rest = "  "
+			}
+		case i == len(elements) - 1 :
+			first, rest = "└─", "  "
+		}
+		lines = append(lines, first + sub[0])
+		for _, l := range sub[1:] {
+			lines = append(lines, rest + l)
+		}
+	}
+	return lines
+}
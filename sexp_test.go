@@ -0,0 +1,112 @@
+package r
+
+import "testing"
+import "fmt"
+import "bytes"
+import "io/ioutil"
+import "path/filepath"
+import "strings"
+
+func TestSexp(e *testing.T) {
+	var tests = []struct {
+		src  string
+		sexp string
+	}{
+		{ "x <- 1 + 2", "`<-`(x, `+`(1, 2))" },
+		{ "y -> x", "`<-`(x, y)" },
+		{ "(a)", "`(`(a)" },
+		{ "{ a; b }", "`{`(a, b)" },
+		{ "x[[1L]]", "`[[`(x, 1L)" },
+		{ "x[, 'a', drop = FALSE]", "`[`(x, , \"a\", drop = FALSE)" },
+		{ "-2i", "`-`(2i)" },
+		{ "`my var` %in% NA_character_", "`%in%`(`my var`, NA_character_)" },
+		{ "f(x = , \"a\\n\")", "f(x =, \"a\\n\")" },
+		{ "if (a) b else c", "`if`(a, b, c)" },
+		{ "c(3000000000L, 0x80000000L, 1.5L, 1e-3L, 0x7fffffffL)", "c(3e+09, 2147483648, 1.5, 0.001, 2147483647L)" },
+		{ "c(\"\\xe9\", \"\\101\")", "c(\"\\xe9\", \"A\")" },
+	}
+	for i, test := range tests {
+		x, err := ParseExpr(test.src)
+		if err != nil {
+			e.Error("Test Sexp[", i, "] Failed with parse error", err)
+			continue
+		}
+		s, err := Sexp(x)
+		if err != nil || s != test.sexp {
+			e.Error("Test Sexp[", i, "] Failed: expected", test.sexp, "instead of", s, err)
+		}
+	}
+}
+
+func TestSexpGolden(e *testing.T) {
+	files, _ := filepath.Glob("testdata/sexp/*.R")
+	if len(files) == 0 {
+		e.Fatal("Test SexpGolden Failed: no test file")
+	}
+	for _, name := range files {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			e.Fatal(err)
+		}
+		f, err := ParseFile(bytes.NewReader(src))
+		if err != nil {
+			e.Error("Test SexpGolden[", name, "] Failed with parse error", err)
+			continue
+		}
+		for _, tree := range []bool{ false, true } {
+			golden := strings.TrimSuffix(name, ".R") + ".sexp"
+			if tree {
+				golden = strings.TrimSuffix(name, ".R") + ".ast"
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				e.Fatal(err)
+			}
+			var out strings.Builder
+			for _, x := range f.Exprs {
+				s, err := FormatSexp(x, SexpOptions{ Tree: tree })
+				if err != nil {
+					e.Error("Test SexpGolden[", golden, "] Failed with error", err)
+				}
+				out.WriteString(s)
+				if !tree {
+					out.WriteByte('\n')
+				}
+			}
+			if out.String() != string(expected) {
+				e.Error("Test SexpGolden[", golden, "] Failed:\n" + out.String())
+			}
+		}
+	}
+}
+
+func TestSexpSrcref(e *testing.T) {
+	src := "g <- function(x)\n  x + 1L"
+	x, _ := ParseExpr(src)
+	s, err := FormatSexp(x, SexpOptions{ Srcref: true, File: "g.R", Source: []byte(src) })
+	if err != nil || s != "`<-`(g, `function`(pairlist(x =), `+`(x, 1L), <srcref: file \"g.R\" chars 1:6 to 2:8>))" {
+		e.Error("Test SexpSrcref Failed:", s, err)
+	}
+	tree, _ := FormatSexp(x, SexpOptions{ Srcref: true, Tree: true, Source: []byte(src) })
+	if !strings.HasSuffix(tree, "  └─<inline srcref>\n") {
+		e.Error("Test SexpSrcref Failed: tree\n" + tree)
+	}
+
+	// the bytes are counted from the start of the line, the end is the one of the last token
+	var tests = []struct {
+		src    string
+		srcref string
+	}{
+		{ "g <- function(x)\n  x + 1L", "[1 6 2 8 6 8 1 2]" },
+		{ "g <- \tfunction(x) x", "[1 7 1 19 9 21 1 1]" },
+		{ "é <- function(x) x", "[1 7 1 19 6 18 1 1]" },
+		{ "g <- function(x) \"a\nb\"", "[1 6 2 2 6 2 1 2]" },
+	}
+	for i, test := range tests {
+		x, _ := ParseExpr(test.src)
+		ref := Srcref(x.(*BinaryExpr).Y, []byte(test.src), "g.R")
+		if fmt.Sprint(ref.Data) != test.srcref || !Inherits(ref, "srcref") {
+			e.Error("Test SexpSrcref[", i, "] Failed:", ref.Data)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package r
+
+import "bytes"
+import "embed"
+import "fmt"
+import "io"
+import "strings"
+import "sync"
+
+//go:embed signatures/*.R
+var signatureFiles embed.FS
+
+// SearchPath are the packages attached by R at startup, in search order.
+var SearchPath = []string{ "stats", "graphics", "grDevices", "utils", "datasets", "methods", "base" }
+
+// Signature is the definition of the formals of a function.
+type Signature struct {
+	Package string
+	Name    string
+	// Formals with their default values
+	Formals []*Formal
+	// Name of the primitive for a .Primitive("name") body, "" for a closure: R gives no formals to the
+	// primitives, they are the ones of args()
+	Primitive string
+	// Text of the definition in the source: function(x, y = 2)
+	Text string
+}
+
+var signatures struct {
+	once     sync.Once
+	version  string
+	packages map[string]map[string]*Signature
+}
+
+// loadSignatures parses the embedded signature files.
+func loadSignatures() {
+	signatures.packages = make(map[string]map[string]*Signature)
+	files, _ := signatureFiles.ReadDir("signatures")
+	for _, f := range files {
+		src, err := signatureFiles.ReadFile("signatures/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		pkg := strings.TrimSuffix(f.Name(), ".R")
+		sigs, err := ExtractSignatures(pkg, bytes.NewReader(src))
+		if err != nil {
+			panic(fmt.Sprintf("signatures/%s: %s", f.Name(), err))
+		}
+		signatures.packages[pkg] = make(map[string]*Signature)
+		for _, s := range sigs {
+			signatures.packages[pkg][s.Name] = s
+		}
+		if signatures.version == "" {
+			line := string(src[:bytes.IndexByte(src, '\n')])
+			signatures.version = line[strings.LastIndexByte(line, ' ')+1:]
+		}
+	}
+}
+
+// SignatureVersion returns the version of R of the signature database.
+func SignatureVersion() string {
+	signatures.once.Do(loadSignatures)
+	return signatures.version
+}
+
+// LookupFunction returns the signature of the function name of the package pkg, or of the first package of the
+// SearchPath defining it when pkg is "". The database holds a subset of base, stats, utils, methods, graphics and
+// grDevices: a function it does not know may exist in R.
+func LookupFunction(pkg, name string) (*Signature, bool) {
+	signatures.once.Do(loadSignatures)
+	if pkg != "" {
+		s, ok := signatures.packages[pkg][name]
+		return s, ok
+	}
+	for _, p := range SearchPath {
+		if s, ok := signatures.packages[p][name]; ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// ExtractSignatures returns the signatures of the functions assigned at the top level of the R source of the
+// package pkg: name <- function(...) and name = function(...). A function defined twice keeps its last
+// definition. A body calling .Primitive("name") makes a primitive, as do the functions zzz.R of base assigns in
+// .ArgsEnv and .GenericArgsEnv.
+func ExtractSignatures(pkg string, r io.Reader) (sigs []*Signature, err error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	f, err := ParseFile(bytes.NewReader(src))
+	if err != nil {
+		return
+	}
+	index := make(map[string]int)
+	add := func(s *Signature) {
+		if i, ok := index[s.Name]; ok {
+			sigs[i] = s
+		} else {
+			index[s.Name] = len(sigs)
+			sigs = append(sigs, s)
+		}
+	}
+	env := &argsEnv{ pkg: pkg, src: src, names: make(map[string][]string), funs: make(map[string]*FunctionExpr), add: add }
+	for _, x := range f.Exprs {
+		if c, ok := x.(*CallExpr); ok && isCallTo(c, "assign") {
+			for _, a := range c.Args {
+				if a.Name != nil && a.Name.stringvalue == "envir" && isIdentNamed(a.Value, ".ArgsEnv") {
+					env.assign(c)
+				}
+			}
+			continue
+		}
+		b, ok := x.(*BinaryExpr)
+		if !ok || (b.Op.Type != OP_LEFT_ASSIGN && b.Op.Type != OP_EQUAL_ASSIGN) {
+			continue
+		}
+		var id string
+		switch n := b.X.(type) {
+		case *Ident :
+			id = n.Token.stringvalue
+		case *Constant :
+			if n.Token.Type != CONST_CHARACTER {
+				continue
+			}
+			id = n.Token.stringvalue
+		default:
+			continue
+		}
+		fn, ok := b.Y.(*FunctionExpr)
+		if !ok {
+			// the character vectors the loops of .GenericArgsEnv iterate on
+			if names, ok := env.strings(b.Y); ok {
+				env.names[id] = names
+			}
+			if id == ".GenericArgsEnv" {
+				env.walk(b.Y)
+			}
+			continue
+		}
+		s := &Signature{ Package: pkg, Name: id, Formals: fn.Formals, Text: signatureText(src, fn) }
+		if c, ok := fn.Body.(*CallExpr); ok && isCallTo(c, ".Primitive") && len(c.Args) == 1 {
+			if v, ok := c.Args[0].Value.(*Constant); ok && v.Token.Type == CONST_CHARACTER {
+				s.Primitive = v.Token.stringvalue
+			}
+		}
+		add(s)
+	}
+	return
+}
+
+// signatureText returns the text of the function up to its body.
+func signatureText(src []byte, fn *FunctionExpr) string {
+	return strings.TrimSpace(string(src[fn.Pos().Offset:fn.Body.Pos().Offset]))
+}
+
+// isCallTo reports whether c calls the function name.
+func isCallTo(c *CallExpr, name string) bool {
+	return isIdentNamed(c.Fun, name)
+}
+
+// isIdentNamed reports whether x is the symbol name.
+func isIdentNamed(x Expr, name string) bool {
+	id, ok := x.(*Ident)
+	return ok && id.Token.stringvalue == name
+}
+
+// argsEnv gathers the formals of the primitives, which have none in R, from the definitions of .ArgsEnv and
+// .GenericArgsEnv of zzz.R: assign("name", function(...) NULL, envir = .ArgsEnv), and the loops of the local()
+// defining .GenericArgsEnv, assigning a function variable to each name of a character vector.
+type argsEnv struct {
+	pkg string
+	src []byte
+	// Character vectors and function variables
+	names map[string][]string
+	funs  map[string]*FunctionExpr
+	add   func(*Signature)
+}
+
+func (this *argsEnv) walk(x Expr) {
+	switch v := x.(type) {
+	case *BlockExpr :
+		for _, y := range v.List {
+			this.walk(y)
+		}
+	case *ForExpr :
+		if names, ok := this.strings(v.Seq); ok {
+			for _, name := range names {
+				this.names[v.Var.stringvalue] = []string{ name }
+				this.walk(v.Body)
+			}
+		}
+	case *BinaryExpr :
+		if id, ok := v.X.(*Ident); ok && (v.Op.Type == OP_LEFT_ASSIGN || v.Op.Type == OP_EQUAL_ASSIGN) {
+			if fn, ok := v.Y.(*FunctionExpr); ok {
+				this.funs[id.Token.stringvalue] = fn
+				return
+			}
+			if names, ok := this.strings(v.Y); ok {
+				this.names[id.Token.stringvalue] = names
+				return
+			}
+		}
+		this.walk(v.Y)
+	case *CallExpr :
+		switch {
+		case isCallTo(v, "local") && len(v.Args) > 0 && v.Args[0].Value != nil :
+			this.walk(v.Args[0].Value)
+		case isCallTo(v, "assign") :
+			this.assign(v)
+		}
+	}
+}
+
+// assign adds the primitive of assign(name, value, envir = env), value being a function or a function variable.
+func (this *argsEnv) assign(c *CallExpr) {
+	if len(c.Args) < 2 || c.Args[0].Value == nil {
+		return
+	}
+	names, ok := this.strings(c.Args[0].Value)
+	if !ok || len(names) != 1 {
+		return
+	}
+	fn, ok := c.Args[1].Value.(*FunctionExpr)
+	if id, isIdent := c.Args[1].Value.(*Ident); isIdent {
+		fn, ok = this.funs[id.Token.stringvalue]
+	}
+	if ok {
+		this.add(&Signature{ Package: this.pkg, Name: names[0], Formals: fn.Formals, Text: signatureText(this.src, fn), Primitive: names[0] })
+	}
+}
+
+// strings returns the value of a string, of c() of strings or of a character vector variable.
+func (this *argsEnv) strings(x Expr) ([]string, bool) {
+	switch v := x.(type) {
+	case *Constant :
+		return []string{ v.Token.stringvalue }, v.Token.Type == CONST_CHARACTER
+	case *Ident :
+		names, ok := this.names[v.Token.stringvalue]
+		return names, ok
+	case *CallExpr :
+		if !isCallTo(v, "c") {
+			return nil, false
+		}
+		var names []string
+		for _, a := range v.Args {
+			c, ok := a.Value.(*Constant)
+			if !ok || c.Token.Type != CONST_CHARACTER {
+				return nil, false
+			}
+			names = append(names, c.Token.stringvalue)
+		}
+		return names, true
+	}
+	return nil, false
+}
+
+// IsPrimitive reports whether the function is a primitive.
+func (this *Signature) IsPrimitive() bool {
+	return this.Primitive != ""
+}
+
+// WriteSignatures writes the signatures in the format of the signature database: a first line giving the package
+// and the version of R, then one definition per line with a NULL body, or a .Primitive() body for a primitive.
+func WriteSignatures(w io.Writer, pkg, version string, sigs []*Signature) (err error) {
+	if _, err = fmt.Fprintf(w, "# Formals of the %s package of R %s\n", pkg, version); err != nil {
+		return
+	}
+	for _, s := range sigs {
+		name := s.Name
+		if !IsSyntacticName(name, LOCALE_C) {
+			name = "`" + strings.Replace(name, "`", "\\`", -1) + "`"
+		}
+		body := "NULL"
+		if s.Primitive != "" {
+			body = fmt.Sprintf(".Primitive(%q)", s.Primitive)
+		}
+		if _, err = fmt.Fprintf(w, "%s <- %s %s\n", name, s.Text, body); err != nil {
+			return
+		}
+	}
+	return
+}
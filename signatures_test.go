@@ -0,0 +1,120 @@
+package r
+
+import "bytes"
+import "strings"
+import "testing"
+
+func TestLookupFunction(e *testing.T) {
+	tests := []struct {
+		pkg, name string
+		found     string
+		formals   string
+		primitive string
+	}{
+		{ "", "paste", "base", "... sep collapse recycle0", "" },
+		{ "base", "sum", "base", "... na.rm", "sum" },
+		{ "", "as.numeric", "base", "x ...", "as.double" },
+		{ "", "sd", "stats", "x na.rm", "" },
+		{ "utils", "head", "utils", "x ...", "" },
+		{ "", "setClass", "methods", "Class representation prototype contains validity access where version sealed package S3methods slots", "" },
+		{ "", "plot", "graphics", "x y ...", "" },
+		{ "grDevices", "dev.off", "grDevices", "which", "" },
+		{ "", "Sys.time", "base", "", "" },
+		{ "stats", "paste", "", "", "" },
+		{ "", "mutate", "", "", "" },
+	}
+	for i, test := range tests {
+		s, ok := LookupFunction(test.pkg, test.name)
+		if !ok {
+			if test.found != "" {
+				e.Error("Test LookupFunction[", i, "] Failed: not found")
+			}
+			continue
+		}
+		var formals []string
+		for _, f := range s.Formals {
+			formals = append(formals, f.Name.Value())
+		}
+		if s.Package != test.found || s.Name != test.name || strings.Join(formals, " ") != test.formals || s.Primitive != test.primitive {
+			e.Error("Test LookupFunction[", i, "] Failed:", s.Package, formals, s.Primitive)
+		}
+	}
+
+	s, _ := LookupFunction("base", "log")
+	if s.Formals[0].Default != nil || s.Formals[1].Default.(*CallExpr).Fun.(*Ident).Token.Value() != "exp" || !s.IsPrimitive() {
+		e.Error("Test LookupFunction Failed: defaults of log")
+	}
+	if SignatureVersion() != "4.3.1" {
+		e.Error("Test LookupFunction Failed: version", SignatureVersion())
+	}
+}
+
+func TestExtractSignatures(e *testing.T) {
+	src := "f <- function(x, y = 2) x + y\n" +
+		"`%+%` = function(e1, e2) NULL\n" +
+		"h <- 1\n" +
+		"if (TRUE) k <- function() NULL\n" +
+		"f <- function(x,\n              ...) {\n  x\n}\n" +
+		"abs <- function(x) .Primitive(\"abs\")\n"
+	sigs, err := ExtractSignatures("pkg", strings.NewReader(src))
+	if err != nil {
+		e.Fatal(err)
+	}
+	var names []string
+	for _, s := range sigs {
+		names = append(names, s.Name + ":" + s.Text)
+	}
+	if strings.Join(names, ";") != "f:function(x,\n              ...);%+%:function(e1, e2);abs:function(x)" || sigs[2].Primitive != "abs" {
+		e.Error("Test ExtractSignatures Failed:", strings.Join(names, ";"))
+	}
+
+	var b bytes.Buffer
+	if err = WriteSignatures(&b, "pkg", "4.3.1", sigs); err != nil {
+		e.Fatal(err)
+	}
+	expected := "# Formals of the pkg package of R 4.3.1\n" +
+		"f <- function(x,\n              ...) NULL\n" +
+		"`%+%` <- function(e1, e2) NULL\n" +
+		"abs <- function(x) .Primitive(\"abs\")\n"
+	if b.String() != expected {
+		e.Error("Test ExtractSignatures Failed: write", b.String())
+	}
+	again, err := ExtractSignatures("pkg", &b)
+	if err != nil || len(again) != len(sigs) {
+		e.Error("Test ExtractSignatures Failed: round trip", err)
+	}
+}
+
+func TestExtractPrimitives(e *testing.T) {
+	// the definitions of zzz.R of base
+	src := ".ArgsEnv <- new.env(hash = TRUE, parent = emptyenv())\n" +
+		"assign(\"%*%\", function(x, y) NULL, envir = .ArgsEnv)\n" +
+		"assign(\"nargs\", function() NULL, envir = .ArgsEnv)\n" +
+		"assign(\"x\", function() NULL, envir = elsewhere)\n" +
+		".S3PrimitiveGenerics <- c(\"anyNA\", \"as.character\")\n" +
+		".GenericArgsEnv <- local({\n" +
+		"  env <- new.env(hash = TRUE, parent = emptyenv())\n" +
+		"  for(f in .S3PrimitiveGenerics) {\n" +
+		"    fx <- function(x) {}\n" +
+		"    body(fx) <- substitute(UseMethod(ff), list(ff = f))\n" +
+		"    assign(f, fx, envir = env)\n" +
+		"  }\n" +
+		"  fx <- function(e1, e2) {}\n" +
+		"  for(f in c(\"+\", \"-\")) assign(f, fx, envir = env)\n" +
+		"  assign(\"as.character\", function(x, ...) UseMethod(\"as.character\"), envir = env)\n" +
+		"  env\n" +
+		"})\n"
+	sigs, err := ExtractSignatures("base", strings.NewReader(src))
+	if err != nil {
+		e.Fatal(err)
+	}
+	var names []string
+	for _, s := range sigs {
+		names = append(names, s.Name + ":" + s.Text + ":" + s.Primitive)
+	}
+	expected := "%*%:function(x, y):%*%;nargs:function():nargs;anyNA:function(x):anyNA;" +
+		"as.character:function(x, ...):as.character;+:function(e1, e2):+;-:function(e1, e2):-"
+	if strings.Join(names, ";") != expected {
+		e.Error("Test ExtractPrimitives Failed:", strings.Join(names, ";"))
+	}
+}
@@ -0,0 +1,504 @@
+package r
+
+import "fmt"
+import "math"
+import "strconv"
+
+// SexpType is the type of an R object, as given by typeof() and used by the serialization format.
+type SexpType int
+
+// The R object types.
+const (
+	NILSXP     SexpType = 0  // NULL
+	SYMSXP     SexpType = 1  // symbols
+	LISTSXP    SexpType = 2  // pairlists
+	CLOSXP     SexpType = 3  // closures
+	ENVSXP     SexpType = 4  // environments
+	PROMSXP    SexpType = 5  // promises
+	LANGSXP    SexpType = 6  // language objects
+	SPECIALSXP SexpType = 7  // special functions
+	BUILTINSXP SexpType = 8  // builtin functions
+	CHARSXP    SexpType = 9  // internal strings
+	LGLSXP     SexpType = 10 // logical vectors
+	INTSXP     SexpType = 13 // integer vectors
+	REALSXP    SexpType = 14 // real vectors
+	CPLXSXP    SexpType = 15 // complex vectors
+	STRSXP     SexpType = 16 // character vectors
+	DOTSXP     SexpType = 17 // ... object
+	ANYSXP     SexpType = 18 // any type
+	VECSXP     SexpType = 19 // lists
+	EXPRSXP    SexpType = 20 // expression vectors
+	BCODESXP   SexpType = 21 // byte code
+	EXTPTRSXP  SexpType = 22 // external pointers
+	WEAKREFSXP SexpType = 23 // weak references
+	RAWSXP     SexpType = 24 // raw vectors
+	S4SXP      SexpType = 25 // S4 objects
+)
+
+var sexpTypeNames = map[SexpType]string{
+	NILSXP: "NULL", SYMSXP: "symbol", LISTSXP: "pairlist", CLOSXP: "closure", ENVSXP: "environment",
+	PROMSXP: "promise", LANGSXP: "language", SPECIALSXP: "special", BUILTINSXP: "builtin", CHARSXP: "char",
+	LGLSXP: "logical", INTSXP: "integer", REALSXP: "double", CPLXSXP: "complex", STRSXP: "character",
+	DOTSXP: "...", ANYSXP: "any", VECSXP: "list", EXPRSXP: "expression", BCODESXP: "bytecode",
+	EXTPTRSXP: "externalptr", WEAKREFSXP: "weakref", RAWSXP: "raw", S4SXP: "S4",
+}
+
+// String returns the name of the type as given by typeof().
+func (this SexpType) String() string {
+	if s, ok := sexpTypeNames[this]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// NA values of the vectors.
+const (
+	// NA of the logical and integer vectors
+	NaInteger int32 = math.MinInt32
+	NaLogical int32 = math.MinInt32
+)
+
+// NaReal is the NA of the real vectors: a NaN whose low word is 1954.
+var NaReal = math.Float64frombits(0x7ff00000000007a2)
+
+// IsNaReal reports whether f is NA, and not any other NaN.
+func IsNaReal(f float64) bool {
+	return math.IsNaN(f) && uint32(math.Float64bits(f)) == 1954
+}
+
+// FormatReal formats a double as as.character() does: 15 significant digits, in fixed notation unless the
+// scientific notation is shorter, 1e+05 and 1e-04.
+func FormatReal(f float64) string {
+	switch {
+	case IsNaReal(f) :
+		return "NA"
+	case math.IsNaN(f) :
+		return "NaN"
+	case math.IsInf(f, 1) :
+		return "Inf"
+	case math.IsInf(f, -1) :
+		return "-Inf"
+	case f == 0 :
+		// -0 too
+		return "0"
+	}
+	g, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	fixed := strconv.FormatFloat(g, 'f', -1, 64)
+	if sci := strconv.FormatFloat(g, 'e', -1, 64); len(sci) < len(fixed) {
+		return sci
+	}
+	return fixed
+}
+
+// Value is an R object.
+type Value interface {
+	Type() SexpType
+	// Attributes of the object, nil for the objects that can not have attributes
+	Attributes() *Attrs
+}
+
+// Attribute is a named attribute of an R object.
+type Attribute struct {
+	Name  string
+	Value Value
+}
+
+// Attrs is the ordered list of the attributes of an R object.
+type Attrs struct {
+	List []Attribute
+}
+
+// Attributes returns the attributes, so that all the objects embedding Attrs implement Value.Attributes.
+func (this *Attrs) Attributes() *Attrs {
+	return this
+}
+
+// Get returns the value of the attribute name, nil if there is none.
+func (this *Attrs) Get(name string) Value {
+	for _, a := range this.List {
+		if a.Name == name {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+// Set replaces or appends the attribute name, a nil or NULL value removes it.
+func (this *Attrs) Set(name string, v Value) {
+	for i, a := range this.List {
+		if a.Name == name {
+			if v == nil || v.Type() == NILSXP {
+				this.List = append(this.List[:i], this.List[i+1:]...)
+			} else {
+				this.List[i].Value = v
+			}
+			return
+		}
+	}
+	if v != nil && v.Type() != NILSXP {
+		this.List = append(this.List, Attribute{ name, v })
+	}
+}
+
+// Null is the NULL object.
+type Null struct{}
+
+// NullValue is the NULL object.
+var NullValue = &Null{}
+
+func (this *Null) Type() SexpType { return NILSXP }
+func (this *Null) Attributes() *Attrs { return nil }
+
+// Symbol is a symbol (a name).
+type Symbol struct {
+	Name string
+}
+
+// MissingArg is the empty symbol of the missing arguments and of the formals without default value.
+var MissingArg = &Symbol{ "" }
+
+// UnboundValue is the value of the unbound variables.
+var UnboundValue = &Symbol{ "<unbound>" }
+
+func (this *Symbol) Type() SexpType { return SYMSXP }
+func (this *Symbol) Attributes() *Attrs { return nil }
+
+// Tagged is an element of a pairlist, a language object or a closure formals: an optional tag and a value.
+type Tagged struct {
+	// Tag of the element, "" if there is none
+	Tag   string
+	Value Value
+}
+
+// Pairlist is a pairlist (LISTSXP) or a ... object (DOTSXP).
+type Pairlist struct {
+	Attrs
+	Elements []Tagged
+	Dots     bool
+}
+
+func (this *Pairlist) Type() SexpType {
+	if this.Dots {
+		return DOTSXP
+	}
+	return LISTSXP
+}
+
+// Language is a call: the function and its tagged arguments.
+type Language struct {
+	Attrs
+	Fun  Value
+	Args []Tagged
+}
+
+func (this *Language) Type() SexpType { return LANGSXP }
+
+// Closure is a function defined in R.
+type Closure struct {
+	Attrs
+	Formals []Tagged
+	Body    Value
+	Env     Value
+}
+
+func (this *Closure) Type() SexpType { return CLOSXP }
+
+// Environment is an environment, the global, base and empty environments and the package and namespace
+// environments are not serialized: they are only given by their Name.
+type Environment struct {
+	Attrs
+	// "R_GlobalEnv", "base", "R_EmptyEnv", "namespace:pkg", "package:pkg" or "" for a serialized environment
+	Name string
+	// Name and version of a namespace, names of a package environment
+	Spec    []string
+	Locked  bool
+	Enclos  Value
+	// Variables of the environment: the frame pairlist followed by the hashed variables
+	Frame []Tagged
+	// Special environment a global, base environment or base namespace of a Session stands for
+	special *Environment
+	// Environment holding the variables: the base namespace shares those of the base environment
+	vars *Environment
+}
+
+func (this *Environment) Type() SexpType { return ENVSXP }
+
+// The special environments as the serialized objects refer to them: the search path goes from the global
+// environment to the base environment, the base namespace is enclosed by the global environment. They hold no
+// variables: the variables are those of the environments of a Session, see Session.Resolve.
+var (
+	GlobalEnv     = &Environment{ Name: "R_GlobalEnv", Enclos: BaseEnv }
+	BaseEnv       = &Environment{ Name: "base", Enclos: EmptyEnv }
+	EmptyEnv      = &Environment{ Name: "R_EmptyEnv" }
+	BaseNamespace = &Environment{ Name: "namespace:base", Enclos: GlobalEnv, vars: BaseEnv }
+)
+
+// specialOf returns the special environment e is or stands for, nil for the other environments.
+func specialOf(e *Environment) *Environment {
+	switch e {
+	case GlobalEnv, BaseEnv, EmptyEnv, BaseNamespace :
+		return e
+	}
+	return e.special
+}
+
+// Get returns the value of the variable name of the environment, nil if it is not defined in the frame.
+func (this *Environment) Get(name string) Value {
+	for _, t := range this.Frame {
+		if t.Tag == name {
+			return t.Value
+		}
+	}
+	return nil
+}
+
+// Promise is a delayed evaluation of Expr in Env.
+type Promise struct {
+	Value Value
+	Expr  Value
+	Env   Value
+	// Default is true for the promise of the default value of a missing argument
+	Default bool
+	// The promise is being forced
+	forcing bool
+}
+
+func (this *Promise) Type() SexpType { return PROMSXP }
+func (this *Promise) Attributes() *Attrs { return nil }
+
+// Builtin is a primitive function: a builtin or a special.
+type Builtin struct {
+	Attrs
+	Name    string
+	Special bool
+}
+
+func (this *Builtin) Type() SexpType {
+	if this.Special {
+		return SPECIALSXP
+	}
+	return BUILTINSXP
+}
+
+// Logical is a logical vector, NA is NaLogical.
+type Logical struct {
+	Attrs
+	Data []int32
+}
+
+func (this *Logical) Type() SexpType { return LGLSXP }
+
+// Integer is an integer vector, NA is NaInteger.
+type Integer struct {
+	Attrs
+	Data []int32
+}
+
+func (this *Integer) Type() SexpType { return INTSXP }
+
+// Real is a double vector, NA is NaReal.
+type Real struct {
+	Attrs
+	Data []float64
+}
+
+func (this *Real) Type() SexpType { return REALSXP }
+
+// Complex is a complex vector, NA has a NaReal real part.
+type Complex struct {
+	Attrs
+	Data []complex128
+}
+
+func (this *Complex) Type() SexpType { return CPLXSXP }
+
+// Character is a character vector, NA[i] is true for the NA strings (NA is nil if there are none).
+type Character struct {
+	Attrs
+	Data []string
+	NA   []bool
+}
+
+func (this *Character) Type() SexpType { return STRSXP }
+
+// IsNA reports whether the element i is NA.
+func (this *Character) IsNA(i int) bool {
+	return this.NA != nil && this.NA[i]
+}
+
+// Raw is a raw vector.
+type Raw struct {
+	Attrs
+	Data []byte
+}
+
+func (this *Raw) Type() SexpType { return RAWSXP }
+
+// List is a generic vector (VECSXP) or an expression vector (EXPRSXP).
+type List struct {
+	Attrs
+	Data       []Value
+	Expression bool
+}
+
+func (this *List) Type() SexpType {
+	if this.Expression {
+		return EXPRSXP
+	}
+	return VECSXP
+}
+
+// Bytecode is a compiled closure body: Consts[0] is the original expression.
+type Bytecode struct {
+	Code   Value
+	Consts []Value
+}
+
+func (this *Bytecode) Type() SexpType { return BCODESXP }
+func (this *Bytecode) Attributes() *Attrs { return nil }
+
+// ExternalPointer is an external pointer, the address itself is never serialized.
+type ExternalPointer struct {
+	Attrs
+	Prot Value
+	Tag  Value
+}
+
+func (this *ExternalPointer) Type() SexpType { return EXTPTRSXP }
+
+// WeakRef is a weak reference, its content is not serialized.
+type WeakRef struct {
+	Attrs
+}
+
+func (this *WeakRef) Type() SexpType { return WEAKREFSXP }
+
+// S4Object is an S4 object without data part, the slots are its attributes.
+type S4Object struct {
+	Attrs
+}
+
+func (this *S4Object) Type() SexpType { return S4SXP }
+
+// Length returns the length of a vector, of a pairlist or of a call as length() does, 1 for the other objects.
+func Length(v Value) int {
+	switch x := v.(type) {
+	case *Null :
+		return 0
+	case *Logical :
+		return len(x.Data)
+	case *Integer :
+		return len(x.Data)
+	case *Real :
+		return len(x.Data)
+	case *Complex :
+		return len(x.Data)
+	case *Character :
+		return len(x.Data)
+	case *Raw :
+		return len(x.Data)
+	case *List :
+		return len(x.Data)
+	case *Pairlist :
+		return len(x.Elements)
+	case *Language :
+		return len(x.Args) + 1
+	}
+	return 1
+}
+
+// NewCharacter returns a character vector without NA.
+func NewCharacter(s ...string) *Character {
+	return &Character{ Data: s }
+}
+
+// NewDataFrame returns a data.frame of the columns, all the columns must have the same length.
+// The row names are the compact c(NA, -nrow) form R uses for automatic row names.
+func NewDataFrame(names []string, columns []Value) (*List, error) {
+	if len(names) != len(columns) {
+		return nil, fmt.Errorf("data.frame: %d names for %d columns", len(names), len(columns))
+	}
+	nrow := 0
+	for i, c := range columns {
+		if i == 0 {
+			nrow = Length(c)
+		} else if Length(c) != nrow {
+			return nil, fmt.Errorf("data.frame: column %s has %d rows instead of %d", names[i], Length(c), nrow)
+		}
+	}
+	df := &List{ Data: columns }
+	df.Set("names", NewCharacter(names...))
+	df.Set("class", NewCharacter("data.frame"))
+	df.Set("row.names", &Integer{ Data: []int32{ NaInteger, int32(-nrow) } })
+	return df, nil
+}
+
+// NewFactor returns the factor of the values with the given levels, the values that are not levels are NA.
+func NewFactor(values []string, levels []string) *Integer {
+	index := make(map[string]int32)
+	for i, l := range levels {
+		index[l] = int32(i + 1)
+	}
+	f := &Integer{ Data: make([]int32, len(values)) }
+	for i, v := range values {
+		if code, ok := index[v]; ok {
+			f.Data[i] = code
+		} else {
+			f.Data[i] = NaInteger
+		}
+	}
+	f.Set("levels", NewCharacter(levels...))
+	f.Set("class", NewCharacter("factor"))
+	return f
+}
+
+// Class returns the class of v as class() does: the class attribute or the implicit class.
+func Class(v Value) []string {
+	if a := v.Attributes(); a != nil {
+		if c, ok := a.Get("class").(*Character); ok {
+			return c.Data
+		}
+		if dim := Dim(v); dim != nil {
+			if len(dim) == 2 {
+				return []string{ "matrix", "array" }
+			}
+			return []string{ "array" }
+		}
+	}
+	switch x := v.(type) {
+	case *Closure, *Builtin :
+		return []string{ "function" }
+	case *Real :
+		return []string{ "numeric" }
+	case *Symbol :
+		return []string{ "name" }
+	case *Language :
+		if s, ok := x.Fun.(*Symbol); ok {
+			switch s.Name {
+			case "if", "for", "while", "(", "{", "<-", "=" :
+				return []string{ s.Name }
+			}
+		}
+		return []string{ "call" }
+	}
+	return []string{ v.Type().String() }
+}
+
+// Dim returns the dimensions of v, nil if it has no dim attribute.
+func Dim(v Value) (dim []int) {
+	a := v.Attributes()
+	if a == nil {
+		return nil
+	}
+	switch d := a.Get("dim").(type) {
+	case *Integer :
+		for _, n := range d.Data {
+			dim = append(dim, int(n))
+		}
+	case *Real :
+		for _, n := range d.Data {
+			dim = append(dim, int(n))
+		}
+	}
+	return
+}
@@ -0,0 +1,288 @@
+package r
+
+import "errors"
+import "fmt"
+import "math"
+import "time"
+
+// NA returns a vector of length 1 holding the NA of the type: LGLSXP, INTSXP, REALSXP, CPLXSXP or STRSXP.
+// These are the values of the NA_LOGICAL, NA_INTEGER, NA_REAL, NA_COMPLEX and NA_CHARACTER tokens.
+func NA(typ SexpType) Value {
+	switch typ {
+	case LGLSXP :
+		return &Logical{ Data: []int32{ NaLogical } }
+	case INTSXP :
+		return &Integer{ Data: []int32{ NaInteger } }
+	case REALSXP :
+		return &Real{ Data: []float64{ NaReal } }
+	case CPLXSXP :
+		return &Complex{ Data: []complex128{ complex(NaReal, 0) } }
+	case STRSXP :
+		return &Character{ Data: []string{ "" }, NA: []bool{ true } }
+	}
+	return nil
+}
+
+// IsNA reports whether the element i of a vector is NA as is.na() does: NaN is NA for the real vectors.
+func IsNA(v Value, i int) bool {
+	switch x := v.(type) {
+	case *Logical :
+		return x.Data[i] == NaLogical
+	case *Integer :
+		return x.Data[i] == NaInteger
+	case *Real :
+		return math.IsNaN(x.Data[i])
+	case *Complex :
+		return math.IsNaN(real(x.Data[i])) || math.IsNaN(imag(x.Data[i]))
+	case *Character :
+		return x.IsNA(i)
+	case *List :
+		return Length(x.Data[i]) == 1 && IsNA(x.Data[i], 0)
+	}
+	return false
+}
+
+// attrStrings returns the strings of a character attribute, nil if the attribute is not a character vector.
+func attrStrings(v Value, name string) []string {
+	if a := v.Attributes(); a != nil {
+		if c, ok := a.Get(name).(*Character); ok {
+			return c.Data
+		}
+	}
+	return nil
+}
+
+// Names returns the names attribute of v, nil if there is none.
+func Names(v Value) []string {
+	switch x := v.(type) {
+	case *Pairlist :
+		names := make([]string, len(x.Elements))
+		for i, t := range x.Elements {
+			names[i] = t.Tag
+		}
+		return names
+	}
+	return attrStrings(v, "names")
+}
+
+// Levels returns the levels attribute of a factor.
+func Levels(v Value) []string {
+	return attrStrings(v, "levels")
+}
+
+// DimNames returns the names of the dimensions of an array, a nil element for a dimension without names.
+func DimNames(v Value) (names [][]string) {
+	a := v.Attributes()
+	if a == nil {
+		return nil
+	}
+	l, ok := a.Get("dimnames").(*List)
+	if !ok {
+		return nil
+	}
+	for _, d := range l.Data {
+		if c, ok := d.(*Character); ok {
+			names = append(names, c.Data)
+		} else {
+			names = append(names, nil)
+		}
+	}
+	return
+}
+
+// Inherits reports whether class is one of the classes of v.
+func Inherits(v Value, class string) bool {
+	for _, c := range Class(v) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// DataFrame is a view of a data.frame.
+type DataFrame struct {
+	Names   []string
+	Columns []Value
+	NRow    int
+	// Row names, nil for the automatic row names 1..NRow
+	RowNames []string
+}
+
+// AsDataFrame returns the view of a data.frame: a list with the data.frame class.
+func AsDataFrame(v Value) (df *DataFrame, err error) {
+	l, ok := v.(*List)
+	if !ok || l.Expression || !Inherits(v, "data.frame") {
+		return nil, errors.New("not a data.frame")
+	}
+	df = &DataFrame{ Names: Names(v), Columns: l.Data }
+	if len(df.Names) != len(df.Columns) {
+		return nil, errors.New("data.frame: names and columns differ in length")
+	}
+
+	// The row names are c(NA, -n) or c(NA, n) when they are automatic
+	switch r := l.Get("row.names").(type) {
+	case *Integer :
+		if len(r.Data) == 2 && r.Data[0] == NaInteger {
+			df.NRow = int(r.Data[1])
+			if df.NRow < 0 {
+				df.NRow = -df.NRow
+			}
+		} else {
+			df.NRow = len(r.Data)
+			for _, n := range r.Data {
+				df.RowNames = append(df.RowNames, fmt.Sprint(n))
+			}
+		}
+	case *Character :
+		df.NRow = len(r.Data)
+		df.RowNames = r.Data
+	case nil :
+		if len(df.Columns) > 0 {
+			df.NRow = Length(df.Columns[0])
+		}
+	default:
+		return nil, errors.New("data.frame: invalid row.names")
+	}
+	for i, c := range df.Columns {
+		if Length(c) != df.NRow {
+			return nil, fmt.Errorf("data.frame: column %s has %d rows instead of %d", df.Names[i], Length(c), df.NRow)
+		}
+	}
+	return
+}
+
+// Column returns the column name, nil if there is none.
+func (this *DataFrame) Column(name string) Value {
+	for i, n := range this.Names {
+		if n == name {
+			return this.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Factor is a view of a factor.
+type Factor struct {
+	// Codes of the values: 1-based indices in Levels or NaInteger
+	Codes   []int32
+	Levels  []string
+	Ordered bool
+}
+
+// AsFactor returns the view of a factor: an integer vector with the factor class and levels.
+func AsFactor(v Value) (*Factor, error) {
+	i, ok := v.(*Integer)
+	if !ok || !Inherits(v, "factor") {
+		return nil, errors.New("not a factor")
+	}
+	f := &Factor{ Codes: i.Data, Levels: Levels(v), Ordered: Inherits(v, "ordered") }
+	for _, c := range f.Codes {
+		if c != NaInteger && (c < 1 || int(c) > len(f.Levels)) {
+			return nil, fmt.Errorf("factor: code %d out of the %d levels", c, len(f.Levels))
+		}
+	}
+	return f, nil
+}
+
+// Values returns the levels of the elements of the factor as a character vector.
+func (this *Factor) Values() *Character {
+	c := &Character{ Data: make([]string, len(this.Codes)) }
+	for i, code := range this.Codes {
+		if code == NaInteger {
+			if c.NA == nil {
+				c.NA = make([]bool, len(this.Codes))
+			}
+			c.NA[i] = true
+			continue
+		}
+		c.Data[i] = this.Levels[code-1]
+	}
+	return c
+}
+
+// Matrix is a view of a matrix, the data is stored by column.
+type Matrix struct {
+	NRow, NCol int
+	// Vector of the elements, without the matrix attributes
+	Data     Value
+	RowNames []string
+	ColNames []string
+}
+
+// AsMatrix returns the view of an atomic vector or a list with a dim attribute of length 2.
+func AsMatrix(v Value) (*Matrix, error) {
+	dim := Dim(v)
+	if len(dim) != 2 {
+		return nil, errors.New("not a matrix")
+	}
+	if dim[0] * dim[1] != Length(v) {
+		return nil, fmt.Errorf("matrix: dims [%d, %d] do not match the length %d", dim[0], dim[1], Length(v))
+	}
+	m := &Matrix{ NRow: dim[0], NCol: dim[1], Data: copyVector(v) }
+	if names := DimNames(v); len(names) == 2 {
+		m.RowNames, m.ColNames = names[0], names[1]
+	}
+	return m, nil
+}
+
+// Index returns the index in Data of the element of row i and column j, both 0-based.
+func (this *Matrix) Index(i, j int) int {
+	return i + j * this.NRow
+}
+
+// NewMatrix returns a matrix of the elements of data given by column.
+func NewMatrix(data Value, nrow, ncol int) (Value, error) {
+	if nrow * ncol != Length(data) || data.Attributes() == nil {
+		return nil, fmt.Errorf("matrix: %d elements for dims [%d, %d]", Length(data), nrow, ncol)
+	}
+	m := copyVector(data)
+	m.Attributes().Set("dim", &Integer{ Data: []int32{ int32(nrow), int32(ncol) } })
+	return m, nil
+}
+
+// Dates returns the dates of a Date vector (days since 1970-01-01) in UTC, the NA dates are zero times.
+func Dates(v Value) (dates []time.Time, err error) {
+	if !Inherits(v, "Date") {
+		return nil, errors.New("not a Date")
+	}
+	var days []float64
+	switch x := v.(type) {
+	case *Real :
+		days = x.Data
+	case *Integer :
+		for _, d := range x.Data {
+			if d == NaInteger {
+				days = append(days, NaReal)
+			} else {
+				days = append(days, float64(d))
+			}
+		}
+	default:
+		return nil, errors.New("Date: numeric vector expected")
+	}
+	epoch := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, d := range days {
+		if math.IsNaN(d) || math.IsInf(d, 0) {
+			dates = append(dates, time.Time{})
+			continue
+		}
+		dates = append(dates, epoch.AddDate(0, 0, int(math.Floor(d))))
+	}
+	return
+}
+
+// NewDate returns the Date vector of the dates, the zero times are NA.
+func NewDate(dates []time.Time) *Real {
+	r := &Real{ Data: make([]float64, len(dates)) }
+	for i, d := range dates {
+		if d.IsZero() {
+			r.Data[i] = NaReal
+			continue
+		}
+		y, m, day := d.Date()
+		r.Data[i] = float64(time.Date(y, m, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	}
+	r.Set("class", NewCharacter("Date"))
+	return r
+}
@@ -0,0 +1,149 @@
+package r
+
+import "testing"
+import "math"
+import "strings"
+import "time"
+
+func TestNA(e *testing.T) {
+	var types = []SexpType{ LGLSXP, INTSXP, REALSXP, CPLXSXP, STRSXP }
+	for i, typ := range types {
+		v := NA(typ)
+		if v.Type() != typ || Length(v) != 1 || !IsNA(v, 0) {
+			e.Error("Test NA[", i, "] Failed:", typ)
+		}
+	}
+	if NA(LISTSXP) != nil {
+		e.Error("Test NA Failed: NA of a pairlist")
+	}
+
+	// The NA tokens are the NA of their type
+	var tokens = []string{ "NA", "NA_integer_", "NA_real_", "NA_complex_", "NA_character_" }
+	for i, src := range tokens {
+		f, err := NewParser(strings.NewReader(src)).Parse()
+		if err != nil {
+			e.Error("Test NA token[", i, "] Failed with error", err)
+			continue
+		}
+		v, err := ExprValue(f.Exprs[0])
+		if err != nil || v.Type() != types[i] || !IsNA(v, 0) {
+			e.Error("Test NA token[", i, "] Failed:", v, err)
+		}
+	}
+
+	// NaN is NA for is.na() but not NA_real_
+	nan := &Real{ Data: []float64{ math.NaN(), 1 } }
+	if !IsNA(nan, 0) || IsNA(nan, 1) || IsNaReal(nan.Data[0]) {
+		e.Error("Test NA Failed: NaN")
+	}
+	if IsNA(NewCharacter("NA"), 0) {
+		e.Error("Test NA Failed: \"NA\" string")
+	}
+}
+
+func TestDataFrame(e *testing.T) {
+	x := &Real{ Data: []float64{ 1.5, NaReal, 3 } }
+	f := NewFactor([]string{ "b", "a", "b" }, []string{ "a", "b" })
+	v, _ := NewDataFrame([]string{ "x", "f" }, []Value{ x, f })
+
+	df, err := AsDataFrame(v)
+	if err != nil {
+		e.Fatal(err)
+	}
+	if df.NRow != 3 || df.RowNames != nil || df.Column("f") != f || df.Column("y") != nil {
+		e.Error("Test DataFrame Failed:", df)
+	}
+	if !IsNA(df.Column("x"), 1) || Inherits(v, "list") || !Inherits(v, "data.frame") {
+		e.Error("Test DataFrame Failed: NA or class")
+	}
+
+	v.Set("row.names", NewCharacter("r1", "r2", "r3"))
+	if df, err = AsDataFrame(v); err != nil || strings.Join(df.RowNames, " ") != "r1 r2 r3" {
+		e.Error("Test DataFrame Failed: row names", df, err)
+	}
+	v.Set("row.names", &Integer{ Data: []int32{ NaInteger, -4 } })
+	if _, err = AsDataFrame(v); err == nil {
+		e.Error("Test DataFrame Failed: no error on the number of rows")
+	}
+	if _, err = AsDataFrame(&List{}); err == nil {
+		e.Error("Test DataFrame Failed: no error on a list")
+	}
+}
+
+func TestFactor(e *testing.T) {
+	f, err := AsFactor(NewFactor([]string{ "lo", "hi", "mid", "lo" }, []string{ "lo", "hi" }))
+	if err != nil {
+		e.Fatal(err)
+	}
+	if f.Ordered || len(f.Codes) != 4 || f.Codes[2] != NaInteger || strings.Join(f.Levels, " ") != "lo hi" {
+		e.Error("Test Factor Failed:", f)
+	}
+	values := f.Values()
+	if values.Data[0] != "lo" || values.Data[1] != "hi" || !values.IsNA(2) || values.IsNA(3) {
+		e.Error("Test Factor Failed: values", values)
+	}
+
+	o := NewFactor([]string{ "a" }, []string{ "a" })
+	o.Set("class", NewCharacter("ordered", "factor"))
+	if f, err = AsFactor(o); err != nil || !f.Ordered {
+		e.Error("Test Factor Failed: ordered", f, err)
+	}
+	o.Data[0] = 2
+	if _, err = AsFactor(o); err == nil {
+		e.Error("Test Factor Failed: no error on a code out of the levels")
+	}
+	if _, err = AsFactor(&Integer{ Data: []int32{ 1 } }); err == nil {
+		e.Error("Test Factor Failed: no error on an integer vector")
+	}
+}
+
+func TestMatrix(e *testing.T) {
+	v, err := NewMatrix(&Integer{ Data: []int32{ 1, 2, 3, 4, 5, 6 } }, 2, 3)
+	if err != nil {
+		e.Fatal(err)
+	}
+	dimnames := &List{ Data: []Value{ NewCharacter("a", "b"), NullValue } }
+	v.Attributes().Set("dimnames", dimnames)
+
+	m, err := AsMatrix(v)
+	if err != nil {
+		e.Fatal(err)
+	}
+	if m.NRow != 2 || m.NCol != 3 || strings.Join(m.RowNames, " ") != "a b" || m.ColNames != nil {
+		e.Error("Test Matrix Failed:", m)
+	}
+	// m[2, 3] is the last element, m[1, 2] the third
+	data := m.Data.(*Integer).Data
+	if data[m.Index(1, 2)] != 6 || data[m.Index(0, 1)] != 3 || m.Data.Attributes().Get("dim") != nil {
+		e.Error("Test Matrix Failed: index")
+	}
+	if strings.Join(Class(v), " ") != "matrix array" {
+		e.Error("Test Matrix Failed: class", Class(v))
+	}
+
+	if _, err = NewMatrix(&Integer{ Data: []int32{ 1, 2, 3 } }, 2, 2); err == nil {
+		e.Error("Test Matrix Failed: no error on the dims")
+	}
+	if _, err = AsMatrix(&Integer{ Data: []int32{ 1, 2, 3 } }); err == nil {
+		e.Error("Test Matrix Failed: no error on a vector")
+	}
+}
+
+func TestDates(e *testing.T) {
+	dates := []time.Time{ time.Date(2024, 2, 29, 13, 0, 0, 0, time.UTC), {}, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC) }
+	v := NewDate(dates)
+	if v.Data[0] != 19782 || !IsNaReal(v.Data[1]) || v.Data[2] != -1 || !Inherits(v, "Date") {
+		e.Error("Test Dates Failed: NewDate", v.Data)
+	}
+
+	back, err := Dates(v)
+	if err != nil {
+		e.Fatal(err)
+	}
+	if !back[0].Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) || !back[1].IsZero() || back[2].Day() != 31 {
+		e.Error("Test Dates Failed:", back)
+	}
+	if _, err = Dates(&Real{ Data: []float64{ 1 } }); err == nil {
+		e.Error("Test Dates Failed: no error on a double vector")
+	}
+}
@@ -0,0 +1,683 @@
+package r
+
+import "bytes"
+import "crypto/sha256"
+import "encoding/binary"
+import "errors"
+import "fmt"
+import "hash"
+import "hash/crc32"
+import "hash/crc64"
+
+// The xz container format is described in https://tukaani.org/xz/xz-file-format.txt. R writes one stream of
+// LZMA2 blocks, the only filter decoded here.
+
+// xzError is the error of a malformed or unsupported xz stream.
+func xzError(format string, args ...interface{}) error {
+	return fmt.Errorf("xz: " + format, args...)
+}
+
+var errXZTruncated = errors.New("xz: unexpected end of data")
+
+// Check types of the xz streams.
+const (
+	xzCheckNone   = 0x00
+	xzCheckCRC32  = 0x01
+	xzCheckCRC64  = 0x04
+	xzCheckSHA256 = 0x0a
+)
+
+// LZMA2 filter ID.
+const xzFilterLZMA2 = 0x21
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// xzDecoder reads the streams of an xz file held in memory.
+type xzDecoder struct {
+	in  []byte
+	pos int
+	out []byte
+}
+
+// decodeXZ returns the decompressed content of the xz file data: its concatenated streams, separated by stream
+// padding.
+func decodeXZ(data []byte) ([]byte, error) {
+	d := &xzDecoder{ in: data }
+	for {
+		if err := d.stream(); err != nil {
+			return nil, err
+		}
+		// stream padding: null bytes by groups of 4
+		start := d.pos
+		for d.pos < len(d.in) && d.in[d.pos] == 0 {
+			d.pos++
+		}
+		if (d.pos - start) % 4 != 0 {
+			return nil, xzError("invalid stream padding")
+		}
+		if d.pos == len(d.in) {
+			return d.out, nil
+		}
+	}
+}
+
+// read returns the next n bytes of the input.
+func (this *xzDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(this.in) - this.pos {
+		return nil, errXZTruncated
+	}
+	b := this.in[this.pos:this.pos+n]
+	this.pos += n
+	return b, nil
+}
+
+// varint reads a multibyte integer: 7 bits by byte, the least significant first, 9 bytes at most.
+func (this *xzDecoder) varint() (uint64, error) {
+	var n uint64
+	for i := 0; i < 9; i++ {
+		b, err := this.read(1)
+		if err != nil {
+			return 0, err
+		}
+		n |= uint64(b[0] & 0x7f) << (7 * uint(i))
+		if b[0] & 0x80 == 0 {
+			if b[0] == 0 && i > 0 {
+				return 0, xzError("invalid multibyte integer")
+			}
+			return n, nil
+		}
+	}
+	return 0, xzError("invalid multibyte integer")
+}
+
+// newCheck returns the hash of the check type, nil for none.
+func newCheck(check byte) (hash.Hash, error) {
+	switch check {
+	case xzCheckNone :
+		return nil, nil
+	case xzCheckCRC32 :
+		return crc32.NewIEEE(), nil
+	case xzCheckCRC64 :
+		return crc64.New(crc64Table), nil
+	case xzCheckSHA256 :
+		return sha256.New(), nil
+	}
+	return nil, xzError("unsupported check type %d", check)
+}
+
+// stream reads a stream: its header, its blocks, its index and its footer.
+func (this *xzDecoder) stream() error {
+	header, err := this.read(12)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(header[:6], xzMagic) {
+		return xzError("invalid stream header")
+	}
+	flags := header[6:8]
+	if crc32.ChecksumIEEE(flags) != binary.LittleEndian.Uint32(header[8:]) {
+		return xzError("stream header CRC mismatch")
+	}
+	if flags[0] != 0 || flags[1] & 0xf0 != 0 {
+		return xzError("unsupported stream flags")
+	}
+	check := flags[1]
+
+	// blocks, up to the index indicator
+	type record struct{ unpadded, uncompressed uint64 }
+	var records []record
+	for {
+		if this.pos >= len(this.in) {
+			return errXZTruncated
+		}
+		if this.in[this.pos] == 0 {
+			break
+		}
+		unpadded, uncompressed, err := this.block(check)
+		if err != nil {
+			return err
+		}
+		records = append(records, record{ unpadded, uncompressed })
+	}
+
+	// index
+	start := this.pos
+	this.pos++
+	n, err := this.varint()
+	if err != nil {
+		return err
+	}
+	if n != uint64(len(records)) {
+		return xzError("index does not match the blocks")
+	}
+	for _, r := range records {
+		unpadded, err := this.varint()
+		if err != nil {
+			return err
+		}
+		uncompressed, err := this.varint()
+		if err != nil {
+			return err
+		}
+		if unpadded != r.unpadded || uncompressed != r.uncompressed {
+			return xzError("index does not match the blocks")
+		}
+	}
+	if err = this.padding(this.pos - start); err != nil {
+		return err
+	}
+	index := this.in[start:this.pos]
+	crc, err := this.read(4)
+	if err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(index) != binary.LittleEndian.Uint32(crc) {
+		return xzError("index CRC mismatch")
+	}
+
+	// footer
+	footer, err := this.read(12)
+	if err != nil {
+		return err
+	}
+	if crc32.ChecksumIEEE(footer[4:10]) != binary.LittleEndian.Uint32(footer) {
+		return xzError("stream footer CRC mismatch")
+	}
+	if (uint64(binary.LittleEndian.Uint32(footer[4:])) + 1) * 4 != uint64(len(index) + 4) {
+		return xzError("invalid backward size")
+	}
+	if !bytes.Equal(footer[8:10], flags) || footer[10] != 'Y' || footer[11] != 'Z' {
+		return xzError("invalid stream footer")
+	}
+	return nil
+}
+
+// padding skips the null bytes padding a size to a multiple of 4.
+func (this *xzDecoder) padding(size int) error {
+	b, err := this.read((4 - size % 4) % 4)
+	if err != nil {
+		return err
+	}
+	for _, c := range b {
+		if c != 0 {
+			return xzError("invalid padding")
+		}
+	}
+	return nil
+}
+
+// block reads a block of LZMA2 data and its check, it returns its unpadded and uncompressed sizes.
+func (this *xzDecoder) block(check byte) (unpadded, uncompressed uint64, err error) {
+	start := this.pos
+	header, err := this.read((int(this.in[this.pos]) + 1) * 4)
+	if err != nil {
+		return
+	}
+	if crc32.ChecksumIEEE(header[:len(header)-4]) != binary.LittleEndian.Uint32(header[len(header)-4:]) {
+		return 0, 0, xzError("block header CRC mismatch")
+	}
+	h := &xzDecoder{ in: header[:len(header)-4], pos: 2 }
+	flags := header[1]
+	if flags & 0x3c != 0 {
+		return 0, 0, xzError("unsupported block flags")
+	}
+	compressedSize, uncompressedSize := int64(-1), int64(-1)
+	if flags & 0x40 != 0 {
+		n, err := h.varint()
+		if err != nil || n == 0 || n > 1 << 62 {
+			return 0, 0, xzError("invalid block header")
+		}
+		compressedSize = int64(n)
+	}
+	if flags & 0x80 != 0 {
+		n, err := h.varint()
+		if err != nil || n > 1 << 62 {
+			return 0, 0, xzError("invalid block header")
+		}
+		uncompressedSize = int64(n)
+	}
+	if flags & 0x03 != 0 {
+		return 0, 0, xzError("unsupported filter chain")
+	}
+	id, err := h.varint()
+	if err != nil {
+		return 0, 0, xzError("invalid block header")
+	}
+	size, err := h.varint()
+	if err != nil || id != xzFilterLZMA2 || size != 1 {
+		return 0, 0, xzError("unsupported filter %#x", id)
+	}
+	props, err := h.read(1)
+	if err != nil || props[0] > 40 {
+		return 0, 0, xzError("invalid LZMA2 properties")
+	}
+	for _, c := range h.in[h.pos:] {
+		if c != 0 {
+			return 0, 0, xzError("invalid block header")
+		}
+	}
+
+	hash, err := newCheck(check)
+	if err != nil {
+		return
+	}
+	dataStart, outStart := this.pos, len(this.out)
+	if err = this.lzma2(); err != nil {
+		return
+	}
+	compressed := int64(this.pos - dataStart)
+	uncompressed = uint64(len(this.out) - outStart)
+	if (compressedSize >= 0 && compressed != compressedSize) || (uncompressedSize >= 0 && int64(uncompressed) != uncompressedSize) {
+		return 0, 0, xzError("block sizes do not match the block header")
+	}
+	if err = this.padding(this.pos - start); err != nil {
+		return
+	}
+	unpadded = uint64(len(header)) + uint64(compressed)
+	if hash != nil {
+		sum, err := this.read(hash.Size())
+		if err != nil {
+			return 0, 0, err
+		}
+		hash.Write(this.out[outStart:])
+		// the CRCs are stored in little endian, hash.Sum returns them in big endian
+		expected := hash.Sum(nil)
+		if check != xzCheckSHA256 {
+			for i, j := 0, len(expected) - 1; i < j; i, j = i+1, j-1 {
+				expected[i], expected[j] = expected[j], expected[i]
+			}
+		}
+		if !bytes.Equal(sum, expected) {
+			return 0, 0, xzError("block check mismatch")
+		}
+		unpadded += uint64(len(sum))
+	}
+	return
+}
+
+// lzma2 decodes the chunks of LZMA2 data up to the end marker.
+func (this *xzDecoder) lzma2() error {
+	var z *lzmaDecoder
+	needDictReset, needProps := true, true
+	for {
+		b, err := this.read(1)
+		if err != nil {
+			return err
+		}
+		control := b[0]
+		switch {
+		case control == 0x00 :
+			return nil
+		case control >= 0xe0 || control == 0x01 :
+			needDictReset, needProps = false, true
+			if z == nil {
+				z = &lzmaDecoder{}
+			}
+			z.dictStart = len(this.out)
+		case needDictReset || (control > 0x02 && control < 0x80) :
+			return xzError("invalid LZMA2 chunk")
+		}
+
+		if control < 0x80 {
+			// uncompressed chunk
+			b, err = this.read(2)
+			if err != nil {
+				return err
+			}
+			data, err := this.read(int(binary.BigEndian.Uint16(b)) + 1)
+			if err != nil {
+				return err
+			}
+			this.out = append(this.out, data...)
+			continue
+		}
+
+		b, err = this.read(4)
+		if err != nil {
+			return err
+		}
+		size := int(control & 0x1f) << 16 + int(binary.BigEndian.Uint16(b)) + 1
+		data := int(binary.BigEndian.Uint16(b[2:])) + 1
+		switch {
+		case control >= 0xc0 :
+			b, err = this.read(1)
+			if err != nil {
+				return err
+			}
+			if err = z.setProps(b[0]); err != nil {
+				return err
+			}
+			needProps = false
+			z.reset()
+		case needProps :
+			return xzError("invalid LZMA2 chunk")
+		case control >= 0xa0 :
+			z.reset()
+		}
+		in, err := this.read(data)
+		if err != nil {
+			return err
+		}
+		if this.out, err = z.decode(this.out, in, size); err != nil {
+			return err
+		}
+	}
+}
+
+// LZMA model constants.
+const (
+	lzmaStates       = 12
+	lzmaPosStatesMax = 1 << 4
+	lzmaDistStates   = 4
+	lzmaDistSlots    = 64
+	lzmaDistModelEnd = 14
+	lzmaFullDist     = 1 << (lzmaDistModelEnd / 2)
+	lzmaAlignBits    = 4
+	lzmaMatchLenMin  = 2
+	lzmaProbInit     = 1 << 10
+)
+
+// rangeDecoder decodes the bits of an LZMA chunk.
+type rangeDecoder struct {
+	in    []byte
+	pos   int
+	rng   uint32
+	code  uint32
+	// Read past the end of the chunk
+	overrun bool
+}
+
+func (this *rangeDecoder) init(in []byte) error {
+	if len(in) < 5 || in[0] != 0 {
+		return xzError("invalid LZMA data")
+	}
+	*this = rangeDecoder{ in: in, pos: 5, rng: 0xffffffff, code: binary.BigEndian.Uint32(in[1:]) }
+	return nil
+}
+
+func (this *rangeDecoder) normalize() {
+	if this.rng < 1 << 24 {
+		this.rng <<= 8
+		this.code <<= 8
+		if this.pos < len(this.in) {
+			this.code |= uint32(this.in[this.pos])
+			this.pos++
+		} else {
+			this.overrun = true
+		}
+	}
+}
+
+// bit decodes a bit with the probability p, and adapts p.
+func (this *rangeDecoder) bit(p *uint16) uint32 {
+	this.normalize()
+	bound := (this.rng >> 11) * uint32(*p)
+	if this.code < bound {
+		this.rng = bound
+		*p += (1 << 11 - *p) >> 5
+		return 0
+	}
+	this.rng -= bound
+	this.code -= bound
+	*p -= *p >> 5
+	return 1
+}
+
+// bittree decodes a symbol of n bits, the most significant first.
+func (this *rangeDecoder) bittree(probs []uint16, n uint) uint32 {
+	symbol := uint32(1)
+	for i := uint(0); i < n; i++ {
+		symbol = symbol << 1 | this.bit(&probs[symbol])
+	}
+	return symbol - 1 << n
+}
+
+// reverse decodes a symbol of n bits, the least significant first, with the probabilities from offset.
+func (this *rangeDecoder) reverse(probs []uint16, offset int, n uint) uint32 {
+	symbol, r := uint32(1), uint32(0)
+	for i := uint(0); i < n; i++ {
+		b := this.bit(&probs[offset+int(symbol)])
+		symbol = symbol << 1 | b
+		r |= b << i
+	}
+	return r
+}
+
+// direct decodes n bits of probability 1/2.
+func (this *rangeDecoder) direct(n uint) uint32 {
+	var r uint32
+	for i := uint(0); i < n; i++ {
+		this.normalize()
+		this.rng >>= 1
+		b := uint32(0)
+		if this.code >= this.rng {
+			this.code -= this.rng
+			b = 1
+		}
+		r = r << 1 | b
+	}
+	return r
+}
+
+// lengthDecoder decodes the lengths of the matches.
+type lengthDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [lzmaPosStatesMax][1 << 3]uint16
+	mid     [lzmaPosStatesMax][1 << 3]uint16
+	high    [1 << 8]uint16
+}
+
+func (this *lengthDecoder) decode(rc *rangeDecoder, posState uint32) int {
+	switch {
+	case rc.bit(&this.choice) == 0 :
+		return int(rc.bittree(this.low[posState][:], 3)) + lzmaMatchLenMin
+	case rc.bit(&this.choice2) == 0 :
+		return int(rc.bittree(this.mid[posState][:], 3)) + lzmaMatchLenMin + 8
+	}
+	return int(rc.bittree(this.high[:], 8)) + lzmaMatchLenMin + 16
+}
+
+// lzmaDecoder is the state of the LZMA decoder of an LZMA2 stream, kept between its chunks.
+type lzmaDecoder struct {
+	lc, lp, pb uint
+	// Start of the dictionary in the output
+	dictStart int
+	state      int
+	// Last four distances, minus one
+	rep        [4]uint32
+	rc         rangeDecoder
+
+	literal    []uint16
+	isMatch    [lzmaStates][lzmaPosStatesMax]uint16
+	isRep      [lzmaStates]uint16
+	isRep0     [lzmaStates]uint16
+	isRep1     [lzmaStates]uint16
+	isRep2     [lzmaStates]uint16
+	isRep0Long [lzmaStates][lzmaPosStatesMax]uint16
+	distSlot   [lzmaDistStates][lzmaDistSlots]uint16
+	distSpecial [lzmaFullDist - lzmaDistModelEnd]uint16
+	distAlign  [1 << lzmaAlignBits]uint16
+	matchLen   lengthDecoder
+	repLen     lengthDecoder
+}
+
+// setProps sets the lc, lp and pb properties of the byte props.
+func (this *lzmaDecoder) setProps(props byte) error {
+	if props >= 9 * 5 * 5 {
+		return xzError("invalid LZMA properties")
+	}
+	this.lc, this.lp, this.pb = uint(props % 9), uint(props / 9 % 5), uint(props / 45)
+	if this.lc + this.lp > 4 {
+		return xzError("invalid LZMA properties")
+	}
+	return nil
+}
+
+// reset resets the state and the probabilities.
+func (this *lzmaDecoder) reset() {
+	this.state, this.rep = 0, [4]uint32{}
+	this.literal = make([]uint16, 0x300 << (this.lc + this.lp))
+	probs := [][]uint16{ this.literal, this.isRep[:], this.isRep0[:], this.isRep1[:], this.isRep2[:], this.distSpecial[:], this.distAlign[:] }
+	for i := 0; i < lzmaStates; i++ {
+		probs = append(probs, this.isMatch[i][:], this.isRep0Long[i][:])
+	}
+	for i := 0; i < lzmaDistStates; i++ {
+		probs = append(probs, this.distSlot[i][:])
+	}
+	for _, l := range []*lengthDecoder{ &this.matchLen, &this.repLen } {
+		l.choice, l.choice2 = lzmaProbInit, lzmaProbInit
+		probs = append(probs, l.high[:])
+		for i := 0; i < lzmaPosStatesMax; i++ {
+			probs = append(probs, l.low[i][:], l.mid[i][:])
+		}
+	}
+	for _, p := range probs {
+		for i := range p {
+			p[i] = lzmaProbInit
+		}
+	}
+}
+
+// nextState returns the state after a match, a long rep or a short rep: afterLiteral if the state is one of a
+// literal, afterMatch otherwise.
+func nextState(state, afterLiteral, afterMatch int) int {
+	if state < 7 {
+		return afterLiteral
+	}
+	return afterMatch
+}
+
+// decode appends to out the size bytes of the LZMA chunk in.
+func (this *lzmaDecoder) decode(out, in []byte, size int) ([]byte, error) {
+	rc := &this.rc
+	if err := rc.init(in); err != nil {
+		return nil, err
+	}
+	end := len(out) + size
+	for len(out) < end && !rc.overrun {
+		pos := uint32(len(out) - this.dictStart)
+		posState := pos & (1 << this.pb - 1)
+		if rc.bit(&this.isMatch[this.state][posState]) == 0 {
+			b, ok := this.literalByte(out, pos)
+			if !ok {
+				return nil, xzError("invalid LZMA distance")
+			}
+			out = append(out, b)
+			switch {
+			case this.state < 4 :
+				this.state = 0
+			case this.state < 10 :
+				this.state -= 3
+			default:
+				this.state -= 6
+			}
+			continue
+		}
+
+		n := 1
+		switch {
+		case rc.bit(&this.isRep[this.state]) == 0 :
+			// match: a new distance
+			this.state = nextState(this.state, 7, 10)
+			n = this.matchLen.decode(rc, posState)
+			this.rep[3], this.rep[2], this.rep[1] = this.rep[2], this.rep[1], this.rep[0]
+			this.rep[0] = this.distance(n)
+		case rc.bit(&this.isRep0[this.state]) == 0 :
+			if rc.bit(&this.isRep0Long[this.state][posState]) == 0 {
+				// short rep: one byte at the last distance
+				this.state = nextState(this.state, 9, 11)
+				break
+			}
+			this.state = nextState(this.state, 8, 11)
+			n = this.repLen.decode(rc, posState)
+		default:
+			// rep: one of the three previous distances
+			var d uint32
+			if rc.bit(&this.isRep1[this.state]) == 0 {
+				d = this.rep[1]
+			} else {
+				if rc.bit(&this.isRep2[this.state]) == 0 {
+					d = this.rep[2]
+				} else {
+					d, this.rep[3] = this.rep[3], this.rep[2]
+				}
+				this.rep[2] = this.rep[1]
+			}
+			this.rep[1], this.rep[0] = this.rep[0], d
+			this.state = nextState(this.state, 8, 11)
+			n = this.repLen.decode(rc, posState)
+		}
+		if uint64(this.rep[0]) >= uint64(len(out) - this.dictStart) || n > end - len(out) {
+			return nil, xzError("invalid LZMA distance")
+		}
+		from := len(out) - int(this.rep[0]) - 1
+		for i := 0; i < n; i++ {
+			out = append(out, out[from+i])
+		}
+	}
+	// the encoder flushes the bytes of the last normalization
+	rc.normalize()
+	if len(out) != end || rc.overrun || rc.pos != len(rc.in) || rc.code != 0 {
+		return nil, xzError("invalid LZMA chunk")
+	}
+	return out, nil
+}
+
+// literalByte decodes a literal at the position pos of the dictionary. It is not ok if the last distance is
+// outside of the dictionary.
+func (this *lzmaDecoder) literalByte(out []byte, pos uint32) (byte, bool) {
+	rc := &this.rc
+	prev := uint32(0)
+	if len(out) > this.dictStart {
+		prev = uint32(out[len(out)-1])
+	}
+	state := (pos & (1 << this.lp - 1)) << this.lc + prev >> (8 - this.lc)
+	probs := this.literal[0x300*state:0x300*(state+1)]
+	symbol := uint32(1)
+	if this.state < 7 {
+		for symbol < 0x100 {
+			symbol = symbol << 1 | rc.bit(&probs[symbol])
+		}
+		return byte(symbol), true
+	}
+
+	// after a match, the bits of the byte at the last distance select the probabilities until they differ
+	if uint64(this.rep[0]) >= uint64(len(out) - this.dictStart) {
+		return 0, false
+	}
+	match := uint32(out[len(out)-int(this.rep[0])-1])
+	offset := uint32(0x100)
+	for symbol < 0x100 {
+		match <<= 1
+		bit := match & offset
+		if rc.bit(&probs[offset+bit+symbol]) == 1 {
+			symbol = symbol << 1 | 1
+			offset = bit
+		} else {
+			symbol <<= 1
+			offset ^= bit
+		}
+	}
+	return byte(symbol), true
+}
+
+// distance decodes the distance of a match of length n.
+func (this *lzmaDecoder) distance(n int) uint32 {
+	rc := &this.rc
+	state := n - lzmaMatchLenMin
+	if state >= lzmaDistStates {
+		state = lzmaDistStates - 1
+	}
+	slot := rc.bittree(this.distSlot[state][:], 6)
+	if slot < 4 {
+		return slot
+	}
+	limit := uint(slot >> 1 - 1)
+	d := (2 | slot & 1) << limit
+	if slot < lzmaDistModelEnd {
+		return d + rc.reverse(this.distSpecial[:], int(d) - int(slot) - 1, limit)
+	}
+	d += rc.direct(limit - lzmaAlignBits) << lzmaAlignBits
+	return d + rc.reverse(this.distAlign[:], 0, lzmaAlignBits)
+}
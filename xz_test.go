@@ -0,0 +1,49 @@
+package r
+
+import "testing"
+import "bytes"
+import "io/ioutil"
+import "path/filepath"
+
+// The files of testdata/xz are compressed by xz 5.6.4 with various checks, blocks and streams.
+func TestDecodeXZ(e *testing.T) {
+	var tests = []struct {
+		name     string
+		original string
+		// Number of streams, each of the original
+		streams  int
+	}{
+		{ "text.xz", "text", 1 },
+		{ "text-none.xz", "text", 1 },
+		{ "text-streams.xz", "text", 2 },
+		{ "mixed-crc64.xz", "mixed", 1 },
+		{ "mixed-blocks.xz", "mixed", 1 },
+	}
+	for i, test := range tests {
+		data, err := ioutil.ReadFile(filepath.Join("testdata", "xz", test.name))
+		if err != nil {
+			e.Fatal(err)
+		}
+		expected, err := ioutil.ReadFile(filepath.Join("testdata", "xz", test.original))
+		if err != nil {
+			e.Fatal(err)
+		}
+		expected = bytes.Repeat(expected, test.streams)
+		b, err := decodeXZ(data)
+		if err != nil || !bytes.Equal(b, expected) {
+			e.Error("Test DecodeXZ[", i, "] Failed:", len(b), err)
+		}
+
+		// any truncation or corruption is an error
+		if _, err = decodeXZ(data[:len(data)-1]); err == nil {
+			e.Error("Test DecodeXZ[", i, "] Failed: no error on truncated data")
+		}
+		for j := 0; j < len(data); j += 7 {
+			corrupt := append([]byte(nil), data...)
+			corrupt[j] ^= 0x55
+			if b, err = decodeXZ(corrupt); err == nil && !bytes.Equal(b, expected) {
+				e.Error("Test DecodeXZ[", i, "] Failed: no error on corrupted byte", j)
+			}
+		}
+	}
+}